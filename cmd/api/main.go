@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	awsLambda "github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/api"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/executor"
+	"github.com/kiran94/dca-manager/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultAddress = ":8081"
+
+var (
+	server  *api.Server
+	address string
+)
+
+func init() {
+	awsConfig, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		logrus.WithError(err).Panic("Could not retrieve default aws config")
+	}
+
+	address = os.Getenv(configuration.EnvAPIAddress)
+	if address == "" {
+		address = defaultAddress
+	}
+
+	executorServices := executor.NewDCAServices(awsConfig, pkg.SQS{Client: sqs.NewFromConfig(awsConfig)}, metrics.NewRegistry())
+	executorConfig := executor.NewAppConfig()
+
+	services := api.NewServices(pkg.S3{Client: s3.NewFromConfig(awsConfig)}, api.RunOrders(executorServices, executorConfig))
+	server = api.NewServer(context.Background(), services, api.NewConfig())
+}
+
+func main() {
+	logrus.SetOutput(os.Stdout)
+	logrus.SetReportCaller(false)
+
+	if os.Getenv("_LAMBDA_SERVER_PORT") != "" {
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+		awsLambda.Start(server.HandleFunctionURL)
+		return
+	}
+
+	logrus.SetFormatter(&logrus.TextFormatter{})
+	logrus.WithField("address", address).Info("Serving Admin API")
+	if err := http.ListenAndServe(address, server); err != nil {
+		logrus.WithError(err).Error("Admin API stopped")
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/backtest"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+const dateLayout = "2006-01-02"
+
+// pairFlags collects repeated name=value flags.
+type pairFlags []string
+
+func (p *pairFlags) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *pairFlags) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("%s must be in the form name=value", value)
+	}
+	*p = append(*p, value)
+	return nil
+}
+
+// variantFile is a variant in the variants file where the
+// config is a path relative to the variants file.
+type variantFile struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	Config   string `json:"config"`
+}
+
+// AppConfig contains all configuration to be injected into logic
+type AppConfig struct {
+	candles   pairFlags
+	schedules pairFlags
+	config    string
+	variants  string
+	start     string
+	end       string
+	feePct    string
+	format    string
+}
+
+func main() {
+	appConfig := &AppConfig{}
+	flag.Var(&appConfig.candles, "candles", "pair=path of a candle CSV, repeat for each pair")
+	flag.Var(&appConfig.schedules, "schedule", "name=expression of a schedule to run the config on, repeat for each variant")
+	flag.StringVar(&appConfig.config, "config", "", "path to the DCA config used with -schedule")
+	flag.StringVar(&appConfig.variants, "variants", "", "path to a JSON list of variants with a name, schedule and config path")
+	flag.StringVar(&appConfig.start, "start", "", "first day of the backtest, defaults to the first candle")
+	flag.StringVar(&appConfig.end, "end", "", "day after the backtest ends, defaults to after the last candle")
+	flag.StringVar(&appConfig.feePct, "fee", "0.26", "fee percentage charged on every order")
+	flag.StringVar(&appConfig.format, "format", backtest.FormatText, "output format: text or json")
+	flag.Parse()
+
+	logrus.SetOutput(os.Stderr)
+	logrus.SetFormatter(&logrus.TextFormatter{})
+
+	results, err := RunBacktest(backtest.Simulator{}, appConfig)
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not run backtest")
+	}
+
+	if err := backtest.WriteResults(os.Stdout, results, appConfig.format); err != nil {
+		logrus.WithError(err).Fatal("Could not write results")
+	}
+}
+
+// RunBacktest loads the candles and variants and runs each variant.
+func RunBacktest(backtester backtest.Backtester, config *AppConfig) ([]backtest.Result, error) {
+	candles, err := loadCandles(config.candles)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := loadVariants(config)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := loadOptions(config, candles)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]backtest.Result, 0, len(variants))
+	for _, variant := range variants {
+		logrus.WithFields(logrus.Fields{
+			"variant":  variant.Name,
+			"schedule": variant.Schedule,
+			"start":    options.Start,
+			"end":      options.End,
+		}).Info("Running Backtest")
+
+		result, err := backtester.Run(variant, candles, options)
+		if err != nil {
+			return nil, fmt.Errorf("variant %s: %w", variant.Name, err)
+		}
+		results = append(results, *result)
+	}
+
+	return results, nil
+}
+
+func loadCandles(flags pairFlags) (map[string][]orders.Candle, error) {
+	if len(flags) == 0 {
+		return nil, errors.New("at least one -candles pair=path is required")
+	}
+
+	candles := map[string][]orders.Candle{}
+	for _, flag := range flags {
+		parts := strings.SplitN(flag, "=", 2)
+
+		file, err := os.Open(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		pairCandles, err := backtest.LoadCandles(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", parts[1], err)
+		}
+
+		candles[parts[0]] = pairCandles
+	}
+
+	return candles, nil
+}
+
+func loadVariants(config *AppConfig) ([]backtest.Variant, error) {
+	files := []variantFile{}
+
+	if config.variants != "" {
+		content, err := ioutil.ReadFile(config.variants)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(content, &files); err != nil {
+			return nil, fmt.Errorf("invalid variants file %s: %w", config.variants, err)
+		}
+
+		for index := range files {
+			if !filepath.IsAbs(files[index].Config) {
+				files[index].Config = filepath.Join(filepath.Dir(config.variants), files[index].Config)
+			}
+		}
+	}
+
+	for _, schedule := range config.schedules {
+		if config.config == "" {
+			return nil, errors.New("-config is required with -schedule")
+		}
+
+		parts := strings.SplitN(schedule, "=", 2)
+		files = append(files, variantFile{Name: parts[0], Schedule: parts[1], Config: config.config})
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("at least one -schedule or -variants is required")
+	}
+
+	variants := make([]backtest.Variant, 0, len(files))
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file.Config)
+		if err != nil {
+			return nil, err
+		}
+
+		variant := backtest.Variant{Name: file.Name, Schedule: file.Schedule}
+		if err := json.Unmarshal(content, &variant.Config); err != nil {
+			return nil, fmt.Errorf("invalid config %s: %w", file.Config, err)
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, nil
+}
+
+func loadOptions(config *AppConfig, candles map[string][]orders.Candle) (backtest.Options, error) {
+	options := backtest.Options{}
+
+	var err error
+	if options.FeePct, err = decimal.NewFromString(config.feePct); err != nil {
+		return options, fmt.Errorf("invalid fee %s", config.feePct)
+	}
+
+	// By default the backtest covers the time every pair has candles
+	// until the last candle closes
+	for _, pairCandles := range candles {
+		first, last := pairCandles[0].Time, pairCandles[len(pairCandles)-1].Time
+		if len(pairCandles) > 1 {
+			last = last.Add(last.Sub(pairCandles[len(pairCandles)-2].Time))
+		}
+
+		if first.After(options.Start) {
+			options.Start = first
+		}
+		if options.End.IsZero() || last.Before(options.End) {
+			options.End = last
+		}
+	}
+
+	if config.start != "" {
+		if options.Start, err = time.Parse(dateLayout, config.start); err != nil {
+			return options, fmt.Errorf("invalid start %s", config.start)
+		}
+	}
+	if config.end != "" {
+		if options.End, err = time.Parse(dateLayout, config.end); err != nil {
+			return options, fmt.Errorf("invalid end %s", config.end)
+		}
+	}
+
+	return options, nil
+}
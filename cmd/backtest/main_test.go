@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/backtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// setup writes a week of daily candles and a config into a temporary directory
+func setup(t *testing.T) (string, *AppConfig) {
+	dir := t.TempDir()
+
+	rows := []string{"time,open,high,low,close"}
+	for day := 1; day <= 7; day++ {
+		rows = append(rows, fmt.Sprintf("2022-01-%02d,%d,%d,%d,%d", day, 100+day, 105+day, 95+day, 100+day))
+	}
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "candles.csv"), []byte(strings.Join(rows, "\n")), 0600))
+
+	config := `{"orders": [{"exchange": "kraken", "direction": "buy", "ordertype": "market", "volume": "1", "pair": "XBTGBP", "enabled": true}]}`
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "dca.json"), []byte(config), 0600))
+
+	appConfig := &AppConfig{feePct: "0", format: backtest.FormatText}
+	appConfig.candles = pairFlags{"XBTGBP=" + filepath.Join(dir, "candles.csv")}
+	return dir, appConfig
+}
+
+// Ensures each schedule runs the config over every candle
+func TestRunBacktestSchedules(t *testing.T) {
+	dir, appConfig := setup(t)
+	appConfig.config = filepath.Join(dir, "dca.json")
+	appConfig.schedules = pairFlags{"weekly=cron(0 6 ? * FRI *)", "daily=rate(1 day)"}
+
+	results, err := RunBacktest(backtest.Simulator{}, appConfig)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, "weekly", results[0].Name)
+	assert.Equal(t, 1, results[0].Runs)
+	assert.Equal(t, "daily", results[1].Name)
+	assert.Equal(t, 7, results[1].Runs)
+}
+
+// Ensures variants files load configs relative to themselves
+func TestRunBacktestVariantsFile(t *testing.T) {
+	dir, appConfig := setup(t)
+	variants := `[{"name": "weekly", "schedule": "cron(0 6 ? * FRI *)", "config": "dca.json"}]`
+	appConfig.variants = filepath.Join(dir, "variants.json")
+	assert.Nil(t, ioutil.WriteFile(appConfig.variants, []byte(variants), 0600))
+	appConfig.start = "2022-01-03"
+
+	results, err := RunBacktest(backtest.Simulator{}, appConfig)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "107", results[0].Invested.String())
+}
+
+// Ensures missing inputs are reported
+func TestRunBacktestInvalid(t *testing.T) {
+	_, appConfig := setup(t)
+	_, err := RunBacktest(backtest.Simulator{}, appConfig)
+	assert.Contains(t, err.Error(), "at least one -schedule or -variants is required")
+
+	appConfig.schedules = pairFlags{"daily=rate(1 day)"}
+	_, err = RunBacktest(backtest.Simulator{}, appConfig)
+	assert.Contains(t, err.Error(), "-config is required with -schedule")
+
+	_, err = RunBacktest(backtest.Simulator{}, &AppConfig{})
+	assert.Contains(t, err.Error(), "at least one -candles pair=path is required")
+}
+
+// Ensures the default range covers every candle
+func TestLoadOptions(t *testing.T) {
+	_, appConfig := setup(t)
+	candles, err := loadCandles(appConfig.candles)
+	assert.Nil(t, err)
+
+	options, err := loadOptions(appConfig, candles)
+
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), options.Start)
+	assert.Equal(t, time.Date(2022, 1, 8, 0, 0, 0, 0, time.UTC), options.End)
+}
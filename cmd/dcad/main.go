@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	awsEvents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/api"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/executor"
+	"github.com/kiran94/dca-manager/pkg/logging"
+	"github.com/kiran94/dca-manager/pkg/metrics"
+	"github.com/kiran94/dca-manager/pkg/processor"
+	"github.com/kiran94/dca-manager/pkg/queue"
+	"github.com/kiran94/dca-manager/pkg/runs"
+	"github.com/kiran94/dca-manager/pkg/schedule"
+	"github.com/kiran94/dca-manager/pkg/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAddress    = ":8080"
+	pollInterval      = 5 * time.Second
+	reloadInterval    = time.Minute
+	visibilityTimeout = 5 * time.Minute
+	receiveBatch      = 10
+	shutdownTimeout   = 30 * time.Second
+)
+
+// Statuses reported by the health endpoint.
+const (
+	statusOK       string = "ok"
+	statusStopping string = "stopping"
+)
+
+var (
+	dcad        *daemon
+	address     string
+	flushTraces tracing.Flush
+)
+
+func init() {
+	awsConfig, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		logrus.WithError(err).Panic("Could not retrieve default aws config")
+	}
+
+	flushTraces, err = tracing.Setup(context.Background(), "dcad")
+	if err != nil {
+		logrus.WithError(err).Warn("Could not setup tracing, spans will not be exported")
+		flushTraces = func(ctx context.Context) error { return nil }
+	}
+
+	address = os.Getenv(configuration.EnvDaemonAddress)
+	if address == "" {
+		address = defaultAddress
+	}
+
+	// Without a data directory pending orders and the last run are lost on restart
+	var queuePath, statePath string
+	if dataDir := os.Getenv(configuration.EnvDaemonDataDir); dataDir != "" {
+		queuePath = filepath.Join(dataDir, "queue.json")
+		statePath = filepath.Join(dataDir, "state.json")
+	}
+
+	localQueue, err := queue.NewLocal(queuePath)
+	if err != nil {
+		logrus.WithError(err).Panic("Could not load queue")
+	}
+
+	registry := metrics.NewRegistry()
+	executorServices := executor.NewDCAServices(awsConfig, localQueue, registry)
+	executorConfig := executor.NewAppConfig()
+	processorServices := processor.NewDCAServices(awsConfig, localQueue, registry)
+	processorConfig := processor.NewAppConfig()
+
+	s3Access := pkg.S3{Client: s3.NewFromConfig(awsConfig)}
+	s3Bucket := os.Getenv(configuration.EnvS3Bucket)
+	s3ConfigPath := os.Getenv(configuration.EnvS3ConfigPath)
+
+	dcad, err = newDaemon(localQueue, registry, statePath)
+	if err != nil {
+		logrus.WithError(err).Panic("Could not load daemon state")
+	}
+
+	dcad.loadConfig = func(ctx context.Context) (*configuration.DCAConfig, error) {
+		return configuration.DCAConfiguration{}.GetDCAConfiguration(ctx, s3Access, &s3Bucket, &s3ConfigPath)
+	}
+	dcad.execute = func(ctx context.Context, triggerTime time.Time) (*runs.RunSummary, error) {
+		return executor.RunOrders(ctx, executorServices, executorConfig, triggerTime)
+	}
+	dcad.process = func(ctx context.Context, event awsEvents.SQSEvent) error {
+		return processor.ProcessTransactions(ctx, processorServices, processorConfig, event)
+	}
+
+	// Runs triggered through the API go through the same local queue as scheduled runs
+	apiServices := api.NewServices(s3Access, api.RunOrders(executorServices, executorConfig))
+	dcad.api = api.NewServer(context.Background(), apiServices, api.NewConfig())
+}
+
+func main() {
+	logrus.SetOutput(os.Stdout)
+	logrus.SetReportCaller(false)
+	logrus.SetFormatter(&logrus.JSONFormatter{})
+	logrus.Info("Daemon Starting")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: address, Handler: dcad.routes()}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Error("HTTP server stopped")
+		}
+	}()
+	logrus.WithField("address", address).Info("Serving Health, Metrics and Admin API")
+
+	dcad.run(ctx)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logrus.WithError(err).Warn("Could not shutdown HTTP server")
+	}
+	if err := flushTraces(shutdownCtx); err != nil {
+		logrus.WithError(err).Warn("Could not export traces")
+	}
+
+	logrus.Info("Daemon Stopped")
+}
+
+// daemon executes the orders on the schedule of the configuration and
+// processes the pending orders from a local queue in place of Lambda and SQS.
+type daemon struct {
+	loadConfig func(ctx context.Context) (*configuration.DCAConfig, error)
+	execute    func(ctx context.Context, triggerTime time.Time) (*runs.RunSummary, error)
+	process    func(ctx context.Context, event awsEvents.SQSEvent) error
+	api        http.Handler
+	queue      *queue.Local
+	registry   *metrics.Registry
+	statePath  string
+	now        func() time.Time
+
+	// shutdownTimeout is how long the work in progress has to drain
+	shutdownTimeout time.Duration
+
+	mu     sync.Mutex
+	state  daemonState
+	status daemonStatus
+}
+
+// daemonState is saved so runs missed while the daemon was down can be caught up.
+type daemonState struct {
+	LastRun time.Time `json:"last_run"`
+}
+
+// daemonStatus is reported by the health endpoint.
+type daemonStatus struct {
+	Status        string           `json:"status"`
+	StartedAt     time.Time        `json:"started_at"`
+	LastRun       *runs.RunSummary `json:"last_run,omitempty"`
+	NextRun       *time.Time       `json:"next_run,omitempty"`
+	QueueMessages int              `json:"queue_messages"`
+	Error         string           `json:"error,omitempty"`
+}
+
+// newDaemon creates a daemon loading the state saved at the path when there is one.
+func newDaemon(localQueue *queue.Local, registry *metrics.Registry, statePath string) (*daemon, error) {
+	d := &daemon{
+		queue:     localQueue,
+		registry:  registry,
+		statePath: statePath,
+		now:       time.Now,
+
+		shutdownTimeout: shutdownTimeout,
+	}
+	d.status = daemonStatus{Status: statusOK, StartedAt: d.now().UTC()}
+
+	if statePath == "" {
+		return d, nil
+	}
+
+	saved, err := ioutil.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return d, json.Unmarshal(saved, &d.state)
+}
+
+// run schedules runs and processes the queue until the context is done
+// then waits for the run or message in progress to drain.
+//
+// The work in progress is not cancelled when the context is done but once
+// shutting down takes longer than the shutdown timeout.
+func (d *daemon) run(ctx context.Context) {
+	work, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for {
+			wait := d.runDue(ctx, work)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for {
+			d.processQueue(ctx, work)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+		}
+	}()
+
+	<-ctx.Done()
+	logrus.Info("Shutting Down, waiting for work in progress")
+	d.setStatus(func(status *daemonStatus) { status.Status = statusStopping })
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), d.shutdownTimeout)
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return
+	case <-shutdownCtx.Done():
+		logrus.Warn("Work in progress did not finish before the shutdown timeout, cancelling it")
+		cancelWork()
+	}
+	<-stopped
+}
+
+// runDue executes every run which is due on the schedule of the configuration
+// and returns how long to wait before checking again.
+//
+// No more runs are started once the context is done but the runs are executed
+// with the work context so orders are never left half placed.
+func (d *daemon) runDue(ctx context.Context, work context.Context) time.Duration {
+	now := d.now().UTC()
+
+	conf, err := d.loadConfig(ctx)
+	if err != nil {
+		logrus.WithError(err).Error("Could not load DCA Configuration")
+		d.setStatus(func(status *daemonStatus) { status.Error = err.Error() })
+		return reloadInterval
+	}
+
+	if conf.Schedule == nil || len(conf.Schedule.Expressions) == 0 {
+		logrus.Debug("No Schedule configured")
+		d.setStatus(func(status *daemonStatus) { status.NextRun, status.Error = nil, "" })
+		return reloadInterval
+	}
+
+	runSchedule, err := schedule.ParseAll(conf.Schedule.Expressions)
+	if err != nil {
+		logrus.WithError(err).Error("Invalid Schedule")
+		d.setStatus(func(status *daemonStatus) { status.Error = err.Error() })
+		return reloadInterval
+	}
+
+	window, err := conf.Schedule.GetCatchUpWindow()
+	if err != nil {
+		logrus.WithError(err).Error("Invalid Schedule")
+		d.setStatus(func(status *daemonStatus) { status.Error = err.Error() })
+		return reloadInterval
+	}
+
+	// Nothing has been missed the first time the daemon starts
+	lastRun := d.lastRun()
+	if lastRun.IsZero() {
+		lastRun = now
+	}
+
+	due, err := schedule.Due(runSchedule, lastRun, now, conf.Schedule.CatchUp, window)
+	if err != nil {
+		logrus.WithError(err).Error("Invalid Schedule")
+		d.setStatus(func(status *daemonStatus) { status.Error = err.Error() })
+		return reloadInterval
+	}
+	d.setStatus(func(status *daemonStatus) { status.Error = "" })
+
+	evaluated := now
+	for _, triggerTime := range due {
+		if ctx.Err() != nil {
+			evaluated = triggerTime.Add(-time.Nanosecond)
+			break
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"triggerTime": triggerTime,
+			"late":        now.Sub(triggerTime).String(),
+		}).Info("Executing Scheduled Run")
+
+		summary, err := d.execute(logging.WithField(work, "triggerTime", triggerTime), triggerTime)
+		if err != nil {
+			logrus.WithError(err).WithField("triggerTime", triggerTime).Error("Scheduled Run Failed")
+		}
+
+		if summary != nil {
+			d.setStatus(func(status *daemonStatus) { status.LastRun = summary })
+		}
+	}
+
+	if err := d.saveLastRun(evaluated); err != nil {
+		logrus.WithError(err).Error("Could not save daemon state")
+	}
+
+	next := runSchedule.Next(evaluated)
+	d.setStatus(func(status *daemonStatus) {
+		status.NextRun = nil
+		if !next.IsZero() {
+			status.NextRun = &next
+		}
+	})
+
+	wait := reloadInterval
+	if !next.IsZero() {
+		if untilNext := next.Sub(d.now()); untilNext < wait {
+			wait = untilNext
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// processQueue processes the visible messages on the queue one at a time so a
+// failing message is retried once it is visible again without holding back the others.
+//
+// No more messages are processed once the context is done, the message in
+// progress is processed with the work context.
+func (d *daemon) processQueue(ctx context.Context, work context.Context) {
+	event, err := d.queue.Receive(receiveBatch, visibilityTimeout)
+	if err != nil {
+		logrus.WithError(err).Error("Could not receive from queue")
+	}
+
+	for _, message := range event.Records {
+		if ctx.Err() != nil {
+			return
+		}
+
+		single := awsEvents.SQSEvent{Records: []awsEvents.SQSMessage{message}}
+		if err := d.process(work, single); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"messageId":    message.MessageId,
+				"retryAfter":   visibilityTimeout.String(),
+				"receiveCount": d.receiveCount(message.MessageId),
+			}).Error("Could not process message")
+		}
+	}
+}
+
+// routes serves the health of the daemon, its metrics and the admin API.
+func (d *daemon) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", d.health)
+	mux.Handle("/metrics", d.registry.Handler())
+
+	if d.api != nil {
+		for _, pattern := range []string{"/runs", "/runs/", "/orders/", "/config/"} {
+			mux.Handle(pattern, d.api)
+		}
+	}
+	return mux
+}
+
+// health reports the status of the daemon, which is unavailable once it is shutting down.
+func (d *daemon) health(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	status := d.status
+	d.mu.Unlock()
+	status.QueueMessages = len(d.queue.Messages())
+
+	w.Header().Set("Content-Type", "application/json")
+	if status.Status != statusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		logrus.WithError(err).Warn("Could not write health")
+	}
+}
+
+func (d *daemon) setStatus(update func(status *daemonStatus)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	update(&d.status)
+}
+
+func (d *daemon) lastRun() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state.LastRun
+}
+
+// saveLastRun records that the schedule has been evaluated up to the given time.
+func (d *daemon) saveLastRun(lastRun time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.state.LastRun = lastRun
+	if d.statePath == "" {
+		return nil
+	}
+
+	serialised, err := json.Marshal(d.state)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(d.statePath, serialised, 0600)
+}
+
+func (d *daemon) receiveCount(messageID string) int {
+	for _, message := range d.queue.Messages() {
+		if message.ID == messageID {
+			return message.ReceiveCount
+		}
+	}
+	return 0
+}
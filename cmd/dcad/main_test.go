@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	awsEvents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/metrics"
+	"github.com/kiran94/dca-manager/pkg/queue"
+	"github.com/kiran94/dca-manager/pkg/runs"
+	"github.com/stretchr/testify/assert"
+)
+
+func at(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// setup creates a daemon with the schedule which records
+// the trigger time of every run it executes.
+func setup(t *testing.T, scheduleConfig *configuration.ScheduleConfig, statePath string) (*daemon, *[]time.Time) {
+	localQueue, err := queue.NewLocal("")
+	assert.Nil(t, err)
+
+	d, err := newDaemon(localQueue, metrics.NewRegistry(), statePath)
+	assert.Nil(t, err)
+
+	executed := &[]time.Time{}
+	d.loadConfig = func(ctx context.Context) (*configuration.DCAConfig, error) {
+		return &configuration.DCAConfig{Schedule: scheduleConfig}, nil
+	}
+	d.execute = func(ctx context.Context, triggerTime time.Time) (*runs.RunSummary, error) {
+		*executed = append(*executed, triggerTime)
+		return &runs.RunSummary{RunID: "RUNID", TriggerTime: triggerTime}, nil
+	}
+
+	return d, executed
+}
+
+// Ensures nothing is caught up the first time the daemon starts
+// and the next run is waited for
+func TestRunDueFirstStart(t *testing.T) {
+	d, executed := setup(t, &configuration.ScheduleConfig{Expressions: []string{"cron(0 6 * * ? *)"}, CatchUp: "all"}, "")
+	d.now = func() time.Time { return at("2022-01-07T05:59:30Z") }
+
+	wait := d.runDue(context.Background(), context.Background())
+
+	assert.Equal(t, 0, len(*executed))
+	assert.Equal(t, 30*time.Second, wait)
+	assert.Equal(t, at("2022-01-07T05:59:30Z"), d.state.LastRun)
+	assert.Equal(t, at("2022-01-07T06:00:00Z"), *d.status.NextRun)
+}
+
+// Ensures runs missed while the daemon was down are run
+// according to the catch up policy and the state is saved
+func TestRunDueCatchUp(t *testing.T) {
+	cases := []struct {
+		policy   string
+		expected []time.Time
+	}{
+		{"none", []time.Time{}},
+		{"latest", []time.Time{at("2022-01-07T06:00:00Z")}},
+		{"all", []time.Time{at("2022-01-06T06:00:00Z"), at("2022-01-07T06:00:00Z")}},
+	}
+
+	for _, c := range cases {
+		statePath := filepath.Join(t.TempDir(), "state.json")
+		d, executed := setup(t, &configuration.ScheduleConfig{Expressions: []string{"cron(0 6 * * ? *)"}, CatchUp: c.policy}, statePath)
+		d.now = func() time.Time { return at("2022-01-07T12:00:00Z") }
+		assert.Nil(t, d.saveLastRun(at("2022-01-05T12:00:00Z")))
+
+		wait := d.runDue(context.Background(), context.Background())
+
+		assert.Equal(t, c.expected, *executed, c.policy)
+		assert.Equal(t, reloadInterval, wait, c.policy)
+
+		reloaded, err := newDaemon(d.queue, d.registry, statePath)
+		assert.Nil(t, err)
+		assert.Equal(t, at("2022-01-07T12:00:00Z"), reloaded.state.LastRun, c.policy)
+	}
+}
+
+// Ensures runs are executed when they are due
+// and are reported as the last run
+func TestRunDue(t *testing.T) {
+	d, executed := setup(t, &configuration.ScheduleConfig{Expressions: []string{"cron(0 6 * * ? *)"}}, "")
+	d.now = func() time.Time { return at("2022-01-07T06:00:01Z") }
+	assert.Nil(t, d.saveLastRun(at("2022-01-07T05:59:00Z")))
+
+	d.runDue(context.Background(), context.Background())
+
+	assert.Equal(t, []time.Time{at("2022-01-07T06:00:00Z")}, *executed)
+	assert.Equal(t, "RUNID", d.status.LastRun.RunID)
+	assert.Equal(t, at("2022-01-08T06:00:00Z"), *d.status.NextRun)
+}
+
+// Ensures no runs are started once the daemon is shutting down
+// and they are left to be caught up on the next start
+func TestRunDueShuttingDown(t *testing.T) {
+	d, executed := setup(t, &configuration.ScheduleConfig{Expressions: []string{"cron(0 6 * * ? *)"}}, "")
+	d.now = func() time.Time { return at("2022-01-07T06:00:01Z") }
+	assert.Nil(t, d.saveLastRun(at("2022-01-07T05:59:00Z")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.runDue(ctx, context.Background())
+
+	assert.Equal(t, 0, len(*executed))
+	assert.True(t, d.state.LastRun.Before(at("2022-01-07T06:00:00Z")))
+}
+
+// Ensures errors loading or evaluating the schedule are
+// reported and nothing is executed
+func TestRunDueInvalid(t *testing.T) {
+	for name, scheduleConfig := range map[string]*configuration.ScheduleConfig{
+		"expression": {Expressions: []string{"every day"}},
+		"policy":     {Expressions: []string{"rate(1 day)"}, CatchUp: "sometimes"},
+		"window":     {Expressions: []string{"rate(1 day)"}, CatchUpWindow: "a day"},
+	} {
+		d, executed := setup(t, scheduleConfig, "")
+
+		wait := d.runDue(context.Background(), context.Background())
+
+		assert.Equal(t, 0, len(*executed), name)
+		assert.Equal(t, reloadInterval, wait, name)
+		assert.NotEmpty(t, d.status.Error, name)
+	}
+
+	d, executed := setup(t, nil, "")
+	d.loadConfig = func(ctx context.Context) (*configuration.DCAConfig, error) {
+		return nil, errors.New("config error")
+	}
+
+	d.runDue(context.Background(), context.Background())
+	assert.Equal(t, 0, len(*executed))
+	assert.Equal(t, "config error", d.status.Error)
+}
+
+// Ensures messages are processed one at a time where a failing
+// message stays on the queue without holding back the others
+func TestProcessQueue(t *testing.T) {
+	d, _ := setup(t, nil, "")
+	for _, body := range []string{"fails", "succeeds"} {
+		_, err := d.queue.SendMessage(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String(body)})
+		assert.Nil(t, err)
+	}
+
+	d.process = func(ctx context.Context, event awsEvents.SQSEvent) error {
+		assert.Equal(t, 1, len(event.Records))
+
+		message := event.Records[0]
+		if message.Body == "fails" {
+			return errors.New("process error")
+		}
+
+		_, err := d.queue.DeleteMessage(ctx, &sqs.DeleteMessageInput{ReceiptHandle: aws.String(message.ReceiptHandle)})
+		return err
+	}
+
+	d.processQueue(context.Background(), context.Background())
+
+	messages := d.queue.Messages()
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "fails", messages[0].Body)
+	assert.Equal(t, 1, messages[0].ReceiveCount)
+}
+
+// Ensures shutting down waits for the message in progress to
+// drain and only cancels it once the shutdown timeout has passed
+func TestRunDrainsWorkInProgress(t *testing.T) {
+	for name, timeout := range map[string]time.Duration{"drained": time.Minute, "cancelled": 50 * time.Millisecond} {
+		d, _ := setup(t, nil, "")
+		d.shutdownTimeout = timeout
+		_, err := d.queue.SendMessage(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String("order")})
+		assert.Nil(t, err, name)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		started := make(chan struct{})
+		var processErr error
+		d.process = func(work context.Context, event awsEvents.SQSEvent) error {
+			close(started)
+			select {
+			case <-work.Done():
+			case <-time.After(200 * time.Millisecond):
+			}
+			processErr = work.Err()
+			return processErr
+		}
+
+		go func() {
+			<-started
+			cancel()
+		}()
+		d.run(ctx)
+
+		if name == "drained" {
+			assert.Nil(t, processErr, name)
+		} else {
+			assert.Equal(t, context.Canceled, processErr, name)
+		}
+	}
+}
+
+// Ensures the health endpoint reports the status of the
+// daemon and is unavailable once it is shutting down
+func TestHealth(t *testing.T) {
+	d, _ := setup(t, nil, "")
+	_, err := d.queue.SendMessage(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String("order")})
+	assert.Nil(t, err)
+
+	server := httptest.NewServer(d.routes())
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/healthz")
+	assert.Nil(t, err)
+	defer response.Body.Close()
+
+	var status daemonStatus
+	assert.Nil(t, json.NewDecoder(response.Body).Decode(&status))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, statusOK, status.Status)
+	assert.Equal(t, 1, status.QueueMessages)
+
+	d.setStatus(func(status *daemonStatus) { status.Status = statusStopping })
+
+	response, err = http.Get(server.URL + "/healthz")
+	assert.Nil(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+
+	response, err = http.Get(server.URL + "/metrics")
+	assert.Nil(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+// Ensures the admin API is served alongside the health
+// of the daemon when it is configured
+func TestRoutesAdminAPI(t *testing.T) {
+	d, _ := setup(t, nil, "")
+	d.api = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	server := httptest.NewServer(d.routes())
+	defer server.Close()
+
+	for _, path := range []string{"/runs", "/runs/RUNID", "/orders/TXID", "/config/validate"} {
+		response, err := http.Get(server.URL + path)
+		assert.Nil(t, err)
+		response.Body.Close()
+		assert.Equal(t, http.StatusTeapot, response.StatusCode, path)
+	}
+
+	response, err := http.Get(server.URL + "/healthz")
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/dlq"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/sirupsen/logrus"
+)
+
+const usage = `usage:
+  dlq list [-json]
+  dlq redrive [-all] [message id...]
+  dlq edit <message id>
+  dlq archive [-all] [message id...]`
+
+// visibility is how long received messages are hidden from anything
+// else reading the dead-letter queue while the command works on them.
+const visibility = 10 * time.Minute
+
+// Editor edits the content, returning the edited content.
+type Editor func(content []byte) ([]byte, error)
+
+// DCAServices contains all services to be injected into logic.
+type DCAServices struct {
+	sqsAccess pkg.SQSAccess
+	s3Access  pkg.S3Access
+	inspector dlq.Inspector
+	editor    Editor
+}
+
+// AppConfig contains all configuration to be injected into logic
+type AppConfig struct {
+	dlqURL        string
+	queueURL      string
+	s3bucket      string
+	archivePrefix string
+}
+
+func main() {
+	logrus.SetOutput(os.Stderr)
+	logrus.SetFormatter(&logrus.TextFormatter{})
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not retrieve default aws config")
+	}
+
+	services := &DCAServices{
+		sqsAccess: pkg.SQS{Client: sqs.NewFromConfig(awsConfig)},
+		s3Access:  pkg.S3{Client: s3.NewFromConfig(awsConfig)},
+		inspector: dlq.SQSInspector{},
+		editor:    externalEditor,
+	}
+	appConfig := &AppConfig{
+		dlqURL:        os.Getenv(configuration.EnvSQSPendingOrdersDLQ),
+		queueURL:      os.Getenv(configuration.EnvSQSPendingOrdersQueue),
+		s3bucket:      os.Getenv(configuration.EnvS3Bucket),
+		archivePrefix: os.Getenv(configuration.EnvS3DLQArchive),
+	}
+
+	if err := run(context.Background(), services, appConfig, os.Args[1:], os.Stdout, time.Now()); err != nil {
+		logrus.WithError(err).Error("Could not complete dead-letter queue command")
+		os.Exit(1)
+	}
+}
+
+// run runs the command given by the arguments.
+func run(ctx context.Context, services *DCAServices, config *AppConfig, args []string, out io.Writer, now time.Time) error {
+	if config.dlqURL == "" {
+		return fmt.Errorf("%s must be set", configuration.EnvSQSPendingOrdersDLQ)
+	}
+
+	switch args[0] {
+	case "list":
+		flags := flag.NewFlagSet("list", flag.ContinueOnError)
+		asJSON := flags.Bool("json", false, "write the messages as json including their bodies")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		return List(ctx, services, config, out, *asJSON)
+
+	case "redrive", "archive":
+		flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+		all := flags.Bool("all", false, "select every message on the dead-letter queue")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *all == (flags.NArg() > 0) {
+			return errors.New(usage)
+		}
+
+		if args[0] == "redrive" {
+			return Redrive(ctx, services, config, flags.Args(), *all)
+		}
+		return Archive(ctx, services, config, flags.Args(), *all, out, now)
+
+	case "edit":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		return Edit(ctx, services, config, args[1])
+
+	default:
+		return fmt.Errorf("unknown command %s\n%s", args[0], usage)
+	}
+}
+
+// List writes every message on the dead-letter queue, then releases them.
+func List(ctx context.Context, services *DCAServices, config *AppConfig, out io.Writer, asJSON bool) (err error) {
+	messages, err := services.inspector.Receive(ctx, services.sqsAccess, config.dlqURL, visibility)
+	defer func() { err = release(ctx, services, config, messages, err) }()
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(messages)
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "MESSAGE ID\tSENT AT\tRECEIVES\tEXCHANGE\tREAL\tTRANSACTION ID\tPAIR\tDIRECTION\tVOLUME")
+	for _, message := range messages {
+		pair, direction, volume := "-", "-", "-"
+		if message.Pending != nil && message.Pending.Order != nil {
+			pair, direction, volume = message.Pending.Order.Pair, message.Pending.Order.Direction, message.Pending.Order.Volume
+		} else if message.DecodeError != "" {
+			pair = "undecodable body"
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			message.MessageID,
+			message.SentAt.Format(time.RFC3339),
+			message.ReceiveCount,
+			orDash(message.Attribute("Exchange")),
+			orDash(message.Attribute("Real")),
+			orDash(message.Attribute("TransactionId")),
+			pair,
+			direction,
+			volume,
+		)
+	}
+	return writer.Flush()
+}
+
+// Redrive sends the selected messages back to the pending orders queue as they are.
+func Redrive(ctx context.Context, services *DCAServices, config *AppConfig, ids []string, all bool) error {
+	if config.queueURL == "" {
+		return fmt.Errorf("%s must be set", configuration.EnvSQSPendingOrdersQueue)
+	}
+
+	return withSelected(ctx, services, config, ids, all, func(message dlq.Message) error {
+		logrus.WithFields(logrus.Fields{"messageId": message.MessageID, "transactionId": message.Attribute("TransactionId")}).Info("Redriving Message")
+		return services.inspector.Redrive(ctx, services.sqsAccess, config.dlqURL, config.queueURL, message, message.Body)
+	})
+}
+
+// Edit opens the pending order of the message in the editor and sends
+// it back to the pending orders queue once it has been saved. The
+// message stays on the dead-letter queue when the edit is not valid.
+func Edit(ctx context.Context, services *DCAServices, config *AppConfig, id string) error {
+	if config.queueURL == "" {
+		return fmt.Errorf("%s must be set", configuration.EnvSQSPendingOrdersQueue)
+	}
+
+	return withSelected(ctx, services, config, []string{id}, false, func(message dlq.Message) error {
+		content := []byte(message.Body)
+		if message.Pending != nil {
+			indented, err := json.MarshalIndent(message.Pending, "", "  ")
+			if err != nil {
+				return err
+			}
+			content = indented
+		}
+
+		edited, err := services.editor(content)
+		if err != nil {
+			return err
+		}
+
+		body, err := readPending(edited)
+		if err != nil {
+			return err
+		}
+
+		logrus.WithFields(logrus.Fields{"messageId": message.MessageID, "transactionId": message.Attribute("TransactionId")}).Info("Redriving Edited Message")
+		return services.inspector.Redrive(ctx, services.sqsAccess, config.dlqURL, config.queueURL, message, body)
+	})
+}
+
+// Archive saves the selected messages to S3 and removes them from the dead-letter queue.
+func Archive(ctx context.Context, services *DCAServices, config *AppConfig, ids []string, all bool, out io.Writer, now time.Time) error {
+	if config.s3bucket == "" || config.archivePrefix == "" {
+		return fmt.Errorf("%s and %s must be set", configuration.EnvS3Bucket, configuration.EnvS3DLQArchive)
+	}
+
+	return withSelected(ctx, services, config, ids, all, func(message dlq.Message) error {
+		s3Key, err := services.inspector.Archive(ctx, services.sqsAccess, services.s3Access, config.dlqURL, config.s3bucket, config.archivePrefix, message, now)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "%s archived to s3://%s/%s\n", message.MessageID, config.s3bucket, s3Key)
+		return nil
+	})
+}
+
+// withSelected receives the messages on the dead-letter queue and acts on
+// every selected message, releasing the rest along with any it failed on.
+func withSelected(ctx context.Context, services *DCAServices, config *AppConfig, ids []string, all bool, act func(message dlq.Message) error) (err error) {
+	messages, err := services.inspector.Receive(ctx, services.sqsAccess, config.dlqURL, visibility)
+
+	unhandled := []dlq.Message{}
+	defer func() { err = release(ctx, services, config, unhandled, err) }()
+	if err != nil {
+		unhandled = messages
+		return err
+	}
+
+	selected := map[string]bool{}
+	for _, id := range ids {
+		selected[id] = true
+	}
+
+	found := map[string]bool{}
+	failed := []error{}
+	for _, message := range messages {
+		if !all && !selected[message.MessageID] {
+			unhandled = append(unhandled, message)
+			continue
+		}
+
+		found[message.MessageID] = true
+		if err := act(message); err != nil {
+			unhandled = append(unhandled, message)
+			failed = append(failed, fmt.Errorf("message %s: %w", message.MessageID, err))
+		}
+	}
+
+	for _, id := range ids {
+		if !found[id] {
+			failed = append(failed, fmt.Errorf("no message %s on the dead-letter queue", id))
+		}
+	}
+
+	if len(failed) == 1 {
+		return failed[0]
+	}
+	if len(failed) > 1 {
+		return fmt.Errorf("%d messages failed, first error: %w", len(failed), failed[0])
+	}
+	return nil
+}
+
+// release makes the messages visible again, keeping the
+// first error when the command had already failed.
+func release(ctx context.Context, services *DCAServices, config *AppConfig, messages []dlq.Message, err error) error {
+	releaseErr := services.inspector.Release(ctx, services.sqsAccess, config.dlqURL, messages)
+	if err != nil {
+		if releaseErr != nil {
+			logrus.WithError(releaseErr).Error("Could not release messages")
+		}
+		return err
+	}
+	return releaseErr
+}
+
+// readPending checks the edited content is a pending order,
+// returning it compacted to be the body of the message.
+func readPending(content []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+
+	pending := &orders.PendingOrders{}
+	if err := decoder.Decode(pending); err != nil {
+		return "", fmt.Errorf("edited message is not a pending order: %w", err)
+	}
+	if pending.TransactionID == "" && !pending.IsScheduledSlice() {
+		return "", errors.New("edited message needs a transaction_id or a slice to place")
+	}
+
+	body, err := json.Marshal(pending)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// externalEditor edits the content in a temporary file with $EDITOR or vi.
+func externalEditor(content []byte) ([]byte, error) {
+	file, err := ioutil.TempFile("", "dlq-*.json")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+
+	command := exec.Command(editor, file.Name())
+	command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := command.Run(); err != nil {
+		return nil, fmt.Errorf("editor %s failed: %w", editor, err)
+	}
+
+	return ioutil.ReadFile(file.Name())
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
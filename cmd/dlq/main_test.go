@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/dlq"
+	"github.com/kiran94/dca-manager/pkg/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var now = time.Date(2022, 1, 7, 6, 0, 0, 0, time.UTC)
+
+const pendingBody = `{"transaction_id":"TX1","s3_bucket":"bucket","s3_key":"pending/TX1.json","order":{"exchange":"kraken","direction":"buy","ordertype":"market","volume":"0.01","pair":"XBTGBP","validate":false,"enabled":true}}`
+
+// Queues routes SQS operations to local queues by their URL
+type Queues map[string]*queue.Local
+
+func (q Queues) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	return q[*params.QueueUrl].SendMessage(ctx, params, optFns...)
+}
+
+func (q Queues) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	return q[*params.QueueUrl].DeleteMessage(ctx, params, optFns...)
+}
+
+func (q Queues) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	return q[*params.QueueUrl].ReceiveMessage(ctx, params, optFns...)
+}
+
+func (q Queues) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return q[*params.QueueUrl].ChangeMessageVisibility(ctx, params, optFns...)
+}
+
+func setup(t *testing.T, bodies ...string) (*DCAServices, *AppConfig, Queues) {
+	queues := Queues{}
+	for _, url := range []string{"dlq", "pending"} {
+		local, err := queue.NewLocal("")
+		assert.Nil(t, err)
+		queues[url] = local
+	}
+
+	for _, body := range bodies {
+		_, err := queues["dlq"].SendMessage(context.Background(), &sqs.SendMessageInput{
+			MessageBody: aws.String(body),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"Exchange":      {DataType: aws.String("String"), StringValue: aws.String("kraken")},
+				"Real":          {DataType: aws.String("String"), StringValue: aws.String("true")},
+				"TransactionId": {DataType: aws.String("String"), StringValue: aws.String("TX1")},
+			},
+		})
+		assert.Nil(t, err)
+	}
+
+	services := &DCAServices{
+		sqsAccess: queues,
+		s3Access:  &pkg.MockS3Access{},
+		inspector: dlq.SQSInspector{},
+	}
+	appConfig := &AppConfig{dlqURL: "dlq", queueURL: "pending", s3bucket: "bucket", archivePrefix: "dlq"}
+	return services, appConfig, queues
+}
+
+// Ensures messages are listed with their decoded orders and left on the queue
+func TestList(t *testing.T) {
+	services, appConfig, queues := setup(t, pendingBody, "not json")
+
+	var out bytes.Buffer
+	err := run(context.Background(), services, appConfig, []string{"list"}, &out, now)
+
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.Contains(t, lines[1], "TX1")
+	assert.Contains(t, lines[1], "XBTGBP")
+	assert.Contains(t, lines[2], "undecodable body")
+
+	out.Reset()
+	err = run(context.Background(), services, appConfig, []string{"list", "-json"}, &out, now)
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), `"transaction_id": "TX1"`)
+
+	// Listed messages are released straight away
+	received, err := queues["dlq"].Receive(10, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(received.Records))
+}
+
+// Ensures only the selected messages are redriven and the rest released
+func TestRedrive(t *testing.T) {
+	services, appConfig, queues := setup(t, pendingBody, pendingBody)
+	ids := []string{queues["dlq"].Messages()[0].ID}
+
+	err := run(context.Background(), services, appConfig, []string{"redrive", ids[0]}, &bytes.Buffer{}, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(queues["pending"].Messages()))
+	assert.Equal(t, pendingBody, queues["pending"].Messages()[0].Body)
+	assert.Equal(t, "true", queues["pending"].Messages()[0].Attributes["Real"])
+
+	remaining, err := queues["dlq"].Receive(10, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(remaining.Records))
+
+	err = run(context.Background(), services, appConfig, []string{"redrive", "unknown"}, &bytes.Buffer{}, now)
+	assert.EqualError(t, err, "no message unknown on the dead-letter queue")
+
+	err = run(context.Background(), services, appConfig, []string{"redrive"}, &bytes.Buffer{}, now)
+	assert.EqualError(t, err, usage)
+}
+
+// Ensures edited messages are redriven and invalid edits are kept on the queue
+func TestEdit(t *testing.T) {
+	services, appConfig, queues := setup(t, pendingBody)
+	id := queues["dlq"].Messages()[0].ID
+
+	services.editor = func(content []byte) ([]byte, error) {
+		return []byte(`{"transaction_id": "TX1", "unknown": true}`), nil
+	}
+	err := run(context.Background(), services, appConfig, []string{"edit", id}, &bytes.Buffer{}, now)
+	assert.NotNil(t, err)
+	assert.Empty(t, queues["pending"].Messages())
+
+	services.editor = func(content []byte) ([]byte, error) {
+		assert.Contains(t, string(content), `"volume": "0.01"`)
+		return bytes.Replace(content, []byte(`"volume": "0.01"`), []byte(`"volume": "0.02"`), 1), nil
+	}
+	err = run(context.Background(), services, appConfig, []string{"edit", id}, &bytes.Buffer{}, now)
+
+	assert.Nil(t, err)
+	assert.Empty(t, queues["dlq"].Messages())
+	assert.Equal(t, 1, len(queues["pending"].Messages()))
+	assert.Contains(t, queues["pending"].Messages()[0].Body, `"volume":"0.02"`)
+}
+
+// Ensures messages are archived to S3 and kept on the queue when they could not be
+func TestArchive(t *testing.T) {
+	services, appConfig, queues := setup(t, pendingBody)
+
+	s3Access := &pkg.MockS3Access{}
+	s3Access.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, errors.New("denied")).Once()
+	s3Access.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, nil).Once()
+	services.s3Access = s3Access
+
+	err := run(context.Background(), services, appConfig, []string{"archive", "-all"}, &bytes.Buffer{}, now)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(queues["dlq"].Messages()))
+
+	var out bytes.Buffer
+	err = run(context.Background(), services, appConfig, []string{"archive", "-all"}, &out, now)
+
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "s3://bucket/dlq/date=2022-01-07/")
+	assert.Empty(t, queues["dlq"].Messages())
+	s3Access.AssertExpectations(t)
+}
@@ -1,60 +1,35 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"os"
-	"strings"
 	"time"
 
 	awsEvents "github.com/aws/aws-lambda-go/events"
 	awsLambda "github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
-	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/kiran94/dca-manager/pkg"
 	"github.com/kiran94/dca-manager/pkg/configuration"
-	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/kiran94/dca-manager/pkg/executor"
+	"github.com/kiran94/dca-manager/pkg/logging"
+	"github.com/kiran94/dca-manager/pkg/metrics"
+	"github.com/kiran94/dca-manager/pkg/tracing"
 	"github.com/sirupsen/logrus"
 )
 
 var (
-	dcaServices *DCAServices
-	appConfig   *AppConfig
-)
-
-// DCAServices contains all services to be injected into logic.
-type DCAServices struct {
-	awsConfig             aws.Config
-	s3Access              pkg.S3Access
-	ssmAccess             pkg.SSMAccess
-	sqsAccess             pkg.SQSAccess
-	configSource          configuration.DCAConfigurationSource
-	ordererFactory        orders.OrdererFactory
-	pendingOrderSubmitter orders.PendingOrderQueue
-}
-
-// AppConfig contains all configuration to be injected into logic
-type AppConfig struct {
-	s3bucket      string
-	dcaConfigPath string
-	allowReal     bool
-	transactions  struct {
-		pendingS3TransactionPrefix   string
-		processedS3TransactionPrefix string
-	}
-	queue struct {
-		sqsURL string
+	dcaServices *executor.DCAServices
+	appConfig   *executor.AppConfig
+	registry    *metrics.Registry
+	flushTraces tracing.Flush
+	metricsConf struct {
+		namespace string
+		address   string
+		emf       bool
 	}
-	glue struct {
-		processTransactionJob       string
-		processTransactionOperation string
-	}
-}
+)
 
 func init() {
 	awsConfig, err := config.LoadDefaultConfig(context.Background())
@@ -62,25 +37,21 @@ func init() {
 		logrus.WithError(err).Panic("Could not retrieve default aws config")
 	}
 
-	dcaServices = &DCAServices{}
-	dcaServices.awsConfig = awsConfig
-	dcaServices.s3Access = pkg.S3{Client: s3.NewFromConfig(awsConfig)}
-	dcaServices.ssmAccess = pkg.SSM{Client: ssm.NewFromConfig(awsConfig)}
-	dcaServices.sqsAccess = pkg.SQS{Client: sqs.NewFromConfig(awsConfig)}
-	dcaServices.ordererFactory = orders.OrdererFac{}
-	dcaServices.configSource = configuration.DCAConfiguration{}
-	dcaServices.pendingOrderSubmitter = orders.PendingOrderSubmitter{}
-
-	appConfig = &AppConfig{
-		s3bucket:      os.Getenv(configuration.EnvS3Bucket),
-		dcaConfigPath: os.Getenv(configuration.EnvS3ConfigPath),
-		allowReal:     os.Getenv(configuration.EnvAllowReal) != "",
+	flushTraces, err = tracing.Setup(context.Background(), "execute_orders")
+	if err != nil {
+		logrus.WithError(err).Warn("Could not setup tracing, spans will not be exported")
+		flushTraces = func(ctx context.Context) error { return nil }
+	}
+
+	registry = metrics.NewRegistry()
+	dcaServices = executor.NewDCAServices(awsConfig, pkg.SQS{Client: sqs.NewFromConfig(awsConfig)}, registry)
+	appConfig = executor.NewAppConfig()
+
+	metricsConf.namespace = os.Getenv(configuration.EnvMetricsNamespace)
+	metricsConf.address = os.Getenv(configuration.EnvMetricsAddress)
+	if metricsConf.namespace == "" {
+		metricsConf.namespace = metrics.DefaultNamespace
 	}
-	appConfig.transactions.pendingS3TransactionPrefix = os.Getenv(configuration.EnvS3PendingTransaction)
-	appConfig.transactions.processedS3TransactionPrefix = os.Getenv(configuration.EnvS3ProcessedTransaction)
-	appConfig.queue.sqsURL = os.Getenv(configuration.EnvSQSPendingOrdersQueue)
-	appConfig.glue.processTransactionJob = os.Getenv(configuration.EnvGlueProcessTransactionJob)
-	appConfig.glue.processTransactionOperation = os.Getenv(configuration.EnvGlueProcessTransactionOperation)
 }
 
 func main() {
@@ -90,10 +61,15 @@ func main() {
 
 	if os.Getenv("_LAMBDA_SERVER_PORT") != "" {
 		logrus.SetFormatter(&logrus.JSONFormatter{})
+		metricsConf.emf = true
 		awsLambda.Start(handleRequest)
 
 	} else {
 		logrus.SetFormatter(&logrus.TextFormatter{})
+		if metricsConf.address != "" {
+			server := metrics.Serve(metricsConf.address, registry)
+			defer server.Close()
+		}
 		handleRequestLocally()
 	}
 
@@ -101,116 +77,41 @@ func main() {
 }
 
 func handleRequest(c context.Context, event awsEvents.CloudWatchEvent) (*string, error) {
-	pendingOrders, err := ExecuteOrders(c, dcaServices, appConfig)
-	if err != nil {
+	c = logging.WithRequest(c)
+	defer publishMetrics()
+	defer flushSpans(c)
+
+	summary, err := executor.RunOrders(c, dcaServices, appConfig, event.Time)
+	if summary == nil {
 		return nil, err
 	}
 
-	serialisedOrders, err := json.Marshal(*pendingOrders)
-	if err != nil {
-		return nil, err
+	serialisedSummary, marshalErr := json.Marshal(summary)
+	if marshalErr != nil {
+		return nil, marshalErr
 	}
 
-	serialisedOrdersString := string(serialisedOrders)
-	return &serialisedOrdersString, err
+	serialisedSummaryString := string(serialisedSummary)
+	return &serialisedSummaryString, err
 }
 
-// ExecuteOrders will execute orders from the DCA configuration
-// into exchanges
-func ExecuteOrders(ctx context.Context, services *DCAServices, config *AppConfig) (*[]orders.PendingOrders, error) {
-	logrus.Info("Executing Orders")
-
-	// Get DCA Configuration
-	logrus.WithFields(logrus.Fields{
-		"s3bucket": config.s3bucket,
-		"s3path":   config.dcaConfigPath,
-	}).Info("Getting DCA Configuration")
-
-	dcaConf, err := services.configSource.GetDCAConfiguration(ctx, services.s3Access, &config.s3bucket, &config.dcaConfigPath)
-	if err != nil {
-		return nil, err
+// flushSpans exports the spans of the invocation before Lambda freezes the process.
+func flushSpans(ctx context.Context) {
+	if err := flushTraces(ctx); err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("Could not export traces")
 	}
-	logrus.WithField("config", *dcaConf).Debug("Pulled config")
+}
 
-	logrus.Info("Getting Orderers")
-	o, ordererErr := services.ordererFactory.GetOrderers(ctx, services.ssmAccess)
-	if ordererErr != nil {
-		return nil, ordererErr
+// publishMetrics writes the metrics of the invocation
+// as CloudWatch Embedded Metric Format log lines.
+func publishMetrics() {
+	if !metricsConf.emf {
+		return
 	}
 
-	// Execute Orders
-	submittedPendingOrders := make([]orders.PendingOrders, len(dcaConf.Orders))
-	for index, order := range dcaConf.Orders {
-		logrus.WithFields(logrus.Fields{
-			"index":     index,
-			"exchange":  order.Exchange,
-			"pair":      order.Pair,
-			"volume":    order.Volume,
-			"type":      order.OrderType,
-			"direction": order.Direction,
-		}).Info("Executing Order")
-
-		var orderResult *orders.OrderFufilled
-		var orderErr error
-
-		if config.allowReal {
-			exchange, ok := (*o)[order.Exchange]
-			if !ok {
-				return nil, fmt.Errorf("no orderer found for exchange %s", order.Exchange)
-			}
-
-			orderResult, orderErr = exchange.MakeOrder(&order)
-		} else {
-			orderResult, orderErr = orders.GetFakeOrderFufilled()
-		}
-
-		if orderErr != nil {
-			return nil, orderErr
-		}
-
-		s3Path := fmt.Sprintf(
-			"%s/exchange=%s/%s.json",
-			config.transactions.pendingS3TransactionPrefix,
-			strings.ToLower(order.Exchange),
-			orderResult.TransactionID,
-		)
-
-		orderResultBytes, err := json.Marshal(orderResult)
-		if err != nil {
-			return nil, err
-		}
-
-		logrus.WithFields(logrus.Fields{
-			"s3bucket":      config.s3bucket,
-			"s3path":        s3Path,
-			"transactionId": orderResult.TransactionID,
-		}).Info("Uploading Order result to bucket")
-
-		_, err = services.s3Access.PutObject(ctx, &s3.PutObjectInput{
-			Bucket: &config.s3bucket,
-			Key:    &s3Path,
-			Body:   bytes.NewReader(orderResultBytes),
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		// Submit to SQS
-		po := orders.PendingOrders{
-			TransactionID: orderResult.TransactionID,
-			S3Bucket:      config.s3bucket,
-			S3Key:         s3Path,
-		}
-
-		submitErr := services.pendingOrderSubmitter.SubmitPendingOrder(ctx, services.sqsAccess, &po, order.Exchange, config.allowReal, config.queue.sqsURL)
-		if submitErr != nil {
-			return nil, submitErr
-		}
-
-		submittedPendingOrders[index] = po
+	if err := registry.WriteEMF(os.Stdout, metricsConf.namespace, time.Now()); err != nil {
+		logrus.WithError(err).Warn("Could not publish metrics")
 	}
-
-	return &submittedPendingOrders, nil
 }
 
 func handleRequestLocally() {
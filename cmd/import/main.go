@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/importer"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/sirupsen/logrus"
+)
+
+// Output formats of the import
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// DefaultExchange is imported from when no exchange or CSV is given.
+const DefaultExchange = "kraken"
+
+// DefaultWriteOperation loads imported orders into the DataLake
+// without duplicating orders which were already loaded.
+const DefaultWriteOperation = "upsert"
+
+// DCAServices contains all services to be injected into logic.
+type DCAServices struct {
+	s3Access       pkg.S3Access
+	ssmAccess      pkg.SSMAccess
+	glueAccess     pkg.GlueAccess
+	ordererFactory orders.OrdererFactory
+	importer       importer.Importer
+}
+
+// AppConfig contains all configuration to be injected into logic
+type AppConfig struct {
+	s3bucket     string
+	transactions struct {
+		processedS3TransactionPrefix string
+	}
+	glue struct {
+		processTransactionJob       string
+		processTransactionOperation string
+	}
+}
+
+// Options are what to import and what to do with it.
+//
+// When a CSV is given it is read with the profile instead of
+// collecting the history from the exchange.
+type Options struct {
+	exchange    string
+	from        time.Time
+	to          time.Time
+	overwrite   bool
+	dryRun      bool
+	glue        bool
+	csvPath     string
+	profile     importer.Profile
+	skipInvalid bool
+}
+
+// Report is the outcome of an import and the Glue job loading it.
+type Report struct {
+	*importer.Result
+	Collected int    `json:"collected"`
+	DryRun    bool   `json:"dry_run"`
+	GlueJobID string `json:"glue_job_id,omitempty"`
+}
+
+func main() {
+	from := flag.String("from", "2013-01-01", "the first date to import (yyyy-mm-dd)")
+	to := flag.String("to", time.Now().UTC().Format(configuration.DateLayout), "the last date to import (yyyy-mm-dd)")
+	exchange := flag.String("exchange", "", "the exchange to import, defaults to kraken or the exchange of the csv profile")
+	overwrite := flag.Bool("overwrite", false, "replace orders which have already been processed")
+	dryRun := flag.Bool("dry-run", false, "report what would be imported without writing anything")
+	submitGlue := flag.Bool("glue", false, "submit a Glue job to load the exchange into the DataLake")
+	format := flag.String("format", FormatText, "output format: text or json")
+	csvPath := flag.String("csv", "", "import orders from a csv export rather than the exchange")
+	profileName := flag.String("profile", importer.ProfileCustom, "the columns of the csv: kraken-ledger, coinbase or custom")
+	mapping := flag.String("mapping", "", "a json file of the profile for a custom csv")
+	skipInvalid := flag.Bool("skip-invalid", false, "import the valid rows of a csv with malformed rows")
+	flag.Parse()
+
+	logrus.SetOutput(os.Stderr)
+	logrus.SetFormatter(&logrus.TextFormatter{})
+
+	options, err := parseOptions(*exchange, *from, *to)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+	options.overwrite = *overwrite
+	options.dryRun = *dryRun
+	options.glue = *submitGlue
+	options.csvPath = *csvPath
+	options.skipInvalid = *skipInvalid
+
+	if *csvPath != "" {
+		if options.profile, err = loadProfile(*profileName, *mapping); err != nil {
+			logrus.WithError(err).Fatal("Invalid profile")
+		}
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not retrieve default aws config")
+	}
+
+	services := &DCAServices{
+		s3Access:       pkg.S3{Client: s3.NewFromConfig(awsConfig)},
+		ssmAccess:      pkg.SSM{Client: ssm.NewFromConfig(awsConfig)},
+		glueAccess:     pkg.Glue{Client: glue.NewFromConfig(awsConfig)},
+		ordererFactory: orders.OrdererFac{},
+		importer:       importer.S3Importer{},
+	}
+
+	appConfig := &AppConfig{s3bucket: os.Getenv(configuration.EnvS3Bucket)}
+	appConfig.transactions.processedS3TransactionPrefix = os.Getenv(configuration.EnvS3ProcessedTransaction)
+	appConfig.glue.processTransactionJob = os.Getenv(configuration.EnvGlueProcessTransactionJob)
+	appConfig.glue.processTransactionOperation = os.Getenv(configuration.EnvGlueProcessTransactionOperation)
+
+	report, err := Import(context.Background(), services, appConfig, options)
+	if report != nil {
+		if writeErr := WriteReport(os.Stdout, report, *format); writeErr != nil {
+			logrus.WithError(writeErr).Error("Could not write import")
+		}
+	}
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not import orders")
+	}
+}
+
+// parseOptions reads the inclusive dates of the range to import.
+func parseOptions(exchange string, from string, to string) (*Options, error) {
+	start, err := time.Parse(configuration.DateLayout, from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from %s, expected %s", from, configuration.DateLayout)
+	}
+
+	end, err := time.Parse(configuration.DateLayout, to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to %s, expected %s", to, configuration.DateLayout)
+	}
+
+	if end.Before(start) {
+		return nil, fmt.Errorf("to %s is before from %s", to, from)
+	}
+
+	return &Options{exchange: exchange, from: start, to: end.AddDate(0, 0, 1)}, nil
+}
+
+// loadProfile finds the built in profile or reads the mapping of a custom csv.
+func loadProfile(name string, mapping string) (importer.Profile, error) {
+	if mapping == "" {
+		profile, ok := importer.Profiles[name]
+		if !ok {
+			return importer.Profile{}, fmt.Errorf("unknown profile %s", name)
+		}
+		return profile, nil
+	}
+
+	if name != importer.ProfileCustom {
+		return importer.Profile{}, fmt.Errorf("a mapping can only be given for the %s profile", importer.ProfileCustom)
+	}
+
+	file, err := os.Open(mapping)
+	if err != nil {
+		return importer.Profile{}, err
+	}
+	defer file.Close()
+
+	return importer.LoadProfile(file)
+}
+
+// Import collects the history of the exchange, or reads it from a CSV, and
+// writes it into the processed orders, submitting a Glue job to load it
+// when asked to.
+//
+// The report is returned alongside any error so what was imported
+// before the failure is still known.
+func Import(ctx context.Context, services *DCAServices, config *AppConfig, options *Options) (*Report, error) {
+	if config.s3bucket == "" || config.transactions.processedS3TransactionPrefix == "" {
+		return nil, fmt.Errorf("%s and %s must be set", configuration.EnvS3Bucket, configuration.EnvS3ProcessedTransaction)
+	}
+	if options.glue && config.glue.processTransactionJob == "" {
+		return nil, fmt.Errorf("%s must be set to submit a Glue job", configuration.EnvGlueProcessTransactionJob)
+	}
+
+	exchange := options.exchange
+	var collected []orders.OrderComplete
+	var err error
+
+	if options.csvPath != "" {
+		if exchange == "" {
+			exchange = options.profile.Exchange
+		}
+		if exchange == "" {
+			return nil, fmt.Errorf("an exchange is required to import %s", options.csvPath)
+		}
+		collected, err = readCSV(options)
+	} else {
+		if exchange == "" {
+			exchange = DefaultExchange
+		}
+		collected, err = collect(ctx, services, exchange, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	importOptions := importer.Options{
+		S3Bucket:        config.s3bucket,
+		ProcessedPrefix: config.transactions.processedS3TransactionPrefix,
+		Exchange:        exchange,
+		Overwrite:       options.overwrite,
+		DryRun:          options.dryRun,
+		MatchTrades:     options.csvPath != "" && options.profile.Ledger,
+	}
+
+	result, err := services.importer.Import(ctx, services.s3Access, collected, importOptions)
+	if result == nil {
+		return nil, err
+	}
+
+	report := &Report{Result: result, Collected: len(collected), DryRun: options.dryRun}
+	if err != nil {
+		return report, err
+	}
+
+	if !options.glue || options.dryRun || len(result.Imported) == 0 {
+		return report, nil
+	}
+
+	writeOperation := config.glue.processTransactionOperation
+	if writeOperation == "" {
+		writeOperation = DefaultWriteOperation
+	}
+
+	logrus.WithFields(logrus.Fields{"glueJobName": config.glue.processTransactionJob, "writeOperation": writeOperation}).Info("Submitting Glue Job")
+	report.GlueJobID, err = services.importer.SubmitGlue(ctx, services.glueAccess, config.glue.processTransactionJob, writeOperation, importOptions)
+	if err != nil {
+		return report, fmt.Errorf("could not submit glue job: %w", err)
+	}
+
+	return report, nil
+}
+
+// collect gets the history of the exchange between the dates.
+func collect(ctx context.Context, services *DCAServices, exchange string, options *Options) ([]orders.OrderComplete, error) {
+	orderers, err := services.ordererFactory.GetOrderers(ctx, services.ssmAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	orderer, ok := (*orderers)[exchange]
+	if !ok {
+		return nil, fmt.Errorf("exchange %s was not configured", exchange)
+	}
+
+	history, _ := orderer.(orders.OrderHistory)
+	trades, _ := orderer.(orders.TradeHistory)
+	if history == nil && trades == nil {
+		return nil, fmt.Errorf("exchange %s has no order or trade history", exchange)
+	}
+
+	logrus.WithFields(logrus.Fields{"exchange": exchange, "from": options.from, "to": options.to}).Info("Collecting Orders")
+	collected, err := services.importer.Collect(ctx, history, trades, options.from, options.to)
+	if err != nil {
+		return nil, fmt.Errorf("could not collect orders from %s: %w", exchange, err)
+	}
+	return collected, nil
+}
+
+// readCSV reads the orders of the CSV closed between the dates, malformed
+// rows fail the import unless they are skipped.
+func readCSV(options *Options) ([]orders.OrderComplete, error) {
+	file, err := os.Open(options.csvPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	logrus.WithField("csv", options.csvPath).Info("Reading Orders")
+	read, err := importer.ReadCSV(file, options.profile)
+
+	var rowErrors importer.RowErrors
+	if errors.As(err, &rowErrors) && options.skipInvalid {
+		for _, rowErr := range rowErrors {
+			logrus.WithField("row", rowErr.Row).WithError(rowErr.Err).Warn("Skipping Malformed Row")
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", options.csvPath, err)
+	}
+
+	within := make([]orders.OrderComplete, 0, len(read))
+	for _, order := range read {
+		closedAt := time.Unix(int64(order.CloseTime), 0)
+		if closedAt.Before(options.from) || !closedAt.Before(options.to) {
+			continue
+		}
+		within = append(within, order)
+	}
+	return within, nil
+}
+
+// WriteReport writes the report as a summary or as json.
+func WriteReport(out io.Writer, report *Report, format string) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case FormatText:
+	default:
+		return errors.New("unsupported format " + format)
+	}
+
+	verb := "imported"
+	if report.DryRun {
+		verb = "would import"
+	}
+
+	fmt.Fprintf(out, "%s: %d collected, %s %d, %d already processed, %d trades of processed orders, %d duplicates\n",
+		report.Exchange,
+		report.Collected,
+		verb,
+		len(report.Imported),
+		len(report.Existing),
+		len(report.Matched),
+		len(report.Duplicates),
+	)
+
+	if report.GlueJobID != "" {
+		fmt.Fprintf(out, "glue job %s submitted\n", report.GlueJobID)
+	}
+	return nil
+}
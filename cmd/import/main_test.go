@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/importer"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var january = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Orderer without an order history
+type Orderer struct{}
+
+func (o Orderer) MakeOrder(ctx context.Context, order *configuration.DCAOrder) (*orders.OrderFufilled, error) {
+	return nil, nil
+}
+
+func (o Orderer) ProcessTransaction(ctx context.Context, transactionsIds ...string) (*[]orders.OrderComplete, error) {
+	return nil, nil
+}
+
+func (o Orderer) CancelOrder(ctx context.Context, transactionID string) error {
+	return nil
+}
+
+// Orderer with a fixed order history
+type HistoryOrderer struct {
+	Orderer
+	closed []orders.OrderComplete
+}
+
+func (h HistoryOrderer) GetClosedOrders(ctx context.Context, start time.Time, end time.Time) ([]orders.OrderComplete, error) {
+	return h.closed, nil
+}
+
+// Orderer Factory
+type StaticOrdererFactory struct {
+	orderers map[string]orders.Orderer
+}
+
+func (s StaticOrdererFactory) GetOrderers(ctx context.Context, ssm pkg.SSMAccess) (*map[string]orders.Orderer, error) {
+	return &s.orderers, nil
+}
+
+// Calls records what was written to S3 and submitted to Glue
+type Calls struct {
+	written   []string
+	submitted []string
+}
+
+func setup() (*DCAServices, *AppConfig, *Calls) {
+	calls := &Calls{}
+	s3Access := &pkg.MockS3Access{}
+	s3Access.On("ListObjectsV2", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListObjectsV2Output{Contents: []s3types.Object{
+		{Key: aws.String("processed/exchange=kraken/TX-DONE.json")},
+	}}, nil)
+	s3Access.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		calls.written = append(calls.written, *input.Key)
+		return true
+	}), mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	glueAccess := &pkg.MockGlueAccess{}
+	glueAccess.On("StartJobRun", mock.Anything, mock.MatchedBy(func(input *glue.StartJobRunInput) bool {
+		calls.submitted = append(calls.submitted, input.Arguments["--input_path"])
+		return input.Arguments["--write_operation"] == DefaultWriteOperation
+	}), mock.Anything).Return(&glue.StartJobRunOutput{JobRunId: aws.String("jr_1")}, nil)
+
+	kraken := HistoryOrderer{closed: []orders.OrderComplete{
+		{TransactionID: "TX-DONE", ExchangeStatus: "closed", Volume: decimal.NewFromInt(1)},
+		{TransactionID: "TX-MANUAL", ExchangeStatus: "closed", Volume: decimal.NewFromInt(2)},
+	}}
+
+	services := &DCAServices{
+		s3Access:       s3Access,
+		ssmAccess:      &pkg.MockSSMClient{},
+		glueAccess:     glueAccess,
+		ordererFactory: StaticOrdererFactory{orderers: map[string]orders.Orderer{"kraken": kraken, "other": Orderer{}}},
+		importer:       importer.S3Importer{},
+	}
+
+	appConfig := &AppConfig{s3bucket: "bucket"}
+	appConfig.transactions.processedS3TransactionPrefix = "processed"
+	appConfig.glue.processTransactionJob = "process_transaction"
+	return services, appConfig, calls
+}
+
+// Ensures orders which were not processed are imported and loaded by a single Glue job
+func TestImport(t *testing.T) {
+	services, appConfig, calls := setup()
+	options, err := parseOptions("kraken", "2013-01-01", "2022-01-01")
+	assert.Nil(t, err)
+	options.glue = true
+
+	report, err := Import(context.Background(), services, appConfig, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Collected)
+	assert.Equal(t, []string{"TX-MANUAL"}, report.Imported)
+	assert.Equal(t, []string{"TX-DONE"}, report.Existing)
+	assert.Equal(t, "jr_1", report.GlueJobID)
+	assert.Equal(t, []string{"processed/exchange=kraken/TX-MANUAL.json"}, calls.written)
+	assert.Equal(t, []string{"s3a://bucket/processed/exchange=kraken/"}, calls.submitted)
+}
+
+// Ensures a dry run neither writes nor submits a Glue job
+func TestImportDryRun(t *testing.T) {
+	services, appConfig, calls := setup()
+	options, _ := parseOptions("kraken", "2013-01-01", "2022-01-01")
+	options.glue = true
+	options.dryRun = true
+
+	report, err := Import(context.Background(), services, appConfig, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"TX-MANUAL"}, report.Imported)
+	assert.Empty(t, calls.written)
+	assert.Empty(t, calls.submitted)
+
+	var out bytes.Buffer
+	assert.Nil(t, WriteReport(&out, report, FormatText))
+	assert.Equal(t, "kraken: 2 collected, would import 1, 1 already processed, 0 trades of processed orders, 0 duplicates\n", out.String())
+}
+
+// Ensures exchanges which are missing or have no history are rejected
+func TestImportExchanges(t *testing.T) {
+	services, appConfig, _ := setup()
+
+	options, _ := parseOptions("other", "2013-01-01", "2022-01-01")
+	_, err := Import(context.Background(), services, appConfig, options)
+	assert.EqualError(t, err, "exchange other has no order or trade history")
+
+	options.exchange = "unknown"
+	_, err = Import(context.Background(), services, appConfig, options)
+	assert.EqualError(t, err, "exchange unknown was not configured")
+
+	options.exchange = "kraken"
+	appConfig.transactions.processedS3TransactionPrefix = ""
+	_, err = Import(context.Background(), services, appConfig, options)
+	assert.NotNil(t, err)
+}
+
+// Ensures a csv is imported within the dates and malformed rows fail the import unless skipped
+func TestImportCSV(t *testing.T) {
+	services, appConfig, calls := setup()
+	csvPath := filepath.Join(t.TempDir(), "trades.csv")
+	assert.Nil(t, os.WriteFile(csvPath, []byte(`transaction_id,time,type,pair,price,volume,fee
+TX-DONE,2021-01-01,buy,XBTGBP,30000,0.01,1
+TX-CSV,2021-01-02,buy,XBTGBP,30000,0.01,1
+TX-LATER,2022-06-01,buy,XBTGBP,30000,0.01,1
+TX-BAD,2021-01-03,buy,XBTGBP,30000,,1
+`), 0600))
+
+	options, _ := parseOptions("", "2013-01-01", "2022-01-01")
+	options.csvPath = csvPath
+	options.profile, _ = loadProfile("custom", "")
+
+	_, err := Import(context.Background(), services, appConfig, options)
+	assert.EqualError(t, err, "an exchange is required to import "+csvPath)
+
+	options.exchange = "kraken"
+	_, err = Import(context.Background(), services, appConfig, options)
+	assert.EqualError(t, err, "could not read "+csvPath+": 1 malformed rows\nrow 5: missing volume")
+	assert.Empty(t, calls.written)
+
+	options.skipInvalid = true
+	report, err := Import(context.Background(), services, appConfig, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Collected)
+	assert.Equal(t, []string{"TX-CSV"}, report.Imported)
+	assert.Equal(t, []string{"TX-DONE"}, report.Existing)
+	assert.Equal(t, []string{"processed/exchange=kraken/TX-CSV.json"}, calls.written)
+}
+
+// Ensures trades of a ledger are matched against the orders already processed
+func TestImportLedger(t *testing.T) {
+	services, appConfig, calls := setup()
+	services.s3Access.(*pkg.MockS3Access).On("GetObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(
+		`{"transaction_id": "TX-DONE", "exchange_status": "closed", "pair": "XBTGBP", "type": "buy", "volume": "0.01", "open_time": 1609556644, "close_time": 1609556645}`,
+	))}, nil)
+
+	csvPath := filepath.Join(t.TempDir(), "ledgers.csv")
+	assert.Nil(t, os.WriteFile(csvPath, []byte(`"txid","refid","time","type","subtype","aclass","asset","amount","fee","balance"
+"L1","T1","2021-01-02 03:04:05","trade","","currency","ZGBP",-300.0000,0.7800,700.0000
+"L2","T1","2021-01-02 03:04:05","trade","","currency","XXBT",0.0100000000,0.0000000000,0.0100000000
+"L3","T2","2021-01-03 00:00:00","trade","","currency","XXBT",-0.0050000000,0.0000000000,0.0050000000
+"L4","T2","2021-01-03 00:00:00","trade","","currency","ZGBP",160.0000,0.4000,859.6000
+`), 0600))
+
+	options, _ := parseOptions("", "2013-01-01", "2022-01-01")
+	options.csvPath = csvPath
+	options.profile, _ = loadProfile("kraken-ledger", "")
+
+	report, err := Import(context.Background(), services, appConfig, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"T1"}, report.Matched)
+	assert.Equal(t, []string{"T2"}, report.Imported)
+	assert.Equal(t, []string{"processed/exchange=kraken/T2.json"}, calls.written)
+}
+
+// Ensures mappings are only read for the custom profile
+func TestLoadProfile(t *testing.T) {
+	profile, err := loadProfile("coinbase", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "coinbase", profile.Exchange)
+
+	_, err = loadProfile("binance", "")
+	assert.EqualError(t, err, "unknown profile binance")
+
+	_, err = loadProfile("coinbase", "mapping.json")
+	assert.EqualError(t, err, "a mapping can only be given for the custom profile")
+}
+
+// Ensures the dates are inclusive and must be in order
+func TestParseOptions(t *testing.T) {
+	options, err := parseOptions("kraken", "2022-01-01", "2022-01-07")
+	assert.Nil(t, err)
+	assert.Equal(t, january, options.from)
+	assert.Equal(t, january.AddDate(0, 0, 7), options.to)
+
+	_, err = parseOptions("kraken", "2022-01-07", "2022-01-01")
+	assert.EqualError(t, err, "to 2022-01-01 is before from 2022-01-07")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/overrides"
+	"github.com/sirupsen/logrus"
+)
+
+const usage = `usage:
+  overrides pause [-until yyyy-mm-dd] [-reason text] <order id>
+  overrides resume <order id>
+  overrides list`
+
+// DCAServices contains all services to be injected into logic.
+type DCAServices struct {
+	s3Access      pkg.S3Access
+	configSource  configuration.DCAConfigurationSource
+	overrideStore overrides.Store
+}
+
+// AppConfig contains all configuration to be injected into logic
+type AppConfig struct {
+	s3bucket      string
+	dcaConfigPath string
+	overridesPath string
+}
+
+func main() {
+	logrus.SetOutput(os.Stderr)
+	logrus.SetFormatter(&logrus.TextFormatter{})
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not retrieve default aws config")
+	}
+
+	services := &DCAServices{
+		s3Access:      pkg.S3{Client: s3.NewFromConfig(awsConfig)},
+		configSource:  configuration.DCAConfiguration{},
+		overrideStore: overrides.S3Store{},
+	}
+	appConfig := &AppConfig{
+		s3bucket:      os.Getenv(configuration.EnvS3Bucket),
+		dcaConfigPath: os.Getenv(configuration.EnvS3ConfigPath),
+		overridesPath: os.Getenv(configuration.EnvS3Overrides),
+	}
+
+	if err := run(context.Background(), services, appConfig, os.Args[1:], os.Stdout, time.Now()); err != nil {
+		logrus.WithError(err).Error("Could not update overrides")
+		os.Exit(1)
+	}
+}
+
+// run runs the command given by the arguments.
+func run(ctx context.Context, services *DCAServices, config *AppConfig, args []string, out io.Writer, now time.Time) error {
+	if config.overridesPath == "" {
+		return fmt.Errorf("%s must be set", configuration.EnvS3Overrides)
+	}
+
+	switch args[0] {
+	case "pause":
+		flags := flag.NewFlagSet("pause", flag.ContinueOnError)
+		until := flags.String("until", "", "the date to resume the order on (yyyy-mm-dd), paused indefinitely by default")
+		reason := flags.String("reason", "", "why the order is paused")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return errors.New(usage)
+		}
+		return Pause(ctx, services, config, flags.Arg(0), *until, *reason, now)
+
+	case "resume":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		return Resume(ctx, services, config, args[1])
+
+	case "list":
+		return List(ctx, services, config, out, now)
+
+	default:
+		return fmt.Errorf("unknown command %s\n%s", args[0], usage)
+	}
+}
+
+// Pause pauses the order with the ID, which must be an order of the configuration.
+func Pause(ctx context.Context, services *DCAServices, config *AppConfig, id string, until string, reason string, now time.Time) error {
+	dcaConf, err := services.configSource.GetDCAConfiguration(ctx, services.s3Access, &config.s3bucket, &config.dcaConfigPath)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, order := range dcaConf.Orders {
+		found = found || order.ID == id
+	}
+	if !found {
+		return fmt.Errorf("no order with id %s in the configuration", id)
+	}
+
+	current, err := services.overrideStore.GetOverrides(ctx, services.s3Access, config.s3bucket, config.overridesPath)
+	if err != nil {
+		return err
+	}
+
+	if err := current.Pause(id, until, reason, now); err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{"id": id, "until": until, "reason": reason}).Info("Pausing Order")
+	return services.overrideStore.PutOverrides(ctx, services.s3Access, config.s3bucket, config.overridesPath, current)
+}
+
+// Resume removes the pause of the order with the ID.
+func Resume(ctx context.Context, services *DCAServices, config *AppConfig, id string) error {
+	current, err := services.overrideStore.GetOverrides(ctx, services.s3Access, config.s3bucket, config.overridesPath)
+	if err != nil {
+		return err
+	}
+
+	if !current.Resume(id) {
+		return fmt.Errorf("order %s is not paused", id)
+	}
+
+	logrus.WithField("id", id).Info("Resuming Order")
+	return services.overrideStore.PutOverrides(ctx, services.s3Access, config.s3bucket, config.overridesPath, current)
+}
+
+// List writes every pause along with whether it still applies.
+func List(ctx context.Context, services *DCAServices, config *AppConfig, out io.Writer, now time.Time) error {
+	current, err := services.overrideStore.GetOverrides(ctx, services.s3Access, config.s3bucket, config.overridesPath)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tACTIVE\tUNTIL\tREASON\tPAUSED AT")
+	for _, id := range current.IDs() {
+		pause := current.Pauses[id]
+		until := pause.Until
+		if until == "" {
+			until = "-"
+		}
+
+		fmt.Fprintf(writer, "%s\t%t\t%s\t%s\t%s\n", id, pause.IsActive(now), until, pause.Reason, pause.PausedAt.Format(time.RFC3339))
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/overrides"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2022, 1, 7, 6, 0, 0, 0, time.UTC)
+
+// DCA Configuration
+type StaticConfiguration struct {
+	config *configuration.DCAConfig
+}
+
+func (s StaticConfiguration) GetDCAConfiguration(ctx context.Context, s3Client pkg.S3Access, s3Bucket *string, s3ConfigPath *string) (*configuration.DCAConfig, error) {
+	return s.config, nil
+}
+
+// Override Store which keeps the overrides in memory
+type MemoryStore struct {
+	saved *overrides.Overrides
+}
+
+func (m *MemoryStore) GetOverrides(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Key string) (*overrides.Overrides, error) {
+	if m.saved == nil {
+		return overrides.New(), nil
+	}
+	return m.saved, nil
+}
+
+func (m *MemoryStore) PutOverrides(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Key string, o *overrides.Overrides) error {
+	m.saved = o
+	return nil
+}
+
+func setup() (*DCAServices, *AppConfig, *MemoryStore) {
+	store := &MemoryStore{}
+	services := &DCAServices{
+		configSource: StaticConfiguration{config: &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+			{ID: "btc-weekly", Pair: "BTCGBP"},
+			{ID: "eth-weekly", Pair: "ETHGBP"},
+		}}},
+		overrideStore: store,
+	}
+
+	return services, &AppConfig{s3bucket: "bucket", dcaConfigPath: "config.json", overridesPath: "overrides.json"}, store
+}
+
+// Ensures orders of the configuration are paused
+// and resumed and unknown orders are rejected
+func TestPauseResume(t *testing.T) {
+	services, appConfig, store := setup()
+	ctx := context.Background()
+
+	assert.Nil(t, run(ctx, services, appConfig, []string{"pause", "-until", "2022-02-01", "-reason", "holiday", "btc-weekly"}, nil, now))
+	assert.Equal(t, overrides.Pause{Until: "2022-02-01", Reason: "holiday", PausedAt: now}, store.saved.Pauses["btc-weekly"])
+
+	assert.EqualError(t, run(ctx, services, appConfig, []string{"pause", "ada-weekly"}, nil, now), "no order with id ada-weekly in the configuration")
+	assert.NotNil(t, run(ctx, services, appConfig, []string{"pause", "-until", "soon", "eth-weekly"}, nil, now))
+
+	assert.Nil(t, run(ctx, services, appConfig, []string{"resume", "btc-weekly"}, nil, now))
+	assert.Equal(t, 0, len(store.saved.Pauses))
+	assert.EqualError(t, run(ctx, services, appConfig, []string{"resume", "btc-weekly"}, nil, now), "order btc-weekly is not paused")
+}
+
+// Ensures pauses are listed with whether they still apply
+func TestList(t *testing.T) {
+	services, appConfig, store := setup()
+	store.saved = overrides.New()
+	assert.Nil(t, store.saved.Pause("btc-weekly", "", "holiday", now))
+	assert.Nil(t, store.saved.Pause("eth-weekly", "2022-01-01", "", now))
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, run(context.Background(), services, appConfig, []string{"list"}, out, now))
+
+	expected := "" +
+		"ID          ACTIVE  UNTIL       REASON   PAUSED AT\n" +
+		"btc-weekly  true    -           holiday  2022-01-07T06:00:00Z\n" +
+		"eth-weekly  false   2022-01-01           2022-01-07T06:00:00Z\n"
+	assert.Equal(t, expected, out.String())
+}
+
+// Ensures commands need the overrides path and a known command
+func TestRunInvalid(t *testing.T) {
+	services, appConfig, _ := setup()
+
+	assert.NotNil(t, run(context.Background(), services, appConfig, []string{"unpause", "btc-weekly"}, nil, now))
+	assert.NotNil(t, run(context.Background(), services, appConfig, []string{"pause"}, nil, now))
+
+	appConfig.overridesPath = ""
+	assert.EqualError(t, run(context.Background(), services, appConfig, []string{"list"}, nil, now), "DCA_OVERRIDES_S3_PATH must be set")
+}
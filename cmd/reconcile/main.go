@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/kiran94/dca-manager/pkg/reconcile"
+	"github.com/sirupsen/logrus"
+)
+
+// Output formats of the reconciliation
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// DCAServices contains all services to be injected into logic.
+type DCAServices struct {
+	s3Access       pkg.S3Access
+	ssmAccess      pkg.SSMAccess
+	sqsAccess      pkg.SQSAccess
+	ordererFactory orders.OrdererFactory
+	reconciler     reconcile.Reconciler
+	submitter      orders.PendingOrderQueue
+}
+
+// AppConfig contains all configuration to be injected into logic
+type AppConfig struct {
+	s3bucket     string
+	sqsURL       string
+	transactions struct {
+		pendingS3TransactionPrefix   string
+		processedS3TransactionPrefix string
+	}
+}
+
+// Options are what to reconcile and whether to requeue the orphans found.
+type Options struct {
+	exchange string
+	from     time.Time
+	to       time.Time
+	requeue  bool
+}
+
+func main() {
+	today := time.Now().UTC().Format(configuration.DateLayout)
+	from := flag.String("from", time.Now().UTC().AddDate(0, 0, -7).Format(configuration.DateLayout), "the first date to reconcile (yyyy-mm-dd)")
+	to := flag.String("to", today, "the last date to reconcile (yyyy-mm-dd)")
+	exchange := flag.String("exchange", "", "the exchange to reconcile, defaults to every exchange")
+	requeue := flag.Bool("requeue", false, "submit orphans closed on the exchange to the pending orders queue")
+	format := flag.String("format", FormatText, "output format: text or json")
+	flag.Parse()
+
+	logrus.SetOutput(os.Stderr)
+	logrus.SetFormatter(&logrus.TextFormatter{})
+
+	options, err := parseOptions(*exchange, *from, *to, *requeue)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not retrieve default aws config")
+	}
+
+	services := &DCAServices{
+		s3Access:       pkg.S3{Client: s3.NewFromConfig(awsConfig)},
+		ssmAccess:      pkg.SSM{Client: ssm.NewFromConfig(awsConfig)},
+		sqsAccess:      pkg.SQS{Client: sqs.NewFromConfig(awsConfig)},
+		ordererFactory: orders.OrdererFac{},
+		reconciler:     reconcile.Ledger{},
+		submitter:      orders.PendingOrderSubmitter{},
+	}
+
+	appConfig := &AppConfig{
+		s3bucket: os.Getenv(configuration.EnvS3Bucket),
+		sqsURL:   os.Getenv(configuration.EnvSQSPendingOrdersQueue),
+	}
+	appConfig.transactions.pendingS3TransactionPrefix = os.Getenv(configuration.EnvS3PendingTransaction)
+	appConfig.transactions.processedS3TransactionPrefix = os.Getenv(configuration.EnvS3ProcessedTransaction)
+
+	reports, err := Reconcile(context.Background(), services, appConfig, options)
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not reconcile orders")
+	}
+
+	if err := WriteReports(os.Stdout, reports, *format); err != nil {
+		logrus.WithError(err).Error("Could not write reconciliation")
+		os.Exit(1)
+	}
+}
+
+// parseOptions reads the inclusive dates of the range to reconcile.
+func parseOptions(exchange string, from string, to string, requeue bool) (*Options, error) {
+	start, err := time.Parse(configuration.DateLayout, from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from %s, expected %s", from, configuration.DateLayout)
+	}
+
+	end, err := time.Parse(configuration.DateLayout, to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to %s, expected %s", to, configuration.DateLayout)
+	}
+
+	if end.Before(start) {
+		return nil, fmt.Errorf("to %s is before from %s", to, from)
+	}
+
+	return &Options{exchange: exchange, from: start, to: end.AddDate(0, 0, 1), requeue: requeue}, nil
+}
+
+// Reconcile reconciles every exchange which has an order history,
+// requeuing the orphans closed on the exchange when asked to.
+func Reconcile(ctx context.Context, services *DCAServices, config *AppConfig, options *Options) ([]*reconcile.Report, error) {
+	if config.transactions.pendingS3TransactionPrefix == "" || config.transactions.processedS3TransactionPrefix == "" {
+		return nil, fmt.Errorf("%s and %s must be set", configuration.EnvS3PendingTransaction, configuration.EnvS3ProcessedTransaction)
+	}
+	if options.requeue && config.sqsURL == "" {
+		return nil, fmt.Errorf("%s must be set to requeue orders", configuration.EnvSQSPendingOrdersQueue)
+	}
+
+	orderers, err := services.ordererFactory.GetOrderers(ctx, services.ssmAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	exchanges := make([]string, 0, len(*orderers))
+	for exchange := range *orderers {
+		if options.exchange == "" || options.exchange == exchange {
+			exchanges = append(exchanges, exchange)
+		}
+	}
+	sort.Strings(exchanges)
+
+	if len(exchanges) == 0 {
+		return nil, fmt.Errorf("exchange %s was not configured", options.exchange)
+	}
+
+	reports := []*reconcile.Report{}
+	for _, exchange := range exchanges {
+		history, ok := (*orderers)[exchange].(orders.OrderHistory)
+		if !ok {
+			logrus.WithField("exchange", exchange).Warn("Exchange has no order history, skipping")
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{"exchange": exchange, "from": options.from, "to": options.to}).Info("Reconciling Orders")
+		report, err := services.reconciler.Reconcile(ctx, services.s3Access, history, reconcile.Options{
+			S3Bucket:        config.s3bucket,
+			PendingPrefix:   config.transactions.pendingS3TransactionPrefix,
+			ProcessedPrefix: config.transactions.processedS3TransactionPrefix,
+			Exchange:        exchange,
+			From:            options.from,
+			To:              options.to,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if options.requeue {
+			requeued, err := services.reconciler.Requeue(ctx, services.sqsAccess, services.submitter, config.sqsURL, config.s3bucket, report)
+			logrus.WithFields(logrus.Fields{"exchange": exchange, "requeued": requeued}).Info("Requeued Orphans")
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// WriteReports writes the reports as a summary with tables of the issues or as json.
+func WriteReports(out io.Writer, reports []*reconcile.Report, format string) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	case FormatText:
+	default:
+		return errors.New("unsupported format " + format)
+	}
+
+	for _, report := range reports {
+		fmt.Fprintf(out, "%s from %s to %s: %d pending, %d processed, %d closed on the exchange, %d issues\n",
+			report.Exchange,
+			report.From.Format(configuration.DateLayout),
+			report.To.AddDate(0, 0, -1).Format(configuration.DateLayout),
+			report.Pending,
+			report.Processed,
+			report.Closed,
+			report.Issues(),
+		)
+
+		writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		if len(report.Orphans) > 0 {
+			fmt.Fprintln(writer, "\nOrphans, pending but never processed")
+			fmt.Fprintln(writer, "TRANSACTION ID\tPENDING SINCE\tEXCHANGE STATUS\tS3 KEY")
+			for _, orphan := range report.Orphans {
+				status := orphan.ExchangeStatus
+				if status == "" {
+					status = "not closed"
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", orphan.TransactionID, orphan.LastModified.Format(time.RFC3339), status, orphan.S3Key)
+			}
+		}
+
+		if len(report.Duplicates) > 0 {
+			fmt.Fprintln(writer, "\nDuplicates, recorded more than once")
+			fmt.Fprintln(writer, "STAGE\tTRANSACTION ID\tS3 KEYS")
+			for _, duplicate := range report.Duplicates {
+				fmt.Fprintf(writer, "%s\t%s\t%s\n", duplicate.Stage, duplicate.TransactionID, strings.Join(duplicate.S3Keys, ", "))
+			}
+		}
+
+		if len(report.External) > 0 {
+			fmt.Fprintln(writer, "\nExternal trades, made outside of the DCA manager")
+			fmt.Fprintln(writer, "TRANSACTION ID\tCLOSED AT\tPAIR\tTYPE\tVOLUME\tPRICE")
+			for _, order := range report.External {
+				closedAt := time.Unix(int64(order.CloseTime), 0).UTC().Format(time.RFC3339)
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", order.TransactionID, closedAt, order.Pair, order.Type, order.Volume, order.Price)
+			}
+		}
+
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/kiran94/dca-manager/pkg/reconcile"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var january = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Orderer without an order history
+type Orderer struct{}
+
+func (o Orderer) MakeOrder(ctx context.Context, order *configuration.DCAOrder) (*orders.OrderFufilled, error) {
+	return nil, nil
+}
+
+func (o Orderer) ProcessTransaction(ctx context.Context, transactionsIds ...string) (*[]orders.OrderComplete, error) {
+	return nil, nil
+}
+
+func (o Orderer) CancelOrder(ctx context.Context, transactionID string) error {
+	return nil
+}
+
+// Orderer with a fixed order history
+type HistoryOrderer struct {
+	Orderer
+	closed []orders.OrderComplete
+}
+
+func (h HistoryOrderer) GetClosedOrders(ctx context.Context, start time.Time, end time.Time) ([]orders.OrderComplete, error) {
+	return h.closed, nil
+}
+
+// Orderer Factory
+type StaticOrdererFactory struct {
+	orderers map[string]orders.Orderer
+}
+
+func (s StaticOrdererFactory) GetOrderers(ctx context.Context, ssm pkg.SSMAccess) (*map[string]orders.Orderer, error) {
+	return &s.orderers, nil
+}
+
+// Pending Order Queue which records the submitted orders
+type Submitter struct {
+	submitted []string
+}
+
+func (s *Submitter) SubmitPendingOrder(ctx context.Context, sc pkg.SQSAccess, po *orders.PendingOrders, exchange string, real bool, sqsQueue string) error {
+	s.submitted = append(s.submitted, exchange+"/"+po.TransactionID)
+	return nil
+}
+
+func setup() (*DCAServices, *AppConfig, *Submitter) {
+	s3Access := &pkg.MockS3Access{}
+	s3Access.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == "pending/"
+	}), mock.Anything).Return(&s3.ListObjectsV2Output{Contents: []s3types.Object{
+		{Key: aws.String("pending/exchange=kraken/TX-ORPHAN.json"), LastModified: aws.Time(january.Add(time.Hour))},
+	}}, nil)
+	s3Access.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == "processed/"
+	}), mock.Anything).Return(&s3.ListObjectsV2Output{}, nil)
+
+	kraken := HistoryOrderer{closed: []orders.OrderComplete{
+		{TransactionID: "TX-ORPHAN", ExchangeStatus: "closed", Volume: decimal.NewFromInt(1)},
+		{TransactionID: "TX-MANUAL", ExchangeStatus: "closed", Pair: "XXBTZGBP", Type: "buy", Volume: decimal.NewFromInt(2), Price: decimal.NewFromInt(30000)},
+	}}
+
+	submitter := &Submitter{}
+	services := &DCAServices{
+		s3Access:       s3Access,
+		ssmAccess:      &pkg.MockSSMClient{},
+		sqsAccess:      &pkg.MockSQSAccess{},
+		ordererFactory: StaticOrdererFactory{orderers: map[string]orders.Orderer{"kraken": kraken, "other": Orderer{}}},
+		reconciler:     reconcile.Ledger{},
+		submitter:      submitter,
+	}
+
+	appConfig := &AppConfig{s3bucket: "bucket", sqsURL: "queue"}
+	appConfig.transactions.pendingS3TransactionPrefix = "pending"
+	appConfig.transactions.processedS3TransactionPrefix = "processed"
+	return services, appConfig, submitter
+}
+
+// Ensures every exchange with an order history is reconciled
+// and orphans are only requeued when asked to
+func TestReconcile(t *testing.T) {
+	services, appConfig, submitter := setup()
+	options, err := parseOptions("", "2022-01-01", "2022-01-07", false)
+	assert.Nil(t, err)
+
+	reports, err := Reconcile(context.Background(), services, appConfig, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(reports))
+	assert.Equal(t, "kraken", reports[0].Exchange)
+	assert.Equal(t, 2, reports[0].Issues())
+	assert.Empty(t, submitter.submitted)
+
+	options.requeue = true
+	_, err = Reconcile(context.Background(), services, appConfig, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"kraken/TX-ORPHAN"}, submitter.submitted)
+
+	options.exchange = "unknown"
+	_, err = Reconcile(context.Background(), services, appConfig, options)
+	assert.EqualError(t, err, "exchange unknown was not configured")
+}
+
+// Ensures the dates are inclusive and must be in order
+func TestParseOptions(t *testing.T) {
+	options, err := parseOptions("kraken", "2022-01-01", "2022-01-07", true)
+	assert.Nil(t, err)
+	assert.Equal(t, january, options.from)
+	assert.Equal(t, january.AddDate(0, 0, 7), options.to)
+
+	_, err = parseOptions("", "2022-01-07", "2022-01-01", false)
+	assert.EqualError(t, err, "to 2022-01-01 is before from 2022-01-07")
+
+	_, err = parseOptions("", "yesterday", "2022-01-01", false)
+	assert.NotNil(t, err)
+}
+
+// Ensures the issues are written in tables
+func TestWriteReports(t *testing.T) {
+	services, appConfig, _ := setup()
+	options, _ := parseOptions("kraken", "2022-01-01", "2022-01-07", false)
+	reports, err := Reconcile(context.Background(), services, appConfig, options)
+	assert.Nil(t, err)
+
+	var text bytes.Buffer
+	assert.Nil(t, WriteReports(&text, reports, FormatText))
+	lines := strings.Split(text.String(), "\n")
+	assert.Equal(t, "kraken from 2022-01-01 to 2022-01-07: 1 pending, 0 processed, 2 closed on the exchange, 2 issues", lines[0])
+	assert.Contains(t, text.String(), "TX-ORPHAN")
+	assert.Contains(t, text.String(), "TX-MANUAL")
+	assert.NotContains(t, text.String(), "Duplicates")
+
+	var jsonOutput bytes.Buffer
+	assert.Nil(t, WriteReports(&jsonOutput, reports, FormatJSON))
+	assert.Contains(t, jsonOutput.String(), `"transaction_id": "TX-MANUAL"`)
+
+	assert.NotNil(t, WriteReports(&text, reports, "html"))
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/kiran94/dca-manager/pkg/performance"
+	"github.com/sirupsen/logrus"
+)
+
+// DCAServices contains all services to be injected into logic.
+type DCAServices struct {
+	s3Access             pkg.S3Access
+	ssmAccess            pkg.SSMAccess
+	ordererFactory       orders.OrdererFactory
+	processedOrderSource orders.ProcessedOrderSource
+	analyser             performance.Analyser
+}
+
+// AppConfig contains all configuration to be injected into logic
+type AppConfig struct {
+	s3bucket     string
+	transactions struct {
+		processedS3TransactionPrefix string
+	}
+}
+
+func main() {
+	format := flag.String("format", performance.FormatText, "output format: text, json or html")
+	output := flag.String("output", "", "file to write the report to, defaults to stdout")
+	flag.Parse()
+
+	// The report itself is written to stdout
+	logrus.SetOutput(os.Stderr)
+	logrus.SetFormatter(&logrus.TextFormatter{})
+
+	awsConfig, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not retrieve default aws config")
+	}
+
+	services := &DCAServices{
+		s3Access:             pkg.S3{Client: s3.NewFromConfig(awsConfig)},
+		ssmAccess:            pkg.SSM{Client: ssm.NewFromConfig(awsConfig)},
+		ordererFactory:       orders.OrdererFac{},
+		processedOrderSource: orders.ProcessedOrderLoader{},
+		analyser:             performance.Performance{},
+	}
+
+	appConfig := &AppConfig{s3bucket: os.Getenv(configuration.EnvS3Bucket)}
+	appConfig.transactions.processedS3TransactionPrefix = os.Getenv(configuration.EnvS3ProcessedTransaction)
+
+	report, err := GenerateReport(context.Background(), services, appConfig, time.Now())
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not generate report")
+	}
+
+	var writer io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			logrus.WithError(err).Fatal("Could not create output file")
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	if err := report.Write(writer, *format); err != nil {
+		logrus.WithError(err).Error("Could not write report")
+		os.Exit(1)
+	}
+}
+
+// GenerateReport reports the performance of the processed orders
+// of every exchange valued at their current ticker price.
+//
+// Every exchange partition is loaded, including exchanges which were only
+// imported, and holdings on exchanges without an orderer are incomplete.
+func GenerateReport(ctx context.Context, services *DCAServices, config *AppConfig, now time.Time) (*performance.Report, error) {
+	orderers, err := services.ordererFactory.GetOrderers(ctx, services.ssmAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	exchanges, err := listExchanges(ctx, services.s3Access, config.s3bucket, config.transactions.processedS3TransactionPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	processed := map[string][]orders.OrderComplete{}
+	for _, exchange := range exchanges {
+		s3Prefix := fmt.Sprintf(
+			"%s/exchange=%s/",
+			config.transactions.processedS3TransactionPrefix,
+			exchange)
+
+		exchangeOrders, err := services.processedOrderSource.GetProcessedOrders(ctx, services.s3Access, config.s3bucket, s3Prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		processed[exchange] = *exchangeOrders
+	}
+
+	logrus.WithField("exchanges", len(exchanges)).Info("Analysing Performance")
+	return services.analyser.Analyse(ctx, processed, performance.TickerPrices{Orderers: *orderers}, now)
+}
+
+// listExchanges lists the exchange partitions of the processed prefix.
+func listExchanges(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string) ([]string, error) {
+	exchanges := []string{}
+	listPrefix := s3Prefix + "/"
+
+	var continuationToken *string
+	for {
+		listed, err := s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            &s3Bucket,
+			Prefix:            &listPrefix,
+			Delimiter:         aws.String("/"),
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range listed.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(partition.Prefix), listPrefix), "/")
+
+			// Anything else such as the parent orders is skipped
+			if !strings.HasPrefix(name, "exchange=") {
+				continue
+			}
+			exchanges = append(exchanges, strings.TrimPrefix(name, "exchange="))
+		}
+
+		if !listed.IsTruncated {
+			break
+		}
+		continuationToken = listed.NextContinuationToken
+	}
+
+	return exchanges, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/kiran94/dca-manager/pkg/performance"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Orderer with Market Data
+type MockMarketOrderer struct {
+	mock.Mock
+}
+
+func (m *MockMarketOrderer) MakeOrder(ctx context.Context, order *configuration.DCAOrder) (*orders.OrderFufilled, error) {
+	args := m.Called(order)
+	return args.Get(0).(*orders.OrderFufilled), args.Error(1)
+}
+
+func (m *MockMarketOrderer) ProcessTransaction(ctx context.Context, transactionsIds ...string) (*[]orders.OrderComplete, error) {
+	args := m.Called(transactionsIds)
+	return args.Get(0).(*[]orders.OrderComplete), args.Error(1)
+}
+
+func (m *MockMarketOrderer) CancelOrder(ctx context.Context, transactionID string) error {
+	args := m.Called(transactionID)
+	return args.Error(0)
+}
+
+func (m *MockMarketOrderer) GetBalances(ctx context.Context) (map[string]decimal.Decimal, error) {
+	args := m.Called()
+	return args.Get(0).(map[string]decimal.Decimal), args.Error(1)
+}
+
+func (m *MockMarketOrderer) GetTicker(ctx context.Context, pair string) (*orders.Ticker, error) {
+	args := m.Called(pair)
+	return args.Get(0).(*orders.Ticker), args.Error(1)
+}
+
+func (m *MockMarketOrderer) GetOHLC(ctx context.Context, pair string, since time.Time) ([]orders.Candle, error) {
+	args := m.Called(pair, since)
+	return args.Get(0).([]orders.Candle), args.Error(1)
+}
+
+// Orderer Factory
+type MockOrdererFactory struct {
+	mock.Mock
+}
+
+func (m MockOrdererFactory) GetOrderers(ctx context.Context, ssm pkg.SSMAccess) (*map[string]orders.Orderer, error) {
+	args := m.Called(ctx, ssm)
+	return args.Get(0).(*map[string]orders.Orderer), args.Error(1)
+}
+
+// Processed Order Source
+type MockProcessedOrderSource struct {
+	mock.Mock
+}
+
+func (m *MockProcessedOrderSource) GetProcessedOrders(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string) (*[]orders.OrderComplete, error) {
+	args := m.Called(ctx, s3Client, s3Bucket, s3Prefix)
+	return args.Get(0).(*[]orders.OrderComplete), args.Error(1)
+}
+
+// partitions lists the processed prefix as having the partitions
+func partitions(names ...string) *s3.ListObjectsV2Output {
+	listed := &s3.ListObjectsV2Output{}
+	for _, name := range names {
+		listed.CommonPrefixes = append(listed.CommonPrefixes, s3types.CommonPrefix{Prefix: aws.String("s3_processed_prefix/" + name + "/")})
+	}
+	return listed
+}
+
+func setup(apply func(o *MockOrdererFactory, p *MockProcessedOrderSource, s *pkg.MockS3Access)) (*DCAServices, *AppConfig) {
+	appConfig := &AppConfig{s3bucket: "bucket"}
+	appConfig.transactions.processedS3TransactionPrefix = "s3_processed_prefix"
+
+	ordererFactory := &MockOrdererFactory{}
+	processedOrderSource := &MockProcessedOrderSource{}
+	s3Access := &pkg.MockS3Access{}
+	apply(ordererFactory, processedOrderSource, s3Access)
+
+	services := &DCAServices{
+		s3Access:             s3Access,
+		ssmAccess:            &pkg.MockSSMClient{},
+		ordererFactory:       ordererFactory,
+		processedOrderSource: processedOrderSource,
+		analyser:             performance.Performance{},
+	}
+
+	return services, appConfig
+}
+
+// Ensures processed orders of every exchange partition are valued at
+// the last price on the ticker, or are incomplete without an orderer
+func TestGenerateReport(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	kraken := &MockMarketOrderer{}
+	kraken.On("GetTicker", "XXBTZGBP").Return(&orders.Ticker{Pair: "XXBTZGBP", Last: decimal.NewFromInt(200)}, nil)
+
+	processed := []orders.OrderComplete{{
+		TransactionID:  "A",
+		ExchangeStatus: "closed",
+		Pair:           "XXBTZGBP",
+		Type:           "buy",
+		Price:          decimal.NewFromInt(100),
+		Fee:            decimal.NewFromInt(1),
+		Volume:         decimal.NewFromInt(2),
+		CloseTime:      float64(now.AddDate(0, -6, 0).Unix()),
+	}}
+
+	imported := []orders.OrderComplete{{
+		TransactionID:  "B",
+		ExchangeStatus: "closed",
+		Pair:           "BTC-GBP",
+		Type:           "buy",
+		Price:          decimal.NewFromInt(150),
+		Fee:            decimal.NewFromInt(1),
+		Volume:         decimal.NewFromInt(1),
+		CloseTime:      float64(now.AddDate(0, -3, 0).Unix()),
+	}}
+
+	services, appConfig := setup(func(o *MockOrdererFactory, p *MockProcessedOrderSource, s *pkg.MockS3Access) {
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(&map[string]orders.Orderer{"kraken": kraken}, nil)
+		s.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+			return *input.Prefix == "s3_processed_prefix/" && *input.Delimiter == "/"
+		}), mock.Anything).Return(partitions("exchange=coinbase", "exchange=kraken", "parent"), nil)
+		p.On("GetProcessedOrders", mock.Anything, mock.Anything, "bucket", "s3_processed_prefix/exchange=kraken/").Return(&processed, nil)
+		p.On("GetProcessedOrders", mock.Anything, mock.Anything, "bucket", "s3_processed_prefix/exchange=coinbase/").Return(&imported, nil)
+	})
+
+	report, err := GenerateReport(context.Background(), services, appConfig, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(report.Holdings))
+	assert.Equal(t, "coinbase", report.Holdings[0].Exchange)
+	assert.Equal(t, "no price source for exchange coinbase", report.Holdings[0].Incomplete)
+	assert.Equal(t, "kraken", report.Holdings[1].Exchange)
+	assert.Equal(t, "201", report.Holdings[1].Invested.String())
+	assert.Equal(t, "400", report.Holdings[1].Value.String())
+	assert.Equal(t, 1, len(report.Assets))
+	assert.Equal(t, []string{"coinbase", "kraken"}, report.Assets[0].Exchanges)
+	services.processedOrderSource.(*MockProcessedOrderSource).AssertExpectations(t)
+	kraken.AssertExpectations(t)
+}
+
+// Ensures errors loading processed orders are returned
+func TestGenerateReportErrorLoading(t *testing.T) {
+	expectedErr := errors.New("error listing")
+	services, appConfig := setup(func(o *MockOrdererFactory, p *MockProcessedOrderSource, s *pkg.MockS3Access) {
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(&map[string]orders.Orderer{"kraken": &MockMarketOrderer{}}, nil)
+		s.On("ListObjectsV2", mock.Anything, mock.Anything, mock.Anything).Return(partitions("exchange=kraken"), nil)
+		p.On("GetProcessedOrders", mock.Anything, mock.Anything, "bucket", mock.Anything).Return(&[]orders.OrderComplete{}, expectedErr)
+	})
+
+	_, err := GenerateReport(context.Background(), services, appConfig, time.Now())
+
+	assert.Equal(t, expectedErr, err)
+}
+
+// Ensures errors listing the exchange partitions are returned
+func TestGenerateReportErrorListing(t *testing.T) {
+	expectedErr := errors.New("error listing")
+	services, appConfig := setup(func(o *MockOrdererFactory, p *MockProcessedOrderSource, s *pkg.MockS3Access) {
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(&map[string]orders.Orderer{}, nil)
+		s.On("ListObjectsV2", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListObjectsV2Output{}, expectedErr)
+	})
+
+	_, err := GenerateReport(context.Background(), services, appConfig, time.Now())
+
+	assert.Equal(t, expectedErr, err)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/kiran94/dca-manager/pkg/tax"
+	"github.com/sirupsen/logrus"
+)
+
+// Output formats of the tax year
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// DCAServices contains all services to be injected into logic.
+type DCAServices struct {
+	s3Access             pkg.S3Access
+	processedOrderSource orders.ProcessedOrderSource
+}
+
+// AppConfig contains all configuration to be injected into logic
+type AppConfig struct {
+	s3bucket     string
+	transactions struct {
+		processedS3TransactionPrefix string
+	}
+}
+
+func main() {
+	method := flag.String("method", tax.MethodSection104, "cost basis method: section104, fifo or average_cost")
+	year := flag.String("year", tax.TaxYearOf(time.Now()), "the tax year to report e.g 2021/22")
+	format := flag.String("format", FormatCSV, "output format: csv or json")
+	output := flag.String("output", "", "file to write the tax year to, defaults to stdout")
+	flag.Parse()
+
+	// The tax year itself is written to stdout
+	logrus.SetOutput(os.Stderr)
+	logrus.SetFormatter(&logrus.TextFormatter{})
+
+	calculator, err := tax.NewCalculator(*method)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid method")
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not retrieve default aws config")
+	}
+
+	services := &DCAServices{
+		s3Access:             pkg.S3{Client: s3.NewFromConfig(awsConfig)},
+		processedOrderSource: orders.ProcessedOrderLoader{},
+	}
+
+	appConfig := &AppConfig{s3bucket: os.Getenv(configuration.EnvS3Bucket)}
+	appConfig.transactions.processedS3TransactionPrefix = os.Getenv(configuration.EnvS3ProcessedTransaction)
+
+	report, err := CalculateTax(context.Background(), services, appConfig, calculator)
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not calculate tax")
+	}
+
+	for _, pair := range report.SkippedPairs {
+		logrus.WithField("pair", pair).Warnf("Skipped pair not quoted in %s", tax.DefaultQuoteCurrency)
+	}
+
+	var writer io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			logrus.WithError(err).Fatal("Could not create output file")
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	if err := WriteYear(writer, report.Year(*year), *format); err != nil {
+		logrus.WithError(err).Error("Could not write tax year")
+		os.Exit(1)
+	}
+}
+
+// CalculateTax calculates the gains of the processed orders of every exchange.
+//
+// Every exchange partition is loaded, including exchanges which were only
+// imported, as disposals are matched against acquisitions on any exchange.
+func CalculateTax(ctx context.Context, services *DCAServices, config *AppConfig, calculator tax.Calculator) (*tax.Report, error) {
+	if config.s3bucket == "" || config.transactions.processedS3TransactionPrefix == "" {
+		return nil, fmt.Errorf("%s and %s must be set", configuration.EnvS3Bucket, configuration.EnvS3ProcessedTransaction)
+	}
+
+	s3Prefix := fmt.Sprintf("%s/exchange=", config.transactions.processedS3TransactionPrefix)
+	processed, err := services.processedOrderSource.GetProcessedOrders(ctx, services.s3Access, config.s3bucket, s3Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.WithField("orders", len(*processed)).Info("Calculating Tax")
+	return calculator.Calculate(*processed)
+}
+
+// WriteYear writes the disposals of the tax year as csv or json.
+func WriteYear(out io.Writer, year tax.TaxYear, format string) error {
+	switch format {
+	case FormatCSV:
+		return year.WriteCSV(out)
+	case FormatJSON:
+		return year.WriteJSON(out)
+	default:
+		return errors.New("unsupported format " + format)
+	}
+}
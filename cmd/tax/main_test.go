@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/kiran94/dca-manager/pkg/tax"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Processed Order Source
+type MockProcessedOrderSource struct {
+	mock.Mock
+}
+
+func (m *MockProcessedOrderSource) GetProcessedOrders(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string) (*[]orders.OrderComplete, error) {
+	args := m.Called(ctx, s3Client, s3Bucket, s3Prefix)
+	return args.Get(0).(*[]orders.OrderComplete), args.Error(1)
+}
+
+func fill(txid string, pair string, direction string, price int64, closed time.Time) orders.OrderComplete {
+	return orders.OrderComplete{
+		TransactionID:  txid,
+		ExchangeStatus: "closed",
+		Pair:           pair,
+		Type:           direction,
+		Price:          decimal.NewFromInt(price),
+		Fee:            decimal.Zero,
+		Volume:         decimal.NewFromInt(1),
+		CloseTime:      float64(closed.Unix()),
+	}
+}
+
+func setup(processed []orders.OrderComplete, err error) (*DCAServices, *AppConfig) {
+	appConfig := &AppConfig{s3bucket: "bucket"}
+	appConfig.transactions.processedS3TransactionPrefix = "processed"
+
+	processedOrderSource := &MockProcessedOrderSource{}
+	processedOrderSource.On("GetProcessedOrders", mock.Anything, mock.Anything, "bucket", "processed/exchange=").Return(&processed, err)
+
+	services := &DCAServices{
+		s3Access:             &pkg.MockS3Access{},
+		processedOrderSource: processedOrderSource,
+	}
+	return services, appConfig
+}
+
+// Ensures every exchange is included and pairs not quoted in GBP are skipped
+func TestCalculateTax(t *testing.T) {
+	may := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	services, appConfig := setup([]orders.OrderComplete{
+		fill("A", "XXBTZGBP", "buy", 100, may),
+		fill("B", "XXBTZGBP", "sell", 150, may.AddDate(0, 0, 40)),
+		fill("C", "XETHZUSD", "sell", 10, may),
+	}, nil)
+
+	report, err := CalculateTax(context.Background(), services, appConfig, tax.Section104{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"XETHZUSD"}, report.SkippedPairs)
+
+	var out bytes.Buffer
+	assert.Nil(t, WriteYear(&out, report.Year("2021/22"), FormatJSON))
+
+	year := tax.TaxYear{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &year))
+	assert.Equal(t, "50", year.NetGain.String())
+	assert.Equal(t, []string{"XETHZUSD"}, year.SkippedPairs)
+
+	assert.EqualError(t, WriteYear(&out, year, "xml"), "unsupported format xml")
+}
+
+// Ensures the report fails when the processed orders cannot be loaded
+func TestCalculateTaxError(t *testing.T) {
+	services, appConfig := setup(nil, errors.New("access denied"))
+
+	_, err := CalculateTax(context.Background(), services, appConfig, tax.FIFO{})
+	assert.EqualError(t, err, "access denied")
+
+	appConfig.s3bucket = ""
+	_, err = CalculateTax(context.Background(), services, appConfig, tax.FIFO{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,530 @@
+// Package api serves an admin API over HTTP to inspect
+// runs and orders, trigger runs and manage the configuration.
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/executor"
+	"github.com/kiran94/dca-manager/pkg/logging"
+	"github.com/kiran94/dca-manager/pkg/runs"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRunLimit = 20
+	maxRunLimit     = 100
+	maxBodyBytes    = 1 << 20
+)
+
+// Statuses of an order looked up by transaction ID.
+const (
+	OrderPending   string = "pending"
+	OrderProcessed string = "processed"
+)
+
+// Trigger starts a run of the orders, faking the orders on a dry run.
+type Trigger func(ctx context.Context, triggerTime time.Time, dryRun bool) (*runs.RunSummary, error)
+
+// RunOrders triggers runs with the executor.
+func RunOrders(services *executor.DCAServices, config *executor.AppConfig) Trigger {
+	return func(ctx context.Context, triggerTime time.Time, dryRun bool) (*runs.RunSummary, error) {
+		runConfig := config
+		if dryRun {
+			runConfig = config.DryRun()
+		}
+
+		return executor.RunOrders(ctx, services, runConfig, triggerTime)
+	}
+}
+
+// Services contains all services to be injected into the API.
+type Services struct {
+	s3Access  pkg.S3Access
+	runLoader runs.Loader
+	trigger   Trigger
+}
+
+// Config contains all configuration to be injected into the API.
+//
+// Without a token every request is rejected.
+type Config struct {
+	token           string
+	s3Bucket        string
+	dcaConfigPath   string
+	runsPrefix      string
+	pendingPrefix   string
+	processedPrefix string
+}
+
+// NewServices creates the services used by the API on AWS
+// where runs are started with the trigger.
+func NewServices(s3Access pkg.S3Access, trigger Trigger) *Services {
+	return &Services{
+		s3Access:  s3Access,
+		runLoader: runs.S3Loader{},
+		trigger:   trigger,
+	}
+}
+
+// NewConfig creates the configuration from the environment.
+func NewConfig() *Config {
+	return &Config{
+		token:           os.Getenv(configuration.EnvAPIToken),
+		s3Bucket:        os.Getenv(configuration.EnvS3Bucket),
+		dcaConfigPath:   os.Getenv(configuration.EnvS3ConfigPath),
+		runsPrefix:      os.Getenv(configuration.EnvS3Runs),
+		pendingPrefix:   os.Getenv(configuration.EnvS3PendingTransaction),
+		processedPrefix: os.Getenv(configuration.EnvS3ProcessedTransaction),
+	}
+}
+
+// Server serves the admin API.
+type Server struct {
+	services *Services
+	config   *Config
+}
+
+// NewServer creates the admin API.
+func NewServer(ctx context.Context, services *Services, config *Config) *Server {
+	if config.token == "" {
+		logging.FromContext(ctx).Warnf("%s is not set, every API request will be rejected", configuration.EnvAPIToken)
+	}
+
+	return &Server{services: services, config: config}
+}
+
+// ErrorResponse is the body of every failed request.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// OrderResponse is an order found by its transaction ID.
+type OrderResponse struct {
+	TransactionID string          `json:"transaction_id"`
+	Exchange      string          `json:"exchange"`
+	Status        string          `json:"status"`
+	Order         json.RawMessage `json:"order"`
+}
+
+// RunRequest is the body to trigger a run.
+type RunRequest struct {
+	DryRun bool `json:"dry_run"`
+}
+
+// EnabledRequest is the body to enable or disable an order.
+type EnabledRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// ValidateResponse is the outcome of validating a configuration.
+type ValidateResponse struct {
+	Valid    bool     `json:"valid"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// ServeHTTP authenticates the request and routes it to the endpoint.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorised(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, r, http.StatusUnauthorized, errors.New("unauthorised"))
+		return
+	}
+
+	ctx := logging.WithFields(r.Context(), logrus.Fields{"method": r.Method, "path": r.URL.Path})
+	r = r.WithContext(ctx)
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(segments) == 1 && segments[0] == "runs":
+		s.route(w, r, map[string]http.HandlerFunc{
+			http.MethodGet:  s.listRuns,
+			http.MethodPost: s.triggerRun,
+		})
+	case len(segments) == 2 && segments[0] == "runs":
+		s.route(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.getRun(w, r, segments[1]) },
+		})
+	case len(segments) == 2 && segments[0] == "orders":
+		s.route(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.getOrder(w, r, segments[1]) },
+		})
+	case len(segments) == 3 && segments[0] == "orders" && segments[2] == "enabled":
+		s.route(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.getConfigOrder(w, r, segments[1]) },
+			http.MethodPut: func(w http.ResponseWriter, r *http.Request) { s.setEnabled(w, r, segments[1]) },
+		})
+	case len(segments) == 2 && segments[0] == "config" && segments[1] == "validate":
+		s.route(w, r, map[string]http.HandlerFunc{
+			http.MethodPost: s.validateConfig,
+		})
+	default:
+		writeError(w, r, http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path))
+	}
+}
+
+// route calls the handler for the method of the request.
+func (s *Server) route(w http.ResponseWriter, r *http.Request, handlers map[string]http.HandlerFunc) {
+	handler, ok := handlers[r.Method]
+	if !ok {
+		allowed := make([]string, 0, len(handlers))
+		for method := range handlers {
+			allowed = append(allowed, method)
+		}
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, r, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	handler(w, r)
+}
+
+// authorised checks the bearer token of the request in constant time.
+func (s *Server) authorised(r *http.Request) bool {
+	if s.config.token == "" {
+		return false
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.config.token)) == 1
+}
+
+// listRuns lists the most recent runs, optionally on a date.
+func (s *Server) listRuns(w http.ResponseWriter, r *http.Request) {
+	limit := defaultRunLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxRunLimit {
+			writeError(w, r, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", maxRunLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	date := r.URL.Query().Get("date")
+	if _, err := time.Parse(runs.DateLayout, date); date != "" && err != nil {
+		writeError(w, r, http.StatusBadRequest, fmt.Errorf("invalid date %s, expected %s", date, runs.DateLayout))
+		return
+	}
+
+	summaries, err := s.services.runLoader.ListRuns(r.Context(), s.services.s3Access, s.config.s3Bucket, s.config.runsPrefix, date, limit)
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, summaries)
+}
+
+// getRun gets the summary of a single run.
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request, runID string) {
+	summary, err := s.services.runLoader.GetRun(r.Context(), s.services.s3Access, s.config.s3Bucket, s.config.runsPrefix, runID)
+	if errors.Is(err, runs.ErrRunNotFound) {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, summary)
+}
+
+// triggerRun runs the orders now and responds with the summary once the
+// run has finished, even when it failed as the summary records the failure.
+func (s *Server) triggerRun(w http.ResponseWriter, r *http.Request) {
+	var request RunRequest
+	if err := decodeBody(r, &request, true); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	logging.FromContext(r.Context()).WithField("dryRun", request.DryRun).Info("Triggering Run")
+	summary, err := s.services.trigger(r.Context(), time.Now(), request.DryRun)
+	if summary == nil {
+		writeServerError(w, r, err)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).WithField(logging.FieldRunID, summary.RunID).Error("Triggered Run Failed")
+	}
+
+	writeJSON(w, r, http.StatusOK, summary)
+}
+
+// getOrder finds an order by transaction ID, preferring the processed
+// order over the pending order it was processed from.
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request, transactionID string) {
+	for _, store := range []struct{ status, prefix string }{
+		{OrderProcessed, s.config.processedPrefix},
+		{OrderPending, s.config.pendingPrefix},
+	} {
+		if store.prefix == "" {
+			continue
+		}
+
+		found, err := s.findOrder(r.Context(), store.prefix, transactionID)
+		if err != nil {
+			writeServerError(w, r, err)
+			return
+		}
+
+		if found != nil {
+			found.Status = store.status
+			writeJSON(w, r, http.StatusOK, found)
+			return
+		}
+	}
+
+	writeError(w, r, http.StatusNotFound, fmt.Errorf("order %s not found", transactionID))
+}
+
+// findOrder looks for the order under every exchange partition of the prefix.
+func (s *Server) findOrder(ctx context.Context, prefix string, transactionID string) (*OrderResponse, error) {
+	listPrefix := prefix + "/"
+	var continuationToken *string
+	for {
+		listed, err := s.services.s3Access.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            &s.config.s3Bucket,
+			Prefix:            &listPrefix,
+			Delimiter:         aws.String("/"),
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range listed.CommonPrefixes {
+			partitionPrefix := aws.ToString(partition.Prefix)
+			exchange := strings.TrimPrefix(strings.TrimSuffix(strings.TrimPrefix(partitionPrefix, listPrefix), "/"), "exchange=")
+
+			object, err := s.services.s3Access.GetObject(ctx, &s3.GetObjectInput{
+				Bucket: &s.config.s3Bucket,
+				Key:    aws.String(partitionPrefix + transactionID + ".json"),
+			})
+			var noSuchKey *s3types.NoSuchKey
+			if errors.As(err, &noSuchKey) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			content, err := ioutil.ReadAll(object.Body)
+			object.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+
+			return &OrderResponse{TransactionID: transactionID, Exchange: exchange, Order: content}, nil
+		}
+
+		if !listed.IsTruncated {
+			return nil, nil
+		}
+		continuationToken = listed.NextContinuationToken
+	}
+}
+
+// getConfigOrder gets a single order of the configuration
+// with the ETag of the configuration to update it with.
+func (s *Server) getConfigOrder(w http.ResponseWriter, r *http.Request, orderIndex string) {
+	index, err := strconv.Atoi(orderIndex)
+	if err != nil || index < 0 {
+		writeError(w, r, http.StatusBadRequest, fmt.Errorf("invalid order index %s", orderIndex))
+		return
+	}
+
+	raw, etag, err := s.getConfig(r.Context())
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	order, err := orderAt(raw, index)
+	if errors.Is(err, errOrderNotFound) {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	writeJSON(w, r, http.StatusOK, order)
+}
+
+// setEnabled enables or disables a single order of the configuration.
+//
+// Only the enabled value of the order is changed in the configuration so
+// anything this version does not know about and the layout are kept as they
+// are. A request with an If-Match header is rejected when the configuration
+// has changed since its ETag.
+func (s *Server) setEnabled(w http.ResponseWriter, r *http.Request, orderIndex string) {
+	index, err := strconv.Atoi(orderIndex)
+	if err != nil || index < 0 {
+		writeError(w, r, http.StatusBadRequest, fmt.Errorf("invalid order index %s", orderIndex))
+		return
+	}
+
+	var request EnabledRequest
+	if err := decodeBody(r, &request, false); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if request.Enabled == nil {
+		writeError(w, r, http.StatusBadRequest, errors.New("enabled is required"))
+		return
+	}
+
+	raw, etag, err := s.getConfig(r.Context())
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	if match := r.Header.Get("If-Match"); match != "" && match != "*" && match != etag {
+		writeError(w, r, http.StatusPreconditionFailed, fmt.Errorf("configuration has changed since %s", match))
+		return
+	}
+
+	updated, err := setOrderEnabled(raw, index, *request.Enabled)
+	if errors.Is(err, errOrderNotFound) {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	order, err := orderAt(updated, index)
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	put, err := s.services.s3Access.PutObject(r.Context(), &s3.PutObjectInput{
+		Bucket:      &s.config.s3Bucket,
+		Key:         &s.config.dcaConfigPath,
+		Body:        bytes.NewReader(updated),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		writeServerError(w, r, err)
+		return
+	}
+
+	logging.FromContext(r.Context()).WithFields(logrus.Fields{
+		logging.FieldOrderIndex: index,
+		"enabled":               *request.Enabled,
+	}).Info("Updated Order")
+
+	w.Header().Set("ETag", aws.ToString(put.ETag))
+	writeJSON(w, r, http.StatusOK, order)
+}
+
+// getConfig gets the raw configuration and its ETag.
+func (s *Server) getConfig(ctx context.Context) ([]byte, string, error) {
+	object, err := s.services.s3Access.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s.config.s3Bucket,
+		Key:    &s.config.dcaConfigPath,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	defer object.Body.Close()
+
+	raw, err := ioutil.ReadAll(object.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return raw, aws.ToString(object.ETag), nil
+}
+
+// validateConfig checks a configuration before it is uploaded.
+func (s *Server) validateConfig(w http.ResponseWriter, r *http.Request) {
+	var dcaConfig configuration.DCAConfig
+	if err := decodeBody(r, &dcaConfig, false); err != nil {
+		writeJSON(w, r, http.StatusUnprocessableEntity, ValidateResponse{Problems: []string{err.Error()}})
+		return
+	}
+
+	problems := dcaConfig.Validate()
+	if len(problems) == 0 {
+		writeJSON(w, r, http.StatusOK, ValidateResponse{Valid: true})
+		return
+	}
+
+	response := ValidateResponse{Problems: make([]string, 0, len(problems))}
+	for _, problem := range problems {
+		response.Problems = append(response.Problems, problem.Error())
+	}
+	writeJSON(w, r, http.StatusUnprocessableEntity, response)
+}
+
+// decodeBody strictly decodes the JSON body of the request
+// where an empty body is allowed when it is optional.
+func decodeBody(r *http.Request, into interface{}, optional bool) error {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodyBytes))
+	if err != nil {
+		return err
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if optional {
+			return nil
+		}
+		return errors.New("request body is required")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(into); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Warn("Could not write response")
+	}
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	writeJSON(w, r, status, ErrorResponse{Error: err.Error()})
+}
+
+// writeServerError logs the error and responds without the details.
+func writeServerError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.FromContext(r.Context()).WithError(err).Error("Request Failed")
+	writeError(w, r, http.StatusInternalServerError, errors.New("internal error"))
+}
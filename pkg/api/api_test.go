@@ -0,0 +1,384 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	awsEvents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/runs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const token = "secret"
+
+// Run Loader
+type MockRunLoader struct {
+	mock.Mock
+}
+
+func (m *MockRunLoader) ListRuns(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, date string, limit int) ([]runs.RunSummary, error) {
+	args := m.Called(s3Bucket, s3Prefix, date, limit)
+	return args.Get(0).([]runs.RunSummary), args.Error(1)
+}
+
+func (m *MockRunLoader) ListRunsBetween(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, from string, to string) ([]runs.RunSummary, error) {
+	args := m.Called(s3Bucket, s3Prefix, from, to)
+	return args.Get(0).([]runs.RunSummary), args.Error(1)
+}
+
+func (m *MockRunLoader) GetRun(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, runID string) (*runs.RunSummary, error) {
+	args := m.Called(s3Bucket, s3Prefix, runID)
+	return args.Get(0).(*runs.RunSummary), args.Error(1)
+}
+
+// setup creates a server for the API with the services
+// where expectations are set via the incoming func.
+func setup(apply func(s3Access *pkg.MockS3Access, loader *MockRunLoader, services *Services)) *httptest.Server {
+	s3Access := &pkg.MockS3Access{}
+	loader := &MockRunLoader{}
+	services := &Services{s3Access: s3Access, runLoader: loader}
+	apply(s3Access, loader, services)
+
+	config := &Config{
+		token:           token,
+		s3Bucket:        "bucket",
+		dcaConfigPath:   "config.json",
+		runsPrefix:      "runs",
+		pendingPrefix:   "pending",
+		processedPrefix: "processed",
+	}
+
+	return httptest.NewServer(NewServer(context.Background(), services, config))
+}
+
+// request sends an authorised request and decodes the response.
+func request(t *testing.T, server *httptest.Server, method string, path string, body string, response interface{}) int {
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer res.Body.Close()
+
+	if response != nil {
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(response))
+	}
+	return res.StatusCode
+}
+
+func object(content string) *s3.GetObjectOutput {
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader([]byte(content)))}
+}
+
+// Ensures requests without the token are rejected
+// and every request is rejected when no token is configured
+func TestAuthorisation(t *testing.T) {
+	server := setup(func(s3Access *pkg.MockS3Access, loader *MockRunLoader, services *Services) {})
+	defer server.Close()
+
+	for _, header := range []string{"", "Bearer wrong", token} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/runs", nil)
+		req.Header.Set("Authorization", header)
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode, header)
+	}
+
+	unconfigured := httptest.NewServer(NewServer(context.Background(), &Services{}, &Config{}))
+	defer unconfigured.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, unconfigured.URL+"/runs", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+// Ensures unknown paths and methods are rejected
+func TestRouting(t *testing.T) {
+	server := setup(func(s3Access *pkg.MockS3Access, loader *MockRunLoader, services *Services) {})
+	defer server.Close()
+
+	var response ErrorResponse
+	assert.Equal(t, http.StatusNotFound, request(t, server, http.MethodGet, "/unknown", "", &response))
+	assert.Equal(t, "/unknown not found", response.Error)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, request(t, server, http.MethodDelete, "/runs", "", &response))
+	assert.Equal(t, http.StatusMethodNotAllowed, request(t, server, http.MethodGet, "/config/validate", "", &response))
+}
+
+// Ensures runs are listed with the limit and date
+// and the limit and date are validated
+func TestListRuns(t *testing.T) {
+	server := setup(func(s3Access *pkg.MockS3Access, loader *MockRunLoader, services *Services) {
+		loader.On("ListRuns", "bucket", "runs", "", defaultRunLimit).Return([]runs.RunSummary{{RunID: "latest"}}, nil)
+		loader.On("ListRuns", "bucket", "runs", "2022-01-07", 5).Return([]runs.RunSummary{{RunID: "first"}, {RunID: "second"}}, nil)
+	})
+	defer server.Close()
+
+	var summaries []runs.RunSummary
+	assert.Equal(t, http.StatusOK, request(t, server, http.MethodGet, "/runs", "", &summaries))
+	assert.Equal(t, "latest", summaries[0].RunID)
+
+	assert.Equal(t, http.StatusOK, request(t, server, http.MethodGet, "/runs?date=2022-01-07&limit=5", "", &summaries))
+	assert.Equal(t, 2, len(summaries))
+
+	assert.Equal(t, http.StatusBadRequest, request(t, server, http.MethodGet, "/runs?limit=1000", "", nil))
+	assert.Equal(t, http.StatusBadRequest, request(t, server, http.MethodGet, "/runs?date=yesterday", "", nil))
+}
+
+// Ensures a run is returned by its ID and
+// a run which was never recorded is not found
+func TestGetRun(t *testing.T) {
+	server := setup(func(s3Access *pkg.MockS3Access, loader *MockRunLoader, services *Services) {
+		loader.On("GetRun", "bucket", "runs", "RUNID").Return(&runs.RunSummary{RunID: "RUNID"}, nil)
+		loader.On("GetRun", "bucket", "runs", "missing").Return((*runs.RunSummary)(nil), runs.ErrRunNotFound)
+		loader.On("GetRun", "bucket", "runs", "broken").Return((*runs.RunSummary)(nil), errors.New("s3 error"))
+	})
+	defer server.Close()
+
+	var summary runs.RunSummary
+	assert.Equal(t, http.StatusOK, request(t, server, http.MethodGet, "/runs/RUNID", "", &summary))
+	assert.Equal(t, "RUNID", summary.RunID)
+
+	assert.Equal(t, http.StatusNotFound, request(t, server, http.MethodGet, "/runs/missing", "", nil))
+
+	var response ErrorResponse
+	assert.Equal(t, http.StatusInternalServerError, request(t, server, http.MethodGet, "/runs/broken", "", &response))
+	assert.Equal(t, "internal error", response.Error)
+}
+
+// Ensures a run is triggered, as a dry run when asked for,
+// and the summary is returned even when the run failed
+func TestTriggerRun(t *testing.T) {
+	dryRuns := []bool{}
+	server := setup(func(s3Access *pkg.MockS3Access, loader *MockRunLoader, services *Services) {
+		services.trigger = func(ctx context.Context, triggerTime time.Time, dryRun bool) (*runs.RunSummary, error) {
+			dryRuns = append(dryRuns, dryRun)
+			if dryRun {
+				return &runs.RunSummary{RunID: "DRYRUN"}, nil
+			}
+			return &runs.RunSummary{RunID: "REAL", Error: "order failed"}, errors.New("order failed")
+		}
+	})
+	defer server.Close()
+
+	var summary runs.RunSummary
+	assert.Equal(t, http.StatusOK, request(t, server, http.MethodPost, "/runs", `{"dry_run": true}`, &summary))
+	assert.Equal(t, "DRYRUN", summary.RunID)
+
+	assert.Equal(t, http.StatusOK, request(t, server, http.MethodPost, "/runs", "", &summary))
+	assert.Equal(t, "REAL", summary.RunID)
+	assert.Equal(t, "order failed", summary.Error)
+
+	assert.Equal(t, http.StatusBadRequest, request(t, server, http.MethodPost, "/runs", `{"dry": true}`, nil))
+	assert.Equal(t, []bool{true, false}, dryRuns)
+}
+
+// Ensures an order is found under its exchange, preferring
+// the processed order, and is not found when in neither
+func TestGetOrder(t *testing.T) {
+	server := setup(func(s3Access *pkg.MockS3Access, loader *MockRunLoader, services *Services) {
+		for _, prefix := range []string{"processed", "pending"} {
+			prefix := prefix
+			s3Access.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+				return *input.Prefix == prefix+"/" && *input.Delimiter == "/"
+			}), mock.Anything).Return(&s3.ListObjectsV2Output{CommonPrefixes: []s3types.CommonPrefix{
+				{Prefix: aws.String(prefix + "/exchange=kraken/")},
+			}}, nil)
+		}
+
+		s3Access.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+			return *input.Key == "processed/exchange=kraken/PROCESSED.json"
+		}), mock.Anything).Return(object(`{"transaction_id": "PROCESSED"}`), nil)
+		s3Access.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+			return *input.Key == "pending/exchange=kraken/PENDING.json"
+		}), mock.Anything).Return(object(`{"transaction_id": "PENDING"}`), nil)
+		s3Access.On("GetObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{}, &s3types.NoSuchKey{})
+	})
+	defer server.Close()
+
+	var response OrderResponse
+	assert.Equal(t, http.StatusOK, request(t, server, http.MethodGet, "/orders/PROCESSED", "", &response))
+	assert.Equal(t, OrderProcessed, response.Status)
+	assert.Equal(t, "kraken", response.Exchange)
+	assert.JSONEq(t, `{"transaction_id": "PROCESSED"}`, string(response.Order))
+
+	assert.Equal(t, http.StatusOK, request(t, server, http.MethodGet, "/orders/PENDING", "", &response))
+	assert.Equal(t, OrderPending, response.Status)
+
+	assert.Equal(t, http.StatusNotFound, request(t, server, http.MethodGet, "/orders/MISSING", "", nil))
+}
+
+// Ensures a single order is enabled or disabled changing only
+// its enabled value and keeping the layout of the configuration
+func TestSetEnabled(t *testing.T) {
+	config := `{
+    "orders": [
+        {"pair": "BTCGBP", "enabled": true},
+        {
+            "volume": "0.10000",
+            "enabled" :  true,
+            "pair": "ETHGBP"
+        },
+        {
+            "pair": "ADAGBP"
+        }
+    ],
+    "custom": 1
+}`
+	var uploaded []string
+	server := setup(func(s3Access *pkg.MockS3Access, loader *MockRunLoader, services *Services) {
+		for i := 0; i < 6; i++ {
+			content := object(config)
+			content.ETag = aws.String(`"v1"`)
+			s3Access.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+				return *input.Key == "config.json"
+			}), mock.Anything).Return(content, nil).Once()
+		}
+		s3Access.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+			content, _ := io.ReadAll(input.Body)
+			uploaded = append(uploaded, string(content))
+			return *input.Key == "config.json"
+		}), mock.Anything).Return(&s3.PutObjectOutput{ETag: aws.String(`"v2"`)}, nil)
+	})
+	defer server.Close()
+
+	send := func(method string, path string, body string, ifMatch string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		assert.Nil(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		return res
+	}
+
+	res := send(http.MethodGet, "/orders/1/enabled", "", "")
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"v1"`, res.Header.Get("ETag"))
+
+	res = send(http.MethodPut, "/orders/1/enabled", `{"enabled": false}`, `"v1"`)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"v2"`, res.Header.Get("ETag"))
+
+	var order map[string]interface{}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&order))
+	assert.Equal(t, false, order["enabled"])
+	assert.Equal(t, "0.10000", order["volume"])
+	assert.Equal(t, strings.Replace(config, `"enabled" :  true,`, `"enabled" :  false,`, 1), uploaded[0])
+
+	res = send(http.MethodPut, "/orders/2/enabled", `{"enabled": false}`, "")
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, strings.Replace(config, `"pair": "ADAGBP"`, `"enabled": false,
+            "pair": "ADAGBP"`, 1), uploaded[1])
+
+	res = send(http.MethodPut, "/orders/0/enabled", `{"enabled": false}`, `"v0"`)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+	assert.Equal(t, 2, len(uploaded))
+
+	assert.Equal(t, http.StatusNotFound, request(t, server, http.MethodPut, "/orders/3/enabled", `{"enabled": false}`, nil))
+	assert.Equal(t, http.StatusNotFound, request(t, server, http.MethodGet, "/orders/3/enabled", "", nil))
+	assert.Equal(t, http.StatusBadRequest, request(t, server, http.MethodPut, "/orders/first/enabled", `{"enabled": false}`, nil))
+	assert.Equal(t, http.StatusBadRequest, request(t, server, http.MethodPut, "/orders/0/enabled", `{}`, nil))
+}
+
+// Ensures an order without an enabled value has it added
+// and an empty order or configuration is handled
+func TestSetOrderEnabled(t *testing.T) {
+	updated, err := setOrderEnabled([]byte(`{"orders":[{}]}`), 0, true)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"orders":[{"enabled": true}]}`, string(updated))
+
+	updated, err = setOrderEnabled([]byte(`{"custom": {"orders": []}, "orders": [[1], {"pair": "BTCGBP"}]}`), 1, false)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"custom": {"orders": []}, "orders": [[1], {"enabled": false,"pair": "BTCGBP"}]}`, string(updated))
+
+	_, err = setOrderEnabled([]byte(`{"orders": [[1]]}`), 0, false)
+	assert.EqualError(t, err, "order 0 is not an object: expected { but got [")
+
+	_, err = setOrderEnabled([]byte(`{}`), 0, false)
+	assert.True(t, errors.Is(err, errOrderNotFound))
+}
+
+// Ensures a valid configuration is accepted and the
+// problems with an invalid configuration are returned
+func TestValidateConfig(t *testing.T) {
+	server := setup(func(s3Access *pkg.MockS3Access, loader *MockRunLoader, services *Services) {})
+	defer server.Close()
+
+	var response ValidateResponse
+	valid := `{"$schema": "schema.json", "orders": [{"exchange": "kraken", "direction": "buy", "ordertype": "market", "volume": "0.01", "pair": "BTCGBP", "enabled": true}]}`
+	assert.Equal(t, http.StatusOK, request(t, server, http.MethodPost, "/config/validate", valid, &response))
+	assert.True(t, response.Valid)
+
+	response = ValidateResponse{}
+	invalid := `{"orders": [{"exchange": "kraken", "direction": "hold", "ordertype": "market", "volume": "0.01", "pair": "BTCGBP"}]}`
+	assert.Equal(t, http.StatusUnprocessableEntity, request(t, server, http.MethodPost, "/config/validate", invalid, &response))
+	assert.False(t, response.Valid)
+	assert.Equal(t, []string{"orders[0]: unsupported direction hold"}, response.Problems)
+
+	response = ValidateResponse{}
+	unknown := `{"orders": [], "order": []}`
+	assert.Equal(t, http.StatusUnprocessableEntity, request(t, server, http.MethodPost, "/config/validate", unknown, &response))
+	assert.Contains(t, response.Problems[0], "unknown field")
+}
+
+// Ensures requests to the Lambda function URL
+// are served by the API including encoded bodies
+func TestHandleFunctionURL(t *testing.T) {
+	services := &Services{trigger: func(ctx context.Context, triggerTime time.Time, dryRun bool) (*runs.RunSummary, error) {
+		return &runs.RunSummary{RunID: "RUNID", Real: !dryRun}, nil
+	}}
+	server := NewServer(context.Background(), services, &Config{token: token})
+
+	event := awsEvents.APIGatewayV2HTTPRequest{
+		RawPath:         "/runs",
+		Headers:         map[string]string{"authorization": "Bearer " + token},
+		Body:            base64.StdEncoding.EncodeToString([]byte(`{"dry_run": true}`)),
+		IsBase64Encoded: true,
+	}
+	event.RequestContext.HTTP.Method = http.MethodPost
+
+	response, err := server.HandleFunctionURL(context.Background(), event)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Headers["Content-Type"])
+
+	var summary runs.RunSummary
+	assert.Nil(t, json.Unmarshal([]byte(response.Body), &summary))
+	assert.Equal(t, "RUNID", summary.RunID)
+	assert.False(t, summary.Real)
+
+	event.Headers = map[string]string{}
+	response, err = server.HandleFunctionURL(context.Background(), event)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	assert.Equal(t, "Bearer", response.Headers["Www-Authenticate"])
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var errOrderNotFound = errors.New("order not found")
+
+// orderAt gets the order at the index of the raw configuration.
+func orderAt(raw []byte, index int) (json.RawMessage, error) {
+	var dcaConfig struct {
+		Orders []json.RawMessage `json:"orders"`
+	}
+	if err := json.Unmarshal(raw, &dcaConfig); err != nil {
+		return nil, err
+	}
+
+	if index >= len(dcaConfig.Orders) {
+		return nil, fmt.Errorf("%w: %d", errOrderNotFound, index)
+	}
+	return dcaConfig.Orders[index], nil
+}
+
+// setOrderEnabled changes the enabled value of the order at the index of the
+// raw configuration, adding it to the start of the order when it has none.
+//
+// Everything else in the configuration is left byte for byte as it was.
+func setOrderEnabled(raw []byte, index int, enabled bool) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+
+	for {
+		if !decoder.More() {
+			return nil, fmt.Errorf("%w: %d", errOrderNotFound, index)
+		}
+
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if key == "orders" {
+			break
+		}
+		if err := skipValue(decoder); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := expectDelim(decoder, '['); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < index; i++ {
+		if !decoder.More() {
+			return nil, fmt.Errorf("%w: %d", errOrderNotFound, index)
+		}
+		if err := skipValue(decoder); err != nil {
+			return nil, err
+		}
+	}
+	if !decoder.More() {
+		return nil, fmt.Errorf("%w: %d", errOrderNotFound, index)
+	}
+
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, fmt.Errorf("order %d is not an object: %w", index, err)
+	}
+	open := int(decoder.InputOffset())
+	value := strconv.FormatBool(enabled)
+
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		keyEnd := int(decoder.InputOffset())
+
+		if err := skipValue(decoder); err != nil {
+			return nil, err
+		}
+		if key != "enabled" {
+			continue
+		}
+
+		// The value starts after the colon following the key
+		valueEnd := int(decoder.InputOffset())
+		valueStart := keyEnd + bytes.IndexByte(raw[keyEnd:valueEnd], ':') + 1
+		valueStart += len(raw[valueStart:valueEnd]) - len(bytes.TrimLeft(raw[valueStart:valueEnd], " \t\r\n"))
+
+		return splice(raw, valueStart, valueEnd, value), nil
+	}
+
+	// The order has no enabled value so it is added with the indentation of the first key
+	indent := raw[open : len(raw)-len(bytes.TrimLeft(raw[open:], " \t\r\n"))]
+	field := `"enabled": ` + value
+	if raw[open+len(indent)] != '}' {
+		field += "," + string(indent)
+	}
+	return splice(raw, open+len(indent), open+len(indent), field), nil
+}
+
+// splice replaces the bytes between start and end with the value.
+func splice(raw []byte, start int, end int, value string) []byte {
+	spliced := make([]byte, 0, len(raw)-(end-start)+len(value))
+	spliced = append(spliced, raw[:start]...)
+	spliced = append(spliced, value...)
+	return append(spliced, raw[end:]...)
+}
+
+// expectDelim reads the next token expecting it to be the delimiter.
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %s but got %v", delim, token)
+	}
+	return nil
+}
+
+// skipValue reads past the next value including everything nested within it.
+func skipValue(decoder *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	awsEvents "github.com/aws/aws-lambda-go/events"
+)
+
+// HandleFunctionURL serves a request made to the Lambda function URL
+// by replaying it against the HTTP handler of the API.
+func (s *Server) HandleFunctionURL(ctx context.Context, event awsEvents.APIGatewayV2HTTPRequest) (awsEvents.APIGatewayV2HTTPResponse, error) {
+	body := event.Body
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(event.Body)
+		if err != nil {
+			return awsEvents.APIGatewayV2HTTPResponse{StatusCode: http.StatusBadRequest}, nil
+		}
+		body = string(decoded)
+	}
+
+	path := event.RawPath
+	if event.RawQueryString != "" {
+		path += "?" + event.RawQueryString
+	}
+
+	r, err := http.NewRequestWithContext(ctx, event.RequestContext.HTTP.Method, path, strings.NewReader(body))
+	if err != nil {
+		return awsEvents.APIGatewayV2HTTPResponse{StatusCode: http.StatusBadRequest}, nil
+	}
+	for name, value := range event.Headers {
+		r.Header.Set(name, value)
+	}
+
+	response := &functionURLResponse{header: http.Header{}}
+	s.ServeHTTP(response, r)
+
+	headers := map[string]string{}
+	for name, values := range response.header {
+		headers[name] = strings.Join(values, ",")
+	}
+
+	return awsEvents.APIGatewayV2HTTPResponse{
+		StatusCode: response.statusCode(),
+		Headers:    headers,
+		Body:       response.body.String(),
+	}, nil
+}
+
+// functionURLResponse collects the response of the API
+// to be returned to the Lambda function URL.
+type functionURLResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header gets the headers to respond with.
+func (f *functionURLResponse) Header() http.Header {
+	return f.header
+}
+
+// WriteHeader sets the status code, only the first is kept.
+func (f *functionURLResponse) WriteHeader(status int) {
+	if f.status == 0 {
+		f.status = status
+	}
+}
+
+// Write adds to the body, responding OK unless another status was set.
+func (f *functionURLResponse) Write(content []byte) (int, error) {
+	f.WriteHeader(http.StatusOK)
+	return f.body.Write(content)
+}
+
+// statusCode gets the status to respond with, OK when nothing was written.
+func (f *functionURLResponse) statusCode() int {
+	if f.status == 0 {
+		return http.StatusOK
+	}
+	return f.status
+}
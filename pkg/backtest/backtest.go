@@ -0,0 +1,262 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/executor"
+	"github.com/kiran94/dca-manager/pkg/logging"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/kiran94/dca-manager/pkg/performance"
+	"github.com/kiran94/dca-manager/pkg/runs"
+	"github.com/kiran94/dca-manager/pkg/schedule"
+	"github.com/kiran94/dca-manager/pkg/strategy"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// Backtester is an abstraction to simulate a configuration over historical prices.
+type Backtester interface {
+	Run(variant Variant, candles map[string][]orders.Candle, options Options) (*Result, error)
+}
+
+// Variant is a configuration executed on a schedule
+// e.g weekly on a Friday or daily.
+type Variant struct {
+	Name     string                  `json:"name"`
+	Schedule string                  `json:"schedule"`
+	Config   configuration.DCAConfig `json:"config"`
+}
+
+// Options are shared by every variant in a backtest.
+type Options struct {
+	Start  time.Time
+	End    time.Time
+	FeePct decimal.Decimal
+}
+
+// Result is the outcome of a single variant.
+//
+// The return is the value held plus the proceeds of sells over what was
+// invested. The max drawdown is the largest fall of the time weighted
+// return from its peak measured at every candle.
+type Result struct {
+	Name           string                `json:"name"`
+	Schedule       string                `json:"schedule"`
+	Runs           int                   `json:"runs"`
+	Orders         int                   `json:"orders"`
+	Invested       decimal.Decimal       `json:"invested"`
+	Proceeds       decimal.Decimal       `json:"proceeds"`
+	Fees           decimal.Decimal       `json:"fees"`
+	Value          decimal.Decimal       `json:"value"`
+	ReturnPct      decimal.Decimal       `json:"return_pct"`
+	MaxDrawdownPct decimal.Decimal       `json:"max_drawdown_pct"`
+	Holdings       []performance.Holding `json:"holdings"`
+}
+
+// Simulator runs variants through the executor used for live
+// runs against a simulated exchange and simulated time.
+type Simulator struct{}
+
+// Run executes the variant at every scheduled time between the start and end.
+func (s Simulator) Run(variant Variant, candles map[string][]orders.Candle, options Options) (*Result, error) {
+	if !options.End.After(options.Start) {
+		return nil, errors.New("backtest end must be after the start")
+	}
+
+	runSchedule, err := schedule.Parse(variant.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	exchange := NewSimulatedExchange(candles, options.FeePct)
+	orderers := map[string]orders.Orderer{}
+	for _, order := range variant.Config.Orders {
+		if order.Exchange != strategy.ExchangeAuto {
+			orderers[order.Exchange] = exchange
+		}
+	}
+	if portfolio := variant.Config.Portfolio; portfolio != nil {
+		orderers[portfolio.Exchange] = exchange
+		for _, target := range portfolio.Targets {
+			exchange.SetAsset(target.Pair, target.Asset)
+		}
+	}
+	if len(orderers) == 0 {
+		orderers["simulated"] = exchange
+	}
+
+	sim := &simulation{config: variant.Config, exchange: exchange, orderers: orderers}
+	services, appConfig := executor.NewSimulatedServices(executor.Simulation{
+		ConfigSource:         sim,
+		OrdererFactory:       sim,
+		S3Access:             sim,
+		PendingOrderQueue:    sim,
+		ProcessedOrderSource: sim,
+		RunLoader:            sim,
+		Clock:                exchange.Now,
+	})
+
+	// The executor logs every order of every run, the outcome is in the result instead
+	quiet := logrus.New()
+	quiet.SetOutput(io.Discard)
+	ctx := logging.WithLogger(context.Background(), logrus.NewEntry(quiet))
+
+	placeDue := func(until time.Time) error {
+		for {
+			due, ok := sim.nextSlice(until)
+			if !ok {
+				return nil
+			}
+
+			at := time.Unix(due.ExecuteAt, 0).UTC()
+			exchange.AdvanceTo(at)
+			if err := sim.placeSlice(ctx, due); err != nil {
+				return fmt.Errorf("slice %d at %s: %w", due.Slice, at.Format(time.RFC3339), err)
+			}
+		}
+	}
+
+	runTimes := schedule.Between(runSchedule, options.Start, options.End)
+	for _, at := range runTimes {
+		if err := placeDue(at); err != nil {
+			return nil, err
+		}
+
+		exchange.AdvanceTo(at)
+		summary, err := runs.NewRunSummary(at)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = executor.ExecuteOrders(ctx, services, appConfig, summary)
+		if err != nil {
+			return nil, fmt.Errorf("run at %s: %w", at.Format(time.RFC3339), err)
+		}
+		sim.record(summary)
+	}
+
+	if err := placeDue(options.End.Add(-time.Nanosecond)); err != nil {
+		return nil, err
+	}
+	exchange.AdvanceTo(options.End)
+
+	return summarise(ctx, variant, len(runTimes), exchange, candles, options)
+}
+
+// summarise builds the result from the fills of the exchange at the end of the backtest.
+func summarise(ctx context.Context, variant Variant, runs int, exchange *SimulatedExchange, candles map[string][]orders.Candle, options Options) (*Result, error) {
+	fills := exchange.Fills()
+	report, err := performance.Performance{}.Analyse(ctx, map[string][]orders.OrderComplete{"simulated": fills}, exchange, options.End)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Name:     variant.Name,
+		Schedule: variant.Schedule,
+		Runs:     runs,
+		Orders:   len(fills),
+		Holdings: report.Holdings,
+	}
+
+	for _, fill := range fills {
+		result.Fees = result.Fees.Add(fill.Fee)
+		amount := fill.Price.Mul(fill.Volume)
+		if fill.Type == "buy" {
+			result.Invested = result.Invested.Add(amount).Add(fill.Fee)
+		} else {
+			result.Proceeds = result.Proceeds.Add(amount).Sub(fill.Fee)
+		}
+	}
+
+	for _, holding := range report.Holdings {
+		result.Value = result.Value.Add(holding.Value)
+	}
+
+	if result.Invested.IsPositive() {
+		result.ReturnPct = result.Value.Add(result.Proceeds).Sub(result.Invested).Div(result.Invested).Mul(decimal.NewFromInt(100))
+	}
+	result.MaxDrawdownPct = maxDrawdown(fills, candles, options)
+
+	return result, nil
+}
+
+// maxDrawdown replays the fills against every candle in the backtest tracking the
+// largest fall of the time weighted return from its peak as a percentage.
+//
+// The time weighted return leaves out money added or taken out by orders
+// so buying at a higher price in a rising market is not a drawdown.
+func maxDrawdown(fills []orders.OrderComplete, candles map[string][]orders.Candle, options Options) decimal.Decimal {
+	type point struct {
+		at    time.Time
+		pair  string
+		close decimal.Decimal
+	}
+
+	points := []point{}
+	for pair, pairCandles := range candles {
+		for _, candle := range pairCandles {
+			if candle.Time.Before(options.Start) || !candle.Time.Before(options.End) {
+				continue
+			}
+			points = append(points, point{at: candle.Time, pair: pair, close: candle.Close})
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		if points[i].at.Equal(points[j].at) {
+			return points[i].pair < points[j].pair
+		}
+		return points[i].at.Before(points[j].at)
+	})
+
+	fills = append([]orders.OrderComplete{}, fills...)
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].CloseTime < fills[j].CloseTime })
+
+	units := map[string]decimal.Decimal{}
+	prices := map[string]decimal.Decimal{}
+	value := func() decimal.Decimal {
+		total := decimal.Zero
+		for pair, held := range units {
+			total = total.Add(held.Mul(prices[pair]))
+		}
+		return total
+	}
+
+	index := decimal.NewFromInt(1)
+	peak := index
+	drawdown := decimal.Zero
+	previous := decimal.Zero
+	next := 0
+
+	for _, p := range points {
+		prices[p.pair] = p.close
+
+		// Growth since the last point before any new orders
+		if previous.IsPositive() {
+			index = index.Mul(value()).Div(previous)
+			peak = decimal.Max(peak, index)
+			drawdown = decimal.Max(drawdown, peak.Sub(index).Div(peak).Mul(decimal.NewFromInt(100)))
+		}
+
+		// Fills within the candle are known by its close
+		for next < len(fills) && fills[next].CloseTime < float64(p.at.Unix()+1) {
+			fill := fills[next]
+			if fill.Type == "buy" {
+				units[fill.Pair] = units[fill.Pair].Add(fill.Volume)
+			} else {
+				units[fill.Pair] = units[fill.Pair].Sub(fill.Volume)
+			}
+			next++
+		}
+
+		previous = value()
+	}
+
+	return drawdown
+}
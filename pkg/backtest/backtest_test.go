@@ -0,0 +1,263 @@
+package backtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+var january = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// risingCandles are daily candles opening and closing at 100 on the
+// first day and rising by one each day after.
+func risingCandles(days int) []orders.Candle {
+	candles := make([]orders.Candle, days)
+	for day := range candles {
+		price := decimal.NewFromInt(int64(100 + day))
+		candles[day] = orders.Candle{
+			Time:  january.AddDate(0, 0, day),
+			Open:  price,
+			High:  price.Add(decimal.NewFromInt(5)),
+			Low:   price.Sub(decimal.NewFromInt(5)),
+			Close: price,
+		}
+	}
+	return candles
+}
+
+func buyOrder(volume string) configuration.DCAOrder {
+	return configuration.DCAOrder{
+		Exchange:  "kraken",
+		Direction: "buy",
+		OrderType: "market",
+		Volume:    volume,
+		Pair:      "XBTGBP",
+		Enabled:   true,
+	}
+}
+
+// Ensures candles are read from CSV in time order
+func TestLoadCandles(t *testing.T) {
+	input := "timestamp,open,high,low,close,volume\n" +
+		"2022-01-02,101,106,96,102,10\n" +
+		"1640995200,100,105,95,101,20\n"
+
+	candles, err := LoadCandles(strings.NewReader(input))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(candles))
+	assert.Equal(t, january, candles[0].Time)
+	assert.Equal(t, "101", candles[0].Close.String())
+	assert.Equal(t, "10", candles[1].Volume.String())
+}
+
+// Ensures invalid candle CSVs describe the problem
+func TestLoadCandlesInvalid(t *testing.T) {
+	_, err := LoadCandles(strings.NewReader("time,open,high,low\n"))
+	assert.Contains(t, err.Error(), "candle csv is missing the close column")
+
+	_, err = LoadCandles(strings.NewReader("time,open,high,low,close\nyesterday,1,1,1,1\n"))
+	assert.Contains(t, err.Error(), `row 2: invalid time "yesterday"`)
+
+	_, err = LoadCandles(strings.NewReader("time,open,high,low,close\n2022-01-01,1,1,one,1\n"))
+	assert.Contains(t, err.Error(), `row 2: invalid low "one"`)
+
+	_, err = LoadCandles(strings.NewReader("time,open,high,low,close\n"))
+	assert.Contains(t, err.Error(), "candle csv has no rows")
+}
+
+// Ensures market orders fill at the open and limit
+// orders rest until a candle trades through them
+func TestSimulatedExchange(t *testing.T) {
+	exchange := NewSimulatedExchange(map[string][]orders.Candle{"XBTGBP": risingCandles(5)}, decimal.NewFromInt(1))
+	exchange.AdvanceTo(january.Add(6 * time.Hour))
+
+	market := buyOrder("2")
+	placed, err := exchange.MakeOrder(context.Background(), &market)
+	assert.Nil(t, err)
+
+	limitPrice := decimal.NewFromInt(97)
+	limit := buyOrder("1")
+	limit.OrderType = "limit"
+	limit.LimitPrice = &limitPrice
+	resting, err := exchange.MakeOrder(context.Background(), &limit)
+	assert.Nil(t, err)
+
+	expiringPrice := decimal.NewFromInt(50)
+	expiring := buyOrder("1")
+	expiring.OrderType = "limit"
+	expiring.LimitPrice = &expiringPrice
+	expiring.ExpireAfter = "24h"
+	expired, err := exchange.MakeOrder(context.Background(), &expiring)
+	assert.Nil(t, err)
+
+	exchange.AdvanceTo(january.AddDate(0, 0, 4))
+	completed, err := exchange.ProcessTransaction(context.Background(), placed.TransactionID, resting.TransactionID, expired.TransactionID)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "closed", (*completed)[0].ExchangeStatus)
+	assert.Equal(t, "100", (*completed)[0].Price.String())
+	assert.Equal(t, "2", (*completed)[0].Fee.String())
+	assert.Equal(t, "closed", (*completed)[1].ExchangeStatus)
+	assert.Equal(t, "97", (*completed)[1].Price.String())
+	assert.Equal(t, "expired", (*completed)[2].ExchangeStatus)
+
+	balances, _ := exchange.GetBalances(context.Background())
+	assert.Equal(t, "3", balances["XBTGBP"].String())
+
+	daily, err := exchange.GetOHLC(context.Background(), "XBTGBP", january)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(daily))
+
+	sell := buyOrder("5")
+	sell.Direction = "sell"
+	_, err = exchange.MakeOrder(context.Background(), &sell)
+	assert.Contains(t, err.Error(), "insufficient balance to sell 5 XBTGBP")
+}
+
+// Ensures variants on different schedules can be compared
+func TestSimulatorRun(t *testing.T) {
+	candles := map[string][]orders.Candle{"XBTGBP": risingCandles(28)}
+	options := Options{Start: january, End: january.AddDate(0, 0, 28), FeePct: decimal.Zero}
+	conf := configuration.DCAConfig{Orders: []configuration.DCAOrder{buyOrder("1")}}
+
+	weekly, err := Simulator{}.Run(Variant{Name: "weekly", Schedule: "cron(0 6 ? * FRI *)", Config: conf}, candles, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, weekly.Runs)
+	assert.Equal(t, 4, weekly.Orders)
+	assert.Equal(t, "466", weekly.Invested.String())
+	assert.Equal(t, "508", weekly.Value.String())
+	assert.Equal(t, "116.5", weekly.Holdings[0].AverageCost.String())
+	assert.Equal(t, "0", weekly.MaxDrawdownPct.String())
+
+	daily, err := Simulator{}.Run(Variant{Name: "daily", Schedule: "rate(1 day)", Config: conf}, candles, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 28, daily.Runs)
+	assert.Equal(t, "3178", daily.Invested.String())
+	assert.Equal(t, "113.5", daily.Holdings[0].AverageCost.String())
+
+	var text bytes.Buffer
+	assert.Nil(t, WriteResults(&text, []Result{*weekly, *daily}, FormatText))
+	lines := strings.Split(strings.TrimSpace(text.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.True(t, strings.HasPrefix(lines[1], "weekly"))
+
+	var jsonOutput bytes.Buffer
+	assert.Nil(t, WriteResults(&jsonOutput, []Result{*weekly}, FormatJSON))
+	decoded := []Result{}
+	assert.Nil(t, json.Unmarshal(jsonOutput.Bytes(), &decoded))
+	assert.Equal(t, "weekly", decoded[0].Name)
+}
+
+// Ensures TWAP orders are sliced through the same plan as live orders
+func TestSimulatorRunTWAP(t *testing.T) {
+	candles := map[string][]orders.Candle{"XBTGBP": risingCandles(3)}
+	options := Options{Start: january, End: january.AddDate(0, 0, 3), FeePct: decimal.NewFromInt(1)}
+
+	order := buyOrder("1")
+	order.Execution = &configuration.ExecutionConfig{Strategy: "twap", Slices: 2, Interval: "12h"}
+	conf := configuration.DCAConfig{Orders: []configuration.DCAOrder{order}}
+
+	result, err := Simulator{}.Run(Variant{Name: "twap", Schedule: "rate(1 day)", Config: conf}, candles, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, result.Runs)
+	assert.Equal(t, 6, result.Orders)
+	assert.Equal(t, "3", result.Holdings[0].Units.String())
+	assert.Equal(t, "3.03", result.Fees.String())
+}
+
+// Ensures orders are skipped outside their window, on excluded days and after max_executions
+func TestSimulatorRunCalendar(t *testing.T) {
+	candles := map[string][]orders.Candle{"XBTGBP": risingCandles(10)}
+	options := Options{Start: january, End: january.AddDate(0, 0, 10), FeePct: decimal.Zero}
+
+	windowed := buyOrder("1")
+	windowed.StartDate = "2022-01-03"
+	windowed.EndDate = "2022-01-08"
+
+	capped := buyOrder("1")
+	capped.ID = "capped"
+	capped.MaxExecutions = 2
+
+	conf := configuration.DCAConfig{
+		Orders:     []configuration.DCAOrder{windowed, capped},
+		Exclusions: []configuration.Exclusion{{Date: "2022-01-05"}},
+	}
+
+	result, err := Simulator{}.Run(Variant{Name: "calendar", Schedule: "rate(1 day)", Config: conf}, candles, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 10, result.Runs)
+	// The window covers the 3rd to the 8th without the excluded 5th
+	// while the capped order is placed on the 1st and 2nd only
+	assert.Equal(t, 7, result.Orders)
+	assert.Equal(t, "7", result.Holdings[0].Units.String())
+}
+
+// Ensures orders go through the executor so disabled and validated orders are never placed
+func TestSimulatorRunExecutor(t *testing.T) {
+	candles := map[string][]orders.Candle{"XBTGBP": risingCandles(3)}
+	options := Options{Start: january, End: january.AddDate(0, 0, 3), FeePct: decimal.Zero}
+
+	disabled := buyOrder("1")
+	disabled.Enabled = false
+	validated := buyOrder("1")
+	validated.Validate = true
+	conf := configuration.DCAConfig{Orders: []configuration.DCAOrder{disabled, validated, buyOrder("2")}}
+
+	result, err := Simulator{}.Run(Variant{Name: "executor", Schedule: "rate(1 day)", Config: conf}, candles, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, result.Orders)
+	assert.Equal(t, "6", result.Holdings[0].Units.String())
+
+	conf.FailurePolicy = "retry"
+	_, err = Simulator{}.Run(Variant{Name: "invalid", Schedule: "rate(1 day)", Config: conf}, candles, options)
+	assert.Contains(t, err.Error(), "run at 2022-01-01T00:00:00Z: unsupported failure_policy retry")
+}
+
+// Ensures the drawdown is measured from the peak return
+func TestMaxDrawdown(t *testing.T) {
+	closes := []int64{100, 80, 120, 90}
+	candles := make([]orders.Candle, len(closes))
+	for day, price := range closes {
+		candles[day] = orders.Candle{Time: january.AddDate(0, 0, day), Close: decimal.NewFromInt(price)}
+	}
+
+	fills := []orders.OrderComplete{{
+		ExchangeStatus: "closed",
+		Pair:           "XBTGBP",
+		Type:           "buy",
+		Price:          decimal.NewFromInt(100),
+		Volume:         decimal.NewFromInt(1),
+		CloseTime:      float64(january.Unix()),
+	}}
+
+	drawdown := maxDrawdown(fills, map[string][]orders.Candle{"XBTGBP": candles}, Options{Start: january, End: january.AddDate(0, 0, 4)})
+
+	assert.Equal(t, "25", drawdown.String())
+}
+
+// Ensures invalid backtests are rejected
+func TestSimulatorRunInvalid(t *testing.T) {
+	candles := map[string][]orders.Candle{"XBTGBP": risingCandles(3)}
+	conf := configuration.DCAConfig{Orders: []configuration.DCAOrder{buyOrder("1")}}
+
+	_, err := Simulator{}.Run(Variant{Schedule: "rate(1 day)", Config: conf}, candles, Options{Start: january, End: january})
+	assert.Contains(t, err.Error(), "backtest end must be after the start")
+
+	_, err = Simulator{}.Run(Variant{Schedule: "daily", Config: conf}, candles, Options{Start: january, End: january.AddDate(0, 0, 1)})
+	assert.Contains(t, err.Error(), "unsupported schedule expression daily")
+
+	_, err = Simulator{}.Run(Variant{Schedule: "rate(1 day)", Config: conf}, candles, Options{Start: january.AddDate(0, 0, -2), End: january.AddDate(0, 0, 1)})
+	assert.Contains(t, err.Error(), "run at 2021-12-30T00:00:00Z: no candles for pair XBTGBP before")
+}
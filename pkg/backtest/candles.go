@@ -0,0 +1,119 @@
+// Package backtest simulates DCA configurations against historical prices.
+package backtest
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+)
+
+// Time layouts accepted in the time column of candle CSVs
+// alongside unix timestamps in seconds.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// LoadCandles reads daily or hourly candles from CSV.
+//
+// The header must name the time, open, high, low and close columns,
+// a volume column is optional. Candles are returned in time order.
+func LoadCandles(r io.Reader) ([]orders.Candle, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read candle header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for index, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "timestamp" || name == "date" {
+			name = "time"
+		}
+		columns[name] = index
+	}
+
+	for _, required := range []string{"time", "open", "high", "low", "close"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("candle csv is missing the %s column", required)
+		}
+	}
+
+	candles := []orders.Candle{}
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+
+		candle := orders.Candle{}
+		if candle.Time, err = parseTime(record[columns["time"]]); err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+
+		fields := []struct {
+			name  string
+			value *decimal.Decimal
+		}{
+			{"open", &candle.Open},
+			{"high", &candle.High},
+			{"low", &candle.Low},
+			{"close", &candle.Close},
+			{"volume", &candle.Volume},
+		}
+
+		for _, field := range fields {
+			index, ok := columns[field.name]
+			if !ok {
+				continue
+			}
+
+			if *field.value, err = decimal.NewFromString(strings.TrimSpace(record[index])); err != nil {
+				return nil, fmt.Errorf("row %d: invalid %s %q", row, field.name, record[index])
+			}
+		}
+
+		candles = append(candles, candle)
+	}
+
+	if len(candles) == 0 {
+		return nil, errors.New("candle csv has no rows")
+	}
+
+	sort.SliceStable(candles, func(i, j int) bool {
+		return candles[i].Time.Before(candles[j].Time)
+	})
+
+	return candles, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	for _, layout := range timeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
@@ -0,0 +1,369 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+)
+
+// SimulatedExchange is an Orderer which fills orders against historical candles.
+//
+// The price at any time is the open of the candle it falls in so nothing is known
+// about the rest of the candle. Market orders fill immediately at that price and
+// limit orders rest until a later candle trades through the limit or they expire.
+// Quote currency is unlimited so buys are never refused.
+//
+// Disabled orders are not placed and orders to validate are accepted
+// without being placed as with a real exchange.
+type SimulatedExchange struct {
+	FeePct decimal.Decimal
+
+	mu       sync.Mutex
+	candles  map[string][]orders.Candle
+	assets   map[string]string
+	now      time.Time
+	sequence int
+	balances map[string]decimal.Decimal
+	placed   map[string]*simulatedOrder
+	ordered  []string
+}
+
+// simulatedOrder is an order placed on the simulated exchange.
+type simulatedOrder struct {
+	complete  orders.OrderComplete
+	limit     decimal.Decimal
+	expireAt  time.Time
+	replace   bool
+	placedAt  time.Time
+	direction string
+}
+
+// NewSimulatedExchange creates an exchange with the candles of each pair.
+func NewSimulatedExchange(candles map[string][]orders.Candle, feePct decimal.Decimal) *SimulatedExchange {
+	return &SimulatedExchange{
+		FeePct:   feePct,
+		candles:  candles,
+		assets:   map[string]string{},
+		balances: map[string]decimal.Decimal{},
+		placed:   map[string]*simulatedOrder{},
+	}
+}
+
+// SetAsset sets the asset balances of the pair are reported under,
+// by default the pair itself is used.
+func (s *SimulatedExchange) SetAsset(pair string, asset string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.assets[pair] = asset
+}
+
+// Now is the current time of the exchange.
+func (s *SimulatedExchange) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.now
+}
+
+// AdvanceTo moves the exchange forward in time filling or
+// expiring resting limit orders against the candles passed.
+func (s *SimulatedExchange) AdvanceTo(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !t.After(s.now) {
+		return
+	}
+
+	for _, txid := range s.ordered {
+		order := s.placed[txid]
+		if !order.complete.IsOpen() {
+			continue
+		}
+
+		for _, candle := range s.candles[order.complete.Pair] {
+			if candle.Time.Before(order.placedAt) || candle.Time.Before(s.now) {
+				continue
+			}
+			if !candle.Time.Before(t) {
+				break
+			}
+
+			if !order.expireAt.IsZero() && !candle.Time.Before(order.expireAt) {
+				if order.replace {
+					s.fill(order, candle.Open, candle.Time)
+				} else {
+					order.complete.ExchangeStatus = "expired"
+				}
+				break
+			}
+
+			crossed := (order.direction == "buy" && candle.Low.LessThanOrEqual(order.limit)) ||
+				(order.direction == "sell" && candle.High.GreaterThanOrEqual(order.limit))
+			if crossed {
+				s.fill(order, order.limit, candle.Time)
+				break
+			}
+		}
+	}
+
+	s.now = t
+}
+
+// Fills are every filled order in the order they were placed.
+func (s *SimulatedExchange) Fills() []orders.OrderComplete {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fills := []orders.OrderComplete{}
+	for _, txid := range s.ordered {
+		if order := s.placed[txid]; order.complete.ExchangeStatus == "closed" {
+			fills = append(fills, order.complete)
+		}
+	}
+	return fills
+}
+
+// MakeOrder places the order at the current time.
+func (s *SimulatedExchange) MakeOrder(ctx context.Context, order *configuration.DCAOrder) (*orders.OrderFufilled, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !order.Enabled {
+		return nil, nil
+	}
+
+	price, err := s.price(order.Pair)
+	if err != nil {
+		return nil, err
+	}
+
+	volume, err := decimal.NewFromString(order.Volume)
+	if err != nil {
+		return nil, fmt.Errorf("invalid volume %s: %w", order.Volume, err)
+	}
+
+	direction := strings.ToLower(order.Direction)
+	if direction != "buy" && direction != "sell" {
+		return nil, fmt.Errorf("unsupported direction %s", order.Direction)
+	}
+
+	if order.Validate {
+		return &orders.OrderFufilled{Timestamp: s.now.Unix(), Validated: true}, nil
+	}
+
+	s.sequence++
+	placed := &simulatedOrder{
+		complete: orders.OrderComplete{
+			TransactionID:  fmt.Sprintf("SIM-%06d", s.sequence),
+			ExchangeStatus: "open",
+			Pair:           order.Pair,
+			OrderType:      order.OrderType,
+			Type:           direction,
+			Volume:         volume,
+			OpenTime:       float64(s.now.Unix()),
+		},
+		placedAt:  s.now,
+		direction: direction,
+		replace:   order.ReplaceWithMarket,
+	}
+
+	if direction == "sell" && s.balance(order.Pair).LessThan(volume) {
+		return nil, fmt.Errorf("insufficient balance to sell %s %s", volume, order.Pair)
+	}
+
+	switch order.OrderType {
+	case "market":
+		s.fill(placed, price, s.now)
+
+	case "limit":
+		if order.LimitPrice == nil {
+			return nil, errors.New("limit order has no limit price")
+		}
+		placed.limit = *order.LimitPrice
+
+		if order.ExpireAfter != "" {
+			expireAfter, err := time.ParseDuration(order.ExpireAfter)
+			if err != nil {
+				return nil, fmt.Errorf("invalid expire_after %s: %w", order.ExpireAfter, err)
+			}
+			placed.expireAt = s.now.Add(expireAfter)
+		}
+
+		marketable := (direction == "buy" && price.LessThanOrEqual(placed.limit)) ||
+			(direction == "sell" && price.GreaterThanOrEqual(placed.limit))
+		if marketable {
+			s.fill(placed, price, s.now)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported order type %s", order.OrderType)
+	}
+
+	s.placed[placed.complete.TransactionID] = placed
+	s.ordered = append(s.ordered, placed.complete.TransactionID)
+
+	return &orders.OrderFufilled{
+		TransactionID: placed.complete.TransactionID,
+		Timestamp:     s.now.Unix(),
+		Result:        placed.complete,
+	}, nil
+}
+
+// ProcessTransaction gets the current state of the orders.
+func (s *SimulatedExchange) ProcessTransaction(ctx context.Context, transactionsIds ...string) (*[]orders.OrderComplete, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	completed := make([]orders.OrderComplete, 0, len(transactionsIds))
+	for _, txid := range transactionsIds {
+		order, ok := s.placed[txid]
+		if !ok {
+			return nil, fmt.Errorf("unknown transaction %s", txid)
+		}
+		completed = append(completed, order.complete)
+	}
+	return &completed, nil
+}
+
+// CancelOrder cancels the order if it is still open.
+func (s *SimulatedExchange) CancelOrder(ctx context.Context, transactionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.placed[transactionID]
+	if !ok {
+		return fmt.Errorf("unknown transaction %s", transactionID)
+	}
+
+	if order.complete.IsOpen() {
+		order.complete.ExchangeStatus = "canceled"
+	}
+	return nil
+}
+
+// GetBalances gets the balance of every asset bought.
+func (s *SimulatedExchange) GetBalances(ctx context.Context) (map[string]decimal.Decimal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	balances := make(map[string]decimal.Decimal, len(s.balances))
+	for asset, balance := range s.balances {
+		balances[asset] = balance
+	}
+	return balances, nil
+}
+
+// GetTicker gets the price of the pair at the current time
+// with no spread between the bid and ask.
+func (s *SimulatedExchange) GetTicker(ctx context.Context, pair string) (*orders.Ticker, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	price, err := s.price(pair)
+	if err != nil {
+		return nil, err
+	}
+	return &orders.Ticker{Pair: pair, Ask: price, Bid: price, Last: price}, nil
+}
+
+// GetOHLC gets the daily candles since the given time
+// which had closed by the current time.
+func (s *SimulatedExchange) GetOHLC(ctx context.Context, pair string, since time.Time) ([]orders.Candle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	candles, ok := s.candles[pair]
+	if !ok {
+		return nil, fmt.Errorf("no candles for pair %s", pair)
+	}
+
+	today := s.now.UTC().Truncate(24 * time.Hour)
+	daily := []orders.Candle{}
+	for _, candle := range candles {
+		if candle.Time.Before(since) {
+			continue
+		}
+		if !candle.Time.Before(today) {
+			break
+		}
+
+		day := candle.Time.UTC().Truncate(24 * time.Hour)
+		if len(daily) == 0 || !daily[len(daily)-1].Time.Equal(day) {
+			daily = append(daily, orders.Candle{Time: day, Open: candle.Open, High: candle.High, Low: candle.Low})
+		}
+
+		current := &daily[len(daily)-1]
+		current.High = decimal.Max(current.High, candle.High)
+		current.Low = decimal.Min(current.Low, candle.Low)
+		current.Close = candle.Close
+		current.Volume = current.Volume.Add(candle.Volume)
+	}
+
+	return daily, nil
+}
+
+// GetFeePct gets the fee charged on every order.
+func (s *SimulatedExchange) GetFeePct(ctx context.Context, pair string) (decimal.Decimal, error) {
+	return s.FeePct, nil
+}
+
+// GetPrice gets the price of the pair at the current time on any exchange.
+func (s *SimulatedExchange) GetPrice(ctx context.Context, exchange string, pair string) (decimal.Decimal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.price(pair)
+}
+
+// price is the open of the latest candle at or before the current time.
+func (s *SimulatedExchange) price(pair string) (decimal.Decimal, error) {
+	candles, ok := s.candles[pair]
+	if !ok {
+		return decimal.Zero, fmt.Errorf("no candles for pair %s", pair)
+	}
+
+	index := sort.Search(len(candles), func(i int) bool {
+		return candles[i].Time.After(s.now)
+	})
+	if index == 0 {
+		return decimal.Zero, fmt.Errorf("no candles for pair %s before %s", pair, s.now.Format(time.RFC3339))
+	}
+
+	return candles[index-1].Open, nil
+}
+
+// fill closes the order at the price charging the fee.
+func (s *SimulatedExchange) fill(order *simulatedOrder, price decimal.Decimal, at time.Time) {
+	order.complete.ExchangeStatus = "closed"
+	order.complete.Price = price
+	order.complete.Fee = price.Mul(order.complete.Volume).Mul(s.FeePct).Div(decimal.NewFromInt(100))
+	order.complete.CloseTime = float64(at.Unix())
+
+	asset := s.asset(order.complete.Pair)
+	if order.direction == "buy" {
+		s.balances[asset] = s.balances[asset].Add(order.complete.Volume)
+	} else {
+		s.balances[asset] = s.balances[asset].Sub(order.complete.Volume)
+	}
+}
+
+func (s *SimulatedExchange) balance(pair string) decimal.Decimal {
+	return s.balances[s.asset(pair)]
+}
+
+func (s *SimulatedExchange) asset(pair string) string {
+	if asset, ok := s.assets[pair]; ok {
+		return asset
+	}
+	return pair
+}
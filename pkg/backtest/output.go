@@ -0,0 +1,54 @@
+package backtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Output formats results can be written in.
+const (
+	FormatText string = "text"
+	FormatJSON string = "json"
+)
+
+// WriteResults writes the results of every variant for comparison.
+func WriteResults(w io.Writer, results []Result, format string) error {
+	switch format {
+	case FormatText:
+		return writeText(w, results)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	default:
+		return fmt.Errorf("unsupported backtest format %s", format)
+	}
+}
+
+// writeText writes a row per holding of each variant.
+func writeText(w io.Writer, results []Result) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "VARIANT\tRUNS\tORDERS\tPAIR\tUNITS\tAVERAGE COST\tINVESTED\tFEES\tVALUE\tRETURN\tMAX DRAWDOWN\t")
+
+	for _, r := range results {
+		if len(r.Holdings) == 0 {
+			fmt.Fprintf(writer, "%s\t%d\t%d\t-\t0\t-\t%s\t%s\t%s\t%s%%\t%s%%\t\n",
+				r.Name, r.Runs, r.Orders,
+				r.Invested.StringFixed(2), r.Fees.StringFixed(2), r.Value.StringFixed(2),
+				r.ReturnPct.StringFixed(2), r.MaxDrawdownPct.StringFixed(2))
+			continue
+		}
+
+		for _, h := range r.Holdings {
+			fmt.Fprintf(writer, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s%%\t%s%%\t\n",
+				r.Name, r.Runs, r.Orders,
+				h.Pair, h.Units, h.AverageCost.StringFixed(2),
+				h.Invested.StringFixed(2), h.Fees.StringFixed(2), h.Value.StringFixed(2),
+				r.ReturnPct.StringFixed(2), r.MaxDrawdownPct.StringFixed(2))
+		}
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,162 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/kiran94/dca-manager/pkg/runs"
+	"github.com/kiran94/dca-manager/pkg/strategy"
+)
+
+// simulation stands in for AWS while the executor runs a variant.
+//
+// The configuration is served as if from S3, the pending orders of
+// scheduled TWAP slices are kept to be placed later, the fills of the
+// exchange are the processed orders and the runs so far are the run history.
+type simulation struct {
+	config   configuration.DCAConfig
+	exchange *SimulatedExchange
+	orderers map[string]orders.Orderer
+
+	mu      sync.Mutex
+	slices  []orders.PendingOrders
+	history []runs.RunSummary
+}
+
+// GetDCAConfiguration gets a copy of the configuration of the variant.
+func (s *simulation) GetDCAConfiguration(ctx context.Context, s3Client pkg.S3Access, s3Bucket *string, s3ConfigPath *string) (*configuration.DCAConfig, error) {
+	conf := s.config
+	conf.Orders = append([]configuration.DCAOrder{}, s.config.Orders...)
+	return &conf, nil
+}
+
+// GetOrderers gets the simulated exchange under every exchange of the variant.
+func (s *simulation) GetOrderers(ctx context.Context, ssm pkg.SSMAccess) (*map[string]orders.Orderer, error) {
+	return &s.orderers, nil
+}
+
+// GetObject is never needed as nothing is read from S3.
+func (s *simulation) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return nil, errors.New("nothing is stored in a backtest")
+}
+
+// PutObject discards the pending orders written by the executor.
+func (s *simulation) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return &s3.PutObjectOutput{}, nil
+}
+
+// ListObjectsV2 lists nothing as nothing is stored.
+func (s *simulation) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return &s3.ListObjectsV2Output{}, nil
+}
+
+// SubmitPendingOrder keeps the TWAP slices scheduled by the executor,
+// every other order has already been filled or is resting on the exchange.
+func (s *simulation) SubmitPendingOrder(ctx context.Context, sc pkg.SQSAccess, po *orders.PendingOrders, exchange string, real bool, sqsQueue string) error {
+	if !po.IsScheduledSlice() {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slices = append(s.slices, *po)
+	return nil
+}
+
+// GetProcessedOrders gets the fills of the exchange so far.
+func (s *simulation) GetProcessedOrders(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string) (*[]orders.OrderComplete, error) {
+	fills := s.exchange.Fills()
+	return &fills, nil
+}
+
+// ListRuns gets the runs of the backtest so far, newest first.
+func (s *simulation) ListRuns(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, date string, limit int) ([]runs.RunSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	listed := []runs.RunSummary{}
+	for index := len(s.history) - 1; index >= 0; index-- {
+		listed = append(listed, s.history[index])
+	}
+	return listed, nil
+}
+
+// ListRunsBetween gets the runs of the backtest so far between the dates, newest first.
+func (s *simulation) ListRunsBetween(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, from string, to string) ([]runs.RunSummary, error) {
+	listed, err := s.ListRuns(ctx, s3Client, s3Bucket, s3Prefix, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	return runs.Between(listed, from, to), nil
+}
+
+// GetRun gets a run of the backtest.
+func (s *simulation) GetRun(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, runID string) (*runs.RunSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for index := range s.history {
+		if s.history[index].RunID == runID {
+			summary := s.history[index]
+			return &summary, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", runs.ErrRunNotFound, runID)
+}
+
+// record adds the run to the run history.
+func (s *simulation) record(summary *runs.RunSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = append(s.history, *summary)
+}
+
+// nextSlice takes the earliest scheduled slice due by the time.
+func (s *simulation) nextSlice(until time.Time) (*orders.PendingOrders, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sort.SliceStable(s.slices, func(i, j int) bool { return s.slices[i].ExecuteAt < s.slices[j].ExecuteAt })
+	if len(s.slices) == 0 || s.slices[0].ExecuteAt > until.Unix() {
+		return nil, false
+	}
+
+	due := s.slices[0]
+	s.slices = s.slices[1:]
+	return &due, true
+}
+
+// placeSlice places the scheduled slice and schedules the next
+// as the processor does when the slice comes off the queue.
+func (s *simulation) placeSlice(ctx context.Context, po *orders.PendingOrders) error {
+	plan, err := strategy.PlanTWAP(po.Order)
+	if err != nil {
+		return err
+	}
+
+	slice, err := plan.SliceOrder(po.Order, po.Slice)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.exchange.MakeOrder(ctx, slice); err != nil {
+		return err
+	}
+
+	if po.Slice+1 >= po.Slices {
+		return nil
+	}
+
+	next := *po
+	next.Slice++
+	next.ExecuteAt = time.Unix(po.ExecuteAt, 0).Add(plan.Interval).Unix()
+	return s.SubmitPendingOrder(ctx, nil, &next, "", true, "")
+}
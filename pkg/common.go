@@ -3,10 +3,14 @@ package pkg
 import (
 	"context"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/glue"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/kiran94/dca-manager/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // AWS S3
@@ -15,6 +19,7 @@ import (
 type S3Access interface {
 	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
 	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
 }
 
 // S3 is a Concrete Wrapper for S3 Operations
@@ -28,10 +33,21 @@ func (s S3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...
 }
 
 // PutObject puts an object into S3
-func (s S3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+func (s S3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (_ *s3.PutObjectOutput, err error) {
+	ctx, span := tracing.Start(ctx, "S3.PutObject",
+		attribute.String("s3.bucket", aws.ToString(params.Bucket)),
+		attribute.String("s3.key", aws.ToString(params.Key)),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	return s.Client.PutObject(ctx, params, optFns...)
 }
 
+// ListObjectsV2 lists objects within a S3 bucket
+func (s S3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return s.Client.ListObjectsV2(ctx, params, optFns...)
+}
+
 // AWS SSM
 
 // SSMAccess is an abstraction for SSM Operations
@@ -55,6 +71,8 @@ func (s SSM) GetParameter(ctx context.Context, params *ssm.GetParameterInput, op
 type SQSAccess interface {
 	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
 	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
 }
 
 // SQS is a Concrete Wrapper for SQS
@@ -72,6 +90,16 @@ func (s SQS) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput,
 	return s.Client.DeleteMessage(ctx, params, optFns...)
 }
 
+// ReceiveMessage receives messages from SQS
+func (s SQS) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	return s.Client.ReceiveMessage(ctx, params, optFns...)
+}
+
+// ChangeMessageVisibility changes how long a received message is hidden on SQS
+func (s SQS) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return s.Client.ChangeMessageVisibility(ctx, params, optFns...)
+}
+
 // AWS Glue
 
 // GlueAccess is an abstraction for AWS Glue
@@ -85,6 +113,26 @@ type Glue struct {
 }
 
 // StartJobRun starts a new AWS Glue job.
-func (g Glue) StartJobRun(ctx context.Context, params *glue.StartJobRunInput, optFns ...func(*glue.Options)) (*glue.StartJobRunOutput, error) {
+func (g Glue) StartJobRun(ctx context.Context, params *glue.StartJobRunInput, optFns ...func(*glue.Options)) (_ *glue.StartJobRunOutput, err error) {
+	ctx, span := tracing.Start(ctx, "Glue.StartJobRun", attribute.String("glue.job", aws.ToString(params.JobName)))
+	defer func() { tracing.End(span, err) }()
+
 	return g.Client.StartJobRun(ctx, params, optFns...)
 }
+
+// AWS SNS
+
+// SNSAccess is an abstraction for SNS Operations
+type SNSAccess interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// SNS is a Concrete Wrapper for SNS
+type SNS struct {
+	Client *sns.Client
+}
+
+// Publish publishes a message to a SNS topic.
+func (s SNS) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	return s.Client.Publish(ctx, params, optFns...)
+}
@@ -0,0 +1,179 @@
+package configuration
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Layouts of the dates in the configuration, where an annual
+// date without the year recurs on the same day every year.
+const (
+	DateLayout       = "2006-01-02"
+	AnnualDateLayout = "01-02"
+)
+
+// Exclusion is a day or range of days orders are not executed on.
+//
+// A date or the from and to dates of an inclusive range are either a
+// single date (yyyy-mm-dd) or recur every year (mm-dd) e.g from 12-25
+// to 12-31 skips the last week of December, ranges which recur every
+// year can wrap into the new year. Weekdays skip those days every week.
+type Exclusion struct {
+	Date     string   `json:"date,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       string   `json:"to,omitempty"`
+	Weekdays []string `json:"weekdays,omitempty"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+// Excludes decides if the day of the time is excluded.
+func (e Exclusion) Excludes(now time.Time) (bool, error) {
+	now = now.UTC()
+
+	if e.Date != "" {
+		if excluded, err := inRange(e.Date, e.Date, now); err != nil || excluded {
+			return excluded, err
+		}
+	}
+
+	if e.From != "" || e.To != "" {
+		if excluded, err := inRange(e.From, e.To, now); err != nil || excluded {
+			return excluded, err
+		}
+	}
+
+	for _, weekday := range e.Weekdays {
+		if !isWeekday(weekday) {
+			return false, fmt.Errorf("unsupported weekday %s", weekday)
+		}
+		if strings.EqualFold(weekday, now.Weekday().String()) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Describe is why an order was skipped for the exclusion.
+func (e Exclusion) Describe() string {
+	if e.Reason != "" {
+		return "excluded: " + e.Reason
+	}
+
+	switch {
+	case e.Date != "":
+		return "excluded on " + e.Date
+	case e.From != "" || e.To != "":
+		return fmt.Sprintf("excluded from %s to %s", e.From, e.To)
+	default:
+		return "excluded on " + strings.Join(e.Weekdays, ", ")
+	}
+}
+
+// validate checks the dates and weekdays of the exclusion can be read.
+func (e Exclusion) validate() error {
+	if e.Date == "" && e.From == "" && e.To == "" && len(e.Weekdays) == 0 {
+		return fmt.Errorf("exclusion needs a date, from and to or weekdays")
+	}
+
+	if e.Date != "" {
+		if _, err := inRange(e.Date, e.Date, time.Time{}); err != nil {
+			return err
+		}
+	}
+	if e.From != "" || e.To != "" {
+		if _, err := inRange(e.From, e.To, time.Time{}); err != nil {
+			return err
+		}
+	}
+	for _, weekday := range e.Weekdays {
+		if !isWeekday(weekday) {
+			return fmt.Errorf("unsupported weekday %s", weekday)
+		}
+	}
+	return nil
+}
+
+// Excluded gets the exclusion of the configuration or
+// the order which applies to the order at the time.
+func (d DCAConfig) Excluded(order DCAOrder, now time.Time) (*Exclusion, error) {
+	exclusions := append(append([]Exclusion{}, d.Exclusions...), order.Exclusions...)
+	for _, exclusion := range exclusions {
+		excluded, err := exclusion.Excludes(now)
+		if err != nil {
+			return nil, err
+		}
+
+		if excluded {
+			exclusion := exclusion
+			return &exclusion, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// OutsideWindow describes why the order is outside its start and end dates
+// at the time, where both dates are inclusive. It is empty inside the window.
+func (o DCAOrder) OutsideWindow(now time.Time) (string, error) {
+	today := now.UTC().Format(DateLayout)
+
+	if o.StartDate != "" {
+		if _, err := time.Parse(DateLayout, o.StartDate); err != nil {
+			return "", fmt.Errorf("invalid start_date %s", o.StartDate)
+		}
+		if today < o.StartDate {
+			return "starts on " + o.StartDate, nil
+		}
+	}
+
+	if o.EndDate != "" {
+		if _, err := time.Parse(DateLayout, o.EndDate); err != nil {
+			return "", fmt.Errorf("invalid end_date %s", o.EndDate)
+		}
+		if today > o.EndDate {
+			return "ended on " + o.EndDate, nil
+		}
+	}
+
+	return "", nil
+}
+
+// inRange decides if the day of the time is between the inclusive dates,
+// which are both single dates or both recur every year.
+func inRange(from string, to string, now time.Time) (bool, error) {
+	if from == "" || to == "" {
+		return false, fmt.Errorf("exclusion needs both from and to")
+	}
+
+	_, fromErr := time.Parse(DateLayout, from)
+	_, toErr := time.Parse(DateLayout, to)
+	if fromErr == nil && toErr == nil {
+		today := now.Format(DateLayout)
+		return from <= today && today <= to, nil
+	}
+
+	_, fromErr = time.Parse(AnnualDateLayout, from)
+	_, toErr = time.Parse(AnnualDateLayout, to)
+	if fromErr != nil || toErr != nil {
+		return false, fmt.Errorf("invalid exclusion %s to %s, expected yyyy-mm-dd or mm-dd", from, to)
+	}
+
+	today := now.Format(AnnualDateLayout)
+	if from <= to {
+		return from <= today && today <= to, nil
+	}
+
+	// Wraps into the new year e.g 12-24 to 01-02
+	return today >= from || today <= to, nil
+}
+
+func isWeekday(name string) bool {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(name, day.String()) {
+			return true
+		}
+	}
+	return false
+}
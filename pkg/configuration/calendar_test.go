@@ -0,0 +1,98 @@
+package configuration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func day(value string) time.Time {
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		panic(err)
+	}
+	return t.Add(6 * time.Hour)
+}
+
+// Ensures single dates, ranges which recur every year
+// including over the new year and weekdays are excluded
+func TestExclusionExcludes(t *testing.T) {
+	cases := []struct {
+		exclusion Exclusion
+		day       string
+		expected  bool
+	}{
+		{Exclusion{Date: "2022-12-25"}, "2022-12-25", true},
+		{Exclusion{Date: "2022-12-25"}, "2023-12-25", false},
+		{Exclusion{Date: "12-25"}, "2023-12-25", true},
+		{Exclusion{From: "2022-12-20", To: "2023-01-05"}, "2023-01-05", true},
+		{Exclusion{From: "2022-12-20", To: "2023-01-05"}, "2023-01-06", false},
+		{Exclusion{From: "12-25", To: "12-31"}, "2024-12-25", true},
+		{Exclusion{From: "12-25", To: "12-31"}, "2024-12-24", false},
+		{Exclusion{From: "12-24", To: "01-02"}, "2024-12-31", true},
+		{Exclusion{From: "12-24", To: "01-02"}, "2025-01-02", true},
+		{Exclusion{From: "12-24", To: "01-02"}, "2025-01-03", false},
+		{Exclusion{Weekdays: []string{"Saturday", "sunday"}}, "2022-01-08", true},
+		{Exclusion{Weekdays: []string{"Saturday", "sunday"}}, "2022-01-07", false},
+	}
+
+	for _, c := range cases {
+		excluded, err := c.exclusion.Excludes(day(c.day))
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, excluded, "%+v on %s", c.exclusion, c.day)
+	}
+
+	for _, invalid := range []Exclusion{
+		{},
+		{Date: "christmas"},
+		{From: "12-25"},
+		{From: "2022-12-25", To: "12-31"},
+		{Weekdays: []string{"someday"}},
+	} {
+		assert.NotNil(t, invalid.validate(), "%+v", invalid)
+	}
+}
+
+// Ensures the exclusions of the configuration and
+// the order both apply to the order
+func TestExcluded(t *testing.T) {
+	conf := DCAConfig{Exclusions: []Exclusion{{From: "12-25", To: "12-31", Reason: "last week of December"}}}
+	order := DCAOrder{Exclusions: []Exclusion{{Weekdays: []string{"monday"}}}}
+
+	exclusion, err := conf.Excluded(order, day("2022-12-27"))
+	assert.Nil(t, err)
+	assert.Equal(t, "excluded: last week of December", exclusion.Describe())
+
+	exclusion, err = conf.Excluded(order, day("2022-01-03"))
+	assert.Nil(t, err)
+	assert.Equal(t, "excluded on monday", exclusion.Describe())
+
+	exclusion, err = conf.Excluded(order, day("2022-01-04"))
+	assert.Nil(t, err)
+	assert.Nil(t, exclusion)
+}
+
+// Ensures orders are only inside their window
+// between the start and end dates inclusive
+func TestOutsideWindow(t *testing.T) {
+	order := DCAOrder{StartDate: "2022-01-07", EndDate: "2022-06-30"}
+
+	for value, expected := range map[string]string{
+		"2022-01-06": "starts on 2022-01-07",
+		"2022-01-07": "",
+		"2022-06-30": "",
+		"2022-07-01": "ended on 2022-06-30",
+	} {
+		reason, err := order.OutsideWindow(day(value))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, reason, value)
+	}
+
+	reason, err := DCAOrder{}.OutsideWindow(day("2022-01-07"))
+	assert.Nil(t, err)
+	assert.Empty(t, reason)
+
+	_, err = DCAOrder{EndDate: "June"}.OutsideWindow(day("2022-01-07"))
+	assert.NotNil(t, err)
+}
@@ -3,10 +3,15 @@ package configuration
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/schedule"
+	"github.com/shopspring/decimal"
 )
 
 // Environment Variable names to retrieve.
@@ -19,15 +24,158 @@ const (
 	EnvS3ProcessedTransaction          string = "DCA_PROCESSED_ORDER_S3_PREFIX"
 	EnvGlueProcessTransactionJob       string = "DCA_GLUE_PROCESS_TRANSACTION_JOB"
 	EnvGlueProcessTransactionOperation string = "DCA_GLUE_PROCESS_TRANSACTION_OPERATION"
+	EnvS3Withdrawal                    string = "DCA_WITHDRAWAL_S3_PREFIX"
+	EnvS3Runs                          string = "DCA_RUNS_S3_PREFIX"
+	EnvNotifySlackWebhook              string = "DCA_NOTIFY_SLACK_WEBHOOK_URL"
+	EnvNotifyDiscordWebhook            string = "DCA_NOTIFY_DISCORD_WEBHOOK_URL"
+	EnvNotifyWebhook                   string = "DCA_NOTIFY_WEBHOOK_URL"
+	EnvNotifySNSTopic                  string = "DCA_NOTIFY_SNS_TOPIC_ARN"
+	EnvNotifyTemplate                  string = "DCA_NOTIFY_TEMPLATE"
+	EnvMetricsNamespace                string = "DCA_METRICS_NAMESPACE"
+	EnvMetricsAddress                  string = "DCA_METRICS_ADDR"
+	EnvDaemonAddress                   string = "DCAD_ADDR"
+	EnvDaemonDataDir                   string = "DCAD_DATA_DIR"
+	EnvAPIToken                        string = "DCA_API_TOKEN"
+	EnvAPIAddress                      string = "DCA_API_ADDR"
+	EnvS3Overrides                     string = "DCA_OVERRIDES_S3_PATH"
+	EnvSQSPendingOrdersDLQ             string = "DCA_PENDING_ORDERS_DLQ_URL"
+	EnvS3DLQArchive                    string = "DCA_DLQ_ARCHIVE_S3_PREFIX"
+)
+
+// Failure policies decide what happens to the rest of a run when an order fails.
+const (
+	FailurePolicyFailFast         string = "fail_fast"
+	FailurePolicyContinue         string = "continue"
+	FailurePolicyContinueThenFail string = "continue_then_fail"
 )
 
 // DCAConfig is the root object for DCA configuration.
+//
+// The version is the S3 version of the configuration object,
+// or the ETag when the bucket is not versioned.
 type DCAConfig struct {
-	Orders []DCAOrder `json:"orders"`
+	Schema         string                      `json:"$schema,omitempty"`
+	Orders         []DCAOrder                  `json:"orders"`
+	Portfolio      *PortfolioConfig            `json:"portfolio,omitempty"`
+	Withdrawals    map[string]WithdrawalConfig `json:"withdrawals,omitempty"`
+	FailurePolicy  string                      `json:"failure_policy,omitempty"`
+	MaxConcurrency int                         `json:"max_concurrency,omitempty"`
+	Schedule       *ScheduleConfig             `json:"schedule,omitempty"`
+	Exclusions     []Exclusion                 `json:"exclusions,omitempty"`
+	Version        string                      `json:"-"`
+}
+
+// ScheduleConfig is when the daemon executes the orders, on Lambda
+// the schedules are EventBridge rules instead and this is ignored.
+//
+// Runs missed while the daemon was down are caught up according
+// to the catch up policy when they were missed within the window.
+type ScheduleConfig struct {
+	Expressions   []string `json:"expressions"`
+	CatchUp       string   `json:"catch_up,omitempty"`
+	CatchUpWindow string   `json:"catch_up_window,omitempty"`
+}
+
+// GetCatchUpWindow gets how long ago a missed run can be caught up
+// where there is no limit by default.
+func (s ScheduleConfig) GetCatchUpWindow() (time.Duration, error) {
+	if s.CatchUpWindow == "" {
+		return 0, nil
+	}
+
+	window, err := time.ParseDuration(s.CatchUpWindow)
+	if err != nil {
+		return 0, fmt.Errorf("invalid catch_up_window %s: %w", s.CatchUpWindow, err)
+	}
+	return window, nil
+}
+
+// GetMaxConcurrency gets how many orders can execute at once
+// where orders run one after another by default.
+func (d DCAConfig) GetMaxConcurrency() int {
+	if d.MaxConcurrency < 1 {
+		return 1
+	}
+	return d.MaxConcurrency
+}
+
+// GetFailurePolicy gets the failure policy of the run
+// which stops at the first failing order by default.
+func (d DCAConfig) GetFailurePolicy() (string, error) {
+	switch d.FailurePolicy {
+	case "":
+		return FailurePolicyFailFast, nil
+	case FailurePolicyFailFast, FailurePolicyContinue, FailurePolicyContinueThenFail:
+		return d.FailurePolicy, nil
+	default:
+		return "", fmt.Errorf("unsupported failure_policy %s", d.FailurePolicy)
+	}
+}
+
+// Validate checks the configuration can be executed, returning
+// every problem found so they can all be fixed at once.
+func (d DCAConfig) Validate() []error {
+	problems := []error{}
+
+	ids := map[string]int{}
+	for index, order := range d.Orders {
+		for _, err := range order.problems() {
+			problems = append(problems, fmt.Errorf("orders[%d]: %w", index, err))
+		}
+
+		if order.ID == "" {
+			continue
+		}
+		if previous, ok := ids[order.ID]; ok {
+			problems = append(problems, fmt.Errorf("orders[%d]: id %s is already used by orders[%d]", index, order.ID, previous))
+		}
+		ids[order.ID] = index
+	}
+
+	if _, err := d.GetFailurePolicy(); err != nil {
+		problems = append(problems, err)
+	}
+
+	for index, exclusion := range d.Exclusions {
+		if err := exclusion.validate(); err != nil {
+			problems = append(problems, fmt.Errorf("exclusions[%d]: %w", index, err))
+		}
+	}
+
+	if d.MaxConcurrency < 0 {
+		problems = append(problems, fmt.Errorf("max_concurrency must not be negative"))
+	}
+
+	if d.Schedule != nil {
+		if _, err := schedule.ParseAll(d.Schedule.Expressions); err != nil {
+			problems = append(problems, fmt.Errorf("schedule: %w", err))
+		}
+
+		switch d.Schedule.CatchUp {
+		case "", schedule.CatchUpNone, schedule.CatchUpLatest, schedule.CatchUpAll:
+		default:
+			problems = append(problems, fmt.Errorf("schedule: unsupported catch_up %s", d.Schedule.CatchUp))
+		}
+
+		if _, err := d.Schedule.GetCatchUpWindow(); err != nil {
+			problems = append(problems, fmt.Errorf("schedule: %w", err))
+		}
+	}
+
+	return problems
 }
 
 // DCAOrder is a single order to be executed
+//
+// The ID is optional but is needed to pause the order with an
+// override or to limit its executions, so should not change once
+// the order has been added.
+//
+// The order is only executed between the inclusive start and end
+// dates, up to the maximum number of executions and not on the days
+// excluded for the order or by the configuration.
 type DCAOrder struct {
+	ID        string `json:"id,omitempty"`
 	Exchange  string `json:"exchange"`
 	Direction string `json:"direction"`
 	OrderType string `json:"ordertype"`
@@ -35,6 +183,200 @@ type DCAOrder struct {
 	Pair      string `json:"pair"`
 	Validate  bool   `json:"validate"`
 	Enabled   bool   `json:"enabled"`
+
+	LimitPrice        *decimal.Decimal `json:"limit_price,omitempty"`
+	LimitOffsetPct    *decimal.Decimal `json:"limit_offset_pct,omitempty"`
+	ExpireAfter       string           `json:"expire_after,omitempty"`
+	ReplaceWithMarket bool             `json:"replace_with_market,omitempty"`
+
+	StartDate     string      `json:"start_date,omitempty"`
+	EndDate       string      `json:"end_date,omitempty"`
+	MaxExecutions int         `json:"max_executions,omitempty"`
+	Exclusions    []Exclusion `json:"exclusions,omitempty"`
+
+	ValueAveraging *ValueAveragingConfig `json:"value_averaging,omitempty"`
+	Dip            *DipConfig            `json:"dip,omitempty"`
+	Execution      *ExecutionConfig      `json:"execution,omitempty"`
+}
+
+// problems checks the order can be placed on an exchange.
+func (o DCAOrder) problems() []error {
+	problems := []error{}
+
+	if o.Exchange == "" {
+		problems = append(problems, fmt.Errorf("exchange is required"))
+	}
+	if o.Pair == "" {
+		problems = append(problems, fmt.Errorf("pair is required"))
+	}
+
+	// Value averaging sizes the order and decides the direction itself
+	if o.ValueAveraging == nil {
+		if o.Direction != "buy" && o.Direction != "sell" {
+			problems = append(problems, fmt.Errorf("unsupported direction %s", o.Direction))
+		}
+
+		volume, err := decimal.NewFromString(o.Volume)
+		if err != nil || !volume.IsPositive() {
+			problems = append(problems, fmt.Errorf("volume %s must be a positive number", o.Volume))
+		}
+	} else {
+		if _, err := time.Parse(DateLayout, o.ValueAveraging.StartDate); err != nil {
+			problems = append(problems, fmt.Errorf("value_averaging: invalid start_date %s", o.ValueAveraging.StartDate))
+		}
+
+		switch o.ValueAveraging.Period {
+		case "daily", "weekly", "monthly":
+		default:
+			problems = append(problems, fmt.Errorf("value_averaging: unsupported period %s", o.ValueAveraging.Period))
+		}
+	}
+
+	switch o.OrderType {
+	case "market":
+	case "limit":
+		if o.LimitPrice == nil && o.LimitOffsetPct == nil {
+			problems = append(problems, fmt.Errorf("limit orders require limit_price or limit_offset_pct"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("unsupported ordertype %s", o.OrderType))
+	}
+
+	if o.ExpireAfter != "" {
+		if _, err := time.ParseDuration(o.ExpireAfter); err != nil {
+			problems = append(problems, fmt.Errorf("invalid expire_after %s", o.ExpireAfter))
+		}
+	}
+
+	if _, err := time.Parse(DateLayout, o.StartDate); o.StartDate != "" && err != nil {
+		problems = append(problems, fmt.Errorf("invalid start_date %s", o.StartDate))
+	}
+	if _, err := time.Parse(DateLayout, o.EndDate); o.EndDate != "" && err != nil {
+		problems = append(problems, fmt.Errorf("invalid end_date %s", o.EndDate))
+	}
+	if o.StartDate != "" && o.EndDate != "" && o.EndDate < o.StartDate {
+		problems = append(problems, fmt.Errorf("end_date %s is before start_date %s", o.EndDate, o.StartDate))
+	}
+
+	if o.MaxExecutions < 0 {
+		problems = append(problems, fmt.Errorf("max_executions must not be negative"))
+	}
+	if o.MaxExecutions > 0 && o.ID == "" {
+		problems = append(problems, fmt.Errorf("max_executions needs an id to count the executions of the order"))
+	}
+
+	for index, exclusion := range o.Exclusions {
+		if err := exclusion.validate(); err != nil {
+			problems = append(problems, fmt.Errorf("exclusions[%d]: %w", index, err))
+		}
+	}
+
+	if o.Dip != nil {
+		if o.Dip.LookbackDays < 1 {
+			problems = append(problems, fmt.Errorf("dip: lookback_days must be at least 1"))
+		}
+		for index, tier := range o.Dip.Tiers {
+			if !tier.Multiplier.IsPositive() {
+				problems = append(problems, fmt.Errorf("dip: tiers[%d] multiplier %s must be positive", index, tier.Multiplier))
+			}
+		}
+		if o.Dip.AboveAverageMultiplier != nil && !o.Dip.AboveAverageMultiplier.IsPositive() {
+			problems = append(problems, fmt.Errorf("dip: above_average_multiplier %s must be positive", o.Dip.AboveAverageMultiplier))
+		}
+	}
+
+	if o.Execution != nil {
+		if o.Execution.Strategy != "twap" {
+			problems = append(problems, fmt.Errorf("execution: unsupported strategy %s", o.Execution.Strategy))
+		}
+		if o.Execution.Slices < 1 {
+			problems = append(problems, fmt.Errorf("execution: slices must be at least 1"))
+		}
+		if _, err := time.ParseDuration(o.Execution.Interval); err != nil {
+			problems = append(problems, fmt.Errorf("execution: invalid interval %s", o.Execution.Interval))
+		}
+	}
+
+	return problems
+}
+
+// ExecutionConfig controls how an order is worked on the exchange.
+//
+// With the twap strategy the volume is split into equal slices
+// where each slice is placed one interval after the previous.
+type ExecutionConfig struct {
+	Strategy string `json:"strategy"`
+	Slices   int    `json:"slices"`
+	Interval string `json:"interval"`
+}
+
+// ValueAveragingConfig sizes an order so the market value of the
+// position grows by a fixed increment every period since the start date.
+//
+// Min and Max clamp the value traded in a single run where
+// a negative value is a sell e.g min of -100 sells at most 100.
+type ValueAveragingConfig struct {
+	StartDate string           `json:"start_date"`
+	Period    string           `json:"period"`
+	Increment decimal.Decimal  `json:"increment"`
+	Min       *decimal.Decimal `json:"min,omitempty"`
+	Max       *decimal.Decimal `json:"max,omitempty"`
+	AllowSell bool             `json:"allow_sell"`
+	DryRun    bool             `json:"dry_run"`
+}
+
+// PortfolioConfig is a target allocation where each run
+// the contribution is split across the targets to move
+// the holdings on the exchange toward the target weights.
+type PortfolioConfig struct {
+	Exchange     string            `json:"exchange"`
+	Contribution decimal.Decimal   `json:"contribution"`
+	Tolerance    decimal.Decimal   `json:"tolerance"`
+	AllowSell    bool              `json:"allow_sell"`
+	Validate     bool              `json:"validate"`
+	Enabled      bool              `json:"enabled"`
+	Targets      []PortfolioTarget `json:"targets"`
+}
+
+// PortfolioTarget is the desired weight of a single asset
+// where the asset is the name the exchange reports balances under
+// and the pair is what is traded to buy or sell it.
+type PortfolioTarget struct {
+	Asset  string          `json:"asset"`
+	Pair   string          `json:"pair"`
+	Weight decimal.Decimal `json:"weight"`
+}
+
+// DipConfig scales the volume of an order based on recent price action.
+//
+// The deepest tier whose drawdown from the high of the lookback period
+// has been reached applies, otherwise when the price is above the moving
+// average the above average multiplier applies.
+type DipConfig struct {
+	LookbackDays           int              `json:"lookback_days"`
+	Tiers                  []DipTier        `json:"tiers"`
+	MovingAverageDays      int              `json:"moving_average_days,omitempty"`
+	AboveAverageMultiplier *decimal.Decimal `json:"above_average_multiplier,omitempty"`
+}
+
+// DipTier multiplies the volume when the price is at least
+// the drawdown percentage below the recent high.
+type DipTier struct {
+	DrawdownPct decimal.Decimal `json:"drawdown_pct"`
+	Multiplier  decimal.Decimal `json:"multiplier"`
+}
+
+// WithdrawalConfig moves an asset off the exchange to cold storage
+// once the balance reaches the threshold, leaving the keep amount behind.
+//
+// The key is the name of a withdrawal address which
+// has already been approved on the exchange.
+type WithdrawalConfig struct {
+	Exchange  string          `json:"exchange"`
+	Key       string          `json:"key"`
+	Threshold decimal.Decimal `json:"threshold"`
+	Keep      decimal.Decimal `json:"keep"`
+	Enabled   bool            `json:"enabled"`
 }
 
 // DCAConfiguration gets configuration from an underlying source.
@@ -69,5 +411,11 @@ func (d DCAConfiguration) GetDCAConfiguration(ctx context.Context, s3Client pkg.
 		return nil, jsonErr
 	}
 
+	if configObject.VersionId != nil {
+		dcaConfig.Version = *configObject.VersionId
+	} else if configObject.ETag != nil {
+		dcaConfig.Version = strings.Trim(*configObject.ETag, `"`)
+	}
+
 	return &dcaConfig, nil
 }
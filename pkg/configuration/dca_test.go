@@ -7,9 +7,11 @@ import (
 	"errors"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/kiran94/dca-manager/pkg"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -81,3 +83,94 @@ func TestGetDCAConfiguration(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, dcaConfig, resultConfig)
 }
+
+// Ensures the version of the configuration object
+// is kept, falling back to the ETag
+func TestGetDCAConfigurationVersion(t *testing.T) {
+	s3Bucket := "myBucket"
+	s3ConfigPath := "my/config.json"
+	versionID := "3HL4kqtJlcpXroDTDmJ"
+	etag := `"9b2cf535f27731c974343645a3985328"`
+
+	for expected, output := range map[string]*s3.GetObjectOutput{
+		versionID:                          {VersionId: &versionID, ETag: &etag},
+		"9b2cf535f27731c974343645a3985328": {ETag: &etag},
+	} {
+		output.Body = io.NopCloser(bytes.NewReader([]byte(`{"orders": []}`)))
+
+		s3Access := pkg.MockS3Access{}
+		s3Access.On("GetObject", mock.Anything, mock.Anything, mock.Anything).Return(output, nil).Once()
+
+		resultConfig, err := DCAConfiguration{}.GetDCAConfiguration(context.Background(), s3Access, &s3Bucket, &s3ConfigPath)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, resultConfig.Version)
+	}
+}
+
+// Ensures the catch up window is a duration
+// with no limit when it is not set
+func TestGetCatchUpWindow(t *testing.T) {
+	window, err := ScheduleConfig{}.GetCatchUpWindow()
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), window)
+
+	window, err = ScheduleConfig{CatchUpWindow: "36h"}.GetCatchUpWindow()
+	assert.Nil(t, err)
+	assert.Equal(t, 36*time.Hour, window)
+
+	_, err = ScheduleConfig{CatchUpWindow: "a day"}.GetCatchUpWindow()
+	assert.NotNil(t, err)
+}
+
+// Ensures every problem with the configuration
+// is found and a valid configuration has none
+func TestValidate(t *testing.T) {
+	limitPrice := decimal.NewFromInt(100)
+	valid := DCAConfig{
+		Orders: []DCAOrder{
+			{ID: "btc-weekly", Exchange: "kraken", Direction: "buy", OrderType: "market", Volume: "0.01", Pair: "BTCGBP"},
+			{ID: "eth-weekly", Exchange: "auto", Direction: "sell", OrderType: "limit", Volume: "1", Pair: "ETHGBP", LimitPrice: &limitPrice, ExpireAfter: "4h"},
+			{Exchange: "kraken", OrderType: "market", Pair: "BTCGBP", ValueAveraging: &ValueAveragingConfig{StartDate: "2022-01-01", Period: "monthly"}},
+			{ID: "btc-2022", Exchange: "kraken", Direction: "buy", OrderType: "market", Volume: "0.01", Pair: "BTCGBP", StartDate: "2022-01-01", EndDate: "2022-12-31", MaxExecutions: 52, Exclusions: []Exclusion{{Weekdays: []string{"Saturday", "sunday"}}}},
+		},
+		FailurePolicy: FailurePolicyContinue,
+		Exclusions:    []Exclusion{{From: "12-24", To: "01-02", Reason: "holidays"}, {Date: "2022-04-15"}},
+		Schedule:      &ScheduleConfig{Expressions: []string{"cron(0 6 * * ? *)"}, CatchUp: "latest", CatchUpWindow: "36h"},
+	}
+	assert.Empty(t, valid.Validate())
+
+	invalid := DCAConfig{
+		Orders: []DCAOrder{
+			{ID: "btc-weekly", Direction: "hold", OrderType: "stop", Volume: "-1"},
+			{ID: "btc-weekly", Exchange: "kraken", Direction: "buy", OrderType: "limit", Volume: "1", Pair: "BTCGBP", ExpireAfter: "soon", Execution: &ExecutionConfig{Strategy: "vwap", Interval: "1h"}},
+			{Exchange: "kraken", Direction: "buy", OrderType: "market", Volume: "1", Pair: "BTCGBP", StartDate: "2022-06-01", EndDate: "2022-01-01", MaxExecutions: 4, Exclusions: []Exclusion{{Weekdays: []string{"Someday"}}}},
+			{Exchange: "kraken", Direction: "buy", OrderType: "market", Volume: "1", Pair: "BTCGBP", Dip: &DipConfig{LookbackDays: 30, Tiers: []DipTier{{DrawdownPct: decimal.NewFromInt(10), Multiplier: decimal.Zero}}, AboveAverageMultiplier: &decimal.Zero}},
+		},
+		Exclusions:     []Exclusion{{}, {From: "12-24"}},
+		FailurePolicy:  "retry",
+		MaxConcurrency: -1,
+		Schedule:       &ScheduleConfig{Expressions: []string{"every day"}, CatchUp: "sometimes", CatchUpWindow: "a day"},
+	}
+
+	problems := invalid.Validate()
+	messages := []string{}
+	for _, problem := range problems {
+		messages = append(messages, problem.Error())
+	}
+
+	assert.Equal(t, 22, len(problems), messages)
+	assert.Contains(t, messages, "orders[1]: id btc-weekly is already used by orders[0]")
+	assert.Contains(t, messages, "orders[0]: exchange is required")
+	assert.Contains(t, messages, "orders[1]: limit orders require limit_price or limit_offset_pct")
+	assert.Contains(t, messages, "orders[1]: execution: slices must be at least 1")
+	assert.Contains(t, messages, "unsupported failure_policy retry")
+	assert.Contains(t, messages, "schedule: unsupported catch_up sometimes")
+	assert.Contains(t, messages, "orders[2]: end_date 2022-01-01 is before start_date 2022-06-01")
+	assert.Contains(t, messages, "orders[2]: max_executions needs an id to count the executions of the order")
+	assert.Contains(t, messages, "orders[2]: exclusions[0]: unsupported weekday Someday")
+	assert.Contains(t, messages, "exclusions[0]: exclusion needs a date, from and to or weekdays")
+	assert.Contains(t, messages, "exclusions[1]: exclusion needs both from and to")
+	assert.Contains(t, messages, "orders[3]: dip: tiers[0] multiplier 0 must be positive")
+	assert.Contains(t, messages, "orders[3]: dip: above_average_multiplier 0 must be positive")
+}
@@ -0,0 +1,214 @@
+// Package dlq inspects pending orders which failed processing too many
+// times and were moved to the dead-letter queue, so they can be sent
+// back to the pending orders queue or archived to S3.
+package dlq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/orders"
+)
+
+// MaxReceive is the most messages SQS returns from a single receive.
+const MaxReceive = 10
+
+// ReceiveWaitSeconds is how long each receive long polls for messages, as a
+// short poll only samples some of the SQS servers and can miss messages.
+const ReceiveWaitSeconds = 2
+
+// EmptyReceives is how many receives in a row must find nothing before
+// the queue is taken to have no more visible messages.
+const EmptyReceives = 3
+
+// Message is a message on the dead-letter queue along with the
+// pending order decoded from its body, when the body can be decoded.
+type Message struct {
+	MessageID     string                `json:"message_id"`
+	ReceiptHandle string                `json:"-"`
+	SentAt        time.Time             `json:"sent_at"`
+	ReceiveCount  int                   `json:"receive_count"`
+	Attributes    map[string]string     `json:"attributes"`
+	Body          string                `json:"body"`
+	Pending       *orders.PendingOrders `json:"pending,omitempty"`
+	DecodeError   string                `json:"decode_error,omitempty"`
+}
+
+// Attribute gets a message attribute such as Exchange, Real or TransactionId.
+func (m Message) Attribute(name string) string {
+	return m.Attributes[name]
+}
+
+// Inspector is an abstraction to work with the messages of a dead-letter queue.
+type Inspector interface {
+	Receive(ctx context.Context, sqsClient pkg.SQSAccess, queueURL string, visibility time.Duration) ([]Message, error)
+	Release(ctx context.Context, sqsClient pkg.SQSAccess, queueURL string, messages []Message) error
+	Redrive(ctx context.Context, sqsClient pkg.SQSAccess, queueURL string, targetURL string, message Message, body string) error
+	Archive(ctx context.Context, sqsClient pkg.SQSAccess, s3Client pkg.S3Access, queueURL string, s3Bucket string, s3Prefix string, message Message, now time.Time) (string, error)
+}
+
+// SQSInspector works with a dead-letter queue on SQS.
+type SQSInspector struct{}
+
+// Receive receives every visible message on the queue, hiding each for
+// the visibility timeout so they can be redriven or archived with their
+// receipt handle. Messages which are not should be released afterwards.
+//
+// SQS may return nothing while messages are still visible, so receiving
+// only stops after several long polls in a row find nothing.
+func (s SQSInspector) Receive(ctx context.Context, sqsClient pkg.SQSAccess, queueURL string, visibility time.Duration) ([]Message, error) {
+	messages := []Message{}
+	seen := map[string]bool{}
+	empty := 0
+
+	for empty < EmptyReceives {
+		received, err := sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            &queueURL,
+			MaxNumberOfMessages: MaxReceive,
+			VisibilityTimeout:   int32(visibility / time.Second),
+			WaitTimeSeconds:     ReceiveWaitSeconds,
+			AttributeNames: []types.QueueAttributeName{
+				types.QueueAttributeName(types.MessageSystemAttributeNameSentTimestamp),
+				types.QueueAttributeName(types.MessageSystemAttributeNameApproximateReceiveCount),
+			},
+			MessageAttributeNames: []string{"All"},
+		})
+		if err != nil {
+			return messages, err
+		}
+
+		if len(received.Messages) == 0 {
+			empty++
+			continue
+		}
+		empty = 0
+
+		for _, message := range received.Messages {
+			decoded := decode(message)
+			if seen[decoded.MessageID] {
+				continue
+			}
+			seen[decoded.MessageID] = true
+			messages = append(messages, decoded)
+		}
+	}
+
+	return messages, nil
+}
+
+// Release makes the messages visible on the queue again straight away.
+func (s SQSInspector) Release(ctx context.Context, sqsClient pkg.SQSAccess, queueURL string, messages []Message) error {
+	for _, message := range messages {
+		receiptHandle := message.ReceiptHandle
+		_, err := sqsClient.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          &queueURL,
+			ReceiptHandle:     &receiptHandle,
+			VisibilityTimeout: 0,
+		})
+		if err != nil {
+			return fmt.Errorf("could not release message %s: %w", message.MessageID, err)
+		}
+	}
+	return nil
+}
+
+// Redrive sends the body to the target queue with the attributes of the
+// message, then deletes the message from the dead-letter queue. The body
+// is the body of the message unless it was edited.
+func (s SQSInspector) Redrive(ctx context.Context, sqsClient pkg.SQSAccess, queueURL string, targetURL string, message Message, body string) error {
+	attributes := map[string]types.MessageAttributeValue{}
+	for name, value := range message.Attributes {
+		attributes[name] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+	}
+
+	// An edited body may have changed the transaction it is for
+	pending := &orders.PendingOrders{}
+	if err := json.Unmarshal([]byte(body), pending); err == nil && pending.TransactionID != "" {
+		attributes["TransactionId"] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(pending.TransactionID)}
+	}
+
+	_, err := sqsClient.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:          &targetURL,
+		MessageBody:       &body,
+		MessageAttributes: attributes,
+	})
+	if err != nil {
+		return fmt.Errorf("could not redrive message %s: %w", message.MessageID, err)
+	}
+
+	return s.delete(ctx, sqsClient, queueURL, message)
+}
+
+// Archive saves the message to S3 under the date it was archived on
+// then deletes it from the dead-letter queue, returning the S3 key.
+func (s SQSInspector) Archive(ctx context.Context, sqsClient pkg.SQSAccess, s3Client pkg.S3Access, queueURL string, s3Bucket string, s3Prefix string, message Message, now time.Time) (string, error) {
+	content, err := json.MarshalIndent(message, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	s3Key := fmt.Sprintf("%s/date=%s/%s.json", s3Prefix, now.UTC().Format("2006-01-02"), message.MessageID)
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &s3Bucket,
+		Key:    &s3Key,
+		Body:   bytes.NewReader(content),
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not archive message %s: %w", message.MessageID, err)
+	}
+
+	return s3Key, s.delete(ctx, sqsClient, queueURL, message)
+}
+
+func (s SQSInspector) delete(ctx context.Context, sqsClient pkg.SQSAccess, queueURL string, message Message) error {
+	receiptHandle := message.ReceiptHandle
+	_, err := sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      &queueURL,
+		ReceiptHandle: &receiptHandle,
+	})
+	if err != nil {
+		return fmt.Errorf("could not delete message %s: %w", message.MessageID, err)
+	}
+	return nil
+}
+
+// decode reads the attributes and the pending order of a received message.
+func decode(received types.Message) Message {
+	message := Message{
+		MessageID:     aws.ToString(received.MessageId),
+		ReceiptHandle: aws.ToString(received.ReceiptHandle),
+		Body:          aws.ToString(received.Body),
+		Attributes:    map[string]string{},
+	}
+
+	for name, attribute := range received.MessageAttributes {
+		if attribute.StringValue != nil {
+			message.Attributes[name] = *attribute.StringValue
+		}
+	}
+
+	if sent, err := strconv.ParseInt(received.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)], 10, 64); err == nil {
+		message.SentAt = time.Unix(0, sent*int64(time.Millisecond)).UTC()
+	}
+	if count, err := strconv.Atoi(received.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)]); err == nil {
+		message.ReceiveCount = count
+	}
+
+	pending := &orders.PendingOrders{}
+	if err := json.Unmarshal([]byte(message.Body), pending); err != nil {
+		message.DecodeError = err.Error()
+	} else {
+		message.Pending = pending
+	}
+
+	return message
+}
@@ -0,0 +1,164 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var now = time.Date(2022, 1, 7, 6, 0, 0, 0, time.UTC)
+
+// Queues routes SQS operations to local queues by their URL
+type Queues map[string]*queue.Local
+
+func (q Queues) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	return q[*params.QueueUrl].SendMessage(ctx, params, optFns...)
+}
+
+func (q Queues) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	return q[*params.QueueUrl].DeleteMessage(ctx, params, optFns...)
+}
+
+func (q Queues) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	return q[*params.QueueUrl].ReceiveMessage(ctx, params, optFns...)
+}
+
+func (q Queues) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return q[*params.QueueUrl].ChangeMessageVisibility(ctx, params, optFns...)
+}
+
+func newQueue(t *testing.T, bodies ...string) *queue.Local {
+	local, err := queue.NewLocal("")
+	assert.Nil(t, err)
+
+	for _, body := range bodies {
+		_, err := local.SendMessage(context.Background(), &sqs.SendMessageInput{
+			MessageBody: aws.String(body),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"Exchange":      {DataType: aws.String("String"), StringValue: aws.String("kraken")},
+				"Real":          {DataType: aws.String("String"), StringValue: aws.String("true")},
+				"TransactionId": {DataType: aws.String("String"), StringValue: aws.String("TX1")},
+			},
+		})
+		assert.Nil(t, err)
+	}
+	return local
+}
+
+// Ensures every message is received with its attributes and decoded pending order
+func TestReceive(t *testing.T) {
+	dlq := newQueue(t, `{"transaction_id": "TX1", "s3_bucket": "bucket", "s3_key": "pending/TX1.json"}`, "not json")
+
+	messages, err := SQSInspector{}.Receive(context.Background(), dlq, "dlq", time.Minute)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "kraken", messages[0].Attribute("Exchange"))
+	assert.Equal(t, "true", messages[0].Attribute("Real"))
+	assert.Equal(t, "TX1", messages[0].Pending.TransactionID)
+	assert.Equal(t, 1, messages[0].ReceiveCount)
+	assert.False(t, messages[0].SentAt.IsZero())
+	assert.Nil(t, messages[1].Pending)
+	assert.NotEmpty(t, messages[1].DecodeError)
+
+	// Received messages stay hidden until they are released
+	hidden, err := SQSInspector{}.Receive(context.Background(), dlq, "dlq", time.Minute)
+	assert.Nil(t, err)
+	assert.Empty(t, hidden)
+
+	assert.Nil(t, SQSInspector{}.Release(context.Background(), dlq, "dlq", messages))
+	released, err := SQSInspector{}.Receive(context.Background(), dlq, "dlq", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(released))
+}
+
+// Ensures receiving long polls and carries on past an empty receive
+// until several receives in a row find nothing
+func TestReceiveEmpty(t *testing.T) {
+	sqsAccess := &pkg.MockSQSAccess{}
+	longPoll := mock.MatchedBy(func(input *sqs.ReceiveMessageInput) bool {
+		return input.WaitTimeSeconds == ReceiveWaitSeconds
+	})
+
+	sqsAccess.On("ReceiveMessage", mock.Anything, longPoll, mock.Anything).Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+	sqsAccess.On("ReceiveMessage", mock.Anything, longPoll, mock.Anything).Return(&sqs.ReceiveMessageOutput{Messages: []types.Message{
+		{MessageId: aws.String("A"), Body: aws.String("not json")},
+	}}, nil).Once()
+	sqsAccess.On("ReceiveMessage", mock.Anything, longPoll, mock.Anything).Return(&sqs.ReceiveMessageOutput{}, nil).Times(EmptyReceives - 1)
+	sqsAccess.On("ReceiveMessage", mock.Anything, longPoll, mock.Anything).Return(&sqs.ReceiveMessageOutput{Messages: []types.Message{
+		{MessageId: aws.String("B"), Body: aws.String("not json")},
+	}}, nil).Once()
+	sqsAccess.On("ReceiveMessage", mock.Anything, longPoll, mock.Anything).Return(&sqs.ReceiveMessageOutput{}, nil).Times(EmptyReceives)
+
+	messages, err := SQSInspector{}.Receive(context.Background(), sqsAccess, "dlq", time.Minute)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "A", messages[0].MessageID)
+	assert.Equal(t, "B", messages[1].MessageID)
+	sqsAccess.AssertExpectations(t)
+}
+
+// Ensures redriven messages are sent to the target queue with their
+// attributes, taking the transaction of an edited body, and deleted
+func TestRedrive(t *testing.T) {
+	dlq := newQueue(t, `{"transaction_id": "TX1"}`)
+	target := newQueue(t)
+	queues := Queues{"dlq": dlq, "target": target}
+
+	messages, err := SQSInspector{}.Receive(context.Background(), queues, "dlq", time.Minute)
+	assert.Nil(t, err)
+
+	err = SQSInspector{}.Redrive(context.Background(), queues, "dlq", "target", messages[0], `{"transaction_id": "TX2"}`)
+	assert.Nil(t, err)
+	assert.Empty(t, dlq.Messages())
+
+	redriven := target.Messages()
+	assert.Equal(t, 1, len(redriven))
+	assert.Equal(t, `{"transaction_id": "TX2"}`, redriven[0].Body)
+	assert.Equal(t, "kraken", redriven[0].Attributes["Exchange"])
+	assert.Equal(t, "TX2", redriven[0].Attributes["TransactionId"])
+}
+
+// Ensures archived messages are saved under the date and deleted, and
+// are kept on the queue when they could not be saved
+func TestArchive(t *testing.T) {
+	dlq := newQueue(t, `{"transaction_id": "TX1"}`)
+	messages, err := SQSInspector{}.Receive(context.Background(), dlq, "dlq", time.Minute)
+	assert.Nil(t, err)
+
+	failing := &pkg.MockS3Access{}
+	failing.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, errors.New("denied"))
+
+	_, err = SQSInspector{}.Archive(context.Background(), dlq, failing, "dlq", "bucket", "dlq", messages[0], now)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(dlq.Messages()))
+
+	archived := ""
+	s3Access := &pkg.MockS3Access{}
+	s3Access.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		content, _ := ioutil.ReadAll(input.Body)
+		archived = string(content)
+		return *input.Bucket == "bucket"
+	}), mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	key, err := SQSInspector{}.Archive(context.Background(), dlq, s3Access, "dlq", "bucket", "dlq", messages[0], now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "dlq/date=2022-01-07/"+messages[0].MessageID+".json", key)
+	assert.Contains(t, archived, `"transaction_id": "TX1"`)
+	assert.Contains(t, archived, `"Exchange": "kraken"`)
+	assert.Empty(t, dlq.Messages())
+	s3Access.AssertExpectations(t)
+}
@@ -0,0 +1,920 @@
+// Package executor places the orders of the DCA configuration on exchanges
+// and submits the placed orders to the queue to be processed.
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/logging"
+	"github.com/kiran94/dca-manager/pkg/metrics"
+	"github.com/kiran94/dca-manager/pkg/notify"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/kiran94/dca-manager/pkg/overrides"
+	"github.com/kiran94/dca-manager/pkg/runs"
+	"github.com/kiran94/dca-manager/pkg/strategy"
+	"github.com/kiran94/dca-manager/pkg/tracing"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DCAServices contains all services to be injected into logic.
+type DCAServices struct {
+	awsConfig             aws.Config
+	s3Access              pkg.S3Access
+	ssmAccess             pkg.SSMAccess
+	sqsAccess             pkg.SQSAccess
+	configSource          configuration.DCAConfigurationSource
+	ordererFactory        orders.OrdererFactory
+	pendingOrderSubmitter orders.PendingOrderQueue
+	portfolioPlanner      strategy.PortfolioPlanner
+	processedOrderSource  orders.ProcessedOrderSource
+	valueAverager         strategy.ValueAverager
+	dipMultiplier         strategy.DipMultiplier
+	router                strategy.Router
+	notifier              notify.Notifier
+	runRecorder           runs.Recorder
+	runLoader             runs.Loader
+	overrideStore         overrides.Store
+	metrics               *metrics.Registry
+	clock                 func() time.Time
+}
+
+// AppConfig contains all configuration to be injected into logic
+type AppConfig struct {
+	s3bucket      string
+	dcaConfigPath string
+	allowReal     bool
+	transactions  struct {
+		pendingS3TransactionPrefix   string
+		processedS3TransactionPrefix string
+	}
+	queue struct {
+		sqsURL string
+	}
+	glue struct {
+		processTransactionJob       string
+		processTransactionOperation string
+	}
+	runs struct {
+		s3Prefix string
+	}
+	overrides struct {
+		s3Path string
+	}
+}
+
+// NewDCAServices creates the services used to execute orders on AWS
+// where pending orders are sent through the queue and metrics are recorded in the registry.
+func NewDCAServices(awsConfig aws.Config, queue pkg.SQSAccess, registry *metrics.Registry) *DCAServices {
+	return &DCAServices{
+		awsConfig:             awsConfig,
+		s3Access:              pkg.S3{Client: s3.NewFromConfig(awsConfig)},
+		ssmAccess:             pkg.SSM{Client: ssm.NewFromConfig(awsConfig)},
+		sqsAccess:             queue,
+		configSource:          configuration.DCAConfiguration{},
+		ordererFactory:        orders.OrdererFac{Metrics: registry},
+		pendingOrderSubmitter: orders.PendingOrderSubmitter{},
+		portfolioPlanner:      strategy.Rebalancer{},
+		processedOrderSource:  orders.ProcessedOrderLoader{},
+		valueAverager:         strategy.ValueAveraging{},
+		dipMultiplier:         strategy.DipBuyer{},
+		router:                strategy.BestExecution{},
+		notifier:              notify.FromEnvironment(pkg.SNS{Client: sns.NewFromConfig(awsConfig)}),
+		runRecorder:           runs.S3Recorder{},
+		runLoader:             runs.S3Loader{},
+		overrideStore:         overrides.S3Store{},
+		metrics:               registry,
+	}
+}
+
+// NewAppConfig creates the configuration from the environment.
+func NewAppConfig() *AppConfig {
+	appConfig := &AppConfig{
+		s3bucket:      os.Getenv(configuration.EnvS3Bucket),
+		dcaConfigPath: os.Getenv(configuration.EnvS3ConfigPath),
+		allowReal:     os.Getenv(configuration.EnvAllowReal) != "",
+	}
+	appConfig.transactions.pendingS3TransactionPrefix = os.Getenv(configuration.EnvS3PendingTransaction)
+	appConfig.transactions.processedS3TransactionPrefix = os.Getenv(configuration.EnvS3ProcessedTransaction)
+	appConfig.queue.sqsURL = os.Getenv(configuration.EnvSQSPendingOrdersQueue)
+	appConfig.glue.processTransactionJob = os.Getenv(configuration.EnvGlueProcessTransactionJob)
+	appConfig.glue.processTransactionOperation = os.Getenv(configuration.EnvGlueProcessTransactionOperation)
+	appConfig.runs.s3Prefix = os.Getenv(configuration.EnvS3Runs)
+	appConfig.overrides.s3Path = os.Getenv(configuration.EnvS3Overrides)
+
+	return appConfig
+}
+
+// DryRun copies the configuration so orders are faked
+// instead of placed, as when real orders are not allowed.
+func (a AppConfig) DryRun() *AppConfig {
+	a.allowReal = false
+	return &a
+}
+
+// now is the current time, which is simulated when backtesting.
+func (s *DCAServices) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock()
+}
+
+// RunOrders executes the orders for a run triggered at the trigger time
+// and records the summary of the run, even when the run fails.
+func RunOrders(ctx context.Context, services *DCAServices, config *AppConfig, triggerTime time.Time) (*runs.RunSummary, error) {
+	summary, err := runs.NewRunSummary(triggerTime)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracing.Start(ctx, "RunOrders", attribute.String("run.id", summary.RunID))
+	defer func() { tracing.End(span, err) }()
+
+	_, err = ExecuteOrders(ctx, services, config, summary)
+
+	// Failing to record the run does not undo the orders which were placed
+	if config.runs.s3Prefix != "" {
+		if recordErr := services.runRecorder.Record(ctx, services.s3Access, config.s3bucket, config.runs.s3Prefix, summary); recordErr != nil {
+			logging.FromContext(ctx).WithError(recordErr).WithField(logging.FieldRunID, summary.RunID).Error("Could not record run summary")
+		}
+	}
+
+	return summary, err
+}
+
+// ExecuteOrders will execute orders from the DCA configuration
+// into exchanges, recording the outcome of every order in the
+// run summary and announcing the summary once finished
+func ExecuteOrders(ctx context.Context, services *DCAServices, config *AppConfig, summary *runs.RunSummary) (_ *[]orders.PendingOrders, err error) {
+	ctx = logging.WithField(ctx, logging.FieldRunID, summary.RunID)
+	logging.FromContext(ctx).Info("Executing Orders")
+
+	summary.Real = config.allowReal
+	defer func() {
+		summary.Finish(err)
+		recordOrderMetrics(services, summary)
+		notifyRun(ctx, services, summary)
+	}()
+
+	// Get DCA Configuration
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"s3bucket": config.s3bucket,
+		"s3path":   config.dcaConfigPath,
+	}).Info("Getting DCA Configuration")
+	configCtx, configSpan := tracing.Start(ctx, "GetDCAConfiguration", attribute.String("s3.key", config.dcaConfigPath))
+	dcaConf, err := services.configSource.GetDCAConfiguration(configCtx, services.s3Access, &config.s3bucket, &config.dcaConfigPath)
+	tracing.End(configSpan, err)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).WithField("config", *dcaConf).Debug("Pulled config")
+	summary.ConfigVersion = dcaConf.Version
+	ctx = logging.WithField(ctx, logging.FieldConfigVersion, dcaConf.Version)
+
+	failurePolicy, err := dcaConf.GetFailurePolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	// Orders paused with an override must never be placed so a run fails without them
+	var pauses *overrides.Overrides
+	if config.overrides.s3Path != "" {
+		pauses, err = services.overrideStore.GetOverrides(ctx, services.s3Access, config.s3bucket, config.overrides.s3Path)
+		if err != nil {
+			return nil, fmt.Errorf("could not load overrides: %w", err)
+		}
+	}
+	now := services.now()
+
+	executionCounts, err := countExecutions(ctx, services, config, dcaConf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Calendars follow the day the run was scheduled for even when it runs late
+	runTime := summary.TriggerTime
+	if runTime.IsZero() {
+		runTime = now
+	}
+
+	logging.FromContext(ctx).Info("Getting Orderers")
+	o, ordererErr := services.ordererFactory.GetOrderers(ctx, services.ssmAccess)
+	if ordererErr != nil {
+		return nil, ordererErr
+	}
+
+	dcaOrders := dcaConf.Orders
+	if dcaConf.Portfolio != nil && dcaConf.Portfolio.Enabled {
+		portfolioOrders, err := planPortfolio(ctx, services, dcaConf.Portfolio, o)
+		if err != nil {
+			return nil, err
+		}
+
+		dcaOrders = append(dcaOrders, portfolioOrders...)
+	}
+
+	// Execute Orders concurrently, collecting the results in the original order
+	concurrency := dcaConf.GetMaxConcurrency()
+	logging.FromContext(ctx).WithField("maxConcurrency", concurrency).Info("Executing Orders")
+
+	state := newRunState(summary.RunID)
+	executions := make([]orderExecution, len(dcaOrders))
+	slots := make(chan struct{}, concurrency)
+	var stopped int32
+	var wg sync.WaitGroup
+
+	for index, order := range dcaOrders {
+		slots <- struct{}{}
+		if atomic.LoadInt32(&stopped) == 1 {
+			break
+		}
+
+		wg.Add(1)
+		go func(index int, order configuration.DCAOrder) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			orderCtx := logging.WithField(ctx, logging.FieldOrderIndex, index)
+			execution := &executions[index]
+			execution.started = true
+			execution.outcome = runs.NewOrderOutcome(index, &order)
+
+			reason, skipErr := skipReason(dcaConf, &order, pauses, executionCounts, now, runTime)
+			if skipErr != nil {
+				execution.err = skipErr
+				if failurePolicy == configuration.FailurePolicyFailFast {
+					atomic.StoreInt32(&stopped, 1)
+				}
+				return
+			}
+			if reason != "" {
+				logging.FromContext(orderCtx).WithFields(logrus.Fields{
+					"id":     order.ID,
+					"pair":   order.Pair,
+					"reason": reason,
+				}).Info("Skipping Order")
+
+				execution.outcome.Outcome = runs.OutcomeSkipped
+				execution.outcome.Reason = reason
+				return
+			}
+
+			logging.FromContext(orderCtx).WithFields(logrus.Fields{
+				"exchange":  order.Exchange,
+				"pair":      order.Pair,
+				"volume":    order.Volume,
+				"type":      order.OrderType,
+				"direction": order.Direction,
+			}).Info("Executing Order")
+
+			orderCtx, span := tracing.Start(orderCtx, "ExecuteOrder",
+				attribute.Int("order.index", index),
+				attribute.String("exchange", order.Exchange),
+				attribute.String("pair", order.Pair),
+			)
+
+			execution.pending, execution.err = executeOrder(orderCtx, services, config, index, order, o, state, &execution.outcome)
+			tracing.End(span, execution.err)
+
+			if execution.err != nil && failurePolicy == configuration.FailurePolicyFailFast {
+				atomic.StoreInt32(&stopped, 1)
+			}
+		}(index, order)
+	}
+	wg.Wait()
+
+	var firstErr error
+	failures := &runs.MultiError{}
+	submittedPendingOrders := make([]orders.PendingOrders, 0, len(dcaOrders))
+	for index, execution := range executions {
+		if !execution.started {
+			continue
+		}
+
+		if execution.err != nil {
+			execution.outcome.Outcome = runs.OutcomeFailed
+			execution.outcome.Error = execution.err.Error()
+			summary.Add(execution.outcome)
+
+			logging.FromContext(ctx).WithError(execution.err).WithFields(logrus.Fields{
+				logging.FieldOrderIndex: index,
+				"pair":                  dcaOrders[index].Pair,
+				"failurePolicy":         failurePolicy,
+			}).Error("Order Failed")
+			failures.Add(fmt.Errorf("order %d %s: %w", index, dcaOrders[index].Pair, execution.err))
+			if firstErr == nil {
+				firstErr = execution.err
+			}
+			continue
+		}
+
+		summary.Add(execution.outcome)
+		if execution.pending != nil {
+			submittedPendingOrders = append(submittedPendingOrders, *execution.pending)
+		}
+	}
+
+	// Orders already executing when the first order failed still finish
+	if failurePolicy == configuration.FailurePolicyFailFast && firstErr != nil {
+		return nil, firstErr
+	}
+
+	// Healthy orders have been placed but the run still fails so it is alerted on
+	return &submittedPendingOrders, failures.ErrorOrNil()
+}
+
+// skipReason decides why the order is not executed in the run, which is empty when it should be.
+//
+// Pauses apply at the time now while the window of the order and the
+// exclusions apply on the day the run was triggered.
+func skipReason(dcaConf *configuration.DCAConfig, order *configuration.DCAOrder, pauses *overrides.Overrides, executionCounts map[string]int, now time.Time, runTime time.Time) (string, error) {
+	if pause, paused := pauses.Paused(order.ID, now); paused {
+		return pause.Describe(), nil
+	}
+
+	if reason, err := order.OutsideWindow(runTime); err != nil || reason != "" {
+		return reason, err
+	}
+
+	if order.MaxExecutions > 0 && executionCounts[order.ID] >= order.MaxExecutions {
+		return fmt.Sprintf("reached max_executions of %d", order.MaxExecutions), nil
+	}
+
+	exclusion, err := dcaConf.Excluded(*order, runTime)
+	if err != nil || exclusion == nil {
+		return "", err
+	}
+	return exclusion.Describe(), nil
+}
+
+// countExecutions counts how many times each order has been executed within its
+// window from the run history when any order has a maximum number of executions.
+//
+// Only the runs within the windows of those orders are loaded.
+func countExecutions(ctx context.Context, services *DCAServices, config *AppConfig, dcaConf *configuration.DCAConfig) (map[string]int, error) {
+	limited := []configuration.DCAOrder{}
+	for _, order := range dcaConf.Orders {
+		if order.MaxExecutions > 0 {
+			limited = append(limited, order)
+		}
+	}
+	if len(limited) == 0 {
+		return map[string]int{}, nil
+	}
+
+	if config.runs.s3Prefix == "" {
+		return nil, fmt.Errorf("max_executions needs %s to count executions from the run history", configuration.EnvS3Runs)
+	}
+
+	// The runs loaded cover the window of every limited order, open ended when any window is
+	from, to := limited[0].StartDate, limited[0].EndDate
+	for _, order := range limited[1:] {
+		if from != "" && (order.StartDate == "" || order.StartDate < from) {
+			from = order.StartDate
+		}
+		if to != "" && (order.EndDate == "" || order.EndDate > to) {
+			to = order.EndDate
+		}
+	}
+
+	summaries, err := services.runLoader.ListRunsBetween(ctx, services.s3Access, config.s3bucket, config.runs.s3Prefix, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("could not load run history: %w", err)
+	}
+
+	executionCounts := map[string]int{}
+	for _, order := range limited {
+		executionCounts[order.ID] = runs.CountExecutions(runs.Between(summaries, order.StartDate, order.EndDate))[order.ID]
+	}
+
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"executions": executionCounts,
+		"from":       from,
+		"to":         to,
+	}).Debug("Counted Executions")
+	return executionCounts, nil
+}
+
+// makeOrder places the order on the exchange within a span.
+func makeOrder(ctx context.Context, exchange orders.Orderer, index int, order *configuration.DCAOrder) (result *orders.OrderFufilled, err error) {
+	_, span := tracing.Start(ctx, "MakeOrder",
+		attribute.Int("order.index", index),
+		attribute.String("exchange", order.Exchange),
+		attribute.String("pair", order.Pair),
+		attribute.String("volume", order.Volume),
+	)
+	defer func() {
+		if result != nil {
+			span.SetAttributes(attribute.String("transaction.id", result.TransactionID))
+		}
+		tracing.End(span, err)
+	}()
+
+	return exchange.MakeOrder(ctx, order)
+}
+
+// recordOrderMetrics counts the outcome of every order in the run.
+func recordOrderMetrics(services *DCAServices, summary *runs.RunSummary) {
+	for _, outcome := range summary.Orders {
+		services.metrics.Add(metrics.OrdersTotal, 1, metrics.Labels{
+			"exchange": outcome.Exchange,
+			"pair":     outcome.Pair,
+			"outcome":  outcome.Outcome,
+		})
+	}
+}
+
+// orderExecution is the result of executing a single order of the run.
+type orderExecution struct {
+	started bool
+	outcome runs.OrderOutcome
+	pending *orders.PendingOrders
+	err     error
+}
+
+// runState is shared between the orders executing concurrently in a run.
+type runState struct {
+	runID     string
+	mu        sync.Mutex
+	exchanges map[string]*sync.Mutex
+	processed map[string][]orders.OrderComplete
+}
+
+func newRunState(runID string) *runState {
+	return &runState{
+		runID:     runID,
+		exchanges: map[string]*sync.Mutex{},
+		processed: map[string][]orders.OrderComplete{},
+	}
+}
+
+// lockExchange waits for any other order on the exchange to finish
+// so orders on a single exchange are placed one at a time.
+func (r *runState) lockExchange(exchange string) func() {
+	r.mu.Lock()
+	lock, ok := r.exchanges[exchange]
+	if !ok {
+		lock = &sync.Mutex{}
+		r.exchanges[exchange] = lock
+	}
+	r.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// processedOrders gets the processed orders of the exchange loaded earlier in the run.
+func (r *runState) processedOrders(exchange string) ([]orders.OrderComplete, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	processed, ok := r.processed[exchange]
+	return processed, ok
+}
+
+// storeProcessedOrders keeps the processed orders of the exchange for the rest of the run.
+func (r *runState) storeProcessedOrders(exchange string, processed []orders.OrderComplete) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.processed[exchange] = processed
+}
+
+// executeOrder sizes and places a single order, recording what happened in the
+// outcome. The pending order is returned when the order was placed.
+//
+// Everything after routing happens while holding the lock of the exchange.
+func executeOrder(ctx context.Context, services *DCAServices, config *AppConfig, index int, order configuration.DCAOrder, o *map[string]orders.Orderer, state *runState, outcome *runs.OrderOutcome) (*orders.PendingOrders, error) {
+	var err error
+	var price *decimal.Decimal
+	var routing *orders.RoutingDecision
+	if order.Exchange == strategy.ExchangeAuto && order.Enabled {
+		routing, err = services.router.Route(ctx, &order, *o)
+		if err != nil {
+			return nil, err
+		}
+
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"pair":         order.Pair,
+			"exchange":     routing.Exchange,
+			"alternatives": routing.Alternatives,
+		}).Info("Routed Order")
+
+		order.Exchange = routing.Exchange
+		for _, quote := range routing.Alternatives {
+			if quote.Exchange == routing.Exchange {
+				quotePrice := quote.Price
+				price = &quotePrice
+			}
+		}
+		outcome.Update(&order, price)
+	}
+
+	unlock := state.lockExchange(order.Exchange)
+	defer unlock()
+
+	if order.ValueAveraging != nil && order.Enabled {
+		trade, err := sizeValueAveraging(ctx, services, config, &order, o, state)
+		if err != nil {
+			return nil, err
+		}
+
+		tradeLog := logging.FromContext(ctx).WithFields(logrus.Fields{
+			"pair":         trade.Pair,
+			"periods":      trade.Periods,
+			"targetValue":  trade.TargetValue,
+			"position":     trade.Position,
+			"currentValue": trade.CurrentValue,
+			"tradeValue":   trade.TradeValue,
+			"direction":    trade.Direction,
+			"price":        trade.Price,
+			"volume":       trade.Volume,
+		})
+
+		order.Direction = trade.Direction
+		order.Volume = trade.Volume.String()
+		price = &trade.Price
+		outcome.Update(&order, price)
+
+		if order.ValueAveraging.DryRun {
+			tradeLog.Info("Value Averaging Dry Run, skipping order")
+			outcome.Outcome = runs.OutcomeSkipped
+			outcome.Reason = "value averaging dry run"
+			return nil, nil
+		}
+
+		if trade.Volume.IsZero() {
+			tradeLog.Info("Value Averaging position on target, skipping order")
+			outcome.Outcome = runs.OutcomeSkipped
+			outcome.Reason = "value averaging position on target"
+			return nil, nil
+		}
+
+		tradeLog.Info("Value Averaging Trade")
+	}
+
+	var multiplier *orders.MultiplierDecision
+	if order.Dip != nil && order.Enabled {
+		multiplier, err = scaleDip(ctx, services, &order, o)
+		if err != nil {
+			return nil, err
+		}
+
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"pair":          order.Pair,
+			"rule":          multiplier.Rule,
+			"price":         multiplier.Price,
+			"high":          multiplier.High,
+			"drawdownPct":   multiplier.DrawdownPct,
+			"movingAverage": multiplier.MovingAverage,
+			"multiplier":    multiplier.Multiplier,
+			"baseVolume":    multiplier.BaseVolume,
+			"volume":        multiplier.Volume,
+		}).Info("Dip Multiplier Decision")
+
+		order.Volume = multiplier.Volume
+		price = &multiplier.Price
+		outcome.Update(&order, price)
+	}
+
+	var expireAt int64
+	if order.OrderType == "limit" && order.Enabled {
+		limitPrice, err := resolveLimitPrice(ctx, &order, o)
+		if err != nil {
+			return nil, err
+		}
+		order.LimitPrice = &limitPrice
+		price = &limitPrice
+		outcome.Update(&order, price)
+
+		if order.ExpireAfter != "" {
+			expireAfter, err := time.ParseDuration(order.ExpireAfter)
+			if err != nil {
+				return nil, fmt.Errorf("invalid expire_after %s: %w", order.ExpireAfter, err)
+			}
+			expireAt = services.now().Add(expireAfter).Unix()
+		}
+
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"pair":       order.Pair,
+			"limitPrice": limitPrice,
+			"expireAt":   expireAt,
+		}).Info("Resolved Limit Order")
+	}
+
+	// Place the first slice now and schedule the rest
+	var twap *strategy.TWAPPlan
+	var parentOrder configuration.DCAOrder
+	var parentID string
+	if strategy.IsTWAP(&order) && order.Enabled {
+		twap, err = strategy.PlanTWAP(&order)
+		if err != nil {
+			return nil, err
+		}
+
+		parentID, err = orders.NewParentID()
+		if err != nil {
+			return nil, err
+		}
+
+		parentOrder = order
+		firstSlice, err := twap.SliceOrder(&parentOrder, 0)
+		if err != nil {
+			return nil, err
+		}
+		order = *firstSlice
+
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"pair":     order.Pair,
+			"parentId": parentID,
+			"slices":   len(twap.Volumes),
+			"interval": twap.Interval,
+			"volume":   order.Volume,
+		}).Info("Slicing Order with TWAP")
+	}
+
+	var orderResult *orders.OrderFufilled
+	var orderErr error
+
+	if config.allowReal {
+		exchange, ok := (*o)[order.Exchange]
+		if !ok {
+			return nil, fmt.Errorf("no orderer found for exchange %s", order.Exchange)
+		}
+
+		orderResult, orderErr = makeOrder(ctx, exchange, index, &order)
+	} else {
+		orderResult, orderErr = orders.GetFakeOrderFufilled()
+	}
+
+	if orderErr != nil {
+		return nil, orderErr
+	}
+
+	// Orderers place nothing for disabled orders
+	if orderResult == nil {
+		outcome.Outcome = runs.OutcomeSkipped
+		outcome.Reason = "order disabled"
+		return nil, nil
+	}
+
+	if order.Validate {
+		outcome.Outcome = runs.OutcomeValidated
+		return nil, nil
+	}
+
+	orderResult.Multiplier = multiplier
+	orderResult.Routing = routing
+
+	s3Path := fmt.Sprintf(
+		"%s/exchange=%s/%s.json",
+		config.transactions.pendingS3TransactionPrefix,
+		strings.ToLower(order.Exchange),
+		orderResult.TransactionID,
+	)
+
+	orderResultBytes, err := json.Marshal(orderResult)
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"s3bucket":      config.s3bucket,
+		"s3path":        s3Path,
+		"transactionId": orderResult.TransactionID,
+	}).Info("Uploading Order result to bucket")
+
+	_, err = services.s3Access.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &config.s3bucket,
+		Key:    &s3Path,
+		Body:   bytes.NewReader(orderResultBytes),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Submit to SQS
+	po := orders.PendingOrders{
+		RunID:         state.runID,
+		TransactionID: orderResult.TransactionID,
+		S3Bucket:      config.s3bucket,
+		S3Key:         s3Path,
+	}
+
+	if expireAt > 0 {
+		placedOrder := order
+		po.ExpireAt = expireAt
+		po.Order = &placedOrder
+	}
+
+	if twap != nil {
+		po.ParentID = parentID
+		po.Slices = len(twap.Volumes)
+	}
+
+	submitErr := services.pendingOrderSubmitter.SubmitPendingOrder(ctx, services.sqsAccess, &po, order.Exchange, config.allowReal, config.queue.sqsURL)
+	if submitErr != nil {
+		return nil, submitErr
+	}
+
+	outcome.Update(&order, price)
+	outcome.Outcome = runs.OutcomePlaced
+	outcome.TransactionID = orderResult.TransactionID
+
+	if twap != nil && len(twap.Volumes) > 1 {
+		nextSlice := orders.PendingOrders{
+			RunID:     state.runID,
+			S3Bucket:  config.s3bucket,
+			Order:     &parentOrder,
+			ParentID:  parentID,
+			Slice:     1,
+			Slices:    len(twap.Volumes),
+			ExecuteAt: services.now().Add(twap.Interval).Unix(),
+		}
+
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"parentId":  parentID,
+			"slice":     nextSlice.Slice,
+			"executeAt": nextSlice.ExecuteAt,
+		}).Info("Scheduling Next Slice")
+
+		submitErr = services.pendingOrderSubmitter.SubmitPendingOrder(ctx, services.sqsAccess, &nextSlice, order.Exchange, config.allowReal, config.queue.sqsURL)
+		if submitErr != nil {
+			return nil, submitErr
+		}
+	}
+
+	return &po, nil
+}
+
+// notifyRun announces the run, a failure to announce does not fail the run.
+func notifyRun(ctx context.Context, services *DCAServices, summary *runs.RunSummary) {
+	event := notify.Event{
+		Kind:   notify.KindRun,
+		Time:   summary.TriggerTime,
+		Real:   summary.Real,
+		Orders: make([]notify.OrderEvent, 0, len(summary.Orders)),
+		Errors: []string{},
+	}
+
+	for _, outcome := range summary.Orders {
+		reason := outcome.Reason
+		if outcome.Error != "" {
+			reason = outcome.Error
+		}
+
+		event.Orders = append(event.Orders, notify.OrderEvent{
+			Exchange:      outcome.Exchange,
+			Pair:          outcome.Pair,
+			Direction:     outcome.Direction,
+			OrderType:     outcome.OrderType,
+			Volume:        outcome.Volume,
+			Price:         outcome.Price,
+			Amount:        outcome.EstimatedCost,
+			TransactionID: outcome.TransactionID,
+			Status:        outcome.Outcome,
+			Reason:        reason,
+		})
+	}
+
+	if summary.Error != "" {
+		event.Errors = append(event.Errors, summary.Error)
+	}
+
+	event.Title = fmt.Sprintf(
+		"DCA run: %d placed, %d skipped, %d failed",
+		summary.Count(runs.OutcomePlaced),
+		summary.Count(runs.OutcomeSkipped),
+		summary.Count(runs.OutcomeFailed),
+	)
+	if !event.Real {
+		event.Title += " (simulated)"
+	}
+
+	if err := services.notifier.Notify(ctx, event); err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("Could not send run notification")
+	}
+}
+
+// planPortfolio plans the orders which move the portfolio
+// toward its targets using the market data of the portfolio exchange.
+func planPortfolio(ctx context.Context, services *DCAServices, portfolio *configuration.PortfolioConfig, o *map[string]orders.Orderer) ([]configuration.DCAOrder, error) {
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"exchange":     portfolio.Exchange,
+		"contribution": portfolio.Contribution,
+		"targets":      len(portfolio.Targets),
+	}).Info("Planning Portfolio Orders")
+
+	market, err := getMarketData(o, portfolio.Exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	return services.portfolioPlanner.Plan(ctx, portfolio, market)
+}
+
+// sizeValueAveraging computes the value averaging trade for the order using
+// the processed orders of the exchange which are loaded once per exchange.
+func sizeValueAveraging(ctx context.Context, services *DCAServices, config *AppConfig, order *configuration.DCAOrder, o *map[string]orders.Orderer, state *runState) (*strategy.ValueAveragingTrade, error) {
+	market, err := getMarketData(o, order.Exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	history, ok := state.processedOrders(order.Exchange)
+	if !ok {
+		s3Prefix := fmt.Sprintf(
+			"%s/exchange=%s/",
+			config.transactions.processedS3TransactionPrefix,
+			strings.ToLower(order.Exchange),
+		)
+
+		processed, err := services.processedOrderSource.GetProcessedOrders(ctx, services.s3Access, config.s3bucket, s3Prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		history = *processed
+		state.storeProcessedOrders(order.Exchange, history)
+	}
+
+	ticker, err := market.GetTicker(ctx, order.Pair)
+	if err != nil {
+		return nil, err
+	}
+
+	return services.valueAverager.Size(order, history, ticker, services.now())
+}
+
+// scaleDip decides the dip multiplier for the order from
+// the daily candles and current price of the order pair.
+func scaleDip(ctx context.Context, services *DCAServices, order *configuration.DCAOrder, o *map[string]orders.Orderer) (*orders.MultiplierDecision, error) {
+	market, err := getMarketData(o, order.Exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	now := services.now().UTC()
+	candles, err := market.GetOHLC(ctx, order.Pair, now.AddDate(0, 0, -strategy.CandleDays(order.Dip)))
+	if err != nil {
+		return nil, err
+	}
+
+	ticker, err := market.GetTicker(ctx, order.Pair)
+	if err != nil {
+		return nil, err
+	}
+
+	return services.dipMultiplier.Scale(order, candles, ticker.Last, now)
+}
+
+// resolveLimitPrice resolves the limit price of the order
+// using the current ticker when the price is relative to the market.
+func resolveLimitPrice(ctx context.Context, order *configuration.DCAOrder, o *map[string]orders.Orderer) (decimal.Decimal, error) {
+	if order.LimitOffsetPct == nil || order.LimitPrice != nil {
+		return strategy.LimitPrice(order, nil)
+	}
+
+	market, err := getMarketData(o, order.Exchange)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	ticker, err := market.GetTicker(ctx, order.Pair)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return strategy.LimitPrice(order, ticker)
+}
+
+// getMarketData gets the market data for the given exchange.
+func getMarketData(o *map[string]orders.Orderer, exchange string) (orders.MarketData, error) {
+	orderer, ok := (*o)[exchange]
+	if !ok {
+		return nil, fmt.Errorf("no orderer found for exchange %s", exchange)
+	}
+
+	market, ok := orderer.(orders.MarketData)
+	if !ok {
+		return nil, fmt.Errorf("exchange %s does not provide market data", exchange)
+	}
+
+	return market, nil
+}
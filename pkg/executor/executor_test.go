@@ -0,0 +1,1377 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/metrics"
+	"github.com/kiran94/dca-manager/pkg/notify"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/kiran94/dca-manager/pkg/overrides"
+	"github.com/kiran94/dca-manager/pkg/runs"
+	"github.com/kiran94/dca-manager/pkg/strategy"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Kraken Orderer
+type MockKrakenOrderer struct {
+	mock.Mock
+}
+
+func (m *MockKrakenOrderer) MakeOrder(ctx context.Context, order *configuration.DCAOrder) (*orders.OrderFufilled, error) {
+	args := m.Called(order)
+	return args.Get(0).(*orders.OrderFufilled), args.Error(1)
+}
+
+func (m *MockKrakenOrderer) ProcessTransaction(ctx context.Context, transactionsIds ...string) (*[]orders.OrderComplete, error) {
+	args := m.Called(transactionsIds)
+	return args.Get(0).(*[]orders.OrderComplete), args.Error(1)
+}
+
+func (m *MockKrakenOrderer) CancelOrder(ctx context.Context, transactionID string) error {
+	args := m.Called(transactionID)
+	return args.Error(0)
+}
+
+// Kraken Orderer with Market Data
+type MockMarketOrderer struct {
+	MockKrakenOrderer
+}
+
+func (m *MockMarketOrderer) GetBalances(ctx context.Context) (map[string]decimal.Decimal, error) {
+	args := m.Called()
+	return args.Get(0).(map[string]decimal.Decimal), args.Error(1)
+}
+
+func (m *MockMarketOrderer) GetTicker(ctx context.Context, pair string) (*orders.Ticker, error) {
+	args := m.Called(pair)
+	return args.Get(0).(*orders.Ticker), args.Error(1)
+}
+
+func (m *MockMarketOrderer) GetOHLC(ctx context.Context, pair string, since time.Time) ([]orders.Candle, error) {
+	args := m.Called(pair, since)
+	return args.Get(0).([]orders.Candle), args.Error(1)
+}
+
+func (m *MockMarketOrderer) GetFeePct(ctx context.Context, pair string) (decimal.Decimal, error) {
+	args := m.Called(pair)
+	return args.Get(0).(decimal.Decimal), args.Error(1)
+}
+
+// Portfolio Planner
+type MockPortfolioPlanner struct {
+	mock.Mock
+}
+
+func (m *MockPortfolioPlanner) Plan(ctx context.Context, conf *configuration.PortfolioConfig, market orders.MarketData) ([]configuration.DCAOrder, error) {
+	args := m.Called(conf, market)
+	return args.Get(0).([]configuration.DCAOrder), args.Error(1)
+}
+
+// Processed Order Source
+type MockProcessedOrderSource struct {
+	mock.Mock
+}
+
+func (m *MockProcessedOrderSource) GetProcessedOrders(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string) (*[]orders.OrderComplete, error) {
+	args := m.Called(ctx, s3Client, s3Bucket, s3Prefix)
+	return args.Get(0).(*[]orders.OrderComplete), args.Error(1)
+}
+
+// Run Loader
+type MockRunLoader struct {
+	mock.Mock
+}
+
+func (m *MockRunLoader) ListRuns(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, date string, limit int) ([]runs.RunSummary, error) {
+	args := m.Called(s3Bucket, s3Prefix, date, limit)
+	return args.Get(0).([]runs.RunSummary), args.Error(1)
+}
+
+func (m *MockRunLoader) ListRunsBetween(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, from string, to string) ([]runs.RunSummary, error) {
+	args := m.Called(s3Bucket, s3Prefix, from, to)
+	return args.Get(0).([]runs.RunSummary), args.Error(1)
+}
+
+func (m *MockRunLoader) GetRun(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, runID string) (*runs.RunSummary, error) {
+	args := m.Called(s3Bucket, s3Prefix, runID)
+	return args.Get(0).(*runs.RunSummary), args.Error(1)
+}
+
+// DCA Configration
+type MockDCAConfiguration struct {
+	mock.Mock
+}
+
+func (d MockDCAConfiguration) GetDCAConfiguration(ctx context.Context, s3Client pkg.S3Access, s3Bucket *string, s3ConfigPath *string) (*configuration.DCAConfig, error) {
+	args := d.Called(ctx, s3Client, s3Bucket, s3ConfigPath)
+	return args.Get(0).(*configuration.DCAConfig), args.Error(1)
+}
+
+// Orderer Factory
+type MockOrdererFactory struct {
+	mock.Mock
+}
+
+func (m MockOrdererFactory) GetOrderers(ctx context.Context, ssm pkg.SSMAccess) (*map[string]orders.Orderer, error) {
+	args := m.Called(ctx, ssm)
+	return args.Get(0).(*map[string]orders.Orderer), args.Error(1)
+}
+
+// Pending Order Submitter
+type MockPendingOrderSubmitter struct {
+	mock.Mock
+}
+
+func (m MockPendingOrderSubmitter) SubmitPendingOrder(ctx context.Context, sc pkg.SQSAccess, po *orders.PendingOrders, exchange string, real bool, sqsQueue string) error {
+	args := m.Called(ctx, sc, po, exchange, real, sqsQueue)
+	return args.Error(0)
+}
+
+// Notifier which records every event
+type RecordingNotifier struct {
+	events []notify.Event
+}
+
+func (r *RecordingNotifier) Notify(ctx context.Context, event notify.Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+// Orderer which counts the orders placed at the same time
+type ConcurrentOrderer struct {
+	placing    *int32
+	maxPlacing *int32
+}
+
+func (c ConcurrentOrderer) MakeOrder(ctx context.Context, order *configuration.DCAOrder) (*orders.OrderFufilled, error) {
+	placing := atomic.AddInt32(c.placing, 1)
+	defer atomic.AddInt32(c.placing, -1)
+
+	for {
+		max := atomic.LoadInt32(c.maxPlacing)
+		if placing <= max || atomic.CompareAndSwapInt32(c.maxPlacing, max, placing) {
+			break
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	return &orders.OrderFufilled{TransactionID: "TX-" + order.Pair}, nil
+}
+
+func (c ConcurrentOrderer) ProcessTransaction(ctx context.Context, transactionsIds ...string) (*[]orders.OrderComplete, error) {
+	return &[]orders.OrderComplete{}, nil
+}
+
+func (c ConcurrentOrderer) CancelOrder(ctx context.Context, transactionID string) error {
+	return nil
+}
+
+// Orderer which counts against both the exchange and every exchange
+type exchangeOrderer struct {
+	exchange ConcurrentOrderer
+	all      ConcurrentOrderer
+}
+
+func (e exchangeOrderer) MakeOrder(ctx context.Context, order *configuration.DCAOrder) (*orders.OrderFufilled, error) {
+	var result *orders.OrderFufilled
+	var err error
+	done := make(chan struct{})
+
+	go func() {
+		result, err = e.all.MakeOrder(ctx, order)
+		close(done)
+	}()
+	e.exchange.MakeOrder(ctx, order)
+	<-done
+
+	return result, err
+}
+
+func (e exchangeOrderer) ProcessTransaction(ctx context.Context, transactionsIds ...string) (*[]orders.OrderComplete, error) {
+	return &[]orders.OrderComplete{}, nil
+}
+
+func (e exchangeOrderer) CancelOrder(ctx context.Context, transactionID string) error {
+	return nil
+}
+
+// S3 and queue which accept everything from any goroutine
+type ConcurrentS3 struct{}
+
+func (c ConcurrentS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return nil, errors.New("not found")
+}
+
+func (c ConcurrentS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (c ConcurrentS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return &s3.ListObjectsV2Output{}, nil
+}
+
+type ConcurrentQueue struct{}
+
+func (c ConcurrentQueue) SubmitPendingOrder(ctx context.Context, sc pkg.SQSAccess, po *orders.PendingOrders, exchange string, real bool, sqsQueue string) error {
+	return nil
+}
+
+/*
+   Generates a fresh Services and Configuration
+   Expectations are set via incoming func
+*/
+func setup(apply func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig)) (*DCAServices, *AppConfig) {
+
+	appConfig := &AppConfig{s3bucket: "bucket", dcaConfigPath: "config_path", allowReal: false}
+	appConfig.transactions.pendingS3TransactionPrefix = "s3_pending_prefix"
+	appConfig.transactions.processedS3TransactionPrefix = "s3_processed_prefix"
+	appConfig.queue.sqsURL = "sqs_url"
+	appConfig.glue.processTransactionJob = "process_transaction_glue_job"
+	appConfig.glue.processTransactionOperation = "process_transaction_glue_operation"
+
+	awsConfig := aws.Config{}
+	s3Access := &pkg.MockS3Access{}
+	ssmAccess := &pkg.MockSSMClient{}
+	sqsAccess := &pkg.MockSQSAccess{}
+	configSource := &MockDCAConfiguration{}
+	ordererFactory := &MockOrdererFactory{}
+	pendingOrderSubmitter := &MockPendingOrderSubmitter{}
+	apply(s3Access, ssmAccess, sqsAccess, configSource, ordererFactory, pendingOrderSubmitter, appConfig)
+
+	services := &DCAServices{
+		awsConfig:             awsConfig,
+		s3Access:              s3Access,
+		ssmAccess:             ssmAccess,
+		sqsAccess:             sqsAccess,
+		configSource:          configSource,
+		ordererFactory:        ordererFactory,
+		pendingOrderSubmitter: pendingOrderSubmitter,
+		portfolioPlanner:      &MockPortfolioPlanner{},
+		processedOrderSource:  &MockProcessedOrderSource{},
+		valueAverager:         strategy.ValueAveraging{},
+		dipMultiplier:         strategy.DipBuyer{},
+		router:                strategy.BestExecution{},
+		notifier:              &RecordingNotifier{},
+		runRecorder:           runs.S3Recorder{},
+		runLoader:             &MockRunLoader{},
+		overrideStore:         overrides.S3Store{},
+		metrics:               metrics.NewRegistry(),
+	}
+
+	return services, appConfig
+}
+
+func AssertExpectations(t *testing.T, services *DCAServices) {
+	services.s3Access.(*pkg.MockS3Access).AssertExpectations(t)
+	services.ssmAccess.(*pkg.MockSSMClient).AssertExpectations(t)
+	services.sqsAccess.(*pkg.MockSQSAccess).AssertExpectations(t)
+	services.configSource.(*MockDCAConfiguration).AssertExpectations(t)
+	services.ordererFactory.(*MockOrdererFactory).AssertExpectations(t)
+	services.pendingOrderSubmitter.(*MockPendingOrderSubmitter).AssertExpectations(t)
+	services.portfolioPlanner.(*MockPortfolioPlanner).AssertExpectations(t)
+	services.processedOrderSource.(*MockProcessedOrderSource).AssertExpectations(t)
+	services.runLoader.(*MockRunLoader).AssertExpectations(t)
+}
+
+// Ensures when an error is returned when getting the DCA config
+// an error is returned
+func TestExecuteOrdersErrorGettingConfig(t *testing.T) {
+	var expectedErr error = errors.New("error getting config")
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(&configuration.DCAConfig{}, expectedErr)
+	})
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+	assert.Nil(t, pos)
+	assert.Equal(t, expectedErr, err)
+
+	AssertExpectations(t, services)
+}
+
+// Ensures when an error is returned when getting
+// Orderers an error is returned
+func TestExecuteOrdersErrorGettingOrderers(t *testing.T) {
+	expectedConfig := &configuration.DCAConfig{}
+
+	expectedOrdererResult := &map[string]orders.Orderer{}
+	var expectedOrdererErr error = errors.New("error getting orderer")
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(expectedConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, expectedOrdererErr)
+	})
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+	assert.Nil(t, pos)
+	assert.Equal(t, expectedOrdererErr, err)
+
+	AssertExpectations(t, services)
+}
+
+// Ensures when no orders are configured,
+// empty pending are returned
+func TestExecuteOrdersNoConfiguredOrders(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{}}
+	mockOrderer := &MockKrakenOrderer{}
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": mockOrderer}
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+
+	})
+	mockOrderer.On("MakeOrder", mock.Anything).Times(0)
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Equal(t, 0, len(*pos))
+	assert.Equal(t, 0, cap(*pos))
+	assert.Nil(t, err)
+
+	AssertExpectations(t, services)
+	services.s3Access.(*pkg.MockS3Access).AssertNotCalled(t, "PutObject", mock.Anything, mock.Anything, mock.Anything)
+	services.pendingOrderSubmitter.(*MockPendingOrderSubmitter).AssertNotCalled(t, "SubmitPendingOrder")
+}
+
+// Ensures when no orderer is configured
+// for the order exchange
+// an error is returned
+func TestExecuteOrdersNoOrdererForExchange(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{
+			Exchange:  "binance",
+			Pair:      "BTCGBP",
+			Volume:    "1",
+			Direction: "buy",
+		},
+	}}
+	mockOrderer := &MockKrakenOrderer{}
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": mockOrderer}
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+
+	})
+	mockOrderer.On("MakeOrder", mock.Anything).Times(0)
+
+	appConfig.allowReal = true
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Nil(t, pos)
+	assert.Contains(t, err.Error(), "no orderer found for exchange binance")
+}
+
+// Ensures when allo real is disabled, then
+// the fake transaction is used
+func TestExecuteOrdersAllowRealDisabled(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{
+			Exchange:  "kraken",
+			Pair:      "BTCGBP",
+			Volume:    "1",
+			Direction: "buy",
+		},
+	}}
+	mockOrderer := &MockKrakenOrderer{}
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": mockOrderer}
+	expectedS3PutObject := &s3.PutObjectOutput{}
+	var expectedErr error
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+		s3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(expectedS3PutObject, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", appConfig.allowReal, appConfig.queue.sqsURL).Return(expectedErr)
+	})
+	mockOrderer.On("MakeOrder", mock.Anything).Times(0)
+
+	appConfig.allowReal = false
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.NotNil(t, pos)
+	assert.Nil(t, err)
+
+	AssertExpectations(t, services)
+	assert.Equal(t, 1, len(*pos))
+	assert.Equal(t, "bucket", (*pos)[0].S3Bucket)
+	assert.Equal(t, "s3_pending_prefix/exchange=kraken/OEBG2U-KIRAN-4U6WHJ.json", (*pos)[0].S3Key)
+	assert.Equal(t, "OEBG2U-KIRAN-4U6WHJ", (*pos)[0].TransactionID)
+
+	events := services.notifier.(*RecordingNotifier).events
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "DCA run: 1 placed, 0 skipped, 0 failed (simulated)", events[0].Title)
+	assert.Equal(t, notify.StatusPlaced, events[0].Orders[0].Status)
+	assert.Equal(t, "OEBG2U-KIRAN-4U6WHJ", events[0].Orders[0].TransactionID)
+}
+
+// Ensures when there is an error
+// uploading the object
+// it is propagated
+func TestExecuteOrdersErrorUploadingObject(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{
+			Exchange:  "kraken",
+			Pair:      "BTCGBP",
+			Volume:    "1",
+			Direction: "buy",
+		},
+	}}
+	mockOrderer := &MockKrakenOrderer{}
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": mockOrderer}
+	expectedS3PutObject := &s3.PutObjectOutput{}
+	var expectedErr error = errors.New("error uploading object")
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+		s3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(expectedS3PutObject, expectedErr)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", appConfig.allowReal, appConfig.queue.sqsURL).Return(nil)
+	})
+	mockOrderer.On("MakeOrder", mock.Anything).Times(0)
+
+	appConfig.allowReal = false
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Nil(t, pos)
+	assert.Equal(t, expectedErr, err)
+
+	events := services.notifier.(*RecordingNotifier).events
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, []string{"error uploading object"}, events[0].Errors)
+}
+
+// Ensures when there is an error submitting pending orders
+// an error is returned
+func TestExecuteOrdersErrorSubmittingQueue(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{
+			Exchange:  "kraken",
+			Pair:      "BTCGBP",
+			Volume:    "1",
+			Direction: "buy",
+		},
+	}}
+	mockOrderer := &MockKrakenOrderer{}
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": mockOrderer}
+	expectedS3PutObject := &s3.PutObjectOutput{}
+	var expectedErr error = errors.New("error submit pending order")
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+		s3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(expectedS3PutObject, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", appConfig.allowReal, appConfig.queue.sqsURL).Return(expectedErr)
+	})
+	mockOrderer.On("MakeOrder", mock.Anything).Times(0)
+
+	appConfig.allowReal = false
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Nil(t, pos)
+	assert.Equal(t, expectedErr, err)
+}
+
+// Ensures when there is an order error, the error is returned
+func TestExecuteOrdersAllowRealOrderError(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{
+			Exchange:  "kraken",
+			Pair:      "BTCGBP",
+			Volume:    "1",
+			Direction: "buy",
+		},
+	}}
+	mockOrderer := &MockKrakenOrderer{}
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": mockOrderer}
+	expectedS3PutObject := &s3.PutObjectOutput{}
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+		s3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(expectedS3PutObject, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", appConfig.allowReal, appConfig.queue.sqsURL).Return(nil)
+	})
+
+	var expectedOrderFufilled = &orders.OrderFufilled{}
+	var expectedError error = errors.New("error making order")
+	mockOrderer.On("MakeOrder", &dcaConfig.Orders[0]).Return(expectedOrderFufilled, expectedError)
+
+	appConfig.allowReal = true
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+	assert.Nil(t, pos)
+	assert.NotNil(t, err)
+	assert.Equal(t, expectedError, err)
+}
+
+func TestExecuteOrdersAllowRealOrderSuccessful(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{
+			Exchange:  "kraken",
+			Pair:      "BTCGBP",
+			Volume:    "1",
+			Direction: "buy",
+		},
+	}}
+	mockOrderer := &MockKrakenOrderer{}
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": mockOrderer}
+	expectedS3PutObject := &s3.PutObjectOutput{}
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.allowReal = true
+
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+		s3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(expectedS3PutObject, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", appConfig.allowReal, appConfig.queue.sqsURL).Return(nil)
+	})
+
+	var expectedOrderFufilled = &orders.OrderFufilled{
+		TransactionID: "TXID",
+		Timestamp:     10002202,
+	}
+	mockOrderer.On("MakeOrder", &dcaConfig.Orders[0]).Return(expectedOrderFufilled, nil)
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.NotNil(t, pos)
+	assert.Nil(t, err)
+
+	AssertExpectations(t, services)
+	assert.Equal(t, 1, len(*pos))
+	assert.Equal(t, "bucket", (*pos)[0].S3Bucket)
+	assert.Equal(t, "s3_pending_prefix/exchange=kraken/TXID.json", (*pos)[0].S3Key)
+	assert.Equal(t, "TXID", (*pos)[0].TransactionID)
+}
+
+// Ensures when a portfolio is configured
+// the planned orders are executed after the configured orders
+func TestExecuteOrdersPortfolio(t *testing.T) {
+	portfolio := &configuration.PortfolioConfig{Exchange: "kraken", Enabled: true}
+	dcaConfig := &configuration.DCAConfig{
+		Orders: []configuration.DCAOrder{
+			{Exchange: "kraken", Pair: "BTCGBP", Volume: "1", Direction: "buy"},
+		},
+		Portfolio: portfolio,
+	}
+	plannedOrders := []configuration.DCAOrder{
+		{Exchange: "kraken", Pair: "ETHGBP", Volume: "0.5", Direction: "buy"},
+	}
+
+	mockOrderer := &MockMarketOrderer{}
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": mockOrderer}
+	expectedS3PutObject := &s3.PutObjectOutput{}
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.allowReal = true
+
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+		s3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(expectedS3PutObject, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", appConfig.allowReal, appConfig.queue.sqsURL).Return(nil)
+	})
+	services.portfolioPlanner.(*MockPortfolioPlanner).On("Plan", portfolio, mockOrderer).Return(plannedOrders, nil)
+
+	mockOrderer.On("MakeOrder", &dcaConfig.Orders[0]).Return(&orders.OrderFufilled{TransactionID: "TXID1"}, nil)
+	mockOrderer.On("MakeOrder", &plannedOrders[0]).Return(&orders.OrderFufilled{TransactionID: "TXID2"}, nil)
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Nil(t, err)
+	AssertExpectations(t, services)
+	mockOrderer.AssertExpectations(t)
+	assert.Equal(t, 2, len(*pos))
+	assert.Equal(t, "TXID1", (*pos)[0].TransactionID)
+	assert.Equal(t, "TXID2", (*pos)[1].TransactionID)
+}
+
+// Ensures when the portfolio exchange does not
+// provide market data an error is returned
+func TestExecuteOrdersPortfolioNoMarketData(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{
+		Portfolio: &configuration.PortfolioConfig{Exchange: "kraken", Enabled: true},
+	}
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": &MockKrakenOrderer{}}
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+	})
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Nil(t, pos)
+	assert.Contains(t, err.Error(), "exchange kraken does not provide market data")
+}
+
+// Ensures value averaging orders are sized
+// from the processed history and the ticker
+func TestExecuteOrdersValueAveraging(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{
+			Exchange:  "kraken",
+			Pair:      "ADAGBP",
+			OrderType: "market",
+			Enabled:   true,
+			ValueAveraging: &configuration.ValueAveragingConfig{
+				StartDate: time.Now().UTC().Format(strategy.DateLayout),
+				Period:    "weekly",
+				Increment: decimal.NewFromInt(100),
+			},
+		},
+	}}
+	history := &[]orders.OrderComplete{
+		{Pair: "ADAGBP", Type: "buy", Volume: decimal.NewFromInt(40)},
+	}
+
+	mockOrderer := &MockMarketOrderer{}
+	mockOrderer.On("GetTicker", "ADAGBP").Return(&orders.Ticker{Pair: "ADAGBP", Ask: decimal.NewFromInt(2), Bid: decimal.NewFromInt(2), Last: decimal.NewFromInt(2)}, nil)
+	mockOrderer.On("MakeOrder", mock.MatchedBy(func(o *configuration.DCAOrder) bool {
+		return o.Direction == "buy" && o.Volume == "10"
+	})).Return(&orders.OrderFufilled{TransactionID: "TXID"}, nil)
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": mockOrderer}
+	expectedS3PutObject := &s3.PutObjectOutput{}
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.allowReal = true
+
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+		s3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(expectedS3PutObject, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", appConfig.allowReal, appConfig.queue.sqsURL).Return(nil)
+	})
+	services.processedOrderSource.(*MockProcessedOrderSource).
+		On("GetProcessedOrders", mock.Anything, services.s3Access, "bucket", "s3_processed_prefix/exchange=kraken/").
+		Return(history, nil)
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Nil(t, err)
+	AssertExpectations(t, services)
+	mockOrderer.AssertExpectations(t)
+	assert.Equal(t, 1, len(*pos))
+	assert.Equal(t, "TXID", (*pos)[0].TransactionID)
+}
+
+// Ensures value averaging orders in dry run
+// are sized but never placed
+func TestExecuteOrdersValueAveragingDryRun(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{
+			Exchange: "kraken",
+			Pair:     "ADAGBP",
+			Enabled:  true,
+			ValueAveraging: &configuration.ValueAveragingConfig{
+				StartDate: "2022-01-01",
+				Period:    "daily",
+				Increment: decimal.NewFromInt(10),
+				DryRun:    true,
+			},
+		},
+	}}
+
+	mockOrderer := &MockMarketOrderer{}
+	mockOrderer.On("GetTicker", "ADAGBP").Return(&orders.Ticker{Pair: "ADAGBP", Ask: decimal.NewFromInt(1), Bid: decimal.NewFromInt(1), Last: decimal.NewFromInt(1)}, nil)
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": mockOrderer}
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.allowReal = true
+
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+	})
+	services.processedOrderSource.(*MockProcessedOrderSource).
+		On("GetProcessedOrders", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(&[]orders.OrderComplete{}, nil)
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(*pos))
+	mockOrderer.AssertNotCalled(t, "MakeOrder", mock.Anything)
+	services.s3Access.(*pkg.MockS3Access).AssertNotCalled(t, "PutObject", mock.Anything, mock.Anything, mock.Anything)
+	services.pendingOrderSubmitter.(*MockPendingOrderSubmitter).AssertNotCalled(t, "SubmitPendingOrder")
+
+	skipped := services.notifier.(*RecordingNotifier).events[0].Orders[0]
+	assert.Equal(t, notify.StatusSkipped, skipped.Status)
+	assert.Equal(t, "value averaging dry run", skipped.Reason)
+	assert.Equal(t, "1", skipped.Price.String())
+}
+
+// Ensures dip orders are scaled and the
+// decision is recorded against the order result
+func TestExecuteOrdersDipMultiplier(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{
+			Exchange:  "kraken",
+			Pair:      "XBTGBP",
+			Volume:    "0.01",
+			Direction: "buy",
+			Enabled:   true,
+			Dip: &configuration.DipConfig{
+				LookbackDays: 30,
+				Tiers: []configuration.DipTier{
+					{DrawdownPct: decimal.NewFromInt(10), Multiplier: decimal.NewFromInt(2)},
+				},
+			},
+		},
+	}}
+
+	mockOrderer := &MockMarketOrderer{}
+	mockOrderer.On("GetOHLC", "XBTGBP", mock.Anything).Return([]orders.Candle{
+		{Time: time.Now().UTC().AddDate(0, 0, -1), High: decimal.NewFromInt(100), Close: decimal.NewFromInt(95)},
+	}, nil)
+	mockOrderer.On("GetTicker", "XBTGBP").Return(&orders.Ticker{Pair: "XBTGBP", Ask: decimal.NewFromInt(85), Bid: decimal.NewFromInt(85), Last: decimal.NewFromInt(85)}, nil)
+	mockOrderer.On("MakeOrder", mock.MatchedBy(func(o *configuration.DCAOrder) bool {
+		return o.Volume == "0.02"
+	})).Return(&orders.OrderFufilled{TransactionID: "TXID"}, nil)
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": mockOrderer}
+	expectedS3PutObject := &s3.PutObjectOutput{}
+	recordsDecision := mock.MatchedBy(func(i *s3.PutObjectInput) bool {
+		body, _ := io.ReadAll(i.Body)
+		return strings.Contains(string(body), `"rule":"drawdown"`)
+	})
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.allowReal = true
+
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+		s3.On("PutObject", mock.Anything, recordsDecision, mock.Anything).Return(expectedS3PutObject, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", appConfig.allowReal, appConfig.queue.sqsURL).Return(nil)
+	})
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Nil(t, err)
+	AssertExpectations(t, services)
+	mockOrderer.AssertExpectations(t)
+	assert.Equal(t, 1, len(*pos))
+}
+
+// Ensures limit orders relative to the market are priced
+// from the ticker and carry their expiry to the queue
+func TestExecuteOrdersLimitOrder(t *testing.T) {
+	offset := decimal.NewFromInt(1)
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{
+			Exchange:          "kraken",
+			Pair:              "XBTGBP",
+			Volume:            "0.01",
+			Direction:         "buy",
+			OrderType:         "limit",
+			Enabled:           true,
+			LimitOffsetPct:    &offset,
+			ExpireAfter:       "1h",
+			ReplaceWithMarket: true,
+		},
+	}}
+
+	mockOrderer := &MockMarketOrderer{}
+	mockOrderer.On("GetTicker", "XBTGBP").Return(&orders.Ticker{Pair: "XBTGBP", Ask: decimal.NewFromInt(101), Bid: decimal.NewFromInt(100), Last: decimal.NewFromInt(100)}, nil)
+	mockOrderer.On("MakeOrder", mock.MatchedBy(func(o *configuration.DCAOrder) bool {
+		return o.LimitPrice != nil && o.LimitPrice.Equal(decimal.NewFromInt(99))
+	})).Return(&orders.OrderFufilled{TransactionID: "TXID"}, nil)
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": mockOrderer}
+	expectedS3PutObject := &s3.PutObjectOutput{}
+	carriesExpiry := mock.MatchedBy(func(po *orders.PendingOrders) bool {
+		return po.ExpireAt > time.Now().Unix() && po.Order != nil && po.Order.ReplaceWithMarket
+	})
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.allowReal = true
+
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+		s3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(expectedS3PutObject, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, carriesExpiry, "kraken", appConfig.allowReal, appConfig.queue.sqsURL).Return(nil)
+	})
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Nil(t, err)
+	AssertExpectations(t, services)
+	mockOrderer.AssertExpectations(t)
+	assert.Equal(t, 1, len(*pos))
+}
+
+// Ensures limit orders without a price are rejected
+func TestExecuteOrdersLimitOrderNoPrice(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{Exchange: "kraken", Pair: "XBTGBP", Volume: "0.01", Direction: "buy", OrderType: "limit", Enabled: true},
+	}}
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": &MockMarketOrderer{}}
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+	})
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Nil(t, pos)
+	assert.Contains(t, err.Error(), "requires limit_price or limit_offset_pct")
+}
+
+// Ensures TWAP orders place the first slice and
+// schedule the next slice under the same parent and run
+func TestExecuteOrdersTWAP(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{
+			Exchange:  "kraken",
+			Pair:      "XBTGBP",
+			Volume:    "0.06",
+			Direction: "buy",
+			OrderType: "market",
+			Enabled:   true,
+			Execution: &configuration.ExecutionConfig{Strategy: "twap", Slices: 6, Interval: "10m"},
+		},
+	}}
+
+	mockOrderer := &MockKrakenOrderer{}
+	mockOrderer.On("MakeOrder", mock.MatchedBy(func(o *configuration.DCAOrder) bool {
+		return o.Volume == "0.01" && o.Execution == nil
+	})).Return(&orders.OrderFufilled{TransactionID: "TXID"}, nil)
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": mockOrderer}
+	expectedS3PutObject := &s3.PutObjectOutput{}
+
+	var parentID string
+	isFirstSlice := mock.MatchedBy(func(po *orders.PendingOrders) bool {
+		parentID = po.ParentID
+		return po.TransactionID == "TXID" && po.ParentID != "" && po.Slices == 6 && po.RunID == "RUNID"
+	})
+	isNextSlice := mock.MatchedBy(func(po *orders.PendingOrders) bool {
+		return po.IsScheduledSlice() && po.ParentID == parentID && po.Slice == 1 && po.RunID == "RUNID" &&
+			po.Order.Volume == "0.06" && po.ExecuteAt > time.Now().Add(9*time.Minute).Unix()
+	})
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.allowReal = true
+
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+		s3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(expectedS3PutObject, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, isFirstSlice, "kraken", appConfig.allowReal, appConfig.queue.sqsURL).Return(nil).Once()
+		po.On("SubmitPendingOrder", mock.Anything, sqs, isNextSlice, "kraken", appConfig.allowReal, appConfig.queue.sqsURL).Return(nil).Once()
+	})
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{RunID: "RUNID"})
+
+	assert.Nil(t, err)
+	AssertExpectations(t, services)
+	mockOrderer.AssertExpectations(t)
+	assert.Equal(t, 1, len(*pos))
+	assert.Equal(t, parentID, (*pos)[0].ParentID)
+}
+
+// Ensures orders with the auto exchange are placed on the
+// cheapest exchange and the decision is recorded
+func TestExecuteOrdersAutoExchange(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{Exchange: "auto", Pair: "XBTGBP", Volume: "0.01", Direction: "buy", OrderType: "market", Enabled: true},
+	}}
+
+	cheap := &MockMarketOrderer{}
+	cheap.On("GetTicker", "XBTGBP").Return(&orders.Ticker{Pair: "XBTGBP", Ask: decimal.NewFromInt(100), Bid: decimal.NewFromInt(99)}, nil)
+	cheap.On("GetFeePct", "XBTGBP").Return(decimal.RequireFromString("0.26"), nil)
+	cheap.On("MakeOrder", mock.MatchedBy(func(o *configuration.DCAOrder) bool {
+		return o.Exchange == "kraken"
+	})).Return(&orders.OrderFufilled{TransactionID: "TXID"}, nil)
+
+	expensive := &MockMarketOrderer{}
+	expensive.On("GetTicker", "XBTGBP").Return(&orders.Ticker{Pair: "XBTGBP", Ask: decimal.NewFromInt(100), Bid: decimal.NewFromInt(99)}, nil)
+	expensive.On("GetFeePct", "XBTGBP").Return(decimal.RequireFromString("0.6"), nil)
+
+	expectedOrdererResult := &map[string]orders.Orderer{"kraken": cheap, "coinbase": expensive}
+	expectedS3PutObject := &s3.PutObjectOutput{}
+
+	var uploaded orders.OrderFufilled
+	recordsRouting := mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		body, _ := ioutil.ReadAll(input.Body)
+		json.Unmarshal(body, &uploaded)
+		return strings.Contains(*input.Key, "exchange=kraken/TXID.json")
+	})
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.allowReal = true
+
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(expectedOrdererResult, nil)
+		s3.On("PutObject", mock.Anything, recordsRouting, mock.Anything).Return(expectedS3PutObject, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", appConfig.allowReal, appConfig.queue.sqsURL).Return(nil)
+	})
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Nil(t, err)
+	AssertExpectations(t, services)
+	cheap.AssertExpectations(t)
+	assert.Equal(t, 1, len(*pos))
+	assert.Equal(t, "kraken", uploaded.Routing.Exchange)
+	assert.Equal(t, 2, len(uploaded.Routing.Alternatives))
+}
+
+// Ensures a run records the summary of the run
+// under the date it was triggered
+func TestRunOrders(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Version: "v1", Orders: []configuration.DCAOrder{
+		{Exchange: "kraken", Pair: "BTCGBP", Volume: "1", Direction: "buy"},
+	}}
+	triggerTime := time.Date(2022, 1, 7, 6, 0, 0, 0, time.UTC)
+	expectedS3PutObject := &s3.PutObjectOutput{}
+	keys := []string{}
+
+	services, appConfig := setup(func(s3Access *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.runs.s3Prefix = "runs"
+
+		c.On("GetDCAConfiguration", mock.Anything, s3Access, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(&map[string]orders.Orderer{"kraken": &MockKrakenOrderer{}}, nil)
+		s3Access.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+			keys = append(keys, *input.Key)
+			return true
+		}), mock.Anything).Return(expectedS3PutObject, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", false, appConfig.queue.sqsURL).Return(nil)
+	})
+
+	summary, err := RunOrders(context.Background(), services, appConfig, triggerTime)
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, summary.RunID)
+	assert.Equal(t, triggerTime, summary.TriggerTime)
+	assert.Equal(t, "v1", summary.ConfigVersion)
+	assert.Equal(t, 1, len(summary.Orders))
+	assert.Equal(t, runs.OutcomePlaced, summary.Orders[0].Outcome)
+	assert.Equal(t, "OEBG2U-KIRAN-4U6WHJ", summary.Orders[0].TransactionID)
+
+	assert.Contains(t, keys, "runs/date=2022-01-07/"+summary.RunID+".json")
+}
+
+// Ensures disabled, validated and failing orders
+// each record their outcome
+func TestExecuteOrdersOutcomes(t *testing.T) {
+	limitPrice := decimal.NewFromInt(100)
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{Exchange: "kraken", Pair: "BTCGBP", Volume: "1", Direction: "buy"},
+		{Exchange: "kraken", Pair: "ETHGBP", Volume: "2", Direction: "buy", OrderType: "limit", LimitPrice: &limitPrice, Validate: true, Enabled: true},
+		{Exchange: "kraken", Pair: "ADAGBP", Volume: "3", Direction: "buy", Enabled: true},
+	}}
+
+	mockOrderer := &MockKrakenOrderer{}
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.allowReal = true
+
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(&map[string]orders.Orderer{"kraken": mockOrderer}, nil)
+	})
+
+	var disabledResult *orders.OrderFufilled
+	mockOrderer.On("MakeOrder", &dcaConfig.Orders[0]).Return(disabledResult, nil)
+	mockOrderer.On("MakeOrder", mock.MatchedBy(func(order *configuration.DCAOrder) bool { return order.Pair == "ETHGBP" })).Return(&orders.OrderFufilled{Validated: true}, nil)
+	mockOrderer.On("MakeOrder", &dcaConfig.Orders[2]).Return(disabledResult, errors.New("insufficient funds"))
+
+	summary := &runs.RunSummary{}
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, summary)
+
+	assert.Nil(t, pos)
+	assert.Equal(t, "insufficient funds", err.Error())
+	assert.Equal(t, "insufficient funds", summary.Error)
+	assert.Equal(t, 3, len(summary.Orders))
+
+	assert.Equal(t, runs.OutcomeSkipped, summary.Orders[0].Outcome)
+	assert.Equal(t, "order disabled", summary.Orders[0].Reason)
+
+	assert.Equal(t, runs.OutcomeValidated, summary.Orders[1].Outcome)
+	assert.Equal(t, "200", summary.Orders[1].EstimatedCost.String())
+
+	assert.Equal(t, runs.OutcomeFailed, summary.Orders[2].Outcome)
+	assert.Equal(t, "insufficient funds", summary.Orders[2].Error)
+
+	assert.Equal(t, float64(1), services.metrics.Value(metrics.OrdersTotal, metrics.Labels{"exchange": "kraken", "pair": "BTCGBP", "outcome": runs.OutcomeSkipped}))
+	assert.Equal(t, float64(1), services.metrics.Value(metrics.OrdersTotal, metrics.Labels{"exchange": "kraken", "pair": "ETHGBP", "outcome": runs.OutcomeValidated}))
+	assert.Equal(t, float64(1), services.metrics.Value(metrics.OrdersTotal, metrics.Labels{"exchange": "kraken", "pair": "ADAGBP", "outcome": runs.OutcomeFailed}))
+
+	services.s3Access.(*pkg.MockS3Access).AssertNotCalled(t, "PutObject", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Ensures orders after a failing order are still placed
+// unless the run fails fast
+func TestExecuteOrdersFailurePolicy(t *testing.T) {
+	type testCase struct {
+		policy      string
+		placed      int
+		expectedErr string
+	}
+
+	cases := []testCase{
+		{policy: "", placed: 0, expectedErr: "error making order"},
+		{policy: configuration.FailurePolicyFailFast, placed: 0, expectedErr: "error making order"},
+		{policy: configuration.FailurePolicyContinue, placed: 1, expectedErr: "1 orders failed: order 0 ADAGBP: error making order"},
+		{policy: configuration.FailurePolicyContinueThenFail, placed: 1, expectedErr: "1 orders failed: order 0 ADAGBP: error making order"},
+	}
+
+	for _, currentCase := range cases {
+		dcaConfig := &configuration.DCAConfig{FailurePolicy: currentCase.policy, Orders: []configuration.DCAOrder{
+			{Exchange: "kraken", Pair: "ADAGBP", Volume: "10", Direction: "buy", Enabled: true},
+			{Exchange: "kraken", Pair: "BTCGBP", Volume: "1", Direction: "buy", Enabled: true},
+		}}
+
+		mockOrderer := &MockKrakenOrderer{}
+		expectedS3PutObject := &s3.PutObjectOutput{}
+		services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+			appConfig.allowReal = true
+
+			c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+			o.On("GetOrderers", mock.Anything, mock.Anything).Return(&map[string]orders.Orderer{"kraken": mockOrderer}, nil)
+			s3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(expectedS3PutObject, nil)
+			po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", true, appConfig.queue.sqsURL).Return(nil)
+		})
+
+		var failedResult *orders.OrderFufilled
+		mockOrderer.On("MakeOrder", &dcaConfig.Orders[0]).Return(failedResult, errors.New("error making order"))
+		mockOrderer.On("MakeOrder", &dcaConfig.Orders[1]).Return(&orders.OrderFufilled{TransactionID: "TXID"}, nil)
+
+		summary := &runs.RunSummary{}
+		pos, err := ExecuteOrders(context.Background(), services, appConfig, summary)
+
+		if currentCase.expectedErr == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.Equal(t, currentCase.expectedErr, err.Error())
+		}
+
+		if pos != nil {
+			assert.Equal(t, currentCase.placed, len(*pos))
+		}
+		assert.Equal(t, currentCase.placed, summary.Count(runs.OutcomePlaced))
+		assert.Equal(t, 1, summary.Count(runs.OutcomeFailed))
+		assert.NotEmpty(t, summary.Error)
+	}
+}
+
+// Ensures a run which continues past failures still fails with
+// every failure, reporting them in the summary and the notification
+func TestExecuteOrdersContinue(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{FailurePolicy: configuration.FailurePolicyContinue, Orders: []configuration.DCAOrder{
+		{Exchange: "kraken", Pair: "ADAGBP", Volume: "10", Direction: "buy", Enabled: true},
+		{Exchange: "kraken", Pair: "BTCGBP", Volume: "1", Direction: "buy", Enabled: true},
+		{Exchange: "kraken", Pair: "ETHGBP", Volume: "1", Direction: "buy", Enabled: true},
+	}}
+
+	mockOrderer := &MockKrakenOrderer{}
+	expectedS3PutObject := &s3.PutObjectOutput{}
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.allowReal = true
+
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(&map[string]orders.Orderer{"kraken": mockOrderer}, nil)
+		s3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(expectedS3PutObject, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", true, appConfig.queue.sqsURL).Return(nil)
+	})
+
+	var failedResult *orders.OrderFufilled
+	mockOrderer.On("MakeOrder", &dcaConfig.Orders[0]).Return(failedResult, errors.New("unknown pair"))
+	mockOrderer.On("MakeOrder", &dcaConfig.Orders[1]).Return(&orders.OrderFufilled{TransactionID: "TXID"}, nil)
+	mockOrderer.On("MakeOrder", &dcaConfig.Orders[2]).Return(failedResult, errors.New("insufficient funds"))
+
+	summary := &runs.RunSummary{}
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, summary)
+
+	assert.Equal(t, summary.Error, err.Error())
+	assert.Equal(t, 1, len(*pos))
+	assert.Equal(t, "2 orders failed: order 0 ADAGBP: unknown pair; order 2 ETHGBP: insufficient funds", summary.Error)
+
+	events := services.notifier.(*RecordingNotifier).events
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, []string{summary.Error}, events[0].Errors)
+	assert.Equal(t, "DCA run: 1 placed, 0 skipped, 2 failed", events[0].Title)
+}
+
+// Ensures unknown failure policies are rejected
+func TestExecuteOrdersUnknownFailurePolicy(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{FailurePolicy: "retry"}
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+	})
+
+	_, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Equal(t, "unsupported failure_policy retry", err.Error())
+}
+
+// Ensures orders execute concurrently up to the limit, one at a
+// time on each exchange and are collected in the original order
+func TestExecuteOrdersConcurrently(t *testing.T) {
+	type testCase struct {
+		exchanges      []string
+		maxConcurrency int
+		expectedMax    int32
+	}
+
+	cases := []testCase{
+		{exchanges: []string{"kraken", "kraken", "kraken", "binance", "binance", "binance"}, maxConcurrency: 4, expectedMax: 2},
+		{exchanges: []string{"a", "b", "c", "d", "e", "f"}, maxConcurrency: 3, expectedMax: 3},
+		{exchanges: []string{"a", "b", "c", "d"}, maxConcurrency: 0, expectedMax: 1},
+	}
+
+	for _, currentCase := range cases {
+		var placing, maxPlacing int32
+		perExchange := map[string]*int32{}
+		orderers := map[string]orders.Orderer{}
+
+		dcaConfig := &configuration.DCAConfig{MaxConcurrency: currentCase.maxConcurrency}
+		for index, exchange := range currentCase.exchanges {
+			if _, ok := perExchange[exchange]; !ok {
+				var exchangePlacing, exchangeMax int32
+				perExchange[exchange] = &exchangeMax
+				orderers[exchange] = exchangeOrderer{ConcurrentOrderer{placing: &exchangePlacing, maxPlacing: &exchangeMax}, ConcurrentOrderer{placing: &placing, maxPlacing: &maxPlacing}}
+			}
+
+			dcaConfig.Orders = append(dcaConfig.Orders, configuration.DCAOrder{
+				Exchange:  exchange,
+				Pair:      fmt.Sprintf("PAIR%d", index),
+				Volume:    "1",
+				Direction: "buy",
+				Enabled:   true,
+			})
+		}
+
+		services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+			appConfig.allowReal = true
+
+			c.On("GetDCAConfiguration", mock.Anything, mock.Anything, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+			o.On("GetOrderers", mock.Anything, mock.Anything).Return(&orderers, nil)
+		})
+		services.s3Access = ConcurrentS3{}
+		services.pendingOrderSubmitter = ConcurrentQueue{}
+
+		summary := &runs.RunSummary{}
+		pos, err := ExecuteOrders(context.Background(), services, appConfig, summary)
+
+		assert.Nil(t, err)
+		assert.Equal(t, currentCase.expectedMax, maxPlacing)
+		for exchange, exchangeMax := range perExchange {
+			assert.Equal(t, int32(1), *exchangeMax, exchange)
+		}
+
+		assert.Equal(t, len(currentCase.exchanges), len(*pos))
+		for index := range currentCase.exchanges {
+			assert.Equal(t, fmt.Sprintf("TX-PAIR%d", index), (*pos)[index].TransactionID)
+			assert.Equal(t, index, summary.Orders[index].Index)
+		}
+	}
+}
+
+// Ensures no more orders start once an order has failed fast
+func TestExecuteOrdersConcurrentlyFailFast(t *testing.T) {
+	var placing, maxPlacing int32
+	dcaConfig := &configuration.DCAConfig{MaxConcurrency: 1, Orders: []configuration.DCAOrder{
+		{Exchange: "broken", Pair: "ADAGBP", Volume: "1", Direction: "buy", Enabled: true},
+		{Exchange: "kraken", Pair: "BTCGBP", Volume: "1", Direction: "buy", Enabled: true},
+	}}
+	orderers := map[string]orders.Orderer{"kraken": ConcurrentOrderer{placing: &placing, maxPlacing: &maxPlacing}}
+
+	services, appConfig := setup(func(s3 *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.allowReal = true
+
+		c.On("GetDCAConfiguration", mock.Anything, s3, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(&orderers, nil)
+	})
+
+	summary := &runs.RunSummary{}
+	_, err := ExecuteOrders(context.Background(), services, appConfig, summary)
+
+	assert.Equal(t, "no orderer found for exchange broken", err.Error())
+	assert.Equal(t, 1, len(summary.Orders))
+	assert.Equal(t, int32(0), maxPlacing)
+}
+
+// Ensures a dry run never places real orders
+// and leaves the original configuration alone
+func TestAppConfigDryRun(t *testing.T) {
+	appConfig := &AppConfig{s3bucket: "bucket", allowReal: true}
+	appConfig.runs.s3Prefix = "runs"
+
+	dryRun := appConfig.DryRun()
+
+	assert.False(t, dryRun.allowReal)
+	assert.Equal(t, "bucket", dryRun.s3bucket)
+	assert.Equal(t, "runs", dryRun.runs.s3Prefix)
+	assert.True(t, appConfig.allowReal)
+}
+
+// Ensures orders paused with an override are skipped with
+// the reason while expired pauses and other orders are placed
+func TestExecuteOrdersPaused(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{ID: "btc-weekly", Exchange: "kraken", Pair: "BTCGBP", Volume: "1", Direction: "buy"},
+		{ID: "eth-weekly", Exchange: "kraken", Pair: "ETHGBP", Volume: "1", Direction: "buy"},
+		{Exchange: "kraken", Pair: "ADAGBP", Volume: "1", Direction: "buy"},
+	}}
+	saved := `{"pauses": {
+		"btc-weekly": {"until": "2999-01-01", "reason": "holiday"},
+		"eth-weekly": {"until": "2022-01-01"}
+	}}`
+
+	services, appConfig := setup(func(s3Access *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.overrides.s3Path = "overrides.json"
+
+		c.On("GetDCAConfiguration", mock.Anything, s3Access, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(&map[string]orders.Orderer{"kraken": &MockKrakenOrderer{}}, nil)
+		s3Access.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+			return *input.Key == "overrides.json"
+		}), mock.Anything).Return(&s3.GetObjectOutput{Body: ioutil.NopCloser(strings.NewReader(saved))}, nil)
+		s3Access.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", false, appConfig.queue.sqsURL).Return(nil)
+	})
+
+	summary := &runs.RunSummary{}
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, summary)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(*pos))
+	assert.Equal(t, "btc-weekly", summary.Orders[0].ID)
+	assert.Equal(t, runs.OutcomeSkipped, summary.Orders[0].Outcome)
+	assert.Equal(t, "paused until 2999-01-01: holiday", summary.Orders[0].Reason)
+	assert.Equal(t, runs.OutcomePlaced, summary.Orders[1].Outcome)
+	assert.Equal(t, runs.OutcomePlaced, summary.Orders[2].Outcome)
+}
+
+// Ensures a run fails without placing anything
+// when the overrides cannot be loaded
+func TestExecuteOrdersErrorGettingOverrides(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{ID: "btc-weekly", Exchange: "kraken", Pair: "BTCGBP", Volume: "1", Direction: "buy"},
+	}}
+
+	services, appConfig := setup(func(s3Access *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.overrides.s3Path = "overrides.json"
+
+		c.On("GetDCAConfiguration", mock.Anything, s3Access, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		s3Access.On("GetObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{}, errors.New("access denied"))
+	})
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Nil(t, pos)
+	assert.EqualError(t, err, "could not load overrides: access denied")
+	AssertExpectations(t, services)
+}
+
+// Ensures orders outside their dates, on excluded days or which have
+// been executed the maximum number of times are skipped with the reason
+func TestExecuteOrdersCalendar(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{
+		Orders: []configuration.DCAOrder{
+			{ID: "future", Exchange: "kraken", Pair: "BTCGBP", Volume: "1", Direction: "buy", StartDate: "2022-02-01"},
+			{ID: "ended", Exchange: "kraken", Pair: "BTCGBP", Volume: "1", Direction: "buy", EndDate: "2021-12-31"},
+			{ID: "limited", Exchange: "kraken", Pair: "ETHGBP", Volume: "1", Direction: "buy", MaxExecutions: 2},
+			{ID: "fridays", Exchange: "kraken", Pair: "ETHGBP", Volume: "1", Direction: "buy", Exclusions: []configuration.Exclusion{{Weekdays: []string{"friday"}}}},
+			{ID: "active", Exchange: "kraken", Pair: "ADAGBP", Volume: "1", Direction: "buy", StartDate: "2022-01-07", EndDate: "2022-01-07", MaxExecutions: 3},
+		},
+		Exclusions: []configuration.Exclusion{{From: "12-25", To: "12-31", Reason: "holidays"}},
+	}
+	history := []runs.RunSummary{
+		{Real: true, Orders: []runs.OrderOutcome{{ID: "limited", Outcome: runs.OutcomePlaced}, {ID: "active", Outcome: runs.OutcomePlaced}}},
+		{Real: true, Orders: []runs.OrderOutcome{{ID: "limited", Outcome: runs.OutcomePlaced}, {ID: "active", Outcome: runs.OutcomePlaced}}},
+	}
+
+	services, appConfig := setup(func(s3Access *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.runs.s3Prefix = "runs"
+
+		c.On("GetDCAConfiguration", mock.Anything, s3Access, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(&map[string]orders.Orderer{"kraken": &MockKrakenOrderer{}}, nil)
+		s3Access.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", false, appConfig.queue.sqsURL).Return(nil)
+	})
+	services.runLoader.(*MockRunLoader).On("ListRunsBetween", "bucket", "runs", "", "").Return(history, nil)
+
+	summary := &runs.RunSummary{TriggerTime: time.Date(2022, 1, 7, 6, 0, 0, 0, time.UTC)}
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, summary)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(*pos))
+	reasons := []string{}
+	for _, outcome := range summary.Orders {
+		reasons = append(reasons, outcome.Reason)
+	}
+	assert.Equal(t, []string{"starts on 2022-02-01", "ended on 2021-12-31", "reached max_executions of 2", "excluded on friday", ""}, reasons)
+	assert.Equal(t, runs.OutcomePlaced, summary.Orders[4].Outcome)
+
+	summary = &runs.RunSummary{TriggerTime: time.Date(2022, 12, 27, 6, 0, 0, 0, time.UTC)}
+	_, err = ExecuteOrders(context.Background(), services, appConfig, summary)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "excluded: holidays", summary.Orders[3].Reason)
+	AssertExpectations(t, services)
+}
+
+// Ensures only the runs within the windows of the limited orders are
+// loaded and each order only counts the executions within its own window
+func TestExecuteOrdersMaxExecutionsWindow(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{
+		Orders: []configuration.DCAOrder{
+			{ID: "january", Exchange: "kraken", Pair: "ETHGBP", Volume: "1", Direction: "buy", StartDate: "2022-01-01", EndDate: "2022-01-31", MaxExecutions: 2},
+			{ID: "winter", Exchange: "kraken", Pair: "ADAGBP", Volume: "1", Direction: "buy", StartDate: "2021-12-01", EndDate: "2022-01-15", MaxExecutions: 2},
+			{ID: "unlimited", Exchange: "kraken", Pair: "BTCGBP", Volume: "1", Direction: "buy"},
+		},
+	}
+	placed := func(day int, ids ...string) runs.RunSummary {
+		summary := runs.RunSummary{Real: true, TriggerTime: time.Date(2022, 1, day, 6, 0, 0, 0, time.UTC)}
+		for _, id := range ids {
+			summary.Orders = append(summary.Orders, runs.OrderOutcome{ID: id, Outcome: runs.OutcomePlaced})
+		}
+		return summary
+	}
+	history := []runs.RunSummary{placed(3, "january", "winter"), placed(20, "january", "winter")}
+
+	services, appConfig := setup(func(s3Access *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		appConfig.runs.s3Prefix = "runs"
+
+		c.On("GetDCAConfiguration", mock.Anything, s3Access, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+		o.On("GetOrderers", mock.Anything, mock.Anything).Return(&map[string]orders.Orderer{"kraken": &MockKrakenOrderer{}}, nil)
+		s3Access.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+		po.On("SubmitPendingOrder", mock.Anything, sqs, mock.Anything, "kraken", false, appConfig.queue.sqsURL).Return(nil)
+	})
+	services.runLoader.(*MockRunLoader).On("ListRunsBetween", "bucket", "runs", "2021-12-01", "2022-01-31").Return(history, nil).Once()
+
+	summary := &runs.RunSummary{TriggerTime: time.Date(2022, 1, 10, 6, 0, 0, 0, time.UTC)}
+	_, err := ExecuteOrders(context.Background(), services, appConfig, summary)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "reached max_executions of 2", summary.Orders[0].Reason)
+	assert.Equal(t, runs.OutcomePlaced, summary.Orders[1].Outcome)
+	assert.Equal(t, runs.OutcomePlaced, summary.Orders[2].Outcome)
+	AssertExpectations(t, services)
+}
+
+// Ensures a run fails when the executions of an order
+// are limited but cannot be counted
+func TestExecuteOrdersMaxExecutionsWithoutHistory(t *testing.T) {
+	dcaConfig := &configuration.DCAConfig{Orders: []configuration.DCAOrder{
+		{ID: "limited", Exchange: "kraken", Pair: "ETHGBP", Volume: "1", Direction: "buy", MaxExecutions: 2},
+	}}
+
+	services, appConfig := setup(func(s3Access *pkg.MockS3Access, ssm *pkg.MockSSMClient, sqs *pkg.MockSQSAccess, c *MockDCAConfiguration, o *MockOrdererFactory, po *MockPendingOrderSubmitter, appConfig *AppConfig) {
+		c.On("GetDCAConfiguration", mock.Anything, s3Access, &appConfig.s3bucket, &appConfig.dcaConfigPath).Return(dcaConfig, nil)
+	})
+
+	pos, err := ExecuteOrders(context.Background(), services, appConfig, &runs.RunSummary{})
+
+	assert.Nil(t, pos)
+	assert.EqualError(t, err, "max_executions needs DCA_RUNS_S3_PREFIX to count executions from the run history")
+}
@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/metrics"
+	"github.com/kiran94/dca-manager/pkg/notify"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/kiran94/dca-manager/pkg/runs"
+	"github.com/kiran94/dca-manager/pkg/strategy"
+)
+
+// Simulation is what stands in for AWS and the exchanges
+// when executing orders in a backtest.
+//
+// The clock is the simulated time the orders are executed at.
+type Simulation struct {
+	ConfigSource         configuration.DCAConfigurationSource
+	OrdererFactory       orders.OrdererFactory
+	S3Access             pkg.S3Access
+	PendingOrderQueue    orders.PendingOrderQueue
+	ProcessedOrderSource orders.ProcessedOrderSource
+	RunLoader            runs.Loader
+	Clock                func() time.Time
+}
+
+// NewSimulatedServices creates the services and configuration to execute
+// the orders of the simulation as real orders with the same strategies as a
+// live run. Nothing is recorded, notified or read from the environment.
+func NewSimulatedServices(simulation Simulation) (*DCAServices, *AppConfig) {
+	services := &DCAServices{
+		s3Access:              simulation.S3Access,
+		configSource:          simulation.ConfigSource,
+		ordererFactory:        simulation.OrdererFactory,
+		pendingOrderSubmitter: simulation.PendingOrderQueue,
+		portfolioPlanner:      strategy.Rebalancer{},
+		processedOrderSource:  simulation.ProcessedOrderSource,
+		valueAverager:         strategy.ValueAveraging{},
+		dipMultiplier:         strategy.DipBuyer{},
+		router:                strategy.BestExecution{},
+		notifier:              notify.Multi{},
+		runLoader:             simulation.RunLoader,
+		metrics:               metrics.NewRegistry(),
+		clock:                 simulation.Clock,
+	}
+
+	appConfig := &AppConfig{
+		s3bucket:      "simulated",
+		dcaConfigPath: "config.json",
+		allowReal:     true,
+	}
+	appConfig.transactions.pendingS3TransactionPrefix = "pending"
+	appConfig.transactions.processedS3TransactionPrefix = "processed"
+	appConfig.queue.sqsURL = "simulated"
+	appConfig.runs.s3Prefix = "runs"
+
+	return services, appConfig
+}
@@ -0,0 +1,567 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+)
+
+// Names of the built in profiles
+const (
+	ProfileKrakenLedger = "kraken-ledger"
+	ProfileCoinbase     = "coinbase"
+	ProfileCustom       = "custom"
+)
+
+// Columns are the names of the columns in a CSV which hold each field of
+// an order. A column may list alternative names separated by |, such as
+// when exports have renamed a column over time.
+//
+// The transaction ID, order type and fee are optional. Orders without a
+// transaction ID are given one generated from the row so that importing
+// the same CSV again is deduplicated.
+type Columns struct {
+	TransactionID string `json:"transaction_id"`
+	Time          string `json:"time"`
+	Type          string `json:"type"`
+	OrderType     string `json:"order_type"`
+	Pair          string `json:"pair"`
+	Asset         string `json:"asset"`
+	Currency      string `json:"currency"`
+	Price         string `json:"price"`
+	Cost          string `json:"cost"`
+	Volume        string `json:"volume"`
+	Fee           string `json:"fee"`
+}
+
+// Profile maps the rows of a CSV export onto orders.
+//
+// Each row is an order with a pair, or an asset and a currency, and a
+// price or a cost. Ledger profiles instead have a row per asset of a
+// trade, paired by their transaction ID, with a signed volume where the
+// quote asset is the one in the quotes. Orders read from a ledger are
+// trades rather than orders, so they are keyed by the trade.
+//
+// Types maps the values of the type column, regardless of case, onto buy,
+// sell or trade and rows of any other type are skipped. Without types the
+// type column must be buy or sell.
+type Profile struct {
+	Exchange   string            `json:"exchange"`
+	Columns    Columns           `json:"columns"`
+	TimeLayout string            `json:"time_layout"`
+	Types      map[string]string `json:"types"`
+	Ledger     bool              `json:"ledger"`
+	Quotes     []string          `json:"quotes"`
+	IDPrefix   string            `json:"id_prefix"`
+}
+
+// Profiles are the built in profiles by name.
+var Profiles = map[string]Profile{
+	ProfileKrakenLedger: {
+		Exchange: "kraken",
+		Columns: Columns{
+			TransactionID: "refid",
+			Time:          "time",
+			Type:          "type",
+			Asset:         "asset",
+			Volume:        "amount",
+			Fee:           "fee",
+		},
+		Types:  map[string]string{"trade": "trade", "spend": "trade", "receive": "trade"},
+		Ledger: true,
+		Quotes: []string{"ZGBP", "ZUSD", "ZEUR", "ZCAD", "ZJPY", "ZCHF", "ZAUD", "GBP", "USD", "EUR", "CAD", "JPY", "CHF", "AUD", "USDT", "USDC"},
+	},
+	ProfileCoinbase: {
+		Exchange: "coinbase",
+		Columns: Columns{
+			TransactionID: "ID",
+			Time:          "Timestamp",
+			Type:          "Transaction Type",
+			Asset:         "Asset",
+			Currency:      "Spot Price Currency|Price Currency",
+			Price:         "Spot Price at Transaction|Price at Transaction",
+			Cost:          "Subtotal",
+			Volume:        "Quantity Transacted",
+			Fee:           "Fees|Fees and/or Spread",
+		},
+		Types: map[string]string{
+			"buy":                 "buy",
+			"sell":                "sell",
+			"advanced trade buy":  "buy",
+			"advanced trade sell": "sell",
+		},
+		IDPrefix: "coinbase-",
+	},
+	ProfileCustom: {
+		Columns: Columns{
+			TransactionID: "transaction_id",
+			Time:          "time",
+			Type:          "type",
+			OrderType:     "order_type",
+			Pair:          "pair",
+			Price:         "price",
+			Cost:          "cost",
+			Volume:        "volume",
+			Fee:           "fee",
+		},
+		IDPrefix: "custom-",
+	},
+}
+
+// Time layouts accepted when a profile has no time layout
+// alongside unix timestamps in seconds.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// RowError is a malformed row of a CSV by its line number.
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+// RowErrors are every malformed row of a CSV.
+type RowErrors []RowError
+
+func (e RowErrors) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("%d malformed rows", len(e)))
+	for _, rowErr := range e {
+		lines = append(lines, rowErr.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LoadProfile reads a profile from JSON, where anything not set
+// is taken from the custom profile.
+func LoadProfile(r io.Reader) (Profile, error) {
+	profile := Profiles[ProfileCustom]
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&profile); err != nil {
+		return Profile{}, fmt.Errorf("invalid profile: %w", err)
+	}
+
+	types := make(map[string]string, len(profile.Types))
+	for value, mapped := range profile.Types {
+		types[strings.ToLower(value)] = mapped
+	}
+	profile.Types = types
+
+	return profile, profile.Validate()
+}
+
+// Validate checks the profile has the columns needed to build an order.
+func (p Profile) Validate() error {
+	missing := func(name string, value string) error {
+		if value == "" {
+			return fmt.Errorf("profile has no %s column", name)
+		}
+		return nil
+	}
+
+	required := map[string]string{"time": p.Columns.Time, "volume": p.Columns.Volume}
+	if p.Ledger {
+		required["transaction_id"] = p.Columns.TransactionID
+		required["asset"] = p.Columns.Asset
+		if len(p.Quotes) == 0 {
+			return errors.New("ledger profile has no quotes")
+		}
+	} else {
+		required["type"] = p.Columns.Type
+		if p.Columns.Pair == "" {
+			required["asset"] = p.Columns.Asset
+			required["currency"] = p.Columns.Currency
+		}
+		if p.Columns.Price == "" && p.Columns.Cost == "" {
+			return errors.New("profile has no price or cost column")
+		}
+	}
+
+	names := make([]string, 0, len(required))
+	for name := range required {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := missing(name, required[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadCSV normalises the rows of a CSV into orders using the profile,
+// oldest first. Rows before the header, such as the preamble of some
+// exports, are skipped.
+//
+// Every malformed row is returned as RowErrors alongside the orders
+// from the rows which could be read.
+func ReadCSV(r io.Reader, profile Profile) ([]orders.OrderComplete, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns, err := readHeader(reader, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []row{}
+	rowErrors := RowErrors{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			rowErrors = append(rowErrors, RowError{Row: parseErr.Line, Err: parseErr.Err})
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) < len(columns.fields) {
+			rowErrors = append(rowErrors, RowError{Row: line, Err: fmt.Errorf("expected %d columns, found %d", len(columns.fields), len(record))})
+			continue
+		}
+
+		parsed, skip, err := columns.parse(record, profile)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: line, Err: err})
+			continue
+		}
+		if !skip {
+			parsed.line = line
+			rows = append(rows, parsed)
+		}
+	}
+
+	var normalised []orders.OrderComplete
+	if profile.Ledger {
+		var ledgerErrors RowErrors
+		normalised, ledgerErrors = pairLedger(rows, profile)
+		rowErrors = append(rowErrors, ledgerErrors...)
+	} else {
+		normalised = make([]orders.OrderComplete, 0, len(rows))
+		for _, parsed := range rows {
+			normalised = append(normalised, parsed.order)
+		}
+	}
+
+	sort.SliceStable(normalised, func(i, j int) bool { return normalised[i].CloseTime < normalised[j].CloseTime })
+	sort.SliceStable(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+
+	if len(rowErrors) > 0 {
+		return normalised, rowErrors
+	}
+	return normalised, nil
+}
+
+// header is the index of each column of a CSV by name.
+type header struct {
+	fields  []string
+	indexes map[string]int
+}
+
+// index finds the column with any of the alternative names.
+func (h header) index(names string) (int, bool) {
+	for _, name := range strings.Split(names, "|") {
+		if index, ok := h.indexes[strings.ToLower(strings.TrimSpace(name))]; ok && name != "" {
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+// value is the trimmed value of the column, empty when the CSV lacks it.
+func (h header) value(record []string, names string) string {
+	index, ok := h.index(names)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(record[index])
+}
+
+// readHeader skips to the first row with the time column
+// and checks every required column of the profile is present.
+func readHeader(reader *csv.Reader, profile Profile) (*header, error) {
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("csv has no header with the %s column", profile.Columns.Time)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read csv header: %w", err)
+		}
+
+		columns := &header{fields: record, indexes: map[string]int{}}
+		for index, name := range record {
+			name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+			columns.indexes[name] = index
+		}
+
+		if _, ok := columns.index(profile.Columns.Time); !ok {
+			continue
+		}
+
+		required := []string{profile.Columns.Volume, profile.Columns.Type, profile.Columns.Pair, profile.Columns.Asset, profile.Columns.Currency}
+		if profile.Ledger {
+			required = append(required, profile.Columns.TransactionID)
+		}
+		for _, names := range required {
+			if _, ok := columns.index(names); names != "" && !ok {
+				return nil, fmt.Errorf("csv is missing the %s column", names)
+			}
+		}
+
+		_, hasPrice := columns.index(profile.Columns.Price)
+		_, hasCost := columns.index(profile.Columns.Cost)
+		if !profile.Ledger && !hasPrice && !hasCost {
+			return nil, fmt.Errorf("csv is missing the %s or %s column", profile.Columns.Price, profile.Columns.Cost)
+		}
+
+		return columns, nil
+	}
+}
+
+// row is a parsed row, for ledgers the order holds a single asset and its signed volume.
+type row struct {
+	line  int
+	asset string
+	order orders.OrderComplete
+}
+
+// parse normalises a row into an order, skipping rows of other types.
+func (h header) parse(record []string, profile Profile) (row, bool, error) {
+	parsed := row{}
+	order := &parsed.order
+	order.ExchangeStatus = "closed"
+
+	order.Type = strings.ToLower(h.value(record, profile.Columns.Type))
+	if len(profile.Types) > 0 {
+		mapped, ok := profile.Types[order.Type]
+		if !ok {
+			return parsed, true, nil
+		}
+		order.Type = mapped
+	}
+	if !profile.Ledger && order.Type != "buy" && order.Type != "sell" {
+		return parsed, false, fmt.Errorf("invalid type %q, expected buy or sell", h.value(record, profile.Columns.Type))
+	}
+
+	closeTime, err := parseTime(h.value(record, profile.Columns.Time), profile.TimeLayout)
+	if err != nil {
+		return parsed, false, err
+	}
+	order.OpenTime = float64(closeTime.Unix())
+	order.CloseTime = order.OpenTime
+
+	order.TransactionID = h.value(record, profile.Columns.TransactionID)
+	if order.TransactionID == "" {
+		if profile.Ledger {
+			return parsed, false, errors.New("missing transaction id")
+		}
+		hash := sha256.Sum256([]byte(strings.Join(record, ",")))
+		order.TransactionID = profile.IDPrefix + hex.EncodeToString(hash[:])[:20]
+	}
+
+	if order.Volume, err = parseDecimal(record, h, "volume", profile.Columns.Volume, true); err != nil {
+		return parsed, false, err
+	}
+	if order.Fee, err = parseDecimal(record, h, "fee", profile.Columns.Fee, false); err != nil {
+		return parsed, false, err
+	}
+
+	if profile.Ledger {
+		parsed.asset = h.value(record, profile.Columns.Asset)
+		if parsed.asset == "" {
+			return parsed, false, errors.New("missing asset")
+		}
+		return parsed, false, nil
+	}
+
+	order.Volume = order.Volume.Abs()
+	if order.Volume.IsZero() {
+		return parsed, false, errors.New("volume is zero")
+	}
+
+	order.Pair = h.value(record, profile.Columns.Pair)
+	if order.Pair == "" {
+		order.Pair = h.value(record, profile.Columns.Asset) + h.value(record, profile.Columns.Currency)
+	}
+	if order.Pair == "" {
+		return parsed, false, errors.New("missing pair")
+	}
+
+	order.OrderType = strings.ToLower(h.value(record, profile.Columns.OrderType))
+	if order.OrderType == "" {
+		order.OrderType = "market"
+	}
+
+	if _, ok := h.index(profile.Columns.Price); ok && h.value(record, profile.Columns.Price) != "" {
+		if order.Price, err = parseDecimal(record, h, "price", profile.Columns.Price, true); err != nil {
+			return parsed, false, err
+		}
+		return parsed, false, nil
+	}
+
+	cost, err := parseDecimal(record, h, "cost", profile.Columns.Cost, true)
+	if err != nil {
+		return parsed, false, err
+	}
+	order.Price = cost.Abs().Div(order.Volume)
+	return parsed, false, nil
+}
+
+// pairLedger builds an order from each pair of ledger entries of a trade,
+// where the entry in a quote asset is what was paid or received.
+//
+// The pair is named as Kraken names the pairs of its orders e.g XBTGBP
+// rather than joining the ledger assets into XXBTZGBP.
+func pairLedger(rows []row, profile Profile) ([]orders.OrderComplete, RowErrors) {
+	quotes := map[string]bool{}
+	for _, quote := range profile.Quotes {
+		quotes[strings.ToUpper(quote)] = true
+	}
+
+	grouped := map[string][]row{}
+	ordered := []string{}
+	for _, entry := range rows {
+		transactionID := entry.order.TransactionID
+		if _, ok := grouped[transactionID]; !ok {
+			ordered = append(ordered, transactionID)
+		}
+		grouped[transactionID] = append(grouped[transactionID], entry)
+	}
+
+	paired := make([]orders.OrderComplete, 0, len(ordered))
+	rowErrors := RowErrors{}
+	for _, transactionID := range ordered {
+		entries := grouped[transactionID]
+		if len(entries) != 2 {
+			rowErrors = append(rowErrors, RowError{Row: entries[0].line, Err: fmt.Errorf("trade %s has %d ledger entries, expected 2", transactionID, len(entries))})
+			continue
+		}
+
+		base, quote := entries[0], entries[1]
+		if quotes[strings.ToUpper(base.asset)] {
+			base, quote = quote, base
+		}
+		if quotes[strings.ToUpper(base.asset)] || !quotes[strings.ToUpper(quote.asset)] {
+			rowErrors = append(rowErrors, RowError{Row: entries[0].line, Err: fmt.Errorf("trade %s between %s and %s has no single quote asset", transactionID, entries[0].asset, entries[1].asset)})
+			continue
+		}
+
+		volume := base.order.Volume.Abs()
+		if volume.IsZero() {
+			rowErrors = append(rowErrors, RowError{Row: base.line, Err: errors.New("volume is zero")})
+			continue
+		}
+
+		order := base.order
+		order.Pair = orders.KrakenAltname(base.asset) + orders.KrakenAltname(quote.asset)
+		order.OrderType = "market"
+		order.Type = "buy"
+		if base.order.Volume.IsNegative() {
+			order.Type = "sell"
+		}
+		order.Volume = volume
+		order.Price = quote.order.Volume.Abs().Div(volume)
+		order.Fee = quote.order.Fee.Add(base.order.Fee.Mul(order.Price))
+		paired = append(paired, order)
+	}
+	return paired, rowErrors
+}
+
+// currencySymbols are the symbols amounts may be written with.
+const currencySymbols = "£$€¥"
+
+// thousands is an amount with thousands separators, which must be
+// followed by a decimal point so 0,5 is not mistaken for 5.
+var thousands = regexp.MustCompile(`^-?\d{1,3}(,\d{3})+\.\d*$`)
+
+// parseDecimal reads a decimal from the column, ignoring currency symbols,
+// whitespace and thousands separators. Optional columns default to zero.
+func parseDecimal(record []string, h header, field string, names string, required bool) (decimal.Decimal, error) {
+	raw := h.value(record, names)
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || strings.ContainsRune(currencySymbols, r) {
+			return -1
+		}
+		return r
+	}, raw)
+
+	if raw == "" {
+		if required {
+			return decimal.Zero, fmt.Errorf("missing %s", field)
+		}
+		return decimal.Zero, nil
+	}
+
+	if strings.Contains(cleaned, ",") {
+		if !thousands.MatchString(cleaned) {
+			return decimal.Zero, fmt.Errorf("invalid %s %q", field, raw)
+		}
+		cleaned = strings.ReplaceAll(cleaned, ",", "")
+	}
+
+	value, err := decimal.NewFromString(cleaned)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid %s %q", field, raw)
+	}
+	return value, nil
+}
+
+func parseTime(value string, layout string) (time.Time, error) {
+	if layout != "" {
+		parsed, err := time.Parse(layout, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q, expected %s", value, layout)
+		}
+		return parsed.UTC(), nil
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	for _, layout := range timeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
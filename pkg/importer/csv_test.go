@@ -0,0 +1,165 @@
+package importer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const krakenLedger = `"txid","refid","time","type","subtype","aclass","asset","amount","fee","balance"
+"L1","T1","2021-01-02 03:04:05","trade","","currency","ZGBP",-300.0000,0.7800,700.0000
+"L2","T1","2021-01-02 03:04:05","trade","","currency","XXBT",0.0100000000,0.0000000000,0.0100000000
+"L3","D1","2021-01-01 00:00:00","deposit","","currency","ZGBP",1000.0000,0.0000,1000.0000
+"L4","T2","2021-01-01 12:00:00.5","spend","","currency","ZGBP",-100.0000,1.0000,900.0000
+"L5","T2","2021-01-01 12:00:00.5","receive","","currency","XETH",0.1000000000,0.0000000000,0.1000000000
+"L6","T3","2021-01-03 00:00:00","trade","","currency","XXBT",-0.0050000000,0.0000000000,0.0050000000
+"L7","T3","2021-01-03 00:00:00","trade","","currency","ZGBP",160.0000,0.4000,859.6000
+`
+
+const coinbaseReport = `"You can use this transaction report to inform your likely tax obligations."
+Transactions
+User,someone@example.com,1234
+Timestamp,Transaction Type,Asset,Quantity Transacted,Spot Price Currency,Spot Price at Transaction,Subtotal,Total (inclusive of fees),Fees,Notes
+2021-01-02T03:04:05Z,Buy,BTC,0.01,GBP,"£30,000.00",£300.00,£302.99,£2.99,Bought 0.01 BTC for £302.99 GBP
+2021-01-03T03:04:05Z,Receive,BTC,0.5,GBP,31000.00,,,,Received 0.5 BTC from an external account
+2021-01-04T03:04:05Z,Advanced Trade Sell,BTC,0.005,GBP,32000.00,160.00,159.00,1.00,Sold 0.005 BTC
+`
+
+// Ensures ledger entries are paired into buys and sells priced in the quote
+// and named as Kraken names the pairs of its orders
+func TestReadCSVKrakenLedger(t *testing.T) {
+	read, err := ReadCSV(strings.NewReader(krakenLedger), Profiles[ProfileKrakenLedger])
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(read))
+
+	assert.Equal(t, "T2", read[0].TransactionID)
+	assert.Equal(t, "ETHGBP", read[0].Pair)
+	assert.Equal(t, "1000", read[0].Price.String())
+
+	assert.Equal(t, "T1", read[1].TransactionID)
+	assert.Equal(t, "closed", read[1].ExchangeStatus)
+	assert.Equal(t, "XBTGBP", read[1].Pair)
+	assert.Equal(t, "buy", read[1].Type)
+	assert.Equal(t, "0.01", read[1].Volume.String())
+	assert.Equal(t, "30000", read[1].Price.String())
+	assert.Equal(t, "0.78", read[1].Fee.String())
+	assert.Equal(t, float64(1609556645), read[1].CloseTime)
+
+	assert.Equal(t, "sell", read[2].Type)
+	assert.Equal(t, "0.005", read[2].Volume.String())
+	assert.Equal(t, "32000", read[2].Price.String())
+}
+
+// Ensures the preamble is skipped, other types ignored and amounts read with currency symbols
+func TestReadCSVCoinbase(t *testing.T) {
+	read, err := ReadCSV(strings.NewReader(coinbaseReport), Profiles[ProfileCoinbase])
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(read))
+
+	assert.Equal(t, "BTCGBP", read[0].Pair)
+	assert.Equal(t, "buy", read[0].Type)
+	assert.Equal(t, "market", read[0].OrderType)
+	assert.Equal(t, "30000", read[0].Price.String())
+	assert.Equal(t, "2.99", read[0].Fee.String())
+	assert.True(t, strings.HasPrefix(read[0].TransactionID, "coinbase-"))
+	assert.Equal(t, "sell", read[1].Type)
+
+	again, err := ReadCSV(strings.NewReader(coinbaseReport), Profiles[ProfileCoinbase])
+	assert.Nil(t, err)
+	assert.Equal(t, read[0].TransactionID, again[0].TransactionID)
+	assert.NotEqual(t, read[0].TransactionID, read[1].TransactionID)
+}
+
+// Ensures a custom profile maps its own columns and prices from the cost
+func TestReadCSVCustom(t *testing.T) {
+	profile, err := LoadProfile(strings.NewReader(`{
+		"exchange": "binance",
+		"time_layout": "02/01/2006",
+		"columns": {"transaction_id": "Order", "time": "Date", "type": "Side", "pair": "Market", "price": "", "cost": "Total", "volume": "Amount"},
+		"types": {"BUY": "buy"}
+	}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "binance", profile.Exchange)
+
+	read, err := ReadCSV(strings.NewReader("Order,Date,Side,Market,Amount,Total\nB1,02/01/2021,BUY,BTCGBP,0.02,600\n"), profile)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(read))
+	assert.Equal(t, "B1", read[0].TransactionID)
+	assert.Equal(t, "30000", read[0].Price.String())
+	assert.Equal(t, "0", read[0].Fee.String())
+
+	_, err = LoadProfile(strings.NewReader(`{"columns": {"volume": ""}}`))
+	assert.EqualError(t, err, "profile has no volume column")
+
+	_, err = LoadProfile(strings.NewReader(`{"colums": {}}`))
+	assert.NotNil(t, err)
+}
+
+// Ensures every malformed row is reported by its line and the valid rows are still read
+func TestReadCSVMalformed(t *testing.T) {
+	csv := strings.Join([]string{
+		"transaction_id,time,type,pair,price,volume,fee",
+		"TX1,2021-01-01,buy,XBTGBP,30000,0.01,1",
+		"TX2,yesterday,buy,XBTGBP,30000,0.01,1",
+		"TX3,2021-01-01,hold,XBTGBP,30000,0.01,1",
+		"TX4,2021-01-01,buy,XBTGBP,lots,0.01,1",
+		"TX5,2021-01-01,buy,XBTGBP,30000,0,1",
+		"TX6,2021-01-01,buy",
+		"TX7,2021-01-01,buy,XBTGBP,30000,,1",
+		"TX1,2021-01-02,buy,XBTGBP,31000,0.01,1",
+		`TX8,2021-01-01,buy,XBTGBP,"€1.234,56",0.01,1`,
+		`TX9,2021-01-01,buy,XBTGBP,30000,"0,5",1`,
+		`TX10,2021-01-01,buy,XBTGBP,"£ 30,000.00",0.01,1`,
+	}, "\n")
+
+	read, err := ReadCSV(strings.NewReader(csv), Profiles[ProfileCustom])
+
+	var rowErrors RowErrors
+	assert.True(t, errors.As(err, &rowErrors))
+	assert.Equal(t, 8, len(rowErrors))
+	assert.Equal(t, strings.Join([]string{
+		"8 malformed rows",
+		`row 3: invalid time "yesterday"`,
+		`row 4: invalid type "hold", expected buy or sell`,
+		`row 5: invalid price "lots"`,
+		"row 6: volume is zero",
+		"row 7: expected 7 columns, found 3",
+		"row 8: missing volume",
+		`row 10: invalid price "€1.234,56"`,
+		`row 11: invalid volume "0,5"`,
+	}, "\n"), err.Error())
+
+	// Duplicates are left to the import to report
+	assert.Equal(t, 3, len(read))
+	assert.Equal(t, "TX1", read[0].TransactionID)
+	assert.Equal(t, "TX10", read[1].TransactionID)
+	assert.Equal(t, "30000", read[1].Price.String())
+	assert.Equal(t, "TX1", read[2].TransactionID)
+}
+
+// Ensures ledgers whose trades can't be paired are reported
+func TestReadCSVUnpairedLedger(t *testing.T) {
+	ledger := strings.Join([]string{
+		"txid,refid,time,type,asset,amount,fee",
+		"L1,T1,2021-01-01,trade,ZGBP,-300,0",
+		"L2,T2,2021-01-01,trade,XXBT,-0.01,0",
+		"L3,T2,2021-01-01,trade,XETH,0.1,0",
+	}, "\n")
+
+	read, err := ReadCSV(strings.NewReader(ledger), Profiles[ProfileKrakenLedger])
+
+	assert.Empty(t, read)
+	assert.EqualError(t, err, strings.Join([]string{
+		"2 malformed rows",
+		"row 2: trade T1 has 1 ledger entries, expected 2",
+		"row 3: trade T2 between XXBT and XETH has no single quote asset",
+	}, "\n"))
+
+	_, err = ReadCSV(strings.NewReader("refid,amount\n"), Profiles[ProfileKrakenLedger])
+	assert.EqualError(t, err, "csv has no header with the time column")
+}
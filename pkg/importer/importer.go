@@ -0,0 +1,362 @@
+// Package importer records orders made outside of the DCA manager, such as
+// those made before it existed, into the processed orders so that reports
+// cover the whole history of an exchange.
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+)
+
+// Options are where the processed orders are stored and how orders
+// which have already been processed are treated.
+type Options struct {
+	S3Bucket        string
+	ProcessedPrefix string
+	Exchange        string
+	// Overwrite replaces orders which have already been processed
+	// rather than skipping them.
+	Overwrite bool
+	// DryRun reports what would be imported without writing anything.
+	DryRun bool
+	// MatchTrades skips orders which are trades of an order already processed,
+	// matched by pair, direction, time and volume, such as the orders read from
+	// a ledger which are keyed by the trade rather than the order.
+	MatchTrades bool
+}
+
+// Result is the outcome of importing the orders of an exchange.
+//
+// Existing are the transactions which were already processed, Matched are
+// the trades which filled an order already processed and Duplicates are
+// the transactions which appeared more than once.
+type Result struct {
+	Exchange   string   `json:"exchange"`
+	Imported   []string `json:"imported"`
+	Existing   []string `json:"existing"`
+	Matched    []string `json:"matched"`
+	Duplicates []string `json:"duplicates"`
+}
+
+// Importer is an abstraction to import orders into the processed orders.
+type Importer interface {
+	Collect(ctx context.Context, history orders.OrderHistory, trades orders.TradeHistory, start time.Time, end time.Time) ([]orders.OrderComplete, error)
+	Import(ctx context.Context, s3Client pkg.S3Access, imported []orders.OrderComplete, options Options) (*Result, error)
+	SubmitGlue(ctx context.Context, glueClient pkg.GlueAccess, jobName string, writeOperation string, options Options) (string, error)
+}
+
+// S3Importer writes imported orders to S3 alongside the processed orders.
+type S3Importer struct{}
+
+// Collect gets the orders which filled on the exchange between the start and
+// end, oldest first. Either history may be nil when the exchange lacks it.
+//
+// Orders are taken from the closed orders and any order only seen in the
+// trades, such as those beyond what the exchange returns as closed orders,
+// is rebuilt from its trades. Where the trades of a closed order name its
+// pair differently, orders rebuilt from trades take the pair of the closed order.
+func (i S3Importer) Collect(ctx context.Context, history orders.OrderHistory, trades orders.TradeHistory, start time.Time, end time.Time) ([]orders.OrderComplete, error) {
+	collected := []orders.OrderComplete{}
+	seen := map[string]bool{}
+	closedPairs := map[string]string{}
+
+	if history != nil {
+		closed, err := history.GetClosedOrders(ctx, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("could not get closed orders: %w", err)
+		}
+
+		for _, order := range closed {
+			if order.Volume.IsZero() {
+				continue
+			}
+			seen[order.TransactionID] = true
+			closedPairs[order.TransactionID] = order.Pair
+			collected = append(collected, order)
+		}
+	}
+
+	if trades != nil {
+		filled, err := trades.GetTrades(ctx, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("could not get trades: %w", err)
+		}
+
+		pairs := map[string]string{}
+		for _, trade := range filled {
+			if pair, ok := closedPairs[trade.TransactionID]; ok && pair != "" && trade.Pair != "" {
+				pairs[trade.Pair] = pair
+			}
+		}
+
+		for _, order := range fromTrades(filled) {
+			if seen[order.TransactionID] {
+				continue
+			}
+			if pair, ok := pairs[order.Pair]; ok {
+				order.Pair = pair
+			}
+			seen[order.TransactionID] = true
+			collected = append(collected, order)
+		}
+	}
+
+	sort.SliceStable(collected, func(i, j int) bool { return collected[i].CloseTime < collected[j].CloseTime })
+	return collected, nil
+}
+
+// Import writes each order to <prefix>/exchange=<exchange>/<txid>.json, the
+// same layout as the processed orders, tagged with the import source.
+//
+// Orders are deduplicated by their transaction ID and those which have
+// already been processed are skipped unless asked to overwrite them. When
+// matching trades, trades which filled an order already processed are
+// always skipped, even when overwriting.
+func (i S3Importer) Import(ctx context.Context, s3Client pkg.S3Access, imported []orders.OrderComplete, options Options) (*Result, error) {
+	exchange := strings.ToLower(options.Exchange)
+	exchangePrefix := fmt.Sprintf("%s/exchange=%s/", options.ProcessedPrefix, exchange)
+
+	existing, err := listTransactions(ctx, s3Client, options.S3Bucket, exchangePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("could not list processed orders: %w", err)
+	}
+
+	var filled *fills
+	if options.MatchTrades {
+		processed, err := orders.ProcessedOrderLoader{}.GetProcessedOrders(ctx, s3Client, options.S3Bucket, exchangePrefix)
+		if err != nil {
+			return nil, fmt.Errorf("could not load processed orders: %w", err)
+		}
+		filled = newFills(*processed, imported)
+	}
+
+	result := &Result{Exchange: exchange, Imported: []string{}, Existing: []string{}, Matched: []string{}, Duplicates: []string{}}
+	written := map[string]bool{}
+
+	for _, order := range imported {
+		if order.TransactionID == "" {
+			return result, fmt.Errorf("order closed at %v has no transaction id", order.CloseTime)
+		}
+
+		if written[order.TransactionID] {
+			result.Duplicates = append(result.Duplicates, order.TransactionID)
+			continue
+		}
+		written[order.TransactionID] = true
+
+		if existing[order.TransactionID] && !options.Overwrite {
+			result.Existing = append(result.Existing, order.TransactionID)
+			continue
+		}
+
+		if filled != nil && filled.match(order) {
+			result.Matched = append(result.Matched, order.TransactionID)
+			continue
+		}
+
+		if !options.DryRun {
+			order.Source = orders.SourceImport
+			orderBytes, err := json.Marshal(order)
+			if err != nil {
+				return result, err
+			}
+
+			s3Path := exchangePrefix + order.TransactionID + ".json"
+			_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+				Bucket: &options.S3Bucket,
+				Key:    &s3Path,
+				Body:   bytes.NewReader(orderBytes),
+			})
+			if err != nil {
+				return result, fmt.Errorf("could not import %s: %w", order.TransactionID, err)
+			}
+		}
+
+		result.Imported = append(result.Imported, order.TransactionID)
+	}
+
+	return result, nil
+}
+
+// SubmitGlue submits a single job loading every processed order of the
+// exchange into the DataLake, returning the ID of the job run.
+//
+// The job only runs one at a time so the whole exchange is loaded at once
+// rather than submitting a job per imported order like process_orders.
+func (i S3Importer) SubmitGlue(ctx context.Context, glueClient pkg.GlueAccess, jobName string, writeOperation string, options Options) (string, error) {
+	exchange := strings.ToLower(options.Exchange)
+
+	// Partition columns can't be derived below the exchange partition
+	additionalColumnsJSON, err := json.Marshal(map[string]string{"exchange": exchange})
+	if err != nil {
+		return "", err
+	}
+
+	submittedJob, err := glueClient.StartJobRun(ctx, &glue.StartJobRunInput{
+		JobName: &jobName,
+		Arguments: map[string]string{
+			"--input_path":         fmt.Sprintf("s3a://%s/%s/exchange=%s/", options.S3Bucket, options.ProcessedPrefix, exchange),
+			"--write_operation":    writeOperation,
+			"--additional_columns": string(additionalColumnsJSON),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if submittedJob.JobRunId == nil {
+		return "", nil
+	}
+	return *submittedJob.JobRunId, nil
+}
+
+// fromTrades rebuilds the orders filled by the trades, where the price is
+// the volume weighted price of the trades and the fees are summed.
+func fromTrades(trades []orders.Trade) []orders.OrderComplete {
+	rebuilt := map[string]*orders.OrderComplete{}
+	costs := map[string]decimal.Decimal{}
+	ordered := []string{}
+
+	for _, trade := range trades {
+		order, ok := rebuilt[trade.TransactionID]
+		if !ok {
+			order = &orders.OrderComplete{
+				TransactionID:  trade.TransactionID,
+				ExchangeStatus: "closed",
+				Pair:           trade.Pair,
+				OrderType:      trade.OrderType,
+				Type:           trade.Type,
+				OpenTime:       trade.Time,
+			}
+			rebuilt[trade.TransactionID] = order
+			ordered = append(ordered, trade.TransactionID)
+		}
+
+		order.Volume = order.Volume.Add(trade.Volume)
+		order.Fee = order.Fee.Add(trade.Fee)
+		costs[trade.TransactionID] = costs[trade.TransactionID].Add(trade.Cost)
+
+		if trade.Time < order.OpenTime {
+			order.OpenTime = trade.Time
+		}
+		if trade.Time > order.CloseTime {
+			order.CloseTime = trade.Time
+		}
+	}
+
+	collected := make([]orders.OrderComplete, 0, len(ordered))
+	for _, transactionID := range ordered {
+		order := rebuilt[transactionID]
+		if order.Volume.IsZero() {
+			continue
+		}
+		order.Price = costs[transactionID].Div(order.Volume)
+		collected = append(collected, *order)
+	}
+	return collected
+}
+
+// fillTolerance is how far outside of when an order was open its trades may
+// be, as ledgers record times to the second or less.
+const fillTolerance = 1.0
+
+// fills is what is left to fill of each order already processed, which
+// trades being imported are matched against.
+type fills struct {
+	remaining map[string][]*orders.OrderComplete
+}
+
+// newFills tracks the orders already processed other than those being imported
+// again, such as trades from an earlier import of the same ledger.
+func newFills(processed []orders.OrderComplete, imported []orders.OrderComplete) *fills {
+	importing := map[string]bool{}
+	for _, order := range imported {
+		importing[order.TransactionID] = true
+	}
+
+	f := &fills{remaining: map[string][]*orders.OrderComplete{}}
+	for index := range processed {
+		order := processed[index]
+		if importing[order.TransactionID] || order.IsOpen() || !order.Volume.IsPositive() {
+			continue
+		}
+		key := fillKey(order)
+		f.remaining[key] = append(f.remaining[key], &order)
+	}
+	return f
+}
+
+// match takes the volume of the trade from the first order it could have
+// filled, that is of the same pair and direction, open at the time of the
+// trade and with enough volume left.
+func (f *fills) match(trade orders.OrderComplete) bool {
+	for _, order := range f.remaining[fillKey(trade)] {
+		closed := order.CloseTime
+		if closed == 0 {
+			closed = order.OpenTime
+		}
+
+		if trade.CloseTime < order.OpenTime-fillTolerance || trade.CloseTime > closed+fillTolerance {
+			continue
+		}
+		if trade.Volume.GreaterThan(order.Volume) {
+			continue
+		}
+
+		order.Volume = order.Volume.Sub(trade.Volume)
+		return true
+	}
+	return false
+}
+
+// fillKey is the normalised pair and direction of the order.
+func fillKey(order orders.OrderComplete) string {
+	return orders.NormalisePair(order.Pair) + "/" + strings.ToLower(order.Type)
+}
+
+// listTransactions lists the transaction IDs stored as <prefix><txid>.json.
+func listTransactions(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string) (map[string]bool, error) {
+	transactions := map[string]bool{}
+
+	var continuationToken *string
+	for {
+		listed, err := s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            &s3Bucket,
+			Prefix:            &s3Prefix,
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range listed.Contents {
+			if object.Key == nil {
+				continue
+			}
+
+			name := strings.TrimPrefix(*object.Key, s3Prefix)
+			if strings.Contains(name, "/") || !strings.HasSuffix(name, ".json") {
+				continue
+			}
+			transactions[strings.TrimSuffix(name, ".json")] = true
+		}
+
+		if !listed.IsTruncated {
+			break
+		}
+		continuationToken = listed.NextContinuationToken
+	}
+
+	return transactions, nil
+}
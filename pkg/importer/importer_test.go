@@ -0,0 +1,222 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	start = time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	end   = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// History returns fixed closed orders
+type History struct {
+	closed []orders.OrderComplete
+	err    error
+}
+
+func (h History) GetClosedOrders(ctx context.Context, start time.Time, end time.Time) ([]orders.OrderComplete, error) {
+	return h.closed, h.err
+}
+
+// Trades returns fixed trades
+type Trades struct {
+	trades []orders.Trade
+}
+
+func (t Trades) GetTrades(ctx context.Context, start time.Time, end time.Time) ([]orders.Trade, error) {
+	return t.trades, nil
+}
+
+func options() Options {
+	return Options{S3Bucket: "bucket", ProcessedPrefix: "processed", Exchange: "Kraken"}
+}
+
+// Ensures orders are taken from the closed orders and rebuilt from trades when missing
+func TestCollect(t *testing.T) {
+	history := History{closed: []orders.OrderComplete{
+		{TransactionID: "TX-CLOSED", ExchangeStatus: "closed", Volume: decimal.NewFromInt(1), CloseTime: 300},
+		{TransactionID: "TX-CANCELLED", ExchangeStatus: "canceled", Volume: decimal.Zero, CloseTime: 400},
+	}}
+	trades := Trades{trades: []orders.Trade{
+		{TradeID: "T1", TransactionID: "TX-CLOSED", Volume: decimal.NewFromInt(1), Time: 300},
+		{TradeID: "T2", TransactionID: "TX-OLD", Pair: "XXBTZGBP", Type: "buy", OrderType: "limit", Volume: decimal.NewFromInt(1), Cost: decimal.NewFromInt(100), Fee: decimal.NewFromInt(1), Time: 100},
+		{TradeID: "T3", TransactionID: "TX-OLD", Volume: decimal.NewFromInt(3), Cost: decimal.NewFromInt(500), Fee: decimal.NewFromInt(2), Time: 150},
+	}}
+
+	collected, err := S3Importer{}.Collect(context.Background(), history, trades, start, end)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(collected))
+
+	assert.Equal(t, "TX-OLD", collected[0].TransactionID)
+	assert.Equal(t, "closed", collected[0].ExchangeStatus)
+	assert.Equal(t, "XXBTZGBP", collected[0].Pair)
+	assert.Equal(t, "4", collected[0].Volume.String())
+	assert.Equal(t, "150", collected[0].Price.String())
+	assert.Equal(t, "3", collected[0].Fee.String())
+	assert.Equal(t, float64(100), collected[0].OpenTime)
+	assert.Equal(t, float64(150), collected[0].CloseTime)
+
+	assert.Equal(t, "TX-CLOSED", collected[1].TransactionID)
+
+	_, err = S3Importer{}.Collect(context.Background(), History{err: errors.New("rate limited")}, nil, start, end)
+	assert.EqualError(t, err, "could not get closed orders: rate limited")
+}
+
+// Ensures orders rebuilt from trades take the pair of the closed
+// orders when the trades name the pair differently
+func TestCollectTradePairs(t *testing.T) {
+	history := History{closed: []orders.OrderComplete{
+		{TransactionID: "TX-CLOSED", ExchangeStatus: "closed", Pair: "XBTGBP", Volume: decimal.NewFromInt(1), CloseTime: 300},
+	}}
+	trades := Trades{trades: []orders.Trade{
+		{TradeID: "T1", TransactionID: "TX-CLOSED", Pair: "XXBTZGBP", Volume: decimal.NewFromInt(1), Cost: decimal.NewFromInt(100), Time: 300},
+		{TradeID: "T2", TransactionID: "TX-OLD", Pair: "XXBTZGBP", Volume: decimal.NewFromInt(1), Cost: decimal.NewFromInt(100), Time: 100},
+		{TradeID: "T3", TransactionID: "TX-ADA", Pair: "ADAGBP", Volume: decimal.NewFromInt(10), Cost: decimal.NewFromInt(5), Time: 200},
+	}}
+
+	collected, err := S3Importer{}.Collect(context.Background(), history, trades, start, end)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(collected))
+	assert.Equal(t, "TX-OLD", collected[0].TransactionID)
+	assert.Equal(t, "XBTGBP", collected[0].Pair)
+	assert.Equal(t, "TX-ADA", collected[1].TransactionID)
+	assert.Equal(t, "ADAGBP", collected[1].Pair)
+	assert.Equal(t, "TX-CLOSED", collected[2].TransactionID)
+	assert.Equal(t, "XBTGBP", collected[2].Pair)
+}
+
+// Ensures imported orders are written once, tagged with their source and processed orders are kept
+func TestImport(t *testing.T) {
+	s3Access := &pkg.MockS3Access{}
+	s3Access.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == "processed/exchange=kraken/"
+	}), mock.Anything).Return(&s3.ListObjectsV2Output{Contents: []s3types.Object{
+		{Key: aws.String("processed/exchange=kraken/TX-DONE.json")},
+	}}, nil)
+
+	written := map[string]orders.OrderComplete{}
+	s3Access.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		var order orders.OrderComplete
+		body, _ := io.ReadAll(input.Body)
+		if err := json.Unmarshal(body, &order); err != nil {
+			return false
+		}
+		written[*input.Key] = order
+		return *input.Bucket == "bucket"
+	}), mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	imported := []orders.OrderComplete{
+		{TransactionID: "TX-NEW", ExchangeStatus: "closed", Volume: decimal.NewFromInt(1)},
+		{TransactionID: "TX-DONE", ExchangeStatus: "closed", Volume: decimal.NewFromInt(1)},
+		{TransactionID: "TX-NEW", ExchangeStatus: "closed", Volume: decimal.NewFromInt(1)},
+	}
+
+	result, err := S3Importer{}.Import(context.Background(), s3Access, imported, options())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "kraken", result.Exchange)
+	assert.Equal(t, []string{"TX-NEW"}, result.Imported)
+	assert.Equal(t, []string{"TX-DONE"}, result.Existing)
+	assert.Equal(t, []string{"TX-NEW"}, result.Duplicates)
+	assert.Equal(t, 1, len(written))
+	assert.Equal(t, orders.SourceImport, written["processed/exchange=kraken/TX-NEW.json"].Source)
+
+	overwrite := options()
+	overwrite.Overwrite = true
+	result, err = S3Importer{}.Import(context.Background(), s3Access, imported, overwrite)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"TX-NEW", "TX-DONE"}, result.Imported)
+	assert.Equal(t, 2, len(written))
+}
+
+// Ensures trades of orders already processed are matched by pair, direction, time and volume
+func TestImportMatchTrades(t *testing.T) {
+	s3Access := &pkg.MockS3Access{}
+	s3Access.On("ListObjectsV2", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListObjectsV2Output{Contents: []s3types.Object{
+		{Key: aws.String("processed/exchange=kraken/O1.json")},
+		{Key: aws.String("processed/exchange=kraken/T-OLD.json")},
+	}}, nil)
+	s3Access.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+		return *input.Key == "processed/exchange=kraken/O1.json"
+	}), mock.Anything).Return(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(
+		`{"transaction_id": "O1", "exchange_status": "closed", "pair": "XXBTZGBP", "type": "buy", "volume": "0.02", "open_time": 1000, "close_time": 1005}`,
+	))}, nil)
+	s3Access.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+		return *input.Key == "processed/exchange=kraken/T-OLD.json"
+	}), mock.Anything).Return(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(
+		`{"transaction_id": "T-OLD", "exchange_status": "closed", "pair": "XBTGBP", "type": "buy", "volume": "1", "open_time": 1000, "close_time": 1000}`,
+	))}, nil)
+
+	trade := func(txid string, direction string, closed float64) orders.OrderComplete {
+		return orders.OrderComplete{TransactionID: txid, ExchangeStatus: "closed", Pair: "XBTGBP", Type: direction, Volume: decimal.RequireFromString("0.01"), OpenTime: closed, CloseTime: closed}
+	}
+	imported := []orders.OrderComplete{
+		trade("T-OLD", "buy", 1000),
+		trade("T1", "buy", 1003),
+		trade("T2", "sell", 1003),
+		trade("T3", "buy", 1005.5),
+		trade("T4", "buy", 1004),
+		trade("T5", "buy", 5000),
+	}
+
+	matching := options()
+	matching.MatchTrades = true
+	matching.DryRun = true
+	result, err := S3Importer{}.Import(context.Background(), s3Access, imported, matching)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"T-OLD"}, result.Existing)
+	assert.Equal(t, []string{"T1", "T3"}, result.Matched)
+	assert.Equal(t, []string{"T2", "T4", "T5"}, result.Imported)
+}
+
+// Ensures nothing is written on a dry run
+func TestImportDryRun(t *testing.T) {
+	s3Access := &pkg.MockS3Access{}
+	s3Access.On("ListObjectsV2", mock.Anything, mock.Anything, mock.Anything).Return(&s3.ListObjectsV2Output{}, nil)
+
+	dryRun := options()
+	dryRun.DryRun = true
+	result, err := S3Importer{}.Import(context.Background(), s3Access, []orders.OrderComplete{{TransactionID: "TX-NEW"}}, dryRun)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"TX-NEW"}, result.Imported)
+
+	_, err = S3Importer{}.Import(context.Background(), s3Access, []orders.OrderComplete{{CloseTime: 1}}, dryRun)
+	assert.EqualError(t, err, "order closed at 1 has no transaction id")
+}
+
+// Ensures a single job is submitted for the whole exchange
+func TestSubmitGlue(t *testing.T) {
+	glueAccess := &pkg.MockGlueAccess{}
+	glueAccess.On("StartJobRun", mock.Anything, mock.MatchedBy(func(input *glue.StartJobRunInput) bool {
+		return *input.JobName == "process_transaction" &&
+			input.Arguments["--input_path"] == "s3a://bucket/processed/exchange=kraken/" &&
+			input.Arguments["--write_operation"] == "upsert" &&
+			input.Arguments["--additional_columns"] == `{"exchange":"kraken"}`
+	}), mock.Anything).Return(&glue.StartJobRunOutput{JobRunId: aws.String("jr_1")}, nil)
+
+	jobRunID, err := S3Importer{}.SubmitGlue(context.Background(), glueAccess, "process_transaction", "upsert", options())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "jr_1", jobRunID)
+}
@@ -0,0 +1,52 @@
+// Package logging carries a logger scoped to a run in the context
+// so every line logged during the run can be correlated.
+package logging
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/sirupsen/logrus"
+)
+
+// Fields which correlate the lines logged during a run.
+const (
+	FieldRunID         string = "runId"
+	FieldRequestID     string = "requestId"
+	FieldConfigVersion string = "configVersion"
+	FieldOrderIndex    string = "orderIndex"
+)
+
+type contextKey struct{}
+
+// WithLogger carries the logger in the context.
+func WithLogger(ctx context.Context, logger *logrus.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext is the logger carried in the context
+// or the standard logger when there is none.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if logger, ok := ctx.Value(contextKey{}).(*logrus.Entry); ok {
+		return logger
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// WithFields carries a logger with the fields added to the logger in the context.
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return WithLogger(ctx, FromContext(ctx).WithFields(fields))
+}
+
+// WithField carries a logger with the field added to the logger in the context.
+func WithField(ctx context.Context, key string, value interface{}) context.Context {
+	return WithLogger(ctx, FromContext(ctx).WithField(key, value))
+}
+
+// WithRequest carries a logger with the Lambda request ID when invoked by Lambda.
+func WithRequest(ctx context.Context) context.Context {
+	if lambdaContext, ok := lambdacontext.FromContext(ctx); ok {
+		return WithField(ctx, FieldRequestID, lambdaContext.AwsRequestID)
+	}
+	return ctx
+}
@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensures the standard logger is used without a logger in the context
+func TestFromContextDefault(t *testing.T) {
+	logger := FromContext(context.Background())
+
+	assert.Equal(t, logrus.StandardLogger(), logger.Logger)
+	assert.Equal(t, 0, len(logger.Data))
+}
+
+// Ensures fields accumulate without changing the parent context
+func TestWithFields(t *testing.T) {
+	run := WithFields(context.Background(), logrus.Fields{FieldRunID: "RUN", FieldConfigVersion: "v1"})
+	order := WithField(run, FieldOrderIndex, 2)
+
+	assert.Equal(t, logrus.Fields{FieldRunID: "RUN", FieldConfigVersion: "v1"}, FromContext(run).Data)
+	assert.Equal(t, logrus.Fields{FieldRunID: "RUN", FieldConfigVersion: "v1", FieldOrderIndex: 2}, FromContext(order).Data)
+}
+
+// Ensures the Lambda request ID is added when invoked by Lambda
+func TestWithRequest(t *testing.T) {
+	assert.Equal(t, 0, len(FromContext(WithRequest(context.Background())).Data))
+
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{AwsRequestID: "REQUEST"})
+	assert.Equal(t, "REQUEST", FromContext(WithRequest(ctx)).Data[FieldRequestID])
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// emfMaxValues is the most values CloudWatch accepts for a metric in one line.
+const emfMaxValues = 100
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+// WriteEMF writes a CloudWatch Embedded Metric Format line for every series
+// which changed since the last call so a warm Lambda does not publish twice.
+func (r *Registry) WriteEMF(w io.Writer, namespace string, timestamp time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	encoder := json.NewEncoder(w)
+	for _, s := range r.sorted() {
+		values := []interface{}{}
+
+		if kindOf(s.name) == kindCounter {
+			if s.value != s.flushed {
+				values = append(values, s.value-s.flushed)
+			}
+		} else {
+			for start := 0; start < len(s.pending); start += emfMaxValues {
+				end := start + emfMaxValues
+				if end > len(s.pending) {
+					end = len(s.pending)
+				}
+				values = append(values, s.pending[start:end])
+			}
+		}
+
+		for _, value := range values {
+			if err := encoder.Encode(emfLine(s, namespace, timestamp, value)); err != nil {
+				return err
+			}
+		}
+
+		s.flushed = s.value
+		s.pending = nil
+	}
+
+	return nil
+}
+
+// emfLine is the series as an EMF line where every label is a dimension.
+func emfLine(s *series, namespace string, timestamp time.Time, value interface{}) map[string]interface{} {
+	unit := "None"
+	if def, ok := definitions[s.name]; ok {
+		unit = def.unit
+	}
+
+	line := map[string]interface{}{
+		"_aws": emfMetadata{
+			Timestamp: timestamp.UnixNano() / int64(time.Millisecond),
+			CloudWatchMetrics: []emfDirective{{
+				Namespace:  namespace,
+				Dimensions: [][]string{labelNames(s.labels)},
+				Metrics:    []emfMetric{{Name: s.name, Unit: unit}},
+			}},
+		},
+		s.name: value,
+	}
+
+	for name, labelValue := range s.labels {
+		line[name] = labelValue
+	}
+	return line
+}
@@ -0,0 +1,297 @@
+// Package metrics records counters and timings of runs and exposes them in the
+// Prometheus text format or as CloudWatch Embedded Metric Format log lines.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Metrics which are recorded.
+const (
+	OrdersTotal            string = "dca_orders_total"
+	SpendTotal             string = "dca_spend_total"
+	FeesTotal              string = "dca_fees_total"
+	ExchangeRequestSeconds string = "dca_exchange_request_seconds"
+	ExchangeErrorsTotal    string = "dca_exchange_errors_total"
+	SQSMessagesTotal       string = "dca_sqs_messages_total"
+	GlueJobsTotal          string = "dca_glue_jobs_total"
+)
+
+// DefaultNamespace is the CloudWatch namespace metrics are published under.
+const DefaultNamespace = "DCAManager"
+
+// Kinds of metric.
+const (
+	kindCounter   string = "counter"
+	kindHistogram string = "histogram"
+)
+
+// definition describes a metric so it can be exposed.
+type definition struct {
+	kind string
+	help string
+	unit string
+}
+
+var definitions = map[string]definition{
+	OrdersTotal:            {kind: kindCounter, unit: "Count", help: "Orders by exchange, pair and outcome."},
+	SpendTotal:             {kind: kindCounter, unit: "None", help: "Amount spent on filled buy orders by quote currency."},
+	FeesTotal:              {kind: kindCounter, unit: "None", help: "Fees charged on filled orders by quote currency."},
+	ExchangeRequestSeconds: {kind: kindHistogram, unit: "Seconds", help: "Latency of exchange API requests."},
+	ExchangeErrorsTotal:    {kind: kindCounter, unit: "Count", help: "Exchange API requests which failed."},
+	SQSMessagesTotal:       {kind: kindCounter, unit: "Count", help: "SQS messages processed by outcome."},
+	GlueJobsTotal:          {kind: kindCounter, unit: "Count", help: "Glue jobs submitted."},
+}
+
+// DefaultBuckets are the upper bounds in seconds of histogram buckets.
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Labels identify a series within a metric.
+type Labels map[string]string
+
+// Recorder is an abstraction to record metrics.
+type Recorder interface {
+	Add(name string, value float64, labels Labels)
+	Observe(name string, value float64, labels Labels)
+}
+
+// Discard drops every metric.
+type Discard struct{}
+
+// Add drops the value.
+func (d Discard) Add(name string, value float64, labels Labels) {}
+
+// Observe drops the value.
+func (d Discard) Observe(name string, value float64, labels Labels) {}
+
+// series is a single metric and set of labels.
+type series struct {
+	name   string
+	labels Labels
+
+	// counter
+	value   float64
+	flushed float64
+
+	// histogram
+	count   uint64
+	sum     float64
+	buckets []uint64
+	pending []float64
+}
+
+// Registry holds every series recorded by the process and is safe for concurrent use.
+type Registry struct {
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{series: map[string]*series{}}
+}
+
+// Add increases the counter by the value.
+func (r *Registry) Add(name string, value float64, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.get(name, labels).value += value
+}
+
+// Observe records the value in the histogram.
+func (r *Registry) Observe(name string, value float64, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.get(name, labels)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(DefaultBuckets))
+	}
+
+	s.count++
+	s.sum += value
+	s.pending = append(s.pending, value)
+	for index, bound := range DefaultBuckets {
+		if value <= bound {
+			s.buckets[index]++
+		}
+	}
+}
+
+// Value is the current value of the counter.
+func (r *Registry) Value(name string, labels Labels) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s, ok := r.series[seriesKey(name, labels)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// Count is how many values the histogram has observed.
+func (r *Registry) Count(name string, labels Labels) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s, ok := r.series[seriesKey(name, labels)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+// WritePrometheus writes every series in the Prometheus text exposition format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out strings.Builder
+	lastName := ""
+	for _, s := range r.sorted() {
+		def := definitions[s.name]
+		if s.name != lastName {
+			fmt.Fprintf(&out, "# HELP %s %s\n", s.name, def.help)
+			fmt.Fprintf(&out, "# TYPE %s %s\n", s.name, kindOf(s.name))
+			lastName = s.name
+		}
+
+		if kindOf(s.name) == kindCounter {
+			fmt.Fprintf(&out, "%s%s %s\n", s.name, formatLabels(s.labels, "", ""), formatValue(s.value))
+			continue
+		}
+
+		for index, bound := range DefaultBuckets {
+			fmt.Fprintf(&out, "%s_bucket%s %d\n", s.name, formatLabels(s.labels, "le", formatValue(bound)), s.buckets[index])
+		}
+		fmt.Fprintf(&out, "%s_bucket%s %d\n", s.name, formatLabels(s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(&out, "%s_sum%s %s\n", s.name, formatLabels(s.labels, "", ""), formatValue(s.sum))
+		fmt.Fprintf(&out, "%s_count%s %d\n", s.name, formatLabels(s.labels, "", ""), s.count)
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// Handler serves the registry for Prometheus to scrape.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WritePrometheus(w)
+	})
+}
+
+// Serve exposes the registry on /metrics at the address until the server is closed.
+func Serve(address string, registry *Registry) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry.Handler())
+
+	server := &http.Server{Addr: address, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Error("Metrics endpoint stopped")
+		}
+	}()
+
+	logrus.WithField("address", address).Info("Serving Metrics")
+	return server
+}
+
+// get finds or creates the series, the lock must be held.
+func (r *Registry) get(name string, labels Labels) *series {
+	key := seriesKey(name, labels)
+	s, ok := r.series[key]
+	if !ok {
+		copied := Labels{}
+		for k, v := range labels {
+			copied[k] = v
+		}
+		s = &series{name: name, labels: copied}
+		r.series[key] = s
+	}
+	return s
+}
+
+// sorted is every series ordered by name then labels, the lock must be held.
+func (r *Registry) sorted() []*series {
+	keys := make([]string, 0, len(r.series))
+	for key := range r.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sorted := make([]*series, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, r.series[key])
+	}
+	return sorted
+}
+
+// kindOf is the kind of the metric where unknown metrics are counters.
+func kindOf(name string) string {
+	if def, ok := definitions[name]; ok {
+		return def.kind
+	}
+	return kindCounter
+}
+
+// labelNames are the names of the labels in order.
+func labelNames(labels Labels) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func seriesKey(name string, labels Labels) string {
+	return name + formatLabels(labels, "", "")
+}
+
+// formatLabels formats the labels with an optional extra label appended.
+func formatLabels(labels Labels, extraName string, extraValue string) string {
+	pairs := []string{}
+	for _, name := range labelNames(labels) {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(labels[name])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Since observes the seconds elapsed since the start.
+func Since(recorder Recorder, name string, start time.Time, labels Labels) {
+	recorder.Observe(name, time.Since(start).Seconds(), labels)
+}
+
+// QuoteCurrency is the currency the pair is quoted in e.g GBP for XBTGBP or XXBTZGBP.
+func QuoteCurrency(pair string) string {
+	pair = strings.ToUpper(pair)
+	for _, quote := range []string{"USDT", "USDC", "GBP", "EUR", "USD", "CAD", "JPY", "CHF", "AUD", "XBT", "ETH"} {
+		if strings.HasSuffix(pair, quote) && len(pair) > len(quote) {
+			return quote
+		}
+	}
+	return "unknown"
+}
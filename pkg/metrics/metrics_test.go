@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensures counters and histograms are written in the Prometheus text format
+func TestWritePrometheus(t *testing.T) {
+	registry := NewRegistry()
+	registry.Add(OrdersTotal, 1, Labels{"exchange": "kraken", "pair": "XBTGBP", "outcome": "placed"})
+	registry.Add(OrdersTotal, 2, Labels{"exchange": "kraken", "pair": "XBTGBP", "outcome": "placed"})
+	registry.Add(OrdersTotal, 1, Labels{"exchange": "kraken", "pair": "ADA\"GBP", "outcome": "failed"})
+	registry.Observe(ExchangeRequestSeconds, 0.2, Labels{"exchange": "kraken", "operation": "AddOrder"})
+	registry.Observe(ExchangeRequestSeconds, 3, Labels{"exchange": "kraken", "operation": "AddOrder"})
+
+	var out bytes.Buffer
+	assert.Nil(t, registry.WritePrometheus(&out))
+
+	assert.Equal(t, `# HELP dca_exchange_request_seconds Latency of exchange API requests.
+# TYPE dca_exchange_request_seconds histogram
+dca_exchange_request_seconds_bucket{exchange="kraken",operation="AddOrder",le="0.05"} 0
+dca_exchange_request_seconds_bucket{exchange="kraken",operation="AddOrder",le="0.1"} 0
+dca_exchange_request_seconds_bucket{exchange="kraken",operation="AddOrder",le="0.25"} 1
+dca_exchange_request_seconds_bucket{exchange="kraken",operation="AddOrder",le="0.5"} 1
+dca_exchange_request_seconds_bucket{exchange="kraken",operation="AddOrder",le="1"} 1
+dca_exchange_request_seconds_bucket{exchange="kraken",operation="AddOrder",le="2.5"} 1
+dca_exchange_request_seconds_bucket{exchange="kraken",operation="AddOrder",le="5"} 2
+dca_exchange_request_seconds_bucket{exchange="kraken",operation="AddOrder",le="10"} 2
+dca_exchange_request_seconds_bucket{exchange="kraken",operation="AddOrder",le="+Inf"} 2
+dca_exchange_request_seconds_sum{exchange="kraken",operation="AddOrder"} 3.2
+dca_exchange_request_seconds_count{exchange="kraken",operation="AddOrder"} 2
+# HELP dca_orders_total Orders by exchange, pair and outcome.
+# TYPE dca_orders_total counter
+dca_orders_total{exchange="kraken",outcome="failed",pair="ADA\"GBP"} 1
+dca_orders_total{exchange="kraken",outcome="placed",pair="XBTGBP"} 3
+`, out.String())
+}
+
+// Ensures the handler serves the registry
+func TestHandler(t *testing.T) {
+	registry := NewRegistry()
+	registry.Add(GlueJobsTotal, 1, Labels{"job": "process_transaction"})
+
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := ioutil.ReadAll(recorder.Body)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, string(body), `dca_glue_jobs_total{job="process_transaction"} 1`)
+}
+
+// Ensures EMF lines only contain what changed since the last write
+func TestWriteEMF(t *testing.T) {
+	registry := NewRegistry()
+	timestamp := time.Date(2022, 1, 7, 6, 0, 0, 0, time.UTC)
+
+	registry.Add(SpendTotal, 30, Labels{"quote": "GBP"})
+	registry.Observe(ExchangeRequestSeconds, 0.2, Labels{"exchange": "kraken", "operation": "AddOrder"})
+
+	var out bytes.Buffer
+	assert.Nil(t, registry.WriteEMF(&out, "DCAManager", timestamp))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+
+	spend := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &spend))
+	assert.Equal(t, float64(30), spend[SpendTotal])
+	assert.Equal(t, "GBP", spend["quote"])
+	assert.Equal(t, map[string]interface{}{
+		"Timestamp": float64(1641535200000),
+		"CloudWatchMetrics": []interface{}{map[string]interface{}{
+			"Namespace":  "DCAManager",
+			"Dimensions": []interface{}{[]interface{}{"quote"}},
+			"Metrics":    []interface{}{map[string]interface{}{"Name": SpendTotal, "Unit": "None"}},
+		}},
+	}, spend["_aws"])
+
+	latency := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &latency))
+	assert.Equal(t, []interface{}{0.2}, latency[ExchangeRequestSeconds])
+
+	// Only the change since the last write is published
+	registry.Add(SpendTotal, 20, Labels{"quote": "GBP"})
+	out.Reset()
+	assert.Nil(t, registry.WriteEMF(&out, "DCAManager", timestamp))
+
+	lines = strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 1, len(lines))
+	assert.Contains(t, lines[0], `"dca_spend_total":20`)
+	assert.Equal(t, float64(50), registry.Value(SpendTotal, Labels{"quote": "GBP"}))
+}
+
+// Ensures the registry can be recorded to from many goroutines
+func TestRegistryConcurrent(t *testing.T) {
+	registry := NewRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				registry.Add(OrdersTotal, 1, Labels{"exchange": "kraken"})
+				registry.Observe(ExchangeRequestSeconds, 0.1, Labels{"exchange": "kraken"})
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, float64(1000), registry.Value(OrdersTotal, Labels{"exchange": "kraken"}))
+	assert.Equal(t, uint64(1000), registry.Count(ExchangeRequestSeconds, Labels{"exchange": "kraken"}))
+}
+
+// Ensures the quote currency is found from the pair
+func TestQuoteCurrency(t *testing.T) {
+	assert.Equal(t, "GBP", QuoteCurrency("XBTGBP"))
+	assert.Equal(t, "GBP", QuoteCurrency("XXBTZGBP"))
+	assert.Equal(t, "USDT", QuoteCurrency("ethusdt"))
+	assert.Equal(t, "unknown", QuoteCurrency("GBP"))
+}
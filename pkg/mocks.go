@@ -5,6 +5,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/service/glue"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/stretchr/testify/mock"
@@ -27,6 +28,12 @@ func (s MockS3Access) PutObject(ctx context.Context, params *s3.PutObjectInput,
 	return args.Get(0).(*s3.PutObjectOutput), args.Error(1)
 }
 
+// ListObjectsV2 mocks listing objects in s3
+func (s MockS3Access) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	args := s.Called(ctx, params, optFns)
+	return args.Get(0).(*s3.ListObjectsV2Output), args.Error(1)
+}
+
 // MockSSMClient mocks SSM operations
 type MockSSMClient struct {
 	mock.Mock
@@ -55,6 +62,18 @@ func (s MockSQSAccess) DeleteMessage(ctx context.Context, params *sqs.DeleteMess
 	return args.Get(0).(*sqs.DeleteMessageOutput), args.Error(1)
 }
 
+// ReceiveMessage mocks receiving messages from SQS.
+func (s MockSQSAccess) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	args := s.Called(ctx, params, optFns)
+	return args.Get(0).(*sqs.ReceiveMessageOutput), args.Error(1)
+}
+
+// ChangeMessageVisibility mocks changing the visibility of a message on SQS.
+func (s MockSQSAccess) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	args := s.Called(ctx, params, optFns)
+	return args.Get(0).(*sqs.ChangeMessageVisibilityOutput), args.Error(1)
+}
+
 // MockGlueAccess mocks aws glue operations
 type MockGlueAccess struct {
 	mock.Mock
@@ -65,3 +84,14 @@ func (g MockGlueAccess) StartJobRun(ctx context.Context, params *glue.StartJobRu
 	args := g.Called(ctx, params, optFns)
 	return args.Get(0).(*glue.StartJobRunOutput), args.Error(1)
 }
+
+// MockSNSAccess mocks SNS operations
+type MockSNSAccess struct {
+	mock.Mock
+}
+
+// Publish mocks publishing a message to SNS.
+func (s MockSNSAccess) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	args := s.Called(ctx, params, optFns)
+	return args.Get(0).(*sns.PublishOutput), args.Error(1)
+}
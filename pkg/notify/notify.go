@@ -0,0 +1,144 @@
+// Package notify announces the results of runs to chat and webhook sinks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/shopspring/decimal"
+)
+
+// Kinds of event which are announced.
+const (
+	KindRun   string = "run"
+	KindFills string = "fills"
+)
+
+// Outcomes of an order within an event.
+const (
+	StatusPlaced  string = "placed"
+	StatusSkipped string = "skipped"
+	StatusFilled  string = "filled"
+	StatusFailed  string = "failed"
+)
+
+// DefaultTemplate renders an event as a short plain text summary.
+const DefaultTemplate = `{{ .Title }}
+{{- range .Orders }}
+- {{ .Status }} {{ .Direction }} {{ .Volume }} {{ .Pair }} on {{ .Exchange }}
+{{- with .Price }} @ {{ . }}{{ end }}
+{{- with .Amount }} ({{ .StringFixed 2 }}){{ end }}
+{{- with .TransactionID }} [{{ . }}]{{ end }}
+{{- with .Reason }}: {{ . }}{{ end }}
+{{- end }}
+{{- range .Errors }}
+error: {{ . }}
+{{- end }}`
+
+// Notifier is an abstraction to announce an event.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Event is something which happened during a run.
+type Event struct {
+	Kind   string       `json:"kind"`
+	Title  string       `json:"title"`
+	Time   time.Time    `json:"time"`
+	Real   bool         `json:"real"`
+	Orders []OrderEvent `json:"orders"`
+	Errors []string     `json:"errors"`
+}
+
+// OrderEvent is the outcome of a single order. The price is the fill price
+// once filled and otherwise the price the order was sized or limited at.
+type OrderEvent struct {
+	Exchange      string           `json:"exchange"`
+	Pair          string           `json:"pair"`
+	Direction     string           `json:"direction"`
+	OrderType     string           `json:"order_type"`
+	Volume        string           `json:"volume"`
+	Price         *decimal.Decimal `json:"price,omitempty"`
+	Amount        *decimal.Decimal `json:"amount,omitempty"`
+	Fee           *decimal.Decimal `json:"fee,omitempty"`
+	TransactionID string           `json:"transaction_id,omitempty"`
+	Status        string           `json:"status"`
+	Reason        string           `json:"reason,omitempty"`
+}
+
+// Count is how many orders in the event have the status.
+func (e Event) Count(status string) int {
+	count := 0
+	for _, order := range e.Orders {
+		if order.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Render renders the event with the template or the default template when empty.
+func Render(text string, event Event) (string, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+
+	tmpl, err := template.New("event").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid notification template: %w", err)
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, event); err != nil {
+		return "", err
+	}
+
+	return rendered.String(), nil
+}
+
+// Multi announces events to every notifier.
+type Multi []Notifier
+
+// Notify announces the event to every notifier even when one fails.
+func (m Multi) Notify(ctx context.Context, event Event) error {
+	failures := []string{}
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, event); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d notifications failed: %s", len(failures), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// FromEnvironment creates a notifier for every sink configured in the environment,
+// with no sinks configured events are silently dropped.
+func FromEnvironment(snsAccess pkg.SNSAccess) Multi {
+	messageTemplate := os.Getenv(configuration.EnvNotifyTemplate)
+	notifiers := Multi{}
+
+	if url := os.Getenv(configuration.EnvNotifySlackWebhook); url != "" {
+		notifiers = append(notifiers, Slack{URL: url, Template: messageTemplate})
+	}
+	if url := os.Getenv(configuration.EnvNotifyDiscordWebhook); url != "" {
+		notifiers = append(notifiers, Discord{URL: url, Template: messageTemplate})
+	}
+	if url := os.Getenv(configuration.EnvNotifyWebhook); url != "" {
+		notifiers = append(notifiers, Webhook{URL: url, Template: messageTemplate})
+	}
+	if topic := os.Getenv(configuration.EnvNotifySNSTopic); topic != "" {
+		notifiers = append(notifiers, SNS{Client: snsAccess, TopicARN: topic, Template: messageTemplate})
+	}
+
+	return notifiers
+}
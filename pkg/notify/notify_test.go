@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func runEvent() Event {
+	price := decimal.NewFromInt(30000)
+	amount := decimal.NewFromInt(300)
+
+	return Event{
+		Kind:  KindRun,
+		Title: "DCA run: 1 placed, 1 skipped, 1 errors",
+		Time:  time.Date(2022, 1, 7, 6, 0, 0, 0, time.UTC),
+		Real:  true,
+		Orders: []OrderEvent{
+			{Exchange: "kraken", Pair: "XBTGBP", Direction: "buy", Volume: "0.01", Price: &price, Amount: &amount, TransactionID: "TXID", Status: StatusPlaced},
+			{Exchange: "kraken", Pair: "ADAGBP", Direction: "buy", Volume: "10", Status: StatusSkipped, Reason: "value averaging dry run"},
+		},
+		Errors: []string{"error uploading object"},
+	}
+}
+
+// receiver records the body of every request and responds with the status
+func receiver(t *testing.T, status int, bodies *[]map[string]interface{}) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		content, err := ioutil.ReadAll(r.Body)
+		assert.Nil(t, err)
+
+		body := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(content, &body))
+		*bodies = append(*bodies, body)
+
+		w.WriteHeader(status)
+		w.Write([]byte("response"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Ensures the default template summarises every order and error
+func TestRender(t *testing.T) {
+	text, err := Render("", runEvent())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "DCA run: 1 placed, 1 skipped, 1 errors\n"+
+		"- placed buy 0.01 XBTGBP on kraken @ 30000 (300.00) [TXID]\n"+
+		"- skipped buy 10 ADAGBP on kraken: value averaging dry run\n"+
+		"error: error uploading object", text)
+}
+
+// Ensures custom templates are used and invalid templates are reported
+func TestRenderCustomTemplate(t *testing.T) {
+	text, err := Render(`{{ .Kind }}: {{ .Count "placed" }} placed`, runEvent())
+	assert.Nil(t, err)
+	assert.Equal(t, "run: 1 placed", text)
+
+	_, err = Render("{{ .Missing", runEvent())
+	assert.Contains(t, err.Error(), "invalid notification template")
+}
+
+// Ensures slack receives the rendered text
+func TestSlack(t *testing.T) {
+	bodies := []map[string]interface{}{}
+	server := receiver(t, http.StatusOK, &bodies)
+
+	err := Slack{URL: server.URL, Template: "{{ .Title }}"}.Notify(context.Background(), runEvent())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{{"text": "DCA run: 1 placed, 1 skipped, 1 errors"}}, bodies)
+}
+
+// Ensures discord receives the rendered content
+func TestDiscord(t *testing.T) {
+	bodies := []map[string]interface{}{}
+	server := receiver(t, http.StatusNoContent, &bodies)
+
+	err := Discord{URL: server.URL, Template: "{{ .Title }}"}.Notify(context.Background(), runEvent())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{{"content": "DCA run: 1 placed, 1 skipped, 1 errors"}}, bodies)
+}
+
+// Ensures webhooks receive the whole event alongside the text
+func TestWebhook(t *testing.T) {
+	bodies := []map[string]interface{}{}
+	server := receiver(t, http.StatusOK, &bodies)
+
+	err := Webhook{URL: server.URL}.Notify(context.Background(), runEvent())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(bodies))
+	assert.Equal(t, KindRun, bodies[0]["kind"])
+	assert.Contains(t, bodies[0]["text"], "[TXID]")
+
+	placed := bodies[0]["orders"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "30000", placed["price"])
+	assert.Equal(t, StatusPlaced, placed["status"])
+}
+
+// Ensures unsuccessful responses are returned as errors
+func TestWebhookError(t *testing.T) {
+	bodies := []map[string]interface{}{}
+	server := receiver(t, http.StatusBadRequest, &bodies)
+
+	err := Webhook{URL: server.URL}.Notify(context.Background(), runEvent())
+
+	assert.Equal(t, "webhook returned 400 Bad Request: response", err.Error())
+}
+
+// Ensures SNS publishes with the title as the subject
+func TestSNS(t *testing.T) {
+	client := pkg.MockSNSAccess{}
+	client.On("Publish", mock.Anything, mock.MatchedBy(func(input *sns.PublishInput) bool {
+		return *input.TopicArn == "arn:topic" &&
+			*input.Subject == "DCA run: 1 placed, 1 skipped, 1 errors" &&
+			*input.Message == "run"
+	}), mock.Anything).Return(&sns.PublishOutput{}, nil)
+
+	err := SNS{Client: client, TopicARN: "arn:topic", Template: "{{ .Kind }}"}.Notify(context.Background(), runEvent())
+
+	assert.Nil(t, err)
+	client.AssertExpectations(t)
+}
+
+// Ensures every notifier is tried even when one fails
+func TestMulti(t *testing.T) {
+	bodies := []map[string]interface{}{}
+	server := receiver(t, http.StatusOK, &bodies)
+
+	client := pkg.MockSNSAccess{}
+	client.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(&sns.PublishOutput{}, errors.New("sns unavailable"))
+
+	err := Multi{SNS{Client: client}, Slack{URL: server.URL}}.Notify(context.Background(), runEvent())
+
+	assert.Equal(t, "1 notifications failed: sns unavailable", err.Error())
+	assert.Equal(t, 1, len(bodies))
+}
+
+// Ensures sinks are created for the configured environment
+func TestFromEnvironment(t *testing.T) {
+	t.Setenv("DCA_NOTIFY_SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/T/B/X")
+	t.Setenv("DCA_NOTIFY_SNS_TOPIC_ARN", "arn:topic")
+	t.Setenv("DCA_NOTIFY_TEMPLATE", "{{ .Title }}")
+
+	notifiers := FromEnvironment(pkg.MockSNSAccess{})
+
+	assert.Equal(t, 2, len(notifiers))
+	assert.Equal(t, Slack{URL: "https://hooks.slack.com/services/T/B/X", Template: "{{ .Title }}"}, notifiers[0])
+	assert.Equal(t, "arn:topic", notifiers[1].(SNS).TopicARN)
+}
@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/kiran94/dca-manager/pkg"
+)
+
+// snsMaxSubject is the longest subject SNS accepts.
+const snsMaxSubject = 100
+
+var defaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// Slack announces events to a Slack incoming webhook.
+type Slack struct {
+	URL      string
+	Template string
+	Client   *http.Client
+}
+
+// Notify posts the rendered event as the message text.
+func (s Slack) Notify(ctx context.Context, event Event) error {
+	text, err := Render(s.Template, event)
+	if err != nil {
+		return err
+	}
+
+	return postJSON(ctx, s.Client, s.URL, map[string]string{"text": text})
+}
+
+// Discord announces events to a Discord webhook.
+type Discord struct {
+	URL      string
+	Template string
+	Client   *http.Client
+}
+
+// Notify posts the rendered event as the message content.
+func (d Discord) Notify(ctx context.Context, event Event) error {
+	text, err := Render(d.Template, event)
+	if err != nil {
+		return err
+	}
+
+	return postJSON(ctx, d.Client, d.URL, map[string]string{"content": text})
+}
+
+// Webhook announces events to any endpoint accepting JSON.
+type Webhook struct {
+	URL      string
+	Template string
+	Client   *http.Client
+}
+
+// webhookPayload is the event alongside its rendered text.
+type webhookPayload struct {
+	Event
+	Text string `json:"text"`
+}
+
+// Notify posts the whole event with the rendered text.
+func (w Webhook) Notify(ctx context.Context, event Event) error {
+	text, err := Render(w.Template, event)
+	if err != nil {
+		return err
+	}
+
+	return postJSON(ctx, w.Client, w.URL, webhookPayload{Event: event, Text: text})
+}
+
+// SNS announces events to a SNS topic.
+type SNS struct {
+	Client   pkg.SNSAccess
+	TopicARN string
+	Template string
+}
+
+// Notify publishes the rendered event with the title as the subject.
+func (s SNS) Notify(ctx context.Context, event Event) error {
+	text, err := Render(s.Template, event)
+	if err != nil {
+		return err
+	}
+
+	subject := event.Title
+	if len(subject) > snsMaxSubject {
+		subject = subject[:snsMaxSubject]
+	}
+
+	_, err = s.Client.Publish(ctx, &sns.PublishInput{
+		TopicArn: &s.TopicARN,
+		Subject:  &subject,
+		Message:  &text,
+	})
+	return err
+}
+
+// postJSON posts the payload and fails on any non 2xx response.
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	if client == nil {
+		client = defaultClient
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		detail, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", response.Status, bytes.TrimSpace(detail))
+	}
+
+	return nil
+}
@@ -1,21 +1,56 @@
 package orders
 
 import (
+	"context"
 	config "github.com/kiran94/dca-manager/pkg/configuration"
 	"github.com/shopspring/decimal"
 )
 
 // Orderer Responsible for making DCA orders to an Exchange.
 type Orderer interface {
-	MakeOrder(order *config.DCAOrder) (*OrderFufilled, error)
-	ProcessTransaction(transactionsIds ...string) (*[]OrderComplete, error)
+	MakeOrder(ctx context.Context, order *config.DCAOrder) (*OrderFufilled, error)
+	ProcessTransaction(ctx context.Context, transactionsIds ...string) (*[]OrderComplete, error)
+	CancelOrder(ctx context.Context, transactionID string) error
 }
 
 // OrderFufilled which has been sent to the Exchange
 type OrderFufilled struct {
-	TransactionID string      `json:"transaction_id"`
-	Timestamp     int64       `json:"timestamp"`
-	Result        interface{} `json:"result"`
+	TransactionID string              `json:"transaction_id"`
+	Timestamp     int64               `json:"timestamp"`
+	Result        interface{}         `json:"result"`
+	Multiplier    *MultiplierDecision `json:"multiplier,omitempty"`
+	Routing       *RoutingDecision    `json:"routing,omitempty"`
+	Validated     bool                `json:"validated,omitempty"`
+}
+
+// RoutingDecision records which exchange an order was routed to
+// and the quotes from every exchange which was considered.
+type RoutingDecision struct {
+	Exchange     string       `json:"exchange"`
+	Alternatives []RouteQuote `json:"alternatives"`
+}
+
+// RouteQuote is the effective price of an order on a single exchange
+// where the effective price includes the fee charged by the exchange.
+type RouteQuote struct {
+	Exchange       string          `json:"exchange"`
+	Price          decimal.Decimal `json:"price"`
+	FeePct         decimal.Decimal `json:"fee_pct"`
+	EffectivePrice decimal.Decimal `json:"effective_price"`
+	Error          string          `json:"error,omitempty"`
+}
+
+// MultiplierDecision records how a rule scaled the volume
+// of an order so the rule can be analysed later.
+type MultiplierDecision struct {
+	Rule          string           `json:"rule"`
+	Price         decimal.Decimal  `json:"price"`
+	High          decimal.Decimal  `json:"high"`
+	DrawdownPct   decimal.Decimal  `json:"drawdown_pct"`
+	MovingAverage *decimal.Decimal `json:"moving_average,omitempty"`
+	Multiplier    decimal.Decimal  `json:"multiplier"`
+	BaseVolume    string           `json:"base_volume"`
+	Volume        string           `json:"volume"`
 }
 
 // PendingOrders which is processing on the exchange
@@ -27,15 +62,38 @@ type OrderFufilled struct {
 //
 // This object is used to push the transaction to an out-of-process
 // queue for later processing
+//
+// Orders which expire carry the time they expire and the order
+// they were placed from so they can be cancelled and replaced.
+//
+// Orders sliced over time carry the parent they were sliced from.
+// A scheduled slice has no transaction yet and instead carries the
+// parent order, the slice to place and when to place it.
+//
+// Every order carries the run which placed it so processing can be correlated.
 type PendingOrders struct {
-	TransactionID string `json:"transaction_id"`
-	S3Bucket      string `json:"s3_bucket"`
-	S3Key         string `json:"s3_key"`
+	RunID         string           `json:"run_id,omitempty"`
+	TransactionID string           `json:"transaction_id"`
+	S3Bucket      string           `json:"s3_bucket"`
+	S3Key         string           `json:"s3_key"`
+	ExpireAt      int64            `json:"expire_at,omitempty"`
+	Order         *config.DCAOrder `json:"order,omitempty"`
+	ParentID      string           `json:"parent_id,omitempty"`
+	Slice         int              `json:"slice,omitempty"`
+	Slices        int              `json:"slices,omitempty"`
+	ExecuteAt     int64            `json:"execute_at,omitempty"`
+}
+
+// IsScheduledSlice determines if the message is a slice still to be placed.
+func (p PendingOrders) IsScheduledSlice() bool {
+	return p.TransactionID == "" && p.Slices > 0
 }
 
 // OrderComplete from an Exchange
 // This object acts as a common abstraction
 // amongst all exchanges
+//
+// The source is empty for orders placed by the DCA manager.
 type OrderComplete struct {
 	TransactionID  string          `json:"transaction_id"`
 	ExchangeStatus string          `json:"exchange_status"`
@@ -47,4 +105,21 @@ type OrderComplete struct {
 	Volume         decimal.Decimal `json:"volume"`
 	OpenTime       float64         `json:"open_time"`
 	CloseTime      float64         `json:"close_time"`
+	ParentID       string          `json:"parent_id,omitempty"`
+	Source         string          `json:"source,omitempty"`
+}
+
+// SourceImport is the source of orders imported from
+// the history of an exchange rather than placed by a run.
+const SourceImport = "import"
+
+// IsOpen determines if the exchange status means the order can still fill.
+func (o OrderComplete) IsOpen() bool {
+	return o.ExchangeStatus == "pending" || o.ExchangeStatus == "open"
+}
+
+// IsCancelled determines if the exchange status means the order
+// was stopped before it could completely fill.
+func (o OrderComplete) IsCancelled() bool {
+	return o.ExchangeStatus == "canceled" || o.ExchangeStatus == "expired"
 }
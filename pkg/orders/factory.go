@@ -5,6 +5,7 @@ import (
 
 	"github.com/kiran94/dca-manager/pkg"
 	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/metrics"
 
 	krakenapi "github.com/beldur/kraken-go-api-client"
 )
@@ -14,8 +15,11 @@ type OrdererFactory interface {
 	GetOrderers(ctx context.Context, ssm pkg.SSMAccess) (*map[string]Orderer, error)
 }
 
-// OrdererFac is responsible for getting Exchange Orderers
-type OrdererFac struct{}
+// OrdererFac is responsible for getting Exchange Orderers,
+// requests to the exchanges are recorded when metrics are set.
+type OrdererFac struct {
+	Metrics metrics.Recorder
+}
 
 // GetOrderers gets a map of exchange to Orderer.
 func (o OrdererFac) GetOrderers(ctx context.Context, ssm pkg.SSMAccess) (*map[string]Orderer, error) {
@@ -28,8 +32,13 @@ func (o OrdererFac) GetOrderers(ctx context.Context, ssm pkg.SSMAccess) (*map[st
 		return nil, err
 	}
 
+	var krakenClient KrakenAccess = krakenapi.New(*key, *secret)
+	if o.Metrics != nil {
+		krakenClient = InstrumentedKraken{Client: krakenClient, Recorder: o.Metrics}
+	}
+
 	orderers["kraken"] = KrakenOrderer{
-		Client: krakenapi.New(*key, *secret),
+		Client: krakenClient,
 	}
 
 	return &orderers, nil
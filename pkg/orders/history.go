@@ -0,0 +1,89 @@
+package orders
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// ProcessedOrderSource is an abstraction to load orders
+// which have already been processed from an exchange.
+type ProcessedOrderSource interface {
+	GetProcessedOrders(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string) (*[]OrderComplete, error)
+}
+
+// ProcessedOrderLoader loads processed orders from S3.
+type ProcessedOrderLoader struct{}
+
+// GetProcessedOrders loads every processed order json file under the given S3 prefix.
+func (p ProcessedOrderLoader) GetProcessedOrders(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string) (*[]OrderComplete, error) {
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"s3bucket": s3Bucket,
+		"s3prefix": s3Prefix,
+	}).Info("Loading Processed Orders")
+
+	processed := []OrderComplete{}
+
+	var continuationToken *string
+	for {
+		listed, err := s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            &s3Bucket,
+			Prefix:            &s3Prefix,
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range listed.Contents {
+			if object.Key == nil || !strings.HasSuffix(*object.Key, ".json") {
+				continue
+			}
+
+			order, err := getProcessedOrder(ctx, s3Client, s3Bucket, *object.Key)
+			if err != nil {
+				return nil, err
+			}
+
+			processed = append(processed, *order)
+		}
+
+		if !listed.IsTruncated {
+			break
+		}
+		continuationToken = listed.NextContinuationToken
+	}
+
+	logging.FromContext(ctx).WithField("count", len(processed)).Info("Loaded Processed Orders")
+	return &processed, nil
+}
+
+// getProcessedOrder loads a single processed order from S3.
+func getProcessedOrder(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Key string) (*OrderComplete, error) {
+	object, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s3Bucket,
+		Key:    &s3Key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Body.Close()
+
+	objectBytes, err := ioutil.ReadAll(object.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var order OrderComplete
+	if err := json.Unmarshal(objectBytes, &order); err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
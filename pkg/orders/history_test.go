@@ -0,0 +1,72 @@
+package orders
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Ensures when listing fails
+// the error is returned
+func TestGetProcessedOrdersErrorListing(t *testing.T) {
+	mockS3 := pkg.MockS3Access{}
+	var listed *s3.ListObjectsV2Output
+	expectedErr := errors.New("error listing")
+	mockS3.On("ListObjectsV2", mock.Anything, mock.Anything, mock.Anything).Return(listed, expectedErr)
+
+	processed, err := ProcessedOrderLoader{}.GetProcessedOrders(context.Background(), mockS3, "bucket", "prefix")
+
+	assert.Nil(t, processed)
+	assert.Equal(t, expectedErr, err)
+}
+
+// Ensures every page of processed orders
+// is loaded and non json objects are skipped
+func TestGetProcessedOrders(t *testing.T) {
+	mockS3 := pkg.MockS3Access{}
+
+	mockS3.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(i *s3.ListObjectsV2Input) bool {
+		return i.ContinuationToken == nil && *i.Prefix == "prefix"
+	}), mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{
+			{Key: aws.String("prefix/exchange=kraken/TX1.json")},
+			{Key: aws.String("prefix/exchange=kraken/_SUCCESS")},
+		},
+		IsTruncated:           true,
+		NextContinuationToken: aws.String("page2"),
+	}, nil).Once()
+
+	mockS3.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(i *s3.ListObjectsV2Input) bool {
+		return i.ContinuationToken != nil && *i.ContinuationToken == "page2"
+	}), mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{
+			{Key: aws.String("prefix/exchange=kraken/TX2.json")},
+		},
+	}, nil).Once()
+
+	for _, txid := range []string{"TX1", "TX2"} {
+		key := "prefix/exchange=kraken/" + txid + ".json"
+		body := io.NopCloser(bytes.NewReader([]byte(`{"transaction_id": "` + txid + `", "pair": "ADAGBP", "type": "buy", "volume": "10"}`)))
+		mockS3.On("GetObject", mock.Anything, mock.MatchedBy(func(i *s3.GetObjectInput) bool {
+			return *i.Key == key
+		}), mock.Anything).Return(&s3.GetObjectOutput{Body: body}, nil).Once()
+	}
+
+	processed, err := ProcessedOrderLoader{}.GetProcessedOrders(context.Background(), mockS3, "bucket", "prefix")
+
+	assert.Nil(t, err)
+	mockS3.AssertExpectations(t)
+	assert.Equal(t, 2, len(*processed))
+	assert.Equal(t, "TX1", (*processed)[0].TransactionID)
+	assert.Equal(t, "TX2", (*processed)[1].TransactionID)
+	assert.Equal(t, "10", (*processed)[1].Volume.String())
+}
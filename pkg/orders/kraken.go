@@ -1,12 +1,17 @@
 package orders
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	krakenapi "github.com/beldur/kraken-go-api-client"
 	config "github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/logging"
 	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 )
@@ -15,6 +20,10 @@ import (
 type KrakenAccess interface {
 	AddOrder(pair string, direction string, orderType string, volume string, args map[string]string) (*krakenapi.AddOrderResponse, error)
 	QueryOrders(txids string, args map[string]string) (*krakenapi.QueryOrdersResponse, error)
+	ClosedOrders(args map[string]string) (*krakenapi.ClosedOrdersResponse, error)
+	TradesHistory(start int64, end int64, args map[string]string) (*krakenapi.TradesHistoryResponse, error)
+	Query(method string, data map[string]string) (interface{}, error)
+	CancelOrder(txid string) (*krakenapi.CancelOrderResponse, error)
 }
 
 // KrakenOrderer providess access to the Kraken Exchange
@@ -28,9 +37,9 @@ func (ko *KrakenOrderer) New(client KrakenAccess) {
 }
 
 // MakeOrder executes the provided DCAOrder on the Kraken Exchange.
-func (ko KrakenOrderer) MakeOrder(order *config.DCAOrder) (*OrderFufilled, error) {
+func (ko KrakenOrderer) MakeOrder(ctx context.Context, order *config.DCAOrder) (*OrderFufilled, error) {
 
-	logrus.WithFields(logrus.Fields{
+	logging.FromContext(ctx).WithFields(logrus.Fields{
 		"direction": order.Direction,
 		"volume":    order.Volume,
 		"pair":      order.Pair,
@@ -40,19 +49,52 @@ func (ko KrakenOrderer) MakeOrder(order *config.DCAOrder) (*OrderFufilled, error
 	}).Info("Making Order")
 
 	if !order.Enabled {
-		logrus.Warn("order disabled, skipping")
+		logging.FromContext(ctx).Warn("order disabled, skipping")
 		return nil, nil
 	}
 
-	addOrderResponse, err := ko.Client.AddOrder(order.Pair, order.Direction, order.OrderType, order.Volume, make(map[string]string, 0))
+	args := make(map[string]string, 0)
+	if order.Validate {
+		args["validate"] = "true"
+	}
+
+	if order.LimitPrice != nil {
+		price, err := ko.roundPrice(order.Pair, *order.LimitPrice)
+		if err != nil {
+			return nil, err
+		}
+
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"pair":  order.Pair,
+			"price": price,
+		}).Info("Setting Limit Price")
+		args["price"] = price
+	}
+
+	if order.ExpireAfter != "" {
+		expireAfter, err := time.ParseDuration(order.ExpireAfter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expire_after %s: %w", order.ExpireAfter, err)
+		}
+
+		args["expiretm"] = fmt.Sprintf("+%d", int64(expireAfter.Seconds()))
+	}
+
+	addOrderResponse, err := ko.Client.AddOrder(order.Pair, order.Direction, order.OrderType, order.Volume, args)
 	if err != nil {
 		return nil, err
 	}
 
-	logrus.WithField("transactionId", addOrderResponse.TransactionIds).Info("Order Response")
+	// Validated orders are checked by the exchange but never placed
+	if order.Validate {
+		logging.FromContext(ctx).WithField("description", addOrderResponse.Description.Order).Info("Order Validated")
+		return &OrderFufilled{Result: addOrderResponse, Timestamp: time.Now().Unix(), Validated: true}, nil
+	}
+
+	logging.FromContext(ctx).WithField("transactionId", addOrderResponse.TransactionIds).Info("Order Response")
 
 	if len(addOrderResponse.TransactionIds) > 1 {
-		logrus.Warnf("Received more then one TransactionIds %s", addOrderResponse.TransactionIds)
+		logging.FromContext(ctx).Warnf("Received more then one TransactionIds %s", addOrderResponse.TransactionIds)
 	} else if len(addOrderResponse.TransactionIds) == 0 {
 		return nil, errors.New("no transactions ids received")
 	}
@@ -67,7 +109,7 @@ func (ko KrakenOrderer) MakeOrder(order *config.DCAOrder) (*OrderFufilled, error
 // ProcessTransaction takes the given transactionIds
 // and loads details for them from the Kraken Exchange
 // and standardise the order into a OrderComplete object
-func (ko KrakenOrderer) ProcessTransaction(transactionID ...string) (*[]OrderComplete, error) {
+func (ko KrakenOrderer) ProcessTransaction(ctx context.Context, transactionID ...string) (*[]OrderComplete, error) {
 	if len(transactionID) == 0 {
 		return nil, errors.New("no transactions provided")
 	}
@@ -75,7 +117,7 @@ func (ko KrakenOrderer) ProcessTransaction(transactionID ...string) (*[]OrderCom
 	txids := strings.Join(transactionID, ",")
 	args := make(map[string]string, 1)
 
-	logrus.WithField("transactionId", txids).Info("Getting Details for Transactions")
+	logging.FromContext(ctx).WithField("transactionId", txids).Info("Getting Details for Transactions")
 	transactions, err := ko.Client.QueryOrders(txids, args)
 	if err != nil {
 		return nil, err
@@ -83,26 +125,14 @@ func (ko KrakenOrderer) ProcessTransaction(transactionID ...string) (*[]OrderCom
 
 	completeOrders := make([]OrderComplete, len(*transactions))
 
-	logrus.Info("Mapping back response to transactions")
+	logging.FromContext(ctx).Info("Mapping back response to transactions")
 	index := 0
 	for transactionID := range *transactions {
-		logrus.WithField("transactionId", transactionID).Debug("Mapping Transaction")
-
-		co := (*transactions)[transactionID]
-		orderComplete := OrderComplete{
-			TransactionID:  transactionID,
-			ExchangeStatus: co.Status,
-			Pair:           co.Description.AssetPair,
-			OrderType:      co.Description.OrderType,
-			Type:           co.Description.Type,
-			Price:          decimal.NewFromFloat(co.Price),
-			Fee:            decimal.NewFromFloat(co.Fee),
-			Volume:         decimal.NewFromFloat(co.VolumeExecuted),
-			OpenTime:       co.OpenTime,
-			CloseTime:      co.CloseTime,
-		}
-
-		logrus.WithFields(logrus.Fields{
+		logging.FromContext(ctx).WithField("transactionId", transactionID).Debug("Mapping Transaction")
+
+		orderComplete := krakenOrderComplete(transactionID, (*transactions)[transactionID])
+
+		logging.FromContext(ctx).WithFields(logrus.Fields{
 			"transactionId": transactionID,
 			"orderComplete": orderComplete,
 		}).Debug("Complete Order")
@@ -113,3 +143,524 @@ func (ko KrakenOrderer) ProcessTransaction(transactionID ...string) (*[]OrderCom
 
 	return &completeOrders, nil
 }
+
+// GetClosedOrders pages through the orders closed on the Kraken
+// Exchange between the start and end, newest first.
+func (ko KrakenOrderer) GetClosedOrders(ctx context.Context, start time.Time, end time.Time) ([]OrderComplete, error) {
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"start": start,
+		"end":   end,
+	}).Info("Getting Closed Orders")
+
+	closed := []OrderComplete{}
+	for {
+		response, err := ko.Client.ClosedOrders(map[string]string{
+			"start":     strconv.FormatInt(start.Unix(), 10),
+			"end":       strconv.FormatInt(end.Unix(), 10),
+			"closetime": "close",
+			"ofs":       strconv.Itoa(len(closed)),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Kraken returns a page of orders at a time in no particular order
+		page := make([]OrderComplete, 0, len(response.Closed))
+		for transactionID, order := range response.Closed {
+			page = append(page, krakenOrderComplete(transactionID, order))
+		}
+		sort.Slice(page, func(i, j int) bool { return page[i].CloseTime > page[j].CloseTime })
+		closed = append(closed, page...)
+
+		if len(response.Closed) == 0 || len(closed) >= response.Count {
+			break
+		}
+	}
+
+	logging.FromContext(ctx).WithField("count", len(closed)).Info("Got Closed Orders")
+	return closed, nil
+}
+
+// GetTrades pages through the trades made on the Kraken
+// Exchange between the start and end, oldest first.
+//
+// Trades name their pair e.g XXBTZGBP rather than by the altname e.g XBTGBP
+// the orders use, so the pairs are renamed to their altname.
+func (ko KrakenOrderer) GetTrades(ctx context.Context, start time.Time, end time.Time) ([]Trade, error) {
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"start": start,
+		"end":   end,
+	}).Info("Getting Trades")
+
+	trades := []Trade{}
+	for {
+		response, err := ko.Client.TradesHistory(start.Unix(), end.Unix(), map[string]string{
+			"ofs": strconv.Itoa(len(trades)),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for tradeID, trade := range response.Trades {
+			trades = append(trades, Trade{
+				TradeID:       tradeID,
+				TransactionID: trade.TransactionID,
+				Pair:          trade.AssetPair,
+				Type:          trade.Type,
+				OrderType:     trade.OrderType,
+				Price:         decimal.NewFromFloat(trade.Price),
+				Cost:          decimal.NewFromFloat(trade.Cost),
+				Fee:           decimal.NewFromFloat(trade.Fee),
+				Volume:        decimal.NewFromFloat(trade.Volume),
+				Time:          trade.Time,
+			})
+		}
+
+		if len(response.Trades) == 0 || len(trades) >= response.Count {
+			break
+		}
+	}
+
+	altnames, err := ko.altnames(trades)
+	if err != nil {
+		return nil, err
+	}
+	for index := range trades {
+		if altname, ok := altnames[trades[index].Pair]; ok {
+			trades[index].Pair = altname
+		}
+	}
+
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].Time < trades[j].Time })
+
+	logging.FromContext(ctx).WithField("count", len(trades)).Info("Got Trades")
+	return trades, nil
+}
+
+// altnames gets the altname of each pair traded keyed by the pair name.
+func (ko KrakenOrderer) altnames(trades []Trade) (map[string]string, error) {
+	altnames := map[string]string{}
+
+	pairs := []string{}
+	for _, trade := range trades {
+		if _, ok := altnames[trade.Pair]; trade.Pair != "" && !ok {
+			altnames[trade.Pair] = trade.Pair
+			pairs = append(pairs, trade.Pair)
+		}
+	}
+	if len(pairs) == 0 {
+		return altnames, nil
+	}
+	sort.Strings(pairs)
+
+	response, err := ko.Client.Query("AssetPairs", map[string]string{"pair": strings.Join(pairs, ",")})
+	if err != nil {
+		return nil, err
+	}
+
+	assetPairs, ok := response.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected asset pairs response for %s: %v", strings.Join(pairs, ","), response)
+	}
+
+	for name, rawAssetPair := range assetPairs {
+		assetPair, ok := rawAssetPair.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if altname, ok := assetPair["altname"].(string); ok && altname != "" {
+			altnames[name] = altname
+		}
+	}
+	return altnames, nil
+}
+
+// krakenOrderComplete standardises an order on Kraken into a OrderComplete.
+func krakenOrderComplete(transactionID string, order krakenapi.Order) OrderComplete {
+	return OrderComplete{
+		TransactionID:  transactionID,
+		ExchangeStatus: order.Status,
+		Pair:           order.Description.AssetPair,
+		OrderType:      order.Description.OrderType,
+		Type:           order.Description.Type,
+		Price:          decimal.NewFromFloat(order.Price),
+		Fee:            decimal.NewFromFloat(order.Fee),
+		Volume:         decimal.NewFromFloat(order.VolumeExecuted),
+		OpenTime:       order.OpenTime,
+		CloseTime:      order.CloseTime,
+	}
+}
+
+// CancelOrder cancels an open order on the Kraken Exchange.
+func (ko KrakenOrderer) CancelOrder(ctx context.Context, transactionID string) error {
+	logging.FromContext(ctx).WithField("transactionId", transactionID).Info("Cancelling Order")
+
+	cancelResponse, err := ko.Client.CancelOrder(transactionID)
+	if err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"transactionId": transactionID,
+		"count":         cancelResponse.Count,
+		"pending":       cancelResponse.Pending,
+	}).Info("Cancelled Order")
+
+	return nil
+}
+
+// roundPrice truncates the price to the number of
+// decimal places Kraken accepts for the pair.
+func (ko KrakenOrderer) roundPrice(pair string, price decimal.Decimal) (string, error) {
+	response, err := ko.Client.Query("AssetPairs", map[string]string{"pair": pair})
+	if err != nil {
+		return "", err
+	}
+
+	assetPairs, ok := response.(map[string]interface{})
+	if !ok || len(assetPairs) != 1 {
+		return "", fmt.Errorf("unexpected asset pairs response for %s: %v", pair, response)
+	}
+
+	for _, rawAssetPair := range assetPairs {
+		assetPair, ok := rawAssetPair.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		decimals, ok := assetPair["pair_decimals"].(float64)
+		if !ok {
+			break
+		}
+
+		return price.Truncate(int32(decimals)).String(), nil
+	}
+
+	return "", fmt.Errorf("no price decimals found for pair %s", pair)
+}
+
+// GetBalances gets the balance of every asset held on the Kraken Exchange
+// keyed by the Kraken asset name e.g XXBT, XETH, ADA
+func (ko KrakenOrderer) GetBalances(ctx context.Context) (map[string]decimal.Decimal, error) {
+	logging.FromContext(ctx).Info("Getting Balances")
+	response, err := ko.Client.Query("Balance", map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	rawBalances, ok := response.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected balance response %v", response)
+	}
+
+	balances := make(map[string]decimal.Decimal, len(rawBalances))
+	for asset, rawBalance := range rawBalances {
+		balance, err := krakenDecimal(rawBalance)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse balance for %s: %w", asset, err)
+		}
+
+		balances[asset] = balance
+	}
+
+	return balances, nil
+}
+
+// GetTicker gets the latest ask, bid and last trade price for the given pair.
+func (ko KrakenOrderer) GetTicker(ctx context.Context, pair string) (*Ticker, error) {
+	logging.FromContext(ctx).WithField("pair", pair).Info("Getting Ticker")
+	response, err := ko.Client.Query("Ticker", map[string]string{"pair": pair})
+	if err != nil {
+		return nil, err
+	}
+
+	tickers, ok := response.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected ticker response %v", response)
+	}
+
+	// Kraken may respond with its own name for the pair
+	// e.g XXBTZGBP for XBTGBP so fall back to the only result.
+	rawTicker, ok := tickers[pair]
+	if !ok && len(tickers) == 1 {
+		for _, t := range tickers {
+			rawTicker = t
+		}
+	} else if !ok {
+		return nil, fmt.Errorf("no ticker found for pair %s", pair)
+	}
+
+	ticker, ok := rawTicker.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected ticker for pair %s: %v", pair, rawTicker)
+	}
+
+	ask, err := krakenTickerPrice(ticker, "a")
+	if err != nil {
+		return nil, err
+	}
+
+	bid, err := krakenTickerPrice(ticker, "b")
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := krakenTickerPrice(ticker, "c")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Ticker{Pair: pair, Ask: ask, Bid: bid, Last: last}, nil
+}
+
+// GetFeePct gets the taker fee percentage charged to the account for the given pair.
+func (ko KrakenOrderer) GetFeePct(ctx context.Context, pair string) (decimal.Decimal, error) {
+	logging.FromContext(ctx).WithField("pair", pair).Info("Getting Fee")
+	response, err := ko.Client.Query("TradeVolume", map[string]string{"pair": pair, "fee-info": "true"})
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	volume, ok := response.(map[string]interface{})
+	if !ok {
+		return decimal.Zero, fmt.Errorf("unexpected trade volume response %v", response)
+	}
+
+	fees, ok := volume["fees"].(map[string]interface{})
+	if !ok {
+		return decimal.Zero, fmt.Errorf("no fees found for pair %s", pair)
+	}
+
+	// Kraken may respond with its own name for the pair
+	rawFee, ok := fees[pair]
+	if !ok && len(fees) == 1 {
+		for _, f := range fees {
+			rawFee = f
+		}
+	} else if !ok {
+		return decimal.Zero, fmt.Errorf("no fees found for pair %s", pair)
+	}
+
+	fee, ok := rawFee.(map[string]interface{})
+	if !ok {
+		return decimal.Zero, fmt.Errorf("unexpected fee for pair %s: %v", pair, rawFee)
+	}
+
+	return krakenDecimal(fee["fee"])
+}
+
+// WithdrawInfo gets the limit and fee of withdrawing to the given key.
+// Kraken rejects keys which have not been approved on the account.
+func (ko KrakenOrderer) WithdrawInfo(ctx context.Context, asset string, key string, amount decimal.Decimal) (*WithdrawalQuote, error) {
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"asset":  asset,
+		"key":    key,
+		"amount": amount,
+	}).Info("Getting Withdrawal Info")
+
+	response, err := ko.Client.Query("WithdrawInfo", map[string]string{"asset": asset, "key": key, "amount": amount.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	info, ok := response.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected withdraw info response %v", response)
+	}
+
+	quote := &WithdrawalQuote{}
+	quote.Method, _ = info["method"].(string)
+
+	for field, value := range map[string]*decimal.Decimal{"limit": &quote.Limit, "amount": &quote.Amount, "fee": &quote.Fee} {
+		parsed, err := krakenDecimal(info[field])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse withdraw info %s: %w", field, err)
+		}
+		*value = parsed
+	}
+
+	return quote, nil
+}
+
+// Withdraw withdraws the amount of the asset to the given key
+// and returns the reference of the withdrawal.
+func (ko KrakenOrderer) Withdraw(ctx context.Context, asset string, key string, amount decimal.Decimal) (string, error) {
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"asset":  asset,
+		"key":    key,
+		"amount": amount,
+	}).Info("Withdrawing")
+
+	response, err := ko.Client.Query("Withdraw", map[string]string{"asset": asset, "key": key, "amount": amount.String()})
+	if err != nil {
+		return "", err
+	}
+
+	withdrawal, ok := response.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("unexpected withdraw response %v", response)
+	}
+
+	refID, _ := withdrawal["refid"].(string)
+	if refID == "" {
+		return "", fmt.Errorf("no reference returned for withdrawal of %s", asset)
+	}
+
+	return refID, nil
+}
+
+// WithdrawalStatus gets the status of the recent withdrawals of the asset.
+func (ko KrakenOrderer) WithdrawalStatus(ctx context.Context, asset string) ([]Withdrawal, error) {
+	logging.FromContext(ctx).WithField("asset", asset).Info("Getting Withdrawal Status")
+	response, err := ko.Client.Query("WithdrawStatus", map[string]string{"asset": asset})
+	if err != nil {
+		return nil, err
+	}
+
+	rawWithdrawals, ok := response.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected withdraw status response %v", response)
+	}
+
+	withdrawals := make([]Withdrawal, 0, len(rawWithdrawals))
+	for _, rawWithdrawal := range rawWithdrawals {
+		status, ok := rawWithdrawal.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected withdrawal %v", rawWithdrawal)
+		}
+
+		withdrawal := Withdrawal{Exchange: "kraken", Asset: asset}
+		withdrawal.RefID, _ = status["refid"].(string)
+		withdrawal.Address, _ = status["info"].(string)
+		withdrawal.TransactionID, _ = status["txid"].(string)
+		withdrawal.Status, _ = status["status"].(string)
+
+		if withdrawal.Amount, err = krakenDecimal(status["amount"]); err != nil {
+			return nil, fmt.Errorf("could not parse amount of withdrawal %s: %w", withdrawal.RefID, err)
+		}
+
+		if withdrawal.Fee, err = krakenDecimal(status["fee"]); err != nil {
+			return nil, fmt.Errorf("could not parse fee of withdrawal %s: %w", withdrawal.RefID, err)
+		}
+
+		if timestamp, ok := status["time"].(float64); ok {
+			withdrawal.Time = int64(timestamp)
+		}
+
+		withdrawals = append(withdrawals, withdrawal)
+	}
+
+	return withdrawals, nil
+}
+
+// GetOHLC gets the daily candles for the given pair since the given time.
+func (ko KrakenOrderer) GetOHLC(ctx context.Context, pair string, since time.Time) ([]Candle, error) {
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"pair":  pair,
+		"since": since,
+	}).Info("Getting OHLC")
+
+	response, err := ko.Client.Query("OHLC", map[string]string{
+		"pair":     pair,
+		"interval": "1440",
+		"since":    strconv.FormatInt(since.Unix(), 10),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, ok := response.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected ohlc response %v", response)
+	}
+
+	// Alongside the candles Kraken returns a "last" cursor and may
+	// use its own name for the pair so take the first other key.
+	var rawCandles []interface{}
+	for key, value := range result {
+		if key == "last" {
+			continue
+		}
+
+		rawCandles, ok = value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected ohlc for pair %s: %v", pair, value)
+		}
+		break
+	}
+
+	candles := make([]Candle, 0, len(rawCandles))
+	for _, rawCandle := range rawCandles {
+		candle, err := krakenCandle(rawCandle)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse ohlc for pair %s: %w", pair, err)
+		}
+
+		candles = append(candles, *candle)
+	}
+
+	return candles, nil
+}
+
+// krakenCandle converts a Kraken OHLC entry
+// [time, open, high, low, close, vwap, volume, count] into a Candle
+func krakenCandle(rawCandle interface{}) (*Candle, error) {
+	values, ok := rawCandle.([]interface{})
+	if !ok || len(values) < 7 {
+		return nil, fmt.Errorf("unexpected candle %v", rawCandle)
+	}
+
+	timestamp, ok := values[0].(float64)
+	if !ok {
+		return nil, fmt.Errorf("unexpected candle time %v", values[0])
+	}
+
+	prices := make([]decimal.Decimal, 4)
+	for index := range prices {
+		price, err := krakenDecimal(values[index+1])
+		if err != nil {
+			return nil, err
+		}
+		prices[index] = price
+	}
+
+	volume, err := krakenDecimal(values[6])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Candle{
+		Time:   time.Unix(int64(timestamp), 0).UTC(),
+		Open:   prices[0],
+		High:   prices[1],
+		Low:    prices[2],
+		Close:  prices[3],
+		Volume: volume,
+	}, nil
+}
+
+// krakenTickerPrice gets the price from the first element
+// of a ticker field which Kraken returns as an array
+// e.g "a": ["price", "whole lot volume", "lot volume"]
+func krakenTickerPrice(ticker map[string]interface{}, field string) (decimal.Decimal, error) {
+	values, ok := ticker[field].([]interface{})
+	if !ok || len(values) == 0 {
+		return decimal.Zero, fmt.Errorf("ticker field %s missing", field)
+	}
+
+	return krakenDecimal(values[0])
+}
+
+// krakenDecimal converts a value from a generic Kraken response
+// which are typically strings into a decimal.
+func krakenDecimal(value interface{}) (decimal.Decimal, error) {
+	switch v := value.(type) {
+	case string:
+		return decimal.NewFromString(v)
+	case float64:
+		return decimal.NewFromFloat(v), nil
+	default:
+		return decimal.Zero, fmt.Errorf("unexpected value %v", value)
+	}
+}
@@ -0,0 +1,72 @@
+package orders
+
+import (
+	"time"
+
+	krakenapi "github.com/beldur/kraken-go-api-client"
+	"github.com/kiran94/dca-manager/pkg/metrics"
+)
+
+// InstrumentedKraken records the latency and errors of every request to Kraken.
+type InstrumentedKraken struct {
+	Client   KrakenAccess
+	Recorder metrics.Recorder
+}
+
+// AddOrder adds the order on Kraken.
+func (i InstrumentedKraken) AddOrder(pair string, direction string, orderType string, volume string, args map[string]string) (*krakenapi.AddOrderResponse, error) {
+	defer i.record("AddOrder", time.Now())
+	response, err := i.Client.AddOrder(pair, direction, orderType, volume, args)
+	i.recordError("AddOrder", err)
+	return response, err
+}
+
+// QueryOrders queries the orders on Kraken.
+func (i InstrumentedKraken) QueryOrders(txids string, args map[string]string) (*krakenapi.QueryOrdersResponse, error) {
+	defer i.record("QueryOrders", time.Now())
+	response, err := i.Client.QueryOrders(txids, args)
+	i.recordError("QueryOrders", err)
+	return response, err
+}
+
+// ClosedOrders lists the closed orders on Kraken.
+func (i InstrumentedKraken) ClosedOrders(args map[string]string) (*krakenapi.ClosedOrdersResponse, error) {
+	defer i.record("ClosedOrders", time.Now())
+	response, err := i.Client.ClosedOrders(args)
+	i.recordError("ClosedOrders", err)
+	return response, err
+}
+
+// TradesHistory lists the trades made on Kraken.
+func (i InstrumentedKraken) TradesHistory(start int64, end int64, args map[string]string) (*krakenapi.TradesHistoryResponse, error) {
+	defer i.record("TradesHistory", time.Now())
+	response, err := i.Client.TradesHistory(start, end, args)
+	i.recordError("TradesHistory", err)
+	return response, err
+}
+
+// Query calls the method on Kraken.
+func (i InstrumentedKraken) Query(method string, data map[string]string) (interface{}, error) {
+	defer i.record(method, time.Now())
+	response, err := i.Client.Query(method, data)
+	i.recordError(method, err)
+	return response, err
+}
+
+// CancelOrder cancels the order on Kraken.
+func (i InstrumentedKraken) CancelOrder(txid string) (*krakenapi.CancelOrderResponse, error) {
+	defer i.record("CancelOrder", time.Now())
+	response, err := i.Client.CancelOrder(txid)
+	i.recordError("CancelOrder", err)
+	return response, err
+}
+
+func (i InstrumentedKraken) record(operation string, start time.Time) {
+	metrics.Since(i.Recorder, metrics.ExchangeRequestSeconds, start, metrics.Labels{"exchange": "kraken", "operation": operation})
+}
+
+func (i InstrumentedKraken) recordError(operation string, err error) {
+	if err != nil {
+		i.Recorder.Add(metrics.ExchangeErrorsTotal, 1, metrics.Labels{"exchange": "kraken", "operation": operation})
+	}
+}
@@ -0,0 +1,34 @@
+package orders
+
+import (
+	"errors"
+	"testing"
+
+	krakenapi "github.com/beldur/kraken-go-api-client"
+	"github.com/kiran94/dca-manager/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensures the latency of every request and the failed requests are recorded
+func TestInstrumentedKraken(t *testing.T) {
+	client := &MockKrakenAccess{}
+	client.On("AddOrder", "XBTGBP", "buy", "market", "0.01", map[string]string{}).Return(&krakenapi.AddOrderResponse{}, nil)
+	client.On("Query", "Ticker", map[string]string{"pair": "XBTGBP"}).Return(nil, errors.New("EAPI:Rate limit exceeded"))
+
+	registry := metrics.NewRegistry()
+	instrumented := InstrumentedKraken{Client: client, Recorder: registry}
+
+	_, err := instrumented.AddOrder("XBTGBP", "buy", "market", "0.01", map[string]string{})
+	assert.Nil(t, err)
+
+	_, err = instrumented.Query("Ticker", map[string]string{"pair": "XBTGBP"})
+	assert.Equal(t, "EAPI:Rate limit exceeded", err.Error())
+
+	addOrder := metrics.Labels{"exchange": "kraken", "operation": "AddOrder"}
+	ticker := metrics.Labels{"exchange": "kraken", "operation": "Ticker"}
+
+	assert.Equal(t, uint64(1), registry.Count(metrics.ExchangeRequestSeconds, addOrder))
+	assert.Equal(t, uint64(1), registry.Count(metrics.ExchangeRequestSeconds, ticker))
+	assert.Equal(t, float64(0), registry.Value(metrics.ExchangeErrorsTotal, addOrder))
+	assert.Equal(t, float64(1), registry.Value(metrics.ExchangeErrorsTotal, ticker))
+}
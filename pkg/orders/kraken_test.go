@@ -1,8 +1,10 @@
 package orders
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	krakenapi "github.com/beldur/kraken-go-api-client"
 	"github.com/kiran94/dca-manager/pkg/configuration"
@@ -25,13 +27,33 @@ func (m *MockKrakenAccess) QueryOrders(txids string, args map[string]string) (*k
 	return callArgs.Get(0).(*krakenapi.QueryOrdersResponse), callArgs.Error(1)
 }
 
+func (m *MockKrakenAccess) ClosedOrders(args map[string]string) (*krakenapi.ClosedOrdersResponse, error) {
+	callArgs := m.Called(args)
+	return callArgs.Get(0).(*krakenapi.ClosedOrdersResponse), callArgs.Error(1)
+}
+
+func (m *MockKrakenAccess) TradesHistory(start int64, end int64, args map[string]string) (*krakenapi.TradesHistoryResponse, error) {
+	callArgs := m.Called(start, end, args)
+	return callArgs.Get(0).(*krakenapi.TradesHistoryResponse), callArgs.Error(1)
+}
+
+func (m *MockKrakenAccess) Query(method string, data map[string]string) (interface{}, error) {
+	callArgs := m.Called(method, data)
+	return callArgs.Get(0), callArgs.Error(1)
+}
+
+func (m *MockKrakenAccess) CancelOrder(txid string) (*krakenapi.CancelOrderResponse, error) {
+	callArgs := m.Called(txid)
+	return callArgs.Get(0).(*krakenapi.CancelOrderResponse), callArgs.Error(1)
+}
+
 // Ensures when the incoming order is disabled, nothing is run
 func TestMakeOrderDisabled(t *testing.T) {
 	order := configuration.DCAOrder{Enabled: false}
 
 	krakenOrder := KrakenOrderer{}
 	krakenOrder.Client = &MockKrakenAccess{}
-	fulfilled, err := krakenOrder.MakeOrder(&order)
+	fulfilled, err := krakenOrder.MakeOrder(context.Background(), &order)
 
 	assert.Nil(t, fulfilled)
 	assert.Nil(t, err)
@@ -61,7 +83,7 @@ func TestMakeOrderErrorExecutingOrder(t *testing.T) {
 	m.On("AddOrder", order.Pair, order.Direction, order.OrderType, order.Volume, mock.Anything).Return(expectedAddOrderResponse, exepectedErr).Once()
 	krakenOrder.Client = &m
 
-	fulfilled, err := krakenOrder.MakeOrder(&order)
+	fulfilled, err := krakenOrder.MakeOrder(context.Background(), &order)
 	assert.Nil(t, fulfilled)
 	assert.NotNil(t, err)
 	assert.Equal(t, exepectedErr, err)
@@ -88,7 +110,7 @@ func TestMakeOrderNoTransactionIds(t *testing.T) {
 	m.On("AddOrder", order.Pair, order.Direction, order.OrderType, order.Volume, mock.Anything).Return(expectedAddOrderResponse, expectedErr).Once()
 	krakenOrder.Client = &m
 
-	fulfilled, err := krakenOrder.MakeOrder(&order)
+	fulfilled, err := krakenOrder.MakeOrder(context.Background(), &order)
 
 	assert.Nil(t, fulfilled)
 	assert.Equal(t, expectedErr, err)
@@ -117,7 +139,7 @@ func TestMakeOrder(t *testing.T) {
 	m.On("AddOrder", order.Pair, order.Direction, order.OrderType, order.Volume, mock.Anything).Return(expectedAddOrderResponse, nil).Once()
 	krakenOrder.Client = &m
 
-	fulfilled, err := krakenOrder.MakeOrder(&order)
+	fulfilled, err := krakenOrder.MakeOrder(context.Background(), &order)
 
 	assert.Equal(t, expectedAddOrderResponse, fulfilled.Result)
 	assert.Equal(t, "TXID", fulfilled.TransactionID)
@@ -133,12 +155,77 @@ func TestProcessTransactionsNoTransactions(t *testing.T) {
 	krakenOrder := KrakenOrderer{}
 	krakenOrder.Client = &m
 
-	order, err := krakenOrder.ProcessTransaction()
+	order, err := krakenOrder.ProcessTransaction(context.Background())
 	assert.Nil(t, order)
 	assert.NotNil(t, err)
 	assert.Contains(t, "no transactions provided", err.Error())
 }
 
+// Ensures closed orders are paged through until every order is returned
+func TestGetClosedOrders(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	m.On("ClosedOrders", mock.MatchedBy(func(args map[string]string) bool {
+		return args["ofs"] == "0" && args["start"] == "1640995200" && args["end"] == "1643673600" && args["closetime"] == "close"
+	})).Return(&krakenapi.ClosedOrdersResponse{Count: 3, Closed: map[string]krakenapi.Order{
+		"TX1": {Status: "closed", CloseTime: 100, VolumeExecuted: 0.5, Price: 30000, Description: krakenapi.OrderDescription{AssetPair: "XBTGBP", Type: "buy", OrderType: "market"}},
+		"TX2": {Status: "canceled", CloseTime: 200},
+	}}, nil)
+	m.On("ClosedOrders", mock.MatchedBy(func(args map[string]string) bool {
+		return args["ofs"] == "2"
+	})).Return(&krakenapi.ClosedOrdersResponse{Count: 3, Closed: map[string]krakenapi.Order{
+		"TX3": {Status: "closed", CloseTime: 50},
+	}}, nil)
+
+	closed, err := krakenOrder.GetClosedOrders(context.Background(), start, end)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(closed))
+	assert.Equal(t, "TX2", closed[0].TransactionID)
+	assert.Equal(t, "TX1", closed[1].TransactionID)
+	assert.Equal(t, "XBTGBP", closed[1].Pair)
+	assert.Equal(t, "0.5", closed[1].Volume.String())
+	assert.Equal(t, "TX3", closed[2].TransactionID)
+	m.AssertExpectations(t)
+}
+
+// Ensures trades are paged through and returned oldest
+// first with their pairs renamed to the altname
+func TestGetTrades(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	m.On("TradesHistory", start.Unix(), end.Unix(), map[string]string{"ofs": "0"}).Return(&krakenapi.TradesHistoryResponse{Count: 2, Trades: map[string]krakenapi.TradeHistoryInfo{
+		"T2": {TransactionID: "TX1", AssetPair: "XXBTZGBP", Type: "buy", OrderType: "market", Price: 30000, Cost: 300, Fee: 0.78, Volume: 0.01, Time: 200},
+	}}, nil)
+	m.On("TradesHistory", start.Unix(), end.Unix(), map[string]string{"ofs": "1"}).Return(&krakenapi.TradesHistoryResponse{Count: 2, Trades: map[string]krakenapi.TradeHistoryInfo{
+		"T1": {TransactionID: "TX1", AssetPair: "XXBTZGBP", Volume: 0.02, Time: 100},
+		"T3": {TransactionID: "TX2", AssetPair: "ADAGBP", Volume: 10, Time: 300},
+	}}, nil)
+	m.On("Query", "AssetPairs", map[string]string{"pair": "ADAGBP,XXBTZGBP"}).Return(map[string]interface{}{
+		"XXBTZGBP": map[string]interface{}{"altname": "XBTGBP"},
+		"ADAGBP":   map[string]interface{}{"altname": "ADAGBP"},
+	}, nil)
+
+	trades, err := krakenOrder.GetTrades(context.Background(), start, end)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(trades))
+	assert.Equal(t, "T1", trades[0].TradeID)
+	assert.Equal(t, "T2", trades[1].TradeID)
+	assert.Equal(t, "TX1", trades[1].TransactionID)
+	assert.Equal(t, "300", trades[1].Cost.String())
+	assert.Equal(t, []string{"XBTGBP", "XBTGBP", "ADAGBP"}, []string{trades[0].Pair, trades[1].Pair, trades[2].Pair})
+	m.AssertExpectations(t)
+}
+
 // Ensures when there is an error querying
 // then the error is returned
 func TestProcessTransactionsErrorQuerying(t *testing.T) {
@@ -152,7 +239,7 @@ func TestProcessTransactionsErrorQuerying(t *testing.T) {
 	transactionID := "TXID"
 	m.On("QueryOrders", transactionID, mock.Anything).Return(expectedOrderResponse, expectedErr)
 
-	order, err := krakenOrder.ProcessTransaction(transactionID)
+	order, err := krakenOrder.ProcessTransaction(context.Background(), transactionID)
 
 	assert.Nil(t, order)
 	assert.NotNil(t, err)
@@ -183,7 +270,7 @@ func TestProcessTransactions(t *testing.T) {
 	transactionID := "TXID"
 	m.On("QueryOrders", transactionID, mock.Anything).Return(returnOrderResponse, expectedErr)
 
-	orders, err := krakenOrder.ProcessTransaction(transactionID)
+	orders, err := krakenOrder.ProcessTransaction(context.Background(), transactionID)
 
 	assert.NotNil(t, orders)
 	assert.Nil(t, err)
@@ -204,3 +291,320 @@ func TestProcessTransactions(t *testing.T) {
 
 	assert.Equal(t, expectedOrderResponse, (*orders)[0])
 }
+
+// Ensures balances are parsed from the
+// generic kraken response
+func TestGetBalances(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	response := map[string]interface{}{"XXBT": "0.5000000000", "ZGBP": "120.5"}
+	m.On("Query", "Balance", mock.Anything).Return(response, nil)
+
+	balances, err := krakenOrder.GetBalances(context.Background())
+
+	assert.Nil(t, err)
+	assert.True(t, decimal.RequireFromString("0.5").Equal(balances["XXBT"]))
+	assert.True(t, decimal.RequireFromString("120.5").Equal(balances["ZGBP"]))
+	m.AssertExpectations(t)
+}
+
+// Ensures when there is an error getting balances
+// it is returned
+func TestGetBalancesError(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	expectedErr := errors.New("error getting balance")
+	m.On("Query", "Balance", mock.Anything).Return(nil, expectedErr)
+
+	balances, err := krakenOrder.GetBalances(context.Background())
+
+	assert.Nil(t, balances)
+	assert.Equal(t, expectedErr, err)
+}
+
+// Ensures the ticker is parsed even when kraken
+// responds with its own name for the pair
+func TestGetTicker(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	response := map[string]interface{}{
+		"XXBTZGBP": map[string]interface{}{
+			"a": []interface{}{"30010.1", "1", "1.000"},
+			"b": []interface{}{"30000.2", "1", "1.000"},
+			"c": []interface{}{"30005.0", "0.1"},
+		},
+	}
+	m.On("Query", "Ticker", map[string]string{"pair": "XBTGBP"}).Return(response, nil)
+
+	ticker, err := krakenOrder.GetTicker(context.Background(), "XBTGBP")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "XBTGBP", ticker.Pair)
+	assert.True(t, decimal.RequireFromString("30010.1").Equal(ticker.Ask))
+	assert.True(t, decimal.RequireFromString("30000.2").Equal(ticker.Bid))
+	assert.True(t, decimal.RequireFromString("30005").Equal(ticker.Last))
+}
+
+// Ensures when the ticker is missing fields
+// an error is returned
+func TestGetTickerMissingField(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	response := map[string]interface{}{"ADAGBP": map[string]interface{}{}}
+	m.On("Query", "Ticker", mock.Anything).Return(response, nil)
+
+	ticker, err := krakenOrder.GetTicker(context.Background(), "ADAGBP")
+
+	assert.Nil(t, ticker)
+	assert.Contains(t, err.Error(), "ticker field a missing")
+}
+
+// Ensures the fee is parsed even when kraken
+// responds with its own name for the pair
+func TestGetFeePct(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	response := map[string]interface{}{
+		"currency": "ZUSD",
+		"volume":   "1000.0",
+		"fees": map[string]interface{}{
+			"XXBTZGBP": map[string]interface{}{"fee": "0.2600", "minfee": "0.1000", "maxfee": "0.2600"},
+		},
+	}
+	m.On("Query", "TradeVolume", map[string]string{"pair": "XBTGBP", "fee-info": "true"}).Return(response, nil)
+
+	fee, err := krakenOrder.GetFeePct(context.Background(), "XBTGBP")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "0.26", fee.String())
+}
+
+// Ensures when no fees are returned an error is returned
+func TestGetFeePctMissing(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	m.On("Query", "TradeVolume", mock.Anything).Return(map[string]interface{}{"currency": "ZUSD"}, nil)
+
+	fee, err := krakenOrder.GetFeePct(context.Background(), "XBTGBP")
+
+	assert.True(t, fee.IsZero())
+	assert.Contains(t, err.Error(), "no fees found for pair XBTGBP")
+}
+
+// Ensures daily candles are parsed from
+// the generic kraken response
+func TestGetOHLC(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	since := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	response := map[string]interface{}{
+		"XXBTZGBP": []interface{}{
+			[]interface{}{float64(1640995200), "30000.0", "31000.0", "29000.0", "30500.0", "30200.0", "12.5", float64(100)},
+			[]interface{}{float64(1641081600), "30500.0", "30600.0", "28000.0", "28500.0", "29000.0", "20.1", float64(150)},
+		},
+		"last": float64(1641081600),
+	}
+	m.On("Query", "OHLC", map[string]string{"pair": "XBTGBP", "interval": "1440", "since": "1640995200"}).Return(response, nil)
+
+	candles, err := krakenOrder.GetOHLC(context.Background(), "XBTGBP", since)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(candles))
+	assert.Equal(t, since, candles[0].Time)
+	assert.True(t, decimal.RequireFromString("31000").Equal(candles[0].High))
+	assert.True(t, decimal.RequireFromString("28500").Equal(candles[1].Close))
+	assert.True(t, decimal.RequireFromString("20.1").Equal(candles[1].Volume))
+}
+
+// Ensures malformed candles return an error
+func TestGetOHLCMalformed(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	response := map[string]interface{}{
+		"ADAGBP": []interface{}{[]interface{}{float64(1640995200), "1.0"}},
+		"last":   float64(1640995200),
+	}
+	m.On("Query", "OHLC", mock.Anything).Return(response, nil)
+
+	candles, err := krakenOrder.GetOHLC(context.Background(), "ADAGBP", time.Now())
+
+	assert.Nil(t, candles)
+	assert.Contains(t, err.Error(), "could not parse ohlc for pair ADAGBP")
+}
+
+// Ensures limit orders are placed with the price
+// truncated to the pair decimals and the expiry
+func TestMakeOrderLimit(t *testing.T) {
+	limitPrice := decimal.RequireFromString("30123.456")
+	order := configuration.DCAOrder{
+		Enabled:     true,
+		Pair:        "XBTGBP",
+		Direction:   "buy",
+		OrderType:   "limit",
+		Volume:      "0.01",
+		LimitPrice:  &limitPrice,
+		ExpireAfter: "2h",
+	}
+
+	m := MockKrakenAccess{}
+	m.On("Query", "AssetPairs", map[string]string{"pair": "XBTGBP"}).Return(map[string]interface{}{
+		"XXBTZGBP": map[string]interface{}{"pair_decimals": float64(1)},
+	}, nil)
+	m.On("AddOrder", "XBTGBP", "buy", "limit", "0.01", map[string]string{"price": "30123.4", "expiretm": "+7200"}).
+		Return(&krakenapi.AddOrderResponse{TransactionIds: []string{"TXID"}}, nil).Once()
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	fulfilled, err := krakenOrder.MakeOrder(context.Background(), &order)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "TXID", fulfilled.TransactionID)
+	m.AssertExpectations(t)
+}
+
+// Ensures validated orders are only
+// checked by the exchange
+func TestMakeOrderValidate(t *testing.T) {
+	order := configuration.DCAOrder{
+		Enabled:   true,
+		Validate:  true,
+		Pair:      "XBTGBP",
+		Direction: "buy",
+		OrderType: "market",
+		Volume:    "0.01",
+	}
+
+	m := MockKrakenAccess{}
+	m.On("AddOrder", "XBTGBP", "buy", "market", "0.01", map[string]string{"validate": "true"}).
+		Return(&krakenapi.AddOrderResponse{Description: krakenapi.OrderDescription{Order: "buy 0.01 XBTGBP @ market"}}, nil).Once()
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	fulfilled, err := krakenOrder.MakeOrder(context.Background(), &order)
+
+	assert.Nil(t, err)
+	assert.True(t, fulfilled.Validated)
+	assert.Equal(t, "", fulfilled.TransactionID)
+	m.AssertExpectations(t)
+}
+
+// Ensures an invalid expiry is rejected
+// before the order is placed
+func TestMakeOrderInvalidExpiry(t *testing.T) {
+	order := configuration.DCAOrder{Enabled: true, Pair: "XBTGBP", OrderType: "market", ExpireAfter: "tomorrow"}
+
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	fulfilled, err := krakenOrder.MakeOrder(context.Background(), &order)
+
+	assert.Nil(t, fulfilled)
+	assert.Contains(t, err.Error(), "invalid expire_after tomorrow")
+	m.AssertNotCalled(t, "AddOrder", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Ensures orders are cancelled on kraken
+func TestCancelOrder(t *testing.T) {
+	m := MockKrakenAccess{}
+	m.On("CancelOrder", "TXID").Return(&krakenapi.CancelOrderResponse{Count: 1}, nil)
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	err := krakenOrder.CancelOrder(context.Background(), "TXID")
+
+	assert.Nil(t, err)
+	m.AssertExpectations(t)
+}
+
+// Ensures errors cancelling are returned
+func TestCancelOrderError(t *testing.T) {
+	var response *krakenapi.CancelOrderResponse
+	expectedErr := errors.New("unknown order")
+
+	m := MockKrakenAccess{}
+	m.On("CancelOrder", "TXID").Return(response, expectedErr)
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	err := krakenOrder.CancelOrder(context.Background(), "TXID")
+
+	assert.Equal(t, expectedErr, err)
+}
+
+// Ensures withdrawal info is parsed
+func TestWithdrawInfo(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	response := map[string]interface{}{"method": "Bitcoin", "limit": "1.5", "amount": "0.0995", "fee": "0.0005"}
+	m.On("Query", "WithdrawInfo", map[string]string{"asset": "XXBT", "key": "cold", "amount": "0.1"}).Return(response, nil)
+
+	quote, err := krakenOrder.WithdrawInfo(context.Background(), "XXBT", "cold", decimal.RequireFromString("0.1"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Bitcoin", quote.Method)
+	assert.Equal(t, "1.5", quote.Limit.String())
+	assert.Equal(t, "0.0005", quote.Fee.String())
+}
+
+// Ensures unknown withdrawal keys are rejected
+func TestWithdrawInfoUnknownKey(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	expectedErr := errors.New("EFunding:Unknown withdraw key")
+	m.On("Query", "WithdrawInfo", mock.Anything).Return(nil, expectedErr)
+
+	quote, err := krakenOrder.WithdrawInfo(context.Background(), "XXBT", "hot", decimal.RequireFromString("0.1"))
+
+	assert.Nil(t, quote)
+	assert.Equal(t, expectedErr, err)
+}
+
+// Ensures the withdrawal reference is returned
+func TestWithdraw(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	m.On("Query", "Withdraw", map[string]string{"asset": "XXBT", "key": "cold", "amount": "0.1"}).Return(map[string]interface{}{"refid": "REFID"}, nil)
+
+	refID, err := krakenOrder.Withdraw(context.Background(), "XXBT", "cold", decimal.RequireFromString("0.1"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "REFID", refID)
+}
+
+// Ensures the status of recent withdrawals is parsed
+func TestWithdrawalStatus(t *testing.T) {
+	m := MockKrakenAccess{}
+	krakenOrder := KrakenOrderer{Client: &m}
+
+	response := []interface{}{
+		map[string]interface{}{
+			"method": "Bitcoin",
+			"refid":  "REFID",
+			"txid":   "CHAINTX",
+			"info":   "bc1qaddress",
+			"amount": "0.0995",
+			"fee":    "0.0005",
+			"time":   float64(1644000000),
+			"status": "Success",
+		},
+	}
+	m.On("Query", "WithdrawStatus", map[string]string{"asset": "XXBT"}).Return(response, nil)
+
+	withdrawals, err := krakenOrder.WithdrawalStatus(context.Background(), "XXBT")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(withdrawals))
+	assert.Equal(t, "REFID", withdrawals[0].RefID)
+	assert.Equal(t, "CHAINTX", withdrawals[0].TransactionID)
+	assert.Equal(t, "bc1qaddress", withdrawals[0].Address)
+	assert.Equal(t, "Success", withdrawals[0].Status)
+	assert.Equal(t, "0.0005", withdrawals[0].Fee.String())
+	assert.Equal(t, int64(1644000000), withdrawals[0].Time)
+}
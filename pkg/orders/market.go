@@ -0,0 +1,63 @@
+package orders
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// MarketData provides account balances and prices from an Exchange.
+type MarketData interface {
+	GetBalances(ctx context.Context) (map[string]decimal.Decimal, error)
+	GetTicker(ctx context.Context, pair string) (*Ticker, error)
+	GetOHLC(ctx context.Context, pair string, since time.Time) ([]Candle, error)
+}
+
+// FeeSchedule provides the fees an Exchange charges the account.
+type FeeSchedule interface {
+	GetFeePct(ctx context.Context, pair string) (decimal.Decimal, error)
+}
+
+// OrderHistory provides the orders which have closed on an Exchange.
+type OrderHistory interface {
+	GetClosedOrders(ctx context.Context, start time.Time, end time.Time) ([]OrderComplete, error)
+}
+
+// TradeHistory provides the individual fills made on an Exchange.
+type TradeHistory interface {
+	GetTrades(ctx context.Context, start time.Time, end time.Time) ([]Trade, error)
+}
+
+// Trade is a single fill of an order, where the transaction
+// ID is the ID of the order the trade filled.
+type Trade struct {
+	TradeID       string          `json:"trade_id"`
+	TransactionID string          `json:"transaction_id"`
+	Pair          string          `json:"pair"`
+	Type          string          `json:"type"`
+	OrderType     string          `json:"order_type"`
+	Price         decimal.Decimal `json:"price"`
+	Cost          decimal.Decimal `json:"cost"`
+	Fee           decimal.Decimal `json:"fee"`
+	Volume        decimal.Decimal `json:"volume"`
+	Time          float64         `json:"time"`
+}
+
+// Ticker is the latest top of book and last trade for a pair.
+type Ticker struct {
+	Pair string          `json:"pair"`
+	Ask  decimal.Decimal `json:"ask"`
+	Bid  decimal.Decimal `json:"bid"`
+	Last decimal.Decimal `json:"last"`
+}
+
+// Candle is the daily open, high, low and close for a pair.
+type Candle struct {
+	Time   time.Time       `json:"time"`
+	Open   decimal.Decimal `json:"open"`
+	High   decimal.Decimal `json:"high"`
+	Low    decimal.Decimal `json:"low"`
+	Close  decimal.Decimal `json:"close"`
+	Volume decimal.Decimal `json:"volume"`
+}
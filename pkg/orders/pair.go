@@ -0,0 +1,97 @@
+package orders
+
+import (
+	"strings"
+)
+
+// legacyAssets are the Kraken asset codes which name an asset differently
+// to everywhere else, such as the X and Z prefixed codes e.g XXBT and ZGBP.
+var legacyAssets = map[string]string{
+	"XXBT": "BTC",
+	"XBT":  "BTC",
+	"XXDG": "DOGE",
+	"XDG":  "DOGE",
+	"XETH": "ETH",
+	"XETC": "ETC",
+	"XLTC": "LTC",
+	"XMLN": "MLN",
+	"XREP": "REP",
+	"XXLM": "XLM",
+	"XXMR": "XMR",
+	"XXRP": "XRP",
+	"XZEC": "ZEC",
+	"ZAUD": "AUD",
+	"ZCAD": "CAD",
+	"ZEUR": "EUR",
+	"ZGBP": "GBP",
+	"ZJPY": "JPY",
+	"ZUSD": "USD",
+}
+
+// quoteAssets are the assets pairs are quoted in, longest first
+// so a pair quoted in USDT is not mistaken for one quoted in USD.
+var quoteAssets = []string{
+	"XXBT", "XETH", "ZAUD", "ZCAD", "ZEUR", "ZGBP", "ZJPY", "ZUSD", "USDT", "USDC",
+	"AUD", "CAD", "CHF", "EUR", "GBP", "JPY", "USD", "DAI", "XBT", "BTC", "ETH",
+}
+
+// Asset normalises the code of an asset so every exchange and every
+// Kraken spelling names it the same e.g XXBT, XBT and BTC are all BTC.
+func Asset(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if asset, ok := legacyAssets[code]; ok {
+		return asset
+	}
+	return code
+}
+
+// KrakenAltname names the asset as Kraken does in the alternative names of
+// its pairs, which drop the X and Z prefix of the legacy codes e.g XXBT is XBT.
+func KrakenAltname(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, legacy := legacyAssets[code]; legacy && len(code) == 4 {
+		return code[1:]
+	}
+	return code
+}
+
+// SplitPair splits the pair into the normalised asset traded and the asset it is
+// quoted in e.g XBTGBP, XXBTZGBP and BTC-GBP are all BTC and GBP. It is false
+// when the pair is not quoted in a known asset.
+func SplitPair(pair string) (string, string, bool) {
+	pair = strings.ToUpper(strings.TrimSpace(pair))
+
+	for _, separator := range []string{"-", "/", "_"} {
+		if parts := strings.Split(pair, separator); len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			return Asset(parts[0]), Asset(parts[1]), true
+		}
+	}
+
+	for _, quote := range quoteAssets {
+		base := strings.TrimSuffix(pair, quote)
+		if base == pair || base == "" {
+			continue
+		}
+
+		// Legacy quotes only follow legacy bases e.g XXBTZGBP, so XTZGBP is XTZ quoted in GBP
+		if _, legacyQuote := legacyAssets[quote]; legacyQuote && len(quote) == 4 {
+			if _, legacyBase := legacyAssets[base]; !legacyBase || len(base) != 4 {
+				continue
+			}
+		}
+
+		return Asset(base), Asset(quote), true
+	}
+
+	return "", "", false
+}
+
+// NormalisePair names the pair by its normalised assets e.g XXBTZGBP is BTCGBP,
+// pairs which cannot be split are kept as they are.
+func NormalisePair(pair string) string {
+	base, quote, ok := SplitPair(pair)
+	if !ok {
+		return strings.ToUpper(pair)
+	}
+	return base + quote
+}
@@ -0,0 +1,42 @@
+package orders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensures every spelling of a pair splits into the same assets
+func TestSplitPair(t *testing.T) {
+	for pair, expected := range map[string][2]string{
+		"XBTGBP":   {"BTC", "GBP"},
+		"XXBTZGBP": {"BTC", "GBP"},
+		"btc-gbp":  {"BTC", "GBP"},
+		"XTZGBP":   {"XTZ", "GBP"},
+		"ADAGBP":   {"ADA", "GBP"},
+		"XETHXXBT": {"ETH", "BTC"},
+		"XBTUSDT":  {"BTC", "USDT"},
+		"XBTUSD":   {"BTC", "USD"},
+		"XDGEUR":   {"DOGE", "EUR"},
+		"ETH/USDC": {"ETH", "USDC"},
+	} {
+		base, quote, ok := SplitPair(pair)
+		assert.True(t, ok, pair)
+		assert.Equal(t, expected[0], base, pair)
+		assert.Equal(t, expected[1], quote, pair)
+	}
+
+	_, _, ok := SplitPair("GBP")
+	assert.False(t, ok)
+	_, _, ok = SplitPair("ABCXYZ")
+	assert.False(t, ok)
+
+	assert.Equal(t, "XBT", KrakenAltname("XXBT"))
+	assert.Equal(t, "GBP", KrakenAltname("ZGBP"))
+	assert.Equal(t, "XDG", KrakenAltname("XXDG"))
+	assert.Equal(t, "ADA", KrakenAltname("ADA"))
+	assert.Equal(t, "XTZ", KrakenAltname("XTZ"))
+
+	assert.Equal(t, "BTCGBP", NormalisePair("XXBTZGBP"))
+	assert.Equal(t, "ABCXYZ", NormalisePair("abcxyz"))
+}
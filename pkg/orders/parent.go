@@ -0,0 +1,84 @@
+package orders
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/shopspring/decimal"
+)
+
+// ParentOrder rolls up the fills of the child orders
+// an order was sliced into.
+type ParentOrder struct {
+	ParentID     string          `json:"parent_id"`
+	Pair         string          `json:"pair"`
+	Type         string          `json:"type"`
+	Slices       int             `json:"slices"`
+	Volume       decimal.Decimal `json:"volume"`
+	Cost         decimal.Decimal `json:"cost"`
+	Fee          decimal.Decimal `json:"fee"`
+	AveragePrice decimal.Decimal `json:"average_price"`
+	Children     []ChildFill     `json:"children"`
+}
+
+// ChildFill is what a single child order filled.
+type ChildFill struct {
+	TransactionID  string          `json:"transaction_id"`
+	ExchangeStatus string          `json:"exchange_status"`
+	Price          decimal.Decimal `json:"price"`
+	Fee            decimal.Decimal `json:"fee"`
+	Volume         decimal.Decimal `json:"volume"`
+}
+
+// NewParentID creates a random identifier to group child orders under.
+func NewParentID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// Add records the fill of a child order and recalculates the totals.
+// Adding the same child again replaces its previous fill.
+func (p *ParentOrder) Add(child OrderComplete) {
+	fill := ChildFill{
+		TransactionID:  child.TransactionID,
+		ExchangeStatus: child.ExchangeStatus,
+		Price:          child.Price,
+		Fee:            child.Fee,
+		Volume:         child.Volume,
+	}
+
+	if p.Pair == "" {
+		p.Pair = child.Pair
+		p.Type = child.Type
+	}
+
+	replaced := false
+	for index, existing := range p.Children {
+		if existing.TransactionID == fill.TransactionID {
+			p.Children[index] = fill
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		p.Children = append(p.Children, fill)
+	}
+
+	p.Volume = decimal.Zero
+	p.Cost = decimal.Zero
+	p.Fee = decimal.Zero
+	for _, existing := range p.Children {
+		p.Volume = p.Volume.Add(existing.Volume)
+		p.Cost = p.Cost.Add(existing.Volume.Mul(existing.Price))
+		p.Fee = p.Fee.Add(existing.Fee)
+	}
+
+	p.AveragePrice = decimal.Zero
+	if p.Volume.IsPositive() {
+		p.AveragePrice = p.Cost.Div(p.Volume)
+	}
+}
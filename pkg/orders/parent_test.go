@@ -0,0 +1,63 @@
+package orders
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensures child fills roll up into a
+// volume weighted average price
+func TestParentOrderAdd(t *testing.T) {
+	parent := ParentOrder{ParentID: "PARENT", Slices: 2}
+
+	parent.Add(OrderComplete{
+		TransactionID: "A",
+		Pair:          "XBTGBP",
+		Type:          "buy",
+		Price:         decimal.NewFromInt(100),
+		Fee:           decimal.RequireFromString("0.1"),
+		Volume:        decimal.NewFromInt(1),
+	})
+	parent.Add(OrderComplete{
+		TransactionID: "B",
+		Pair:          "XBTGBP",
+		Type:          "buy",
+		Price:         decimal.NewFromInt(130),
+		Fee:           decimal.RequireFromString("0.2"),
+		Volume:        decimal.NewFromInt(2),
+	})
+
+	assert.Equal(t, "XBTGBP", parent.Pair)
+	assert.Equal(t, "buy", parent.Type)
+	assert.Equal(t, 2, len(parent.Children))
+	assert.Equal(t, "3", parent.Volume.String())
+	assert.Equal(t, "360", parent.Cost.String())
+	assert.Equal(t, "0.3", parent.Fee.String())
+	assert.Equal(t, "120", parent.AveragePrice.String())
+}
+
+// Ensures the same child being processed
+// again does not double count its fill
+func TestParentOrderAddDuplicate(t *testing.T) {
+	parent := ParentOrder{ParentID: "PARENT"}
+
+	parent.Add(OrderComplete{TransactionID: "A", ExchangeStatus: "open", Price: decimal.NewFromInt(100), Volume: decimal.RequireFromString("0.5")})
+	parent.Add(OrderComplete{TransactionID: "A", ExchangeStatus: "closed", Price: decimal.NewFromInt(100), Volume: decimal.NewFromInt(1)})
+
+	assert.Equal(t, 1, len(parent.Children))
+	assert.Equal(t, "closed", parent.Children[0].ExchangeStatus)
+	assert.Equal(t, "1", parent.Volume.String())
+}
+
+// Ensures parent identifiers are unique
+func TestNewParentID(t *testing.T) {
+	first, err := NewParentID()
+	assert.Nil(t, err)
+	second, err := NewParentID()
+	assert.Nil(t, err)
+
+	assert.Equal(t, 32, len(first))
+	assert.NotEqual(t, first, second)
+}
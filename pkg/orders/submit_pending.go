@@ -4,14 +4,21 @@ import (
 	"context"
 	"encoding/json"
 	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/logging"
+	"github.com/kiran94/dca-manager/pkg/tracing"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// MaxDelaySeconds is the longest SQS allows a message to be delayed.
+const MaxDelaySeconds int64 = 900
+
 // PendingOrderQueue is an abstraction to submit pending orders to a queue.
 type PendingOrderQueue interface {
 	SubmitPendingOrder(ctx context.Context, sc pkg.SQSAccess, po *PendingOrders, exchange string, real bool, sqsQueue string) error
@@ -20,8 +27,15 @@ type PendingOrderQueue interface {
 // PendingOrderSubmitter submits pending order to a queue
 type PendingOrderSubmitter struct{}
 
-// SubmitPendingOrder submits a pending order to queue.
-func (p PendingOrderSubmitter) SubmitPendingOrder(ctx context.Context, sc pkg.SQSAccess, po *PendingOrders, exchange string, real bool, sqsQueue string) error {
+// SubmitPendingOrder submits a pending order to queue, carrying the
+// trace context in the message so processing joins the same trace.
+func (p PendingOrderSubmitter) SubmitPendingOrder(ctx context.Context, sc pkg.SQSAccess, po *PendingOrders, exchange string, real bool, sqsQueue string) (err error) {
+	ctx, span := tracing.Start(ctx, "SubmitPendingOrder",
+		attribute.String("exchange", exchange),
+		attribute.String("transaction.id", po.TransactionID),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	sqsMessageBodyBytes, err := json.Marshal(po)
 	if err != nil {
 		return err
@@ -47,11 +61,24 @@ func (p PendingOrderSubmitter) SubmitPendingOrder(ctx context.Context, sc pkg.SQ
 		},
 	}
 
-	logrus.WithFields(logrus.Fields{
+	tracing.InjectSQS(ctx, sqsMessageInput.MessageAttributes)
+
+	// Orders which expire are not worth processing until they expire
+	// and slices are not placed until they are due
+	// so hold them back for as long as SQS allows
+	if po.ExecuteAt > 0 {
+		sqsMessageInput.DelaySeconds = int32(delaySeconds(po.ExecuteAt, time.Now()))
+	} else if po.ExpireAt > 0 {
+		sqsMessageInput.DelaySeconds = int32(delaySeconds(po.ExpireAt, time.Now()))
+	}
+
+	logging.FromContext(ctx).WithFields(logrus.Fields{
 		"transactionId": po.TransactionID,
+		"parentId":      po.ParentID,
 		"queue":         sqsQueue,
 		"real":          real,
 		"exchange":      exchange,
+		"delaySeconds":  sqsMessageInput.DelaySeconds,
 	}).Info("Submitting Transaction to Queue")
 
 	_, sqsErr := sc.SendMessage(ctx, sqsMessageInput)
@@ -62,3 +89,18 @@ func (p PendingOrderSubmitter) SubmitPendingOrder(ctx context.Context, sc pkg.SQ
 
 	return nil
 }
+
+// delaySeconds is how long to delay a message until the
+// given unix time, bounded by what SQS allows.
+func delaySeconds(until int64, now time.Time) int64 {
+	delay := until - now.Unix()
+	if delay < 0 {
+		return 0
+	}
+
+	if delay > MaxDelaySeconds {
+		return MaxDelaySeconds
+	}
+
+	return delay
+}
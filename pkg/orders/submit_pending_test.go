@@ -4,11 +4,17 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/tracing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // Ensures when there is an error submitting the message,
@@ -48,3 +54,70 @@ func TestSubmitPendingOrder(t *testing.T) {
 	actualErr := pendingOrder.SubmitPendingOrder(context.Background(), mockSQS, po, exchange, real, sqsQueue)
 	assert.Nil(t, actualErr)
 }
+
+// Ensures orders which expire are delayed
+// until they expire up to the SQS maximum
+func TestSubmitPendingOrderDelayed(t *testing.T) {
+	mockSQS := pkg.MockSQSAccess{}
+	pendingOrder := PendingOrderSubmitter{}
+
+	mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(i *sqs.SendMessageInput) bool {
+		return i.DelaySeconds == int32(MaxDelaySeconds)
+	}), mock.Anything).Return(&sqs.SendMessageOutput{}, nil)
+
+	po := &PendingOrders{TransactionID: "TXID", ExpireAt: time.Now().Add(time.Hour).Unix()}
+
+	actualErr := pendingOrder.SubmitPendingOrder(context.Background(), mockSQS, po, "kraken", true, "queue_url")
+	assert.Nil(t, actualErr)
+	mockSQS.AssertExpectations(t)
+}
+
+// Ensures the delay is bounded
+func TestDelaySeconds(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	assert.Equal(t, int64(0), delaySeconds(900, now))
+	assert.Equal(t, int64(60), delaySeconds(1060, now))
+	assert.Equal(t, MaxDelaySeconds, delaySeconds(5000, now))
+}
+
+// Ensures scheduled slices are delayed until they are due
+func TestSubmitPendingOrderScheduledSlice(t *testing.T) {
+	mockSQS := pkg.MockSQSAccess{}
+	pendingOrder := PendingOrderSubmitter{}
+
+	mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(i *sqs.SendMessageInput) bool {
+		return i.DelaySeconds > 500 && i.DelaySeconds <= 600
+	}), mock.Anything).Return(&sqs.SendMessageOutput{}, nil)
+
+	po := &PendingOrders{ParentID: "PARENT", Slice: 1, Slices: 6, ExecuteAt: time.Now().Add(10 * time.Minute).Unix()}
+
+	actualErr := pendingOrder.SubmitPendingOrder(context.Background(), mockSQS, po, "kraken", true, "queue_url")
+	assert.Nil(t, actualErr)
+	assert.True(t, po.IsScheduledSlice())
+	mockSQS.AssertExpectations(t)
+}
+
+// Ensures the trace context of the run is sent with the message
+func TestSubmitPendingOrderTraceContext(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	ctx, span := tracing.Start(context.Background(), "ExecuteOrder")
+	defer span.End()
+
+	var traceparent string
+	mockSQS := pkg.MockSQSAccess{}
+	mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+		traceparent = aws.ToString(input.MessageAttributes["traceparent"].StringValue)
+		return true
+	}), mock.Anything).Return(&sqs.SendMessageOutput{}, nil)
+
+	po := &PendingOrders{TransactionID: "TXID"}
+	err := PendingOrderSubmitter{}.SubmitPendingOrder(ctx, mockSQS, po, "kraken", true, "queue_url")
+
+	assert.Nil(t, err)
+	assert.Contains(t, traceparent, span.SpanContext().TraceID().String())
+}
@@ -0,0 +1,39 @@
+package orders
+
+import (
+	"context"
+
+	"github.com/shopspring/decimal"
+)
+
+// Withdrawer moves funds off an Exchange to a withdrawal key
+// which has already been approved on the Exchange.
+type Withdrawer interface {
+	WithdrawInfo(ctx context.Context, asset string, key string, amount decimal.Decimal) (*WithdrawalQuote, error)
+	Withdraw(ctx context.Context, asset string, key string, amount decimal.Decimal) (string, error)
+	WithdrawalStatus(ctx context.Context, asset string) ([]Withdrawal, error)
+}
+
+// WithdrawalQuote is what the Exchange will allow and charge for a withdrawal.
+type WithdrawalQuote struct {
+	Method string          `json:"method"`
+	Limit  decimal.Decimal `json:"limit"`
+	Amount decimal.Decimal `json:"amount"`
+	Fee    decimal.Decimal `json:"fee"`
+}
+
+// Withdrawal which has been sent to the Exchange where
+// the reference is the Exchange identifier of the withdrawal
+// and the transaction is the identifier on chain once broadcast.
+type Withdrawal struct {
+	RefID         string          `json:"refid"`
+	Exchange      string          `json:"exchange"`
+	Asset         string          `json:"asset"`
+	Key           string          `json:"key,omitempty"`
+	Address       string          `json:"address,omitempty"`
+	TransactionID string          `json:"transaction_id,omitempty"`
+	Amount        decimal.Decimal `json:"amount"`
+	Fee           decimal.Decimal `json:"fee"`
+	Status        string          `json:"status"`
+	Time          int64           `json:"time"`
+}
@@ -0,0 +1,171 @@
+// Package overrides pauses orders of the configuration by their ID
+// without downloading, editing and uploading the whole configuration.
+package overrides
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+)
+
+// DateLayout is the layout of the date a pause lasts until.
+const DateLayout = "2006-01-02"
+
+// Overrides are changes made to the orders of the configuration
+// which are merged in every run, keyed by the ID of the order.
+type Overrides struct {
+	Pauses map[string]Pause `json:"pauses"`
+}
+
+// Pause stops an order from executing, until the start
+// of the until date when there is one or else indefinitely.
+type Pause struct {
+	Until    string    `json:"until,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	PausedAt time.Time `json:"paused_at"`
+}
+
+// IsActive decides if the pause still applies at the time.
+func (p Pause) IsActive(now time.Time) bool {
+	if p.Until == "" {
+		return true
+	}
+
+	until, err := time.Parse(DateLayout, p.Until)
+	if err != nil {
+		// A pause which cannot be read is safer kept in place
+		return true
+	}
+	return now.Before(until)
+}
+
+// Describe is why an order was skipped for the pause.
+func (p Pause) Describe() string {
+	description := "paused"
+	if p.Until != "" {
+		description += " until " + p.Until
+	}
+	if p.Reason != "" {
+		description += ": " + p.Reason
+	}
+	return description
+}
+
+// New creates overrides with nothing paused.
+func New() *Overrides {
+	return &Overrides{Pauses: map[string]Pause{}}
+}
+
+// Pause pauses the order, replacing any previous pause.
+func (o *Overrides) Pause(id string, until string, reason string, now time.Time) error {
+	if id == "" {
+		return errors.New("only orders with an id can be paused")
+	}
+
+	if until != "" {
+		if _, err := time.Parse(DateLayout, until); err != nil {
+			return fmt.Errorf("invalid until %s, expected %s", until, DateLayout)
+		}
+	}
+
+	if o.Pauses == nil {
+		o.Pauses = map[string]Pause{}
+	}
+	o.Pauses[id] = Pause{Until: until, Reason: reason, PausedAt: now.UTC()}
+	return nil
+}
+
+// Resume removes the pause of the order, returning if it was paused.
+func (o *Overrides) Resume(id string) bool {
+	_, ok := o.Pauses[id]
+	delete(o.Pauses, id)
+	return ok
+}
+
+// Paused gets the pause of the order when it applies at the time.
+func (o *Overrides) Paused(id string, now time.Time) (Pause, bool) {
+	if o == nil || id == "" {
+		return Pause{}, false
+	}
+
+	pause, ok := o.Pauses[id]
+	if !ok || !pause.IsActive(now) {
+		return Pause{}, false
+	}
+	return pause, true
+}
+
+// IDs are the IDs of every paused order in order.
+func (o *Overrides) IDs() []string {
+	ids := make([]string, 0, len(o.Pauses))
+	for id := range o.Pauses {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Store is an abstraction to load and save overrides.
+type Store interface {
+	GetOverrides(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Key string) (*Overrides, error)
+	PutOverrides(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Key string, overrides *Overrides) error
+}
+
+// S3Store keeps the overrides in a single S3 object.
+type S3Store struct{}
+
+// GetOverrides loads the overrides where nothing is
+// overridden when the object does not exist yet.
+func (s S3Store) GetOverrides(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Key string) (*Overrides, error) {
+	object, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s3Bucket,
+		Key:    &s3Key,
+	})
+
+	var noSuchKey *s3types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer object.Body.Close()
+
+	content, err := ioutil.ReadAll(object.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := New()
+	if err := json.Unmarshal(content, overrides); err != nil {
+		return nil, fmt.Errorf("could not read overrides %s: %w", s3Key, err)
+	}
+	if overrides.Pauses == nil {
+		overrides.Pauses = map[string]Pause{}
+	}
+	return overrides, nil
+}
+
+// PutOverrides saves the overrides, replacing the previous overrides.
+func (s S3Store) PutOverrides(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Key string, overrides *Overrides) error {
+	content, err := json.MarshalIndent(overrides, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &s3Bucket,
+		Key:    &s3Key,
+		Body:   bytes.NewReader(content),
+	})
+	return err
+}
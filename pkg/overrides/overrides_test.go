@@ -0,0 +1,97 @@
+package overrides
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var now = time.Date(2022, 1, 7, 6, 0, 0, 0, time.UTC)
+
+// Ensures a pause applies until the start of the until
+// date and a pause without a date applies indefinitely
+func TestPaused(t *testing.T) {
+	overrides := New()
+	assert.Nil(t, overrides.Pause("btc-weekly", "", "holiday", now))
+	assert.Nil(t, overrides.Pause("eth-weekly", "2022-01-08", "", now))
+
+	pause, paused := overrides.Paused("btc-weekly", now.AddDate(1, 0, 0))
+	assert.True(t, paused)
+	assert.Equal(t, "paused: holiday", pause.Describe())
+
+	pause, paused = overrides.Paused("eth-weekly", now)
+	assert.True(t, paused)
+	assert.Equal(t, "paused until 2022-01-08", pause.Describe())
+
+	_, paused = overrides.Paused("eth-weekly", time.Date(2022, 1, 8, 0, 0, 0, 0, time.UTC))
+	assert.False(t, paused)
+
+	_, paused = overrides.Paused("ada-weekly", now)
+	assert.False(t, paused)
+
+	_, paused = overrides.Paused("", now)
+	assert.False(t, paused)
+
+	var missing *Overrides
+	_, paused = missing.Paused("btc-weekly", now)
+	assert.False(t, paused)
+}
+
+// Ensures pauses need an order ID and a valid date
+// and resuming removes the pause
+func TestPauseResume(t *testing.T) {
+	overrides := New()
+	assert.NotNil(t, overrides.Pause("", "", "", now))
+	assert.NotNil(t, overrides.Pause("btc-weekly", "next week", "", now))
+
+	assert.Nil(t, overrides.Pause("btc-weekly", "", "", now))
+	assert.Nil(t, overrides.Pause("ada-weekly", "", "", now))
+	assert.Equal(t, []string{"ada-weekly", "btc-weekly"}, overrides.IDs())
+
+	assert.True(t, overrides.Resume("btc-weekly"))
+	assert.False(t, overrides.Resume("btc-weekly"))
+	assert.Equal(t, []string{"ada-weekly"}, overrides.IDs())
+}
+
+// Ensures overrides are saved to and loaded from S3
+// and nothing is overridden before they have been saved
+func TestS3Store(t *testing.T) {
+	var saved []byte
+	s3Access := &pkg.MockS3Access{}
+	s3Access.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		saved, _ = io.ReadAll(input.Body)
+		return *input.Bucket == "bucket" && *input.Key == "overrides.json"
+	}), mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+	s3Access.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+		return *input.Key == "missing.json"
+	}), mock.Anything).Return(&s3.GetObjectOutput{}, &s3types.NoSuchKey{})
+
+	overrides, err := S3Store{}.GetOverrides(context.Background(), s3Access, "bucket", "missing.json")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(overrides.Pauses))
+
+	assert.Nil(t, overrides.Pause("btc-weekly", "2022-02-01", "holiday", now))
+	assert.Nil(t, S3Store{}.PutOverrides(context.Background(), s3Access, "bucket", "overrides.json", overrides))
+
+	var serialised map[string]interface{}
+	assert.Nil(t, json.Unmarshal(saved, &serialised))
+	assert.Contains(t, serialised, "pauses")
+
+	s3Access.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+		return *input.Key == "overrides.json"
+	}), mock.Anything).Return(&s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(saved))}, nil)
+
+	loaded, err := S3Store{}.GetOverrides(context.Background(), s3Access, "bucket", "overrides.json")
+	assert.Nil(t, err)
+	assert.Equal(t, overrides, loaded)
+}
@@ -0,0 +1,166 @@
+package performance
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/shopspring/decimal"
+)
+
+// Output formats a report can be written in.
+const (
+	FormatText string = "text"
+	FormatJSON string = "json"
+	FormatHTML string = "html"
+)
+
+var textHeader = "EXCHANGE\tPAIR\tINVESTED\tUNITS\tAVERAGE COST\tPRICE\tVALUE\tUNREALISED P&L\tXIRR\tLUMP SUM VALUE\tLUMP SUM XIRR\t"
+var textAssetHeader = "ASSET\tQUOTE\tEXCHANGES\tINVESTED\tUNITS\tAVERAGE COST\tVALUE\tUNREALISED P&L\tREALISED P&L\t"
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"money":   money,
+	"percent": percent,
+	"join":    strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>DCA Performance</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+.loss { color: #b00; }
+</style>
+</head>
+<body>
+<h1>DCA Performance</h1>
+<p>Generated {{ .GeneratedAt.Format "2006-01-02 15:04:05 MST" }}</p>
+<table>
+<tr><th>Exchange</th><th>Pair</th><th>Invested</th><th>Units</th><th>Average Cost</th><th>Price</th><th>Value</th><th>Unrealised P&amp;L</th><th>XIRR</th><th>Lump Sum Value</th><th>Lump Sum XIRR</th></tr>
+{{- range .Holdings }}{{ if not .Incomplete }}
+<tr><td>{{ .Exchange }}</td><td>{{ .Pair }}</td><td>{{ money .Invested }}</td><td>{{ .Units }}</td><td>{{ money .AverageCost }}</td><td>{{ money .Price }}</td><td>{{ money .Value }}</td><td{{ if .UnrealisedPnL.IsNegative }} class="loss"{{ end }}>{{ money .UnrealisedPnL }} ({{ money .UnrealisedPnLPct }}%)</td><td>{{ percent .XIRR }}</td><td>{{ money .LumpSum.Value }}</td><td>{{ percent .LumpSum.XIRR }}</td></tr>
+{{- end }}{{ end }}
+</table>
+{{- range .Holdings }}{{ if .Incomplete }}
+<p class="loss">{{ .Pair }} on {{ .Exchange }} is incomplete: {{ .Incomplete }}</p>
+{{- end }}{{ end }}
+<h2>Assets</h2>
+<table>
+<tr><th>Asset</th><th>Quote</th><th>Exchanges</th><th>Invested</th><th>Units</th><th>Average Cost</th><th>Value</th><th>Unrealised P&amp;L</th><th>Realised P&amp;L</th></tr>
+{{- range .Assets }}{{ if not .Incomplete }}
+<tr><td>{{ .Asset }}</td><td>{{ .Quote }}</td><td>{{ join .Exchanges ", " }}</td><td>{{ money .Invested }}</td><td>{{ .Units }}</td><td>{{ money .AverageCost }}</td><td>{{ money .Value }}</td><td{{ if .UnrealisedPnL.IsNegative }} class="loss"{{ end }}>{{ money .UnrealisedPnL }} ({{ money .UnrealisedPnLPct }}%)</td><td>{{ money .RealisedPnL }}</td></tr>
+{{- end }}{{ end }}
+</table>
+{{- range .Assets }}{{ if .Incomplete }}
+<p class="loss">{{ .Asset }}{{ .Quote }} is incomplete: {{ .Incomplete }}</p>
+{{- end }}{{ end }}
+</body>
+</html>
+`))
+
+// Write writes the report in the given format.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatText:
+		return r.WriteText(w)
+	case FormatJSON:
+		return r.WriteJSON(w)
+	case FormatHTML:
+		return r.WriteHTML(w)
+	default:
+		return fmt.Errorf("unsupported report format %s", format)
+	}
+}
+
+// WriteText writes the holdings and then the assets as aligned tables
+// followed by the holdings and assets which are incomplete.
+func (r *Report) WriteText(w io.Writer) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, textHeader)
+
+	incomplete := []string{}
+	for _, h := range r.Holdings {
+		if h.Incomplete != "" {
+			incomplete = append(incomplete, fmt.Sprintf("%s on %s is incomplete: %s", h.Pair, h.Exchange, h.Incomplete))
+			continue
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s (%s%%)\t%s\t%s\t%s\t\n",
+			h.Exchange,
+			h.Pair,
+			money(h.Invested),
+			h.Units,
+			money(h.AverageCost),
+			money(h.Price),
+			money(h.Value),
+			money(h.UnrealisedPnL),
+			money(h.UnrealisedPnLPct),
+			percent(h.XIRR),
+			money(h.LumpSum.Value),
+			percent(h.LumpSum.XIRR),
+		)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(writer, textAssetHeader)
+	for _, a := range r.Assets {
+		if a.Incomplete != "" {
+			incomplete = append(incomplete, fmt.Sprintf("%s%s is incomplete: %s", a.Asset, a.Quote, a.Incomplete))
+			continue
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s (%s%%)\t%s\t\n",
+			a.Asset,
+			a.Quote,
+			strings.Join(a.Exchanges, ","),
+			money(a.Invested),
+			a.Units,
+			money(a.AverageCost),
+			money(a.Value),
+			money(a.UnrealisedPnL),
+			money(a.UnrealisedPnLPct),
+			money(a.RealisedPnL),
+		)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	for _, line := range incomplete {
+		fmt.Fprintln(w, line)
+	}
+	return nil
+}
+
+// WriteJSON writes the report as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteHTML writes the report as a standalone HTML page.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return htmlReport.Execute(w, r)
+}
+
+func money(d decimal.Decimal) string {
+	return d.StringFixed(2)
+}
+
+func percent(rate *float64) string {
+	if rate == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f%%", *rate*100)
+}
@@ -0,0 +1,332 @@
+// Package performance reports how holdings built from processed orders have performed.
+package performance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+)
+
+var oneHundred = decimal.NewFromInt(100)
+
+// Analyser is an abstraction to report the performance of processed orders.
+type Analyser interface {
+	Analyse(ctx context.Context, processed map[string][]orders.OrderComplete, prices PriceSource, now time.Time) (*Report, error)
+}
+
+// PriceSource is an abstraction to get the current price of a pair on an exchange.
+type PriceSource interface {
+	GetPrice(ctx context.Context, exchange string, pair string) (decimal.Decimal, error)
+}
+
+// Performance reports holdings with an average cost basis.
+type Performance struct{}
+
+// Report is the performance of every holding
+// and of every asset across exchanges.
+type Report struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Holdings    []Holding      `json:"holdings"`
+	Assets      []AssetHolding `json:"assets"`
+}
+
+// Holding is the performance of a single pair on an exchange.
+//
+// The pair is normalised so every spelling of it on the exchange
+// such as XBTGBP and XXBTZGBP is the same holding of BTCGBP.
+//
+// Invested is the cost of every buy including fees and the cost basis
+// is the part of it still held after sells at the average cost.
+//
+// A holding is incomplete when its orders sell more than was bought,
+// such as when the buys were made before the orders were recorded,
+// or when there is no price for the exchange, and so only the reason
+// is reported rather than its performance.
+type Holding struct {
+	Exchange            string          `json:"exchange"`
+	Pair                string          `json:"pair"`
+	Orders              int             `json:"orders"`
+	FirstOrder          time.Time       `json:"first_order"`
+	Invested            decimal.Decimal `json:"invested"`
+	Fees                decimal.Decimal `json:"fees"`
+	Units               decimal.Decimal `json:"units"`
+	CostBasis           decimal.Decimal `json:"cost_basis"`
+	AverageCost         decimal.Decimal `json:"average_cost"`
+	Price               decimal.Decimal `json:"price"`
+	Value               decimal.Decimal `json:"value"`
+	RealisedPnL         decimal.Decimal `json:"realised_pnl"`
+	UnrealisedPnL       decimal.Decimal `json:"unrealised_pnl"`
+	UnrealisedPnLPct    decimal.Decimal `json:"unrealised_pnl_pct"`
+	XIRR                *float64        `json:"xirr,omitempty"`
+	LumpSum             LumpSum         `json:"lump_sum"`
+	LumpSumOutperformed bool            `json:"lump_sum_outperformed"`
+	Incomplete          string          `json:"incomplete,omitempty"`
+}
+
+// AssetHolding is every holding of an asset quoted in the same asset
+// summed across exchanges.
+//
+// It is incomplete when any of its holdings are and then only
+// the complete holdings are summed.
+type AssetHolding struct {
+	Asset            string          `json:"asset"`
+	Quote            string          `json:"quote"`
+	Exchanges        []string        `json:"exchanges"`
+	Orders           int             `json:"orders"`
+	Invested         decimal.Decimal `json:"invested"`
+	Fees             decimal.Decimal `json:"fees"`
+	Units            decimal.Decimal `json:"units"`
+	CostBasis        decimal.Decimal `json:"cost_basis"`
+	AverageCost      decimal.Decimal `json:"average_cost"`
+	Value            decimal.Decimal `json:"value"`
+	RealisedPnL      decimal.Decimal `json:"realised_pnl"`
+	UnrealisedPnL    decimal.Decimal `json:"unrealised_pnl"`
+	UnrealisedPnLPct decimal.Decimal `json:"unrealised_pnl_pct"`
+	Incomplete       string          `json:"incomplete,omitempty"`
+}
+
+// LumpSum is what the holding would be worth had everything
+// invested been bought with the first order instead.
+type LumpSum struct {
+	Price decimal.Decimal `json:"price"`
+	Units decimal.Decimal `json:"units"`
+	Value decimal.Decimal `json:"value"`
+	PnL   decimal.Decimal `json:"pnl"`
+	XIRR  *float64        `json:"xirr,omitempty"`
+}
+
+// Analyse builds a holding for every pair traded on each exchange
+// valued at the current price and sums them for every asset.
+//
+// Holdings on exchanges without a price source are incomplete.
+func (p Performance) Analyse(ctx context.Context, processed map[string][]orders.OrderComplete, prices PriceSource, now time.Time) (*Report, error) {
+	report := &Report{GeneratedAt: now, Holdings: []Holding{}}
+
+	exchanges := make([]string, 0, len(processed))
+	for exchange := range processed {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+
+	for _, exchange := range exchanges {
+		byPair := map[string][]orders.OrderComplete{}
+		for _, order := range processed[exchange] {
+			if order.IsOpen() || !order.Volume.IsPositive() {
+				continue
+			}
+			pair := orders.NormalisePair(order.Pair)
+			byPair[pair] = append(byPair[pair], order)
+		}
+
+		pairs := make([]string, 0, len(byPair))
+		for pair := range byPair {
+			pairs = append(pairs, pair)
+		}
+		sort.Strings(pairs)
+
+		for _, pair := range pairs {
+			// The price is asked for with the spelling the exchange last used
+			tickerPair := latestPair(byPair[pair])
+			price, err := prices.GetPrice(ctx, exchange, tickerPair)
+			if errors.Is(err, ErrNoPriceSource) {
+				report.Holdings = append(report.Holdings, Holding{
+					Exchange:   exchange,
+					Pair:       pair,
+					Orders:     len(byPair[pair]),
+					Incomplete: err.Error(),
+				})
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("could not get price of %s on %s: %w", tickerPair, exchange, err)
+			}
+
+			holding, err := analyseHolding(exchange, pair, byPair[pair], price, now)
+			if err != nil {
+				return nil, err
+			}
+			report.Holdings = append(report.Holdings, *holding)
+		}
+	}
+
+	report.Assets = rollUpAssets(report.Holdings)
+	return report, nil
+}
+
+// rollUpAssets sums the holdings of each pair across exchanges.
+func rollUpAssets(holdings []Holding) []AssetHolding {
+	byPair := map[string]*AssetHolding{}
+	pairs := []string{}
+
+	for _, holding := range holdings {
+		asset, ok := byPair[holding.Pair]
+		if !ok {
+			base, quote, split := orders.SplitPair(holding.Pair)
+			if !split {
+				base, quote = holding.Pair, ""
+			}
+			asset = &AssetHolding{Asset: base, Quote: quote, Exchanges: []string{}}
+			byPair[holding.Pair] = asset
+			pairs = append(pairs, holding.Pair)
+		}
+
+		asset.Exchanges = append(asset.Exchanges, holding.Exchange)
+		asset.Orders += holding.Orders
+		if holding.Incomplete != "" {
+			if asset.Incomplete == "" {
+				asset.Incomplete = fmt.Sprintf("holding on %s is incomplete", holding.Exchange)
+			}
+			continue
+		}
+
+		asset.Invested = asset.Invested.Add(holding.Invested)
+		asset.Fees = asset.Fees.Add(holding.Fees)
+		asset.Units = asset.Units.Add(holding.Units)
+		asset.CostBasis = asset.CostBasis.Add(holding.CostBasis)
+		asset.Value = asset.Value.Add(holding.Value)
+		asset.RealisedPnL = asset.RealisedPnL.Add(holding.RealisedPnL)
+	}
+
+	sort.Strings(pairs)
+	assets := make([]AssetHolding, 0, len(pairs))
+	for _, pair := range pairs {
+		asset := byPair[pair]
+		asset.UnrealisedPnL = asset.Value.Sub(asset.CostBasis)
+		if asset.Units.IsPositive() {
+			asset.AverageCost = asset.CostBasis.Div(asset.Units)
+		}
+		if asset.CostBasis.IsPositive() {
+			asset.UnrealisedPnLPct = asset.UnrealisedPnL.Div(asset.CostBasis).Mul(oneHundred)
+		}
+		assets = append(assets, *asset)
+	}
+
+	return assets
+}
+
+// latestPair is the pair of the most recent order.
+func latestPair(pairOrders []orders.OrderComplete) string {
+	latest := pairOrders[0]
+	for _, order := range pairOrders[1:] {
+		if orderTime(order).After(orderTime(latest)) {
+			latest = order
+		}
+	}
+	return latest.Pair
+}
+
+// analyseHolding replays the orders of a single pair in time order.
+func analyseHolding(exchange string, pair string, pairOrders []orders.OrderComplete, price decimal.Decimal, now time.Time) (*Holding, error) {
+	sort.SliceStable(pairOrders, func(i, j int) bool {
+		return orderTime(pairOrders[i]).Before(orderTime(pairOrders[j]))
+	})
+
+	holding := &Holding{Exchange: exchange, Pair: pair, Price: price}
+	flows := []CashFlow{}
+	returned := decimal.Zero
+	var first *orders.OrderComplete
+
+	for index, order := range pairOrders {
+		amount := order.Price.Mul(order.Volume)
+		holding.Orders++
+		holding.Fees = holding.Fees.Add(order.Fee)
+
+		switch strings.ToLower(order.Type) {
+		case "buy":
+			if first == nil {
+				first = &pairOrders[index]
+			}
+
+			cost := amount.Add(order.Fee)
+			holding.Invested = holding.Invested.Add(cost)
+			holding.CostBasis = holding.CostBasis.Add(cost)
+			holding.Units = holding.Units.Add(order.Volume)
+			flows = append(flows, CashFlow{Time: orderTime(order), Amount: cost.Neg()})
+
+		case "sell":
+			if order.Volume.GreaterThan(holding.Units) {
+				return &Holding{
+					Exchange:   exchange,
+					Pair:       pair,
+					Orders:     len(pairOrders),
+					Price:      price,
+					Incomplete: fmt.Sprintf("sell %s of %s is more than the %s held", order.TransactionID, order.Volume, holding.Units),
+				}, nil
+			}
+
+			proceeds := amount.Sub(order.Fee)
+			cost := holding.CostBasis.Mul(order.Volume).Div(holding.Units)
+			holding.RealisedPnL = holding.RealisedPnL.Add(proceeds.Sub(cost))
+			holding.CostBasis = holding.CostBasis.Sub(cost)
+			holding.Units = holding.Units.Sub(order.Volume)
+			returned = returned.Add(proceeds)
+			flows = append(flows, CashFlow{Time: orderTime(order), Amount: proceeds})
+
+		default:
+			return nil, fmt.Errorf("unsupported direction %s for transaction %s", order.Type, order.TransactionID)
+		}
+	}
+
+	if first == nil {
+		return holding, nil
+	}
+	holding.FirstOrder = orderTime(*first)
+
+	holding.Value = holding.Units.Mul(price)
+	holding.UnrealisedPnL = holding.Value.Sub(holding.CostBasis)
+	if holding.Units.IsPositive() {
+		holding.AverageCost = holding.CostBasis.Div(holding.Units)
+	}
+	if holding.CostBasis.IsPositive() {
+		holding.UnrealisedPnLPct = holding.UnrealisedPnL.Div(holding.CostBasis).Mul(oneHundred)
+	}
+
+	flows = append(flows, CashFlow{Time: now, Amount: holding.Value})
+	if rate, err := XIRR(flows); err == nil {
+		holding.XIRR = &rate
+	}
+
+	holding.LumpSum = lumpSum(*first, holding.Invested, price, now)
+	holding.LumpSumOutperformed = holding.LumpSum.Value.GreaterThan(holding.Value.Add(returned))
+
+	return holding, nil
+}
+
+// lumpSum buys everything invested at the price of the first order
+// paying the same rate of fees as it did.
+func lumpSum(first orders.OrderComplete, invested decimal.Decimal, price decimal.Decimal, now time.Time) LumpSum {
+	benchmark := LumpSum{Price: first.Price}
+	if !first.Price.IsPositive() {
+		return benchmark
+	}
+
+	feeRate := first.Fee.Div(first.Price.Mul(first.Volume))
+	benchmark.Units = invested.Div(first.Price.Mul(decimal.NewFromInt(1).Add(feeRate))).Truncate(8)
+	benchmark.Value = benchmark.Units.Mul(price)
+	benchmark.PnL = benchmark.Value.Sub(invested)
+
+	flows := []CashFlow{
+		{Time: orderTime(first), Amount: invested.Neg()},
+		{Time: now, Amount: benchmark.Value},
+	}
+	if rate, err := XIRR(flows); err == nil {
+		benchmark.XIRR = &rate
+	}
+
+	return benchmark
+}
+
+// orderTime is when the order closed, falling back to when it opened.
+func orderTime(order orders.OrderComplete) time.Time {
+	closed := order.CloseTime
+	if closed == 0 {
+		closed = order.OpenTime
+	}
+	return time.Unix(0, int64(closed*float64(time.Second))).UTC()
+}
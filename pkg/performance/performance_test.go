@@ -0,0 +1,257 @@
+package performance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticPrices map[string]decimal.Decimal
+
+func (s staticPrices) GetPrice(ctx context.Context, exchange string, pair string) (decimal.Decimal, error) {
+	price, ok := s[exchange+"/"+pair]
+	if !ok {
+		return decimal.Zero, errors.New("no ticker")
+	}
+	return price, nil
+}
+
+// exchangePrices only has a price source for some exchanges
+type exchangePrices map[string]staticPrices
+
+func (e exchangePrices) GetPrice(ctx context.Context, exchange string, pair string) (decimal.Decimal, error) {
+	prices, ok := e[exchange]
+	if !ok {
+		return decimal.Zero, fmt.Errorf("%w for exchange %s", ErrNoPriceSource, exchange)
+	}
+	return prices.GetPrice(ctx, exchange, pair)
+}
+
+func fill(txid string, date string, direction string, volume string, price string, fee string) orders.OrderComplete {
+	closed, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		panic(err)
+	}
+
+	return orders.OrderComplete{
+		TransactionID:  txid,
+		ExchangeStatus: "closed",
+		Pair:           "XXBTZGBP",
+		Type:           direction,
+		OrderType:      "market",
+		Price:          decimal.RequireFromString(price),
+		Fee:            decimal.RequireFromString(fee),
+		Volume:         decimal.RequireFromString(volume),
+		CloseTime:      float64(closed.Unix()),
+	}
+}
+
+func sampleReport(t *testing.T) *Report {
+	processed := map[string][]orders.OrderComplete{
+		"kraken": {
+			fill("C", "2021-10-01", "sell", "0.5", "300", "1.5"),
+			fill("A", "2021-01-01", "buy", "1", "100", "1"),
+			fill("B", "2021-07-01", "buy", "1", "200", "2"),
+		},
+	}
+	prices := staticPrices{"kraken/XXBTZGBP": decimal.NewFromInt(400)}
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	report, err := Performance{}.Analyse(context.Background(), processed, prices, now)
+	assert.Nil(t, err)
+	return report
+}
+
+// Ensures holdings are built with an average cost basis
+// and compared against investing everything up front
+func TestAnalyse(t *testing.T) {
+	report := sampleReport(t)
+	assert.Equal(t, 1, len(report.Holdings))
+
+	holding := report.Holdings[0]
+	assert.Equal(t, "kraken", holding.Exchange)
+	assert.Equal(t, 3, holding.Orders)
+	assert.Equal(t, "2021-01-01", holding.FirstOrder.Format("2006-01-02"))
+	assert.Equal(t, "303", holding.Invested.String())
+	assert.Equal(t, "4.5", holding.Fees.String())
+	assert.Equal(t, "1.5", holding.Units.String())
+	assert.Equal(t, "227.25", holding.CostBasis.String())
+	assert.Equal(t, "151.5", holding.AverageCost.String())
+	assert.Equal(t, "600", holding.Value.String())
+	assert.Equal(t, "72.75", holding.RealisedPnL.String())
+	assert.Equal(t, "372.75", holding.UnrealisedPnL.String())
+	assert.Equal(t, "164.03", holding.UnrealisedPnLPct.StringFixed(2))
+	assert.NotNil(t, holding.XIRR)
+	assert.Greater(t, *holding.XIRR, 0.0)
+
+	assert.Equal(t, "3", holding.LumpSum.Units.String())
+	assert.Equal(t, "1200", holding.LumpSum.Value.String())
+	assert.Equal(t, "897", holding.LumpSum.PnL.String())
+	assert.InDelta(t, 2.96, *holding.LumpSum.XIRR, 0.01)
+	assert.True(t, holding.LumpSumOutperformed)
+}
+
+// Ensures missing prices are errors
+func TestAnalyseInvalid(t *testing.T) {
+	processed := map[string][]orders.OrderComplete{"kraken": {fill("A", "2021-01-01", "buy", "1", "100", "1")}}
+	_, err := Performance{}.Analyse(context.Background(), processed, staticPrices{}, time.Now())
+	assert.Contains(t, err.Error(), "could not get price of XXBTZGBP on kraken: no ticker")
+}
+
+// Ensures a holding selling more than was bought is reported as incomplete
+// without failing the holdings of other exchanges
+func TestAnalyseIncomplete(t *testing.T) {
+	processed := map[string][]orders.OrderComplete{
+		"kraken":   {fill("A", "2021-01-01", "buy", "1", "100", "1")},
+		"coinbase": {fill("B", "2021-01-01", "buy", "1", "100", "1"), fill("C", "2021-02-01", "sell", "2", "100", "1")},
+	}
+	prices := staticPrices{"kraken/XXBTZGBP": decimal.NewFromInt(200), "coinbase/XXBTZGBP": decimal.NewFromInt(200)}
+
+	report, err := Performance{}.Analyse(context.Background(), processed, prices, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(report.Holdings))
+	assert.Equal(t, "coinbase", report.Holdings[0].Exchange)
+	assert.Equal(t, "sell C of 2 is more than the 1 held", report.Holdings[0].Incomplete)
+	assert.Equal(t, 2, report.Holdings[0].Orders)
+	assert.True(t, report.Holdings[0].Value.IsZero())
+	assert.Equal(t, "200", report.Holdings[1].Value.String())
+	assert.Empty(t, report.Holdings[1].Incomplete)
+
+	var text bytes.Buffer
+	assert.Nil(t, report.Write(&text, FormatText))
+	lines := strings.Split(strings.TrimSpace(text.String()), "\n")
+	assert.Equal(t, 6, len(lines))
+	assert.True(t, strings.HasPrefix(lines[1], "kraken"))
+	assert.True(t, strings.HasPrefix(lines[3], "ASSET"))
+	assert.Equal(t, "BTCGBP on coinbase is incomplete: sell C of 2 is more than the 1 held", lines[4])
+	assert.Equal(t, "BTCGBP is incomplete: holding on coinbase is incomplete", lines[5])
+
+	var html bytes.Buffer
+	assert.Nil(t, report.Write(&html, FormatHTML))
+	assert.Equal(t, 1, strings.Count(html.String(), "<td>BTCGBP</td>"))
+	assert.Contains(t, html.String(), "BTCGBP on coinbase is incomplete")
+	assert.Contains(t, html.String(), "BTCGBP is incomplete: holding on coinbase is incomplete")
+}
+
+// Ensures every spelling of a pair is a single holding priced with the latest spelling,
+// holdings are summed for each asset across exchanges and holdings on exchanges
+// without a price source are incomplete
+func TestAnalyseAssets(t *testing.T) {
+	spelling := func(order orders.OrderComplete, pair string) orders.OrderComplete {
+		order.Pair = pair
+		return order
+	}
+
+	processed := map[string][]orders.OrderComplete{
+		"kraken": {
+			spelling(fill("A", "2021-01-01", "buy", "1", "100", "1"), "XBTGBP"),
+			fill("B", "2021-07-01", "buy", "1", "200", "2"),
+		},
+		"coinbase": {spelling(fill("C", "2021-03-01", "buy", "2", "150", "3"), "BTC-GBP")},
+		"bitstamp": {spelling(fill("D", "2021-03-01", "buy", "1", "1000", "1"), "ETHGBP")},
+	}
+	prices := exchangePrices{
+		"kraken":   staticPrices{"kraken/XXBTZGBP": decimal.NewFromInt(400)},
+		"coinbase": staticPrices{"coinbase/BTC-GBP": decimal.NewFromInt(400)},
+	}
+
+	report, err := Performance{}.Analyse(context.Background(), processed, prices, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(report.Holdings))
+	assert.Equal(t, "bitstamp", report.Holdings[0].Exchange)
+	assert.Equal(t, "ETHGBP", report.Holdings[0].Pair)
+	assert.Equal(t, "no price source for exchange bitstamp", report.Holdings[0].Incomplete)
+	assert.Equal(t, "coinbase", report.Holdings[1].Exchange)
+	assert.Equal(t, "BTCGBP", report.Holdings[1].Pair)
+	assert.Equal(t, "kraken", report.Holdings[2].Exchange)
+	assert.Equal(t, "BTCGBP", report.Holdings[2].Pair)
+	assert.Equal(t, 2, report.Holdings[2].Orders)
+	assert.Equal(t, "800", report.Holdings[2].Value.String())
+
+	assert.Equal(t, 2, len(report.Assets))
+	btc := report.Assets[0]
+	assert.Equal(t, "BTC", btc.Asset)
+	assert.Equal(t, "GBP", btc.Quote)
+	assert.Equal(t, []string{"coinbase", "kraken"}, btc.Exchanges)
+	assert.Equal(t, 3, btc.Orders)
+	assert.Equal(t, "606", btc.Invested.String())
+	assert.Equal(t, "4", btc.Units.String())
+	assert.Equal(t, "151.5", btc.AverageCost.String())
+	assert.Equal(t, "1600", btc.Value.String())
+	assert.Equal(t, "994", btc.UnrealisedPnL.String())
+	assert.Empty(t, btc.Incomplete)
+
+	eth := report.Assets[1]
+	assert.Equal(t, "ETH", eth.Asset)
+	assert.Equal(t, []string{"bitstamp"}, eth.Exchanges)
+	assert.Equal(t, "holding on bitstamp is incomplete", eth.Incomplete)
+}
+
+// Ensures the annualised return of irregular cash flows is found
+func TestXIRR(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rate, err := XIRR([]CashFlow{
+		{Time: start, Amount: decimal.NewFromInt(-100)},
+		{Time: start.AddDate(0, 0, 365), Amount: decimal.NewFromInt(110)},
+	})
+	assert.Nil(t, err)
+	assert.InDelta(t, 0.1, rate, 1e-6)
+
+	rate, err = XIRR([]CashFlow{
+		{Time: start, Amount: decimal.NewFromInt(-1000)},
+		{Time: start.AddDate(0, 0, 182), Amount: decimal.NewFromInt(-1000)},
+		{Time: start.AddDate(0, 0, 365), Amount: decimal.NewFromInt(1500)},
+	})
+	assert.Nil(t, err)
+	assert.Less(t, rate, -0.3)
+	assert.False(t, math.IsNaN(rate))
+
+	_, err = XIRR([]CashFlow{{Time: start, Amount: decimal.NewFromInt(-100)}, {Time: start, Amount: decimal.NewFromInt(-100)}})
+	assert.Contains(t, err.Error(), "xirr needs both positive and negative cash flows")
+
+	_, err = XIRR([]CashFlow{{Time: start, Amount: decimal.NewFromInt(-100)}, {Time: start, Amount: decimal.NewFromInt(100)}})
+	assert.Contains(t, err.Error(), "xirr needs cash flows on different days")
+}
+
+// Ensures the report can be written as text, JSON and HTML
+func TestReportWrite(t *testing.T) {
+	report := sampleReport(t)
+
+	var text bytes.Buffer
+	assert.Nil(t, report.Write(&text, FormatText))
+	lines := strings.Split(strings.TrimSpace(text.String()), "\n")
+	assert.Equal(t, 5, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "EXCHANGE"))
+	assert.Contains(t, lines[1], "372.75 (164.03%)")
+	assert.Contains(t, lines[1], "1200.00")
+	assert.True(t, strings.HasPrefix(lines[3], "ASSET"))
+	assert.True(t, strings.HasPrefix(lines[4], "BTC"))
+	assert.Contains(t, lines[4], "72.75")
+
+	var jsonOutput bytes.Buffer
+	assert.Nil(t, report.Write(&jsonOutput, FormatJSON))
+	decoded := Report{}
+	assert.Nil(t, json.Unmarshal(jsonOutput.Bytes(), &decoded))
+	assert.Equal(t, "600", decoded.Holdings[0].Value.String())
+
+	var html bytes.Buffer
+	assert.Nil(t, report.Write(&html, FormatHTML))
+	assert.Contains(t, html.String(), "<td>BTCGBP</td>")
+	assert.Contains(t, html.String(), "<td>151.50</td>")
+	assert.Contains(t, html.String(), "<td>BTC</td><td>GBP</td><td>kraken</td>")
+
+	assert.Contains(t, report.Write(&html, "pdf").Error(), "unsupported report format pdf")
+}
@@ -0,0 +1,40 @@
+package performance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+)
+
+// ErrNoPriceSource is when there is no way to price the pairs of an exchange
+// such as for exchanges which were only imported.
+var ErrNoPriceSource = errors.New("no price source")
+
+// TickerPrices gets current prices from the last trade
+// on the ticker of each exchange.
+type TickerPrices struct {
+	Orderers map[string]orders.Orderer
+}
+
+// GetPrice gets the last traded price of the pair on the exchange.
+func (t TickerPrices) GetPrice(ctx context.Context, exchange string, pair string) (decimal.Decimal, error) {
+	orderer, ok := t.Orderers[exchange]
+	if !ok {
+		return decimal.Zero, fmt.Errorf("%w for exchange %s", ErrNoPriceSource, exchange)
+	}
+
+	market, ok := orderer.(orders.MarketData)
+	if !ok {
+		return decimal.Zero, fmt.Errorf("%w for exchange %s as it does not provide market data", ErrNoPriceSource, exchange)
+	}
+
+	ticker, err := market.GetTicker(ctx, pair)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return ticker.Last, nil
+}
@@ -0,0 +1,107 @@
+package performance
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	xirrTolerance     = 1e-9
+	xirrMaxIterations = 100
+	daysPerYear       = 365.0
+)
+
+// CashFlow is money paid (negative) or received (positive) at a time.
+type CashFlow struct {
+	Time   time.Time
+	Amount decimal.Decimal
+}
+
+// XIRR is the annualised internal rate of return of cash flows
+// which are not evenly spaced e.g 0.1 is 10% a year.
+func XIRR(flows []CashFlow) (float64, error) {
+	if len(flows) < 2 {
+		return 0, errors.New("xirr needs at least two cash flows")
+	}
+
+	start := flows[0].Time
+	years := make([]float64, len(flows))
+	amounts := make([]float64, len(flows))
+	hasPositive, hasNegative := false, false
+	for i, flow := range flows {
+		if flow.Time.Before(start) {
+			start = flow.Time
+		}
+		amounts[i] = flow.Amount.InexactFloat64()
+		hasPositive = hasPositive || amounts[i] > 0
+		hasNegative = hasNegative || amounts[i] < 0
+	}
+
+	if !hasPositive || !hasNegative {
+		return 0, errors.New("xirr needs both positive and negative cash flows")
+	}
+
+	span := 0.0
+	for i, flow := range flows {
+		years[i] = flow.Time.Sub(start).Hours() / 24 / daysPerYear
+		span = math.Max(span, years[i])
+	}
+	if span == 0 {
+		return 0, errors.New("xirr needs cash flows on different days")
+	}
+
+	npv := func(rate float64) (float64, float64) {
+		value, derivative := 0.0, 0.0
+		for i := range amounts {
+			discount := math.Pow(1+rate, years[i])
+			value += amounts[i] / discount
+			derivative -= years[i] * amounts[i] / (discount * (1 + rate))
+		}
+		return value, derivative
+	}
+
+	// Newton's method converges quickly from a sensible guess
+	rate := 0.1
+	for i := 0; i < xirrMaxIterations; i++ {
+		value, derivative := npv(rate)
+		if math.Abs(value) < xirrTolerance {
+			return rate, nil
+		}
+		if derivative == 0 {
+			break
+		}
+
+		next := rate - value/derivative
+		if next <= -1 || math.IsNaN(next) || math.IsInf(next, 0) {
+			break
+		}
+		rate = next
+	}
+
+	// Otherwise fall back to bisection which always converges once bracketed
+	low, high := -0.999999, 1e6
+	lowValue, _ := npv(low)
+	highValue, _ := npv(high)
+	if lowValue*highValue > 0 {
+		return 0, errors.New("xirr did not converge")
+	}
+
+	for i := 0; i < 1000; i++ {
+		rate = (low + high) / 2
+		value, _ := npv(rate)
+		if math.Abs(value) < xirrTolerance || (high-low)/2 < xirrTolerance {
+			return rate, nil
+		}
+
+		if value*lowValue > 0 {
+			low, lowValue = rate, value
+		} else {
+			high = rate
+		}
+	}
+
+	return rate, nil
+}
@@ -0,0 +1,817 @@
+// Package processor gets the details of the orders placed on exchanges
+// from the queue, uploads them to S3 and loads them into the DataLake.
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	awsEvents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/logging"
+	"github.com/kiran94/dca-manager/pkg/metrics"
+	"github.com/kiran94/dca-manager/pkg/notify"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/kiran94/dca-manager/pkg/strategy"
+	"github.com/kiran94/dca-manager/pkg/tracing"
+	"github.com/kiran94/dca-manager/pkg/withdrawal"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// parentRollUpAttempts is how many times the roll up of a parent is rebuilt
+// while other children are still being recorded before the message is retried.
+const parentRollUpAttempts = 3
+
+// DCAServices contains all services to be injected into logic.
+type DCAServices struct {
+	awsConfig             aws.Config
+	s3Access              pkg.S3Access
+	ssmAccess             pkg.SSMAccess
+	sqsAccess             pkg.SQSAccess
+	glueAccess            pkg.GlueAccess
+	configSource          configuration.DCAConfigurationSource
+	ordererFactory        orders.OrdererFactory
+	pendingOrderSubmitter orders.PendingOrderQueue
+	withdrawalSweeper     withdrawal.Sweeper
+	notifier              notify.Notifier
+	metrics               *metrics.Registry
+}
+
+// AppConfig contains all configuration to be injected into logic
+type AppConfig struct {
+	s3bucket      string
+	dcaConfigPath string
+	allowReal     bool
+	transactions  struct {
+		pendingS3TransactionPrefix   string
+		processedS3TransactionPrefix string
+	}
+	queue struct {
+		sqsURL string
+	}
+	glue struct {
+		processTransactionJob       string
+		processTransactionOperation string
+	}
+	withdrawals struct {
+		s3Prefix string
+	}
+}
+
+// NewDCAServices creates the services used to process orders on AWS
+// where pending orders are received from the queue and metrics are recorded in the registry.
+func NewDCAServices(awsConfig aws.Config, queue pkg.SQSAccess, registry *metrics.Registry) *DCAServices {
+	return &DCAServices{
+		awsConfig:             awsConfig,
+		s3Access:              pkg.S3{Client: s3.NewFromConfig(awsConfig)},
+		ssmAccess:             pkg.SSM{Client: ssm.NewFromConfig(awsConfig)},
+		sqsAccess:             queue,
+		glueAccess:            pkg.Glue{Client: glue.NewFromConfig(awsConfig)},
+		configSource:          configuration.DCAConfiguration{},
+		ordererFactory:        orders.OrdererFac{Metrics: registry},
+		pendingOrderSubmitter: orders.PendingOrderSubmitter{},
+		withdrawalSweeper:     withdrawal.ColdStorage{},
+		notifier:              notify.FromEnvironment(pkg.SNS{Client: sns.NewFromConfig(awsConfig)}),
+		metrics:               registry,
+	}
+}
+
+// NewAppConfig creates the configuration from the environment.
+func NewAppConfig() *AppConfig {
+	appConfig := &AppConfig{
+		s3bucket:      os.Getenv(configuration.EnvS3Bucket),
+		dcaConfigPath: os.Getenv(configuration.EnvS3ConfigPath),
+		allowReal:     os.Getenv(configuration.EnvAllowReal) != "",
+	}
+	appConfig.transactions.pendingS3TransactionPrefix = os.Getenv(configuration.EnvS3PendingTransaction)
+	appConfig.transactions.processedS3TransactionPrefix = os.Getenv(configuration.EnvS3ProcessedTransaction)
+	appConfig.queue.sqsURL = os.Getenv(configuration.EnvSQSPendingOrdersQueue)
+	appConfig.glue.processTransactionJob = os.Getenv(configuration.EnvGlueProcessTransactionJob)
+	appConfig.glue.processTransactionOperation = os.Getenv(configuration.EnvGlueProcessTransactionOperation)
+	appConfig.withdrawals.s3Prefix = os.Getenv(configuration.EnvS3Withdrawal)
+
+	return appConfig
+}
+
+// ProcessTransactions reads transactions from the queue
+// and gets the details of those transactions from the downstream exchange
+// these details are pushed to S3 and an Anaytics job is created
+// to ingest it into the DataLake. Processed fills are announced.
+func ProcessTransactions(ctx context.Context, dcaServices *DCAServices, appConfig *AppConfig, sqsEvent awsEvents.SQSEvent) (err error) {
+	logging.FromContext(ctx).Info("Processing Transaction Details")
+
+	ctx, span := tracing.Start(ctx, "ProcessTransactions", attribute.Int("sqs.messages", len(sqsEvent.Records)))
+	defer func() { tracing.End(span, err) }()
+
+	fillsEvent := notify.Event{Kind: notify.KindFills, Time: time.Now().UTC(), Real: true}
+	defer func() {
+		if err != nil {
+			fillsEvent.Errors = append(fillsEvent.Errors, err.Error())
+		}
+		notifyFills(ctx, dcaServices, fillsEvent)
+	}()
+
+	if len(sqsEvent.Records) == 0 {
+		return fmt.Errorf("no sqs messages found, returning")
+	}
+
+	o, err := dcaServices.ordererFactory.GetOrderers(ctx, dcaServices.ssmAccess)
+	if err != nil {
+		return err
+	}
+
+	// Process Each of the SQS Messages, the message being
+	// processed when an error is returned is counted as failed
+	processedReal := false
+	messageExchange := ""
+	defer func() {
+		if err != nil && messageExchange != "" {
+			recordMessage(dcaServices, messageExchange, "failed")
+		}
+	}()
+
+	var messageSpan trace.Span
+	defer func() {
+		if messageSpan != nil {
+			tracing.End(messageSpan, err)
+		}
+	}()
+
+	for _, message := range sqsEvent.Records {
+		// Extract Details from the Message
+		exchange := message.MessageAttributes["Exchange"]
+		realAtt := message.MessageAttributes["Real"]
+		messageExchange = aws.ToString(exchange.StringValue)
+
+		// Each message is linked to the trace of the run which sent it
+		if messageSpan != nil {
+			messageSpan.End()
+		}
+		var messageCtx context.Context
+		messageCtx, messageSpan = tracing.StartLinked(ctx, tracing.ExtractSQS(ctx, message.MessageAttributes), "ProcessMessage",
+			attribute.String("sqs.message_id", message.MessageId),
+			attribute.String("exchange", messageExchange),
+		)
+		ctx := messageCtx
+
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"messageId":      message.MessageId,
+			"eventSourceArn": message.EventSourceARN,
+			"exchange":       *exchange.StringValue,
+			"real":           *realAtt.StringValue,
+		}).Info("Processing SQS Message")
+
+		// If the message is a fake/testing message, mark as deleted and continue
+		if *realAtt.StringValue == "false" {
+			logging.FromContext(ctx).WithFields(logrus.Fields{
+				"messageId": message.MessageId,
+				"queue":     message.EventSourceARN,
+			}).Warn("Received SQS message which was not real. Deleting from Queue.")
+
+			_, err = dcaServices.sqsAccess.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      &message.EventSourceARN,
+				ReceiptHandle: &message.ReceiptHandle,
+			})
+
+			if err != nil {
+				return err
+			}
+			recordMessage(dcaServices, messageExchange, "simulated")
+			continue
+		}
+
+		if exchange.StringValue == nil || *exchange.StringValue == "" {
+			return fmt.Errorf("received sqs message with no exchange set. Skipping message %s", message.MessageId)
+		}
+
+		messageBytes := []byte(message.Body)
+
+		var po orders.PendingOrders
+		err := json.Unmarshal(messageBytes, &po)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Unable to unmarshal json from Message %s", message.MessageId)
+			return err
+		}
+
+		// Correlate everything logged for the message with the run which placed it
+		ctx = logging.WithFields(ctx, logrus.Fields{
+			logging.FieldRunID: po.RunID,
+			"transactionId":    po.TransactionID,
+		})
+
+		// Process the Transaction
+		logging.FromContext(ctx).WithField("exchange", *exchange.StringValue).Info("Processing Transaction")
+
+		exchangeOrderer, ok := (*o)[*exchange.StringValue]
+		if !ok {
+			return fmt.Errorf("exchange %s was not configured", *exchange.StringValue)
+		}
+
+		if po.IsScheduledSlice() {
+			if err := placeScheduledSlice(ctx, dcaServices, appConfig, exchangeOrderer, &po, *exchange.StringValue); err != nil {
+				return err
+			}
+
+			logging.FromContext(ctx).WithFields(logrus.Fields{
+				"messageId":      message.MessageId,
+				"eventSourceArn": message.EventSourceARN,
+			}).Info("Deleting Scheduled Slice Message from Queue")
+
+			_, err = dcaServices.sqsAccess.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      &message.EventSourceARN,
+				ReceiptHandle: &message.ReceiptHandle,
+			})
+
+			if err != nil {
+				return err
+			}
+			recordMessage(dcaServices, messageExchange, "scheduled")
+			continue
+		}
+
+		messageSpan.SetAttributes(attribute.String("transaction.id", po.TransactionID))
+		_, processSpan := tracing.Start(ctx, "ProcessTransaction", attribute.String("transaction.id", po.TransactionID))
+		orders, err := exchangeOrderer.ProcessTransaction(ctx, po.TransactionID)
+		tracing.End(processSpan, err)
+		if err != nil {
+			return err
+		}
+		logging.FromContext(ctx).WithField("order", orders).Debug("Orders from processed transaction")
+
+		if po.ExpireAt > 0 {
+			requeued, expiredOrders, err := expireOrders(ctx, dcaServices, appConfig, exchangeOrderer, &po, *exchange.StringValue, orders)
+			if err != nil {
+				return err
+			}
+
+			if requeued {
+				logging.FromContext(ctx).WithFields(logrus.Fields{
+					"messageId":      message.MessageId,
+					"eventSourceArn": message.EventSourceARN,
+				}).Info("Deleting Requeued Message from Queue")
+
+				_, err = dcaServices.sqsAccess.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+					QueueUrl:      &message.EventSourceARN,
+					ReceiptHandle: &message.ReceiptHandle,
+				})
+
+				if err != nil {
+					return err
+				}
+				recordMessage(dcaServices, messageExchange, "requeued")
+				continue
+			}
+
+			orders = expiredOrders
+		}
+
+		// Upload Details to S3
+		s3Bucket := appConfig.s3bucket
+		s3PathPrefix := appConfig.transactions.processedS3TransactionPrefix
+
+		for _, order := range *orders {
+
+			if order.TransactionID == "" {
+				logging.FromContext(ctx).Warnf("Found an order with no transaction id: %v", order)
+				continue
+			}
+
+			s3Path := fmt.Sprintf(
+				"%s/exchange=%s/%s.json",
+				s3PathPrefix,
+				strings.ToLower(*exchange.StringValue),
+				order.TransactionID,
+			)
+
+			order.ParentID = po.ParentID
+			orderBytes, err := json.Marshal(order)
+			if err != nil {
+				return err
+			}
+
+			logging.FromContext(ctx).WithFields(logrus.Fields{
+				"transactionId": order.TransactionID,
+				"s3bucket":      s3Bucket,
+				"s3path":        s3Path,
+			}).Info("Uploading Transaction result to S3")
+
+			_, err = dcaServices.s3Access.PutObject(ctx, &s3.PutObjectInput{
+				Bucket: &s3Bucket,
+				Key:    &s3Path,
+				Body:   bytes.NewReader(orderBytes),
+			})
+
+			if err != nil {
+				return err
+			}
+
+			if order.ExchangeStatus == "closed" {
+				fillsEvent.Orders = append(fillsEvent.Orders, newFillEvent(order, *exchange.StringValue))
+				recordFill(dcaServices, order)
+			}
+
+			// Loading into the DataLake is optional such as when running as a daemon without Glue
+			if appConfig.glue.processTransactionJob == "" {
+				logging.FromContext(ctx).WithField("transactionId", order.TransactionID).Debug("No Glue Job configured, skipping")
+				continue
+			}
+
+			// Since we are passing the absolute complete path for the loaded JSON file
+			// the spark won't be able to derive any hive partition columns
+			// so here we are adding the exchange as an additional column
+			additionalColumns := map[string]string{"exchange": strings.ToLower(*exchange.StringValue)}
+			additionalColumnsJSON, addErr := json.Marshal(additionalColumns)
+			if addErr != nil {
+				return addErr
+			}
+
+			// Submit Glue Job
+			jobName := appConfig.glue.processTransactionJob
+			jobArguments := map[string]string{
+				"--input_path":         fmt.Sprintf("s3a://%s/%s", s3Bucket, s3Path),
+				"--write_operation":    appConfig.glue.processTransactionOperation,
+				"--additional_columns": string(additionalColumnsJSON),
+			}
+
+			logging.FromContext(ctx).WithFields(logrus.Fields{
+				"glueJobName":       jobName,
+				"inputS3Bucket":     s3Bucket,
+				"inputPath":         s3Path,
+				"writeOperation":    jobArguments["--write_operation"],
+				"additionalColumns": jobArguments["--additional_columns"],
+			}).Info("Submitting Glue Job")
+
+			submittedJob, glueStartErr := dcaServices.glueAccess.StartJobRun(ctx, &glue.StartJobRunInput{
+				JobName:   &jobName,
+				Arguments: jobArguments,
+			})
+
+			if glueStartErr != nil {
+				return glueStartErr
+			}
+			dcaServices.metrics.Add(metrics.GlueJobsTotal, 1, metrics.Labels{"job": jobName})
+
+			logging.FromContext(ctx).WithFields(logrus.Fields{
+				"transactionId": order.TransactionID,
+				"glueJobId":     *submittedJob.JobRunId,
+			}).Info("Glue Job Submitted")
+		}
+
+		if po.ParentID != "" {
+			if err := rollUpParent(ctx, dcaServices, appConfig, &po, *exchange.StringValue, orders); err != nil {
+				return err
+			}
+		}
+
+		// Delete from Queue
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"messageId":      message.MessageId,
+			"eventSourceArn": message.EventSourceARN,
+		}).Info("Deleting Message from Queue")
+
+		dcaServices.sqsAccess.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      &message.EventSourceARN,
+			ReceiptHandle: &message.ReceiptHandle,
+		})
+
+		recordMessage(dcaServices, messageExchange, "processed")
+		processedReal = true
+	}
+	messageExchange = ""
+	if messageSpan != nil {
+		messageSpan.End()
+		messageSpan = nil
+	}
+
+	// Withdrawals are best effort as the orders have already been processed
+	if processedReal && appConfig.withdrawals.s3Prefix != "" {
+		if err := sweepWithdrawals(ctx, dcaServices, appConfig, o); err != nil {
+			logging.FromContext(ctx).WithError(err).Error("Error Sweeping Withdrawals")
+		}
+	}
+
+	return nil
+}
+
+// newFillEvent describes an order which filled on the exchange.
+func newFillEvent(order orders.OrderComplete, exchange string) notify.OrderEvent {
+	amount := order.Volume.Mul(order.Price)
+	price, fee := order.Price, order.Fee
+
+	return notify.OrderEvent{
+		Exchange:      exchange,
+		Pair:          order.Pair,
+		Direction:     order.Type,
+		OrderType:     order.OrderType,
+		Volume:        order.Volume.String(),
+		Price:         &price,
+		Amount:        &amount,
+		Fee:           &fee,
+		TransactionID: order.TransactionID,
+		Status:        notify.StatusFilled,
+	}
+}
+
+// recordFill counts the spend and fees of a filled order in its quote currency.
+func recordFill(dcaServices *DCAServices, order orders.OrderComplete) {
+	labels := metrics.Labels{"quote": metrics.QuoteCurrency(order.Pair)}
+
+	if order.Type == "buy" {
+		spend, _ := order.Volume.Mul(order.Price).Float64()
+		dcaServices.metrics.Add(metrics.SpendTotal, spend, labels)
+	}
+
+	fee, _ := order.Fee.Float64()
+	dcaServices.metrics.Add(metrics.FeesTotal, fee, labels)
+}
+
+// recordMessage counts a SQS message which was processed with the outcome.
+func recordMessage(dcaServices *DCAServices, exchange string, outcome string) {
+	dcaServices.metrics.Add(metrics.SQSMessagesTotal, 1, metrics.Labels{"exchange": exchange, "outcome": outcome})
+}
+
+// notifyFills announces the processed fills when there is anything to
+// announce, a failure to announce does not fail the processing.
+func notifyFills(ctx context.Context, dcaServices *DCAServices, event notify.Event) {
+	if len(event.Orders) == 0 && len(event.Errors) == 0 {
+		return
+	}
+
+	event.Title = fmt.Sprintf("DCA fills: %d orders filled, %d errors", len(event.Orders), len(event.Errors))
+	if err := dcaServices.notifier.Notify(ctx, event); err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("Could not send fills notification")
+	}
+}
+
+// sweepWithdrawals withdraws balances to cold storage
+// for the assets which have withdrawals configured.
+func sweepWithdrawals(ctx context.Context, dcaServices *DCAServices, appConfig *AppConfig, o *map[string]orders.Orderer) error {
+	dcaConf, err := dcaServices.configSource.GetDCAConfiguration(ctx, dcaServices.s3Access, &appConfig.s3bucket, &appConfig.dcaConfigPath)
+	if err != nil {
+		return err
+	}
+
+	if len(dcaConf.Withdrawals) == 0 {
+		return nil
+	}
+
+	logging.FromContext(ctx).WithField("assets", len(dcaConf.Withdrawals)).Info("Sweeping Withdrawals")
+
+	withdrawn, err := dcaServices.withdrawalSweeper.Sweep(ctx, dcaServices.s3Access, appConfig.s3bucket, appConfig.withdrawals.s3Prefix, dcaConf.Withdrawals, *o)
+	for _, w := range withdrawn {
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"refId":  w.RefID,
+			"asset":  w.Asset,
+			"amount": w.Amount,
+			"fee":    w.Fee,
+		}).Info("Withdrew to Cold Storage")
+	}
+
+	return err
+}
+
+// expireOrders handles orders which should be cancelled when they expire.
+//
+// Orders which are still open before they expire are requeued to be checked again later.
+// Once expired, open orders are cancelled and when configured, the remaining
+// volume of cancelled orders is replaced with a market order.
+func expireOrders(ctx context.Context, dcaServices *DCAServices, appConfig *AppConfig, orderer orders.Orderer, po *orders.PendingOrders, exchange string, processed *[]orders.OrderComplete) (bool, *[]orders.OrderComplete, error) {
+	cancelled := false
+	for _, order := range *processed {
+		if !order.IsOpen() {
+			continue
+		}
+
+		if time.Now().Unix() < po.ExpireAt {
+			logging.FromContext(ctx).WithFields(logrus.Fields{
+				"transactionId": order.TransactionID,
+				"status":        order.ExchangeStatus,
+				"expireAt":      po.ExpireAt,
+			}).Info("Order still open before expiry, requeuing")
+
+			err := dcaServices.pendingOrderSubmitter.SubmitPendingOrder(ctx, dcaServices.sqsAccess, po, exchange, true, appConfig.queue.sqsURL)
+			return err == nil, nil, err
+		}
+
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"transactionId": order.TransactionID,
+			"status":        order.ExchangeStatus,
+			"expireAt":      po.ExpireAt,
+		}).Warn("Order expired, cancelling")
+
+		if err := orderer.CancelOrder(ctx, order.TransactionID); err != nil {
+			return false, nil, err
+		}
+		cancelled = true
+	}
+
+	// Reload so the cancelled status and final executed volume are recorded
+	if cancelled {
+		var err error
+		processed, err = orderer.ProcessTransaction(ctx, po.TransactionID)
+		if err != nil {
+			return false, nil, err
+		}
+	}
+
+	if po.Order == nil || !po.Order.ReplaceWithMarket {
+		return false, processed, nil
+	}
+
+	for _, order := range *processed {
+		if !order.IsCancelled() {
+			continue
+		}
+
+		if err := replaceWithMarket(ctx, dcaServices, appConfig, orderer, po, exchange, order); err != nil {
+			return false, nil, err
+		}
+	}
+
+	return false, processed, nil
+}
+
+// replaceWithMarket places a market order for the volume
+// of the cancelled order which was never executed.
+func replaceWithMarket(ctx context.Context, dcaServices *DCAServices, appConfig *AppConfig, orderer orders.Orderer, po *orders.PendingOrders, exchange string, cancelled orders.OrderComplete) error {
+	requested, err := decimal.NewFromString(po.Order.Volume)
+	if err != nil {
+		return fmt.Errorf("invalid volume %s: %w", po.Order.Volume, err)
+	}
+
+	remaining := requested.Sub(cancelled.Volume)
+	if !remaining.IsPositive() {
+		return nil
+	}
+
+	replacement := *po.Order
+	replacement.OrderType = "market"
+	replacement.Volume = remaining.String()
+	replacement.LimitPrice = nil
+	replacement.LimitOffsetPct = nil
+	replacement.ExpireAfter = ""
+	replacement.ReplaceWithMarket = false
+
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"transactionId": cancelled.TransactionID,
+		"pair":          replacement.Pair,
+		"direction":     replacement.Direction,
+		"volume":        replacement.Volume,
+	}).Info("Replacing Expired Order with Market Order")
+
+	replacementPo := orders.PendingOrders{RunID: po.RunID, ParentID: po.ParentID, Slices: po.Slices}
+	return placeOrder(ctx, dcaServices, appConfig, orderer, &replacement, exchange, replacementPo)
+}
+
+// placeScheduledSlice places the slice of an order which was
+// scheduled when the order was sliced then schedules the next slice.
+//
+// Slices which are not due yet are requeued.
+func placeScheduledSlice(ctx context.Context, dcaServices *DCAServices, appConfig *AppConfig, orderer orders.Orderer, po *orders.PendingOrders, exchange string) error {
+	now := time.Now()
+	sliceLog := logging.FromContext(ctx).WithFields(logrus.Fields{
+		"parentId":  po.ParentID,
+		"slice":     po.Slice,
+		"slices":    po.Slices,
+		"executeAt": po.ExecuteAt,
+	})
+
+	if now.Unix() < po.ExecuteAt {
+		sliceLog.Info("Slice not due yet, requeuing")
+		return dcaServices.pendingOrderSubmitter.SubmitPendingOrder(ctx, dcaServices.sqsAccess, po, exchange, true, appConfig.queue.sqsURL)
+	}
+
+	if po.Order == nil {
+		return fmt.Errorf("scheduled slice of parent %s has no order", po.ParentID)
+	}
+
+	plan, err := strategy.PlanTWAP(po.Order)
+	if err != nil {
+		return err
+	}
+
+	slice, err := plan.SliceOrder(po.Order, po.Slice)
+	if err != nil {
+		return err
+	}
+
+	slicePo := orders.PendingOrders{RunID: po.RunID, ParentID: po.ParentID, Slices: po.Slices}
+	if slice.OrderType == "limit" && slice.ExpireAfter != "" {
+		expireAfter, err := time.ParseDuration(slice.ExpireAfter)
+		if err != nil {
+			return fmt.Errorf("invalid expire_after %s: %w", slice.ExpireAfter, err)
+		}
+		slicePo.ExpireAt = now.Add(expireAfter).Unix()
+		slicePo.Order = slice
+	}
+
+	sliceLog.WithField("volume", slice.Volume).Info("Placing Slice")
+	if err := placeOrder(ctx, dcaServices, appConfig, orderer, slice, exchange, slicePo); err != nil {
+		return err
+	}
+
+	if po.Slice+1 >= po.Slices {
+		sliceLog.Info("Final Slice Placed")
+		return nil
+	}
+
+	nextSlice := *po
+	nextSlice.Slice++
+	nextSlice.ExecuteAt = now.Add(plan.Interval).Unix()
+
+	sliceLog.WithField("nextExecuteAt", nextSlice.ExecuteAt).Info("Scheduling Next Slice")
+	return dcaServices.pendingOrderSubmitter.SubmitPendingOrder(ctx, dcaServices.sqsAccess, &nextSlice, exchange, true, appConfig.queue.sqsURL)
+}
+
+// placeOrder places the order on the exchange, uploads the result
+// to the pending prefix and submits it to the queue to be processed.
+func placeOrder(ctx context.Context, dcaServices *DCAServices, appConfig *AppConfig, orderer orders.Orderer, order *configuration.DCAOrder, exchange string, po orders.PendingOrders) error {
+	orderResult, err := orderer.MakeOrder(ctx, order)
+	if err != nil {
+		return err
+	}
+
+	if orderResult == nil {
+		return nil
+	}
+
+	s3Path := fmt.Sprintf(
+		"%s/exchange=%s/%s.json",
+		appConfig.transactions.pendingS3TransactionPrefix,
+		strings.ToLower(exchange),
+		orderResult.TransactionID,
+	)
+
+	orderResultBytes, err := json.Marshal(orderResult)
+	if err != nil {
+		return err
+	}
+
+	_, err = dcaServices.s3Access.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &appConfig.s3bucket,
+		Key:    &s3Path,
+		Body:   bytes.NewReader(orderResultBytes),
+	})
+	if err != nil {
+		return err
+	}
+
+	po.TransactionID = orderResult.TransactionID
+	po.S3Bucket = appConfig.s3bucket
+	po.S3Key = s3Path
+
+	return dcaServices.pendingOrderSubmitter.SubmitPendingOrder(ctx, dcaServices.sqsAccess, &po, exchange, true, appConfig.queue.sqsURL)
+}
+
+// rollUpParent records the fills of child orders under their parent and
+// rebuilds the roll up of the parent from every child recorded so far so the
+// parent can be analysed as a single order.
+//
+// Children are recorded as <parent>/<txid>.json so concurrent children never
+// overwrite each other. The roll up is rebuilt again when more children were
+// recorded while it was being written so the last roll up written has them all.
+func rollUpParent(ctx context.Context, dcaServices *DCAServices, appConfig *AppConfig, po *orders.PendingOrders, exchange string, children *[]orders.OrderComplete) error {
+	s3Bucket := appConfig.s3bucket
+	s3Path := fmt.Sprintf(
+		"%s/parent/exchange=%s/%s",
+		appConfig.transactions.processedS3TransactionPrefix,
+		strings.ToLower(exchange),
+		po.ParentID,
+	)
+
+	for _, child := range *children {
+		if child.TransactionID == "" {
+			continue
+		}
+
+		child.ParentID = po.ParentID
+		childBytes, err := json.Marshal(child)
+		if err != nil {
+			return err
+		}
+
+		_, err = dcaServices.s3Access.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: &s3Bucket,
+			Key:    aws.String(fmt.Sprintf("%s/%s.json", s3Path, child.TransactionID)),
+			Body:   bytes.NewReader(childBytes),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	childKeys, err := listParentChildren(ctx, dcaServices.s3Access, s3Bucket, s3Path+"/")
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; attempt < parentRollUpAttempts; attempt++ {
+		parent := orders.ParentOrder{ParentID: po.ParentID, Slices: po.Slices}
+		for _, childKey := range childKeys {
+			child, err := getParentChild(ctx, dcaServices.s3Access, s3Bucket, childKey)
+			if err != nil {
+				return err
+			}
+			parent.Add(*child)
+		}
+
+		parentBytes, err := json.Marshal(parent)
+		if err != nil {
+			return err
+		}
+
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"parentId":     parent.ParentID,
+			"children":     len(parent.Children),
+			"volume":       parent.Volume,
+			"averagePrice": parent.AveragePrice,
+			"s3path":       s3Path + ".json",
+		}).Info("Uploading Parent Order roll up to S3")
+
+		_, err = dcaServices.s3Access.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: &s3Bucket,
+			Key:    aws.String(s3Path + ".json"),
+			Body:   bytes.NewReader(parentBytes),
+		})
+		if err != nil {
+			return err
+		}
+
+		// Another child recorded meanwhile may have been rolled up without these
+		rolledUp := childKeys
+		childKeys, err = listParentChildren(ctx, dcaServices.s3Access, s3Bucket, s3Path+"/")
+		if err != nil {
+			return err
+		}
+		if strings.Join(rolledUp, ",") == strings.Join(childKeys, ",") {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("children of parent %s were still being recorded after %d roll ups", po.ParentID, parentRollUpAttempts)
+}
+
+// listParentChildren lists the keys of the children recorded under the parent.
+func listParentChildren(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string) ([]string, error) {
+	keys := []string{}
+
+	var continuationToken *string
+	for {
+		listed, err := s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            &s3Bucket,
+			Prefix:            &s3Prefix,
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range listed.Contents {
+			if object.Key != nil && strings.HasSuffix(*object.Key, ".json") {
+				keys = append(keys, *object.Key)
+			}
+		}
+
+		if !listed.IsTruncated {
+			break
+		}
+		continuationToken = listed.NextContinuationToken
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// getParentChild loads a child recorded under its parent.
+func getParentChild(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Key string) (*orders.OrderComplete, error) {
+	object, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s3Bucket,
+		Key:    &s3Key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Body.Close()
+
+	var child orders.OrderComplete
+	if err := json.NewDecoder(object.Body).Decode(&child); err != nil {
+		return nil, fmt.Errorf("could not read child %s: %w", s3Key, err)
+	}
+	return &child, nil
+}
@@ -0,0 +1,783 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	awsEvents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/metrics"
+	"github.com/kiran94/dca-manager/pkg/notify"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Orderer Factory
+type MockOrdererFactory struct {
+	mock.Mock
+}
+
+func (m MockOrdererFactory) GetOrderers(ctx context.Context, ssm pkg.SSMAccess) (*map[string]orders.Orderer, error) {
+	args := m.Called(ctx, ssm)
+	return args.Get(0).(*map[string]orders.Orderer), args.Error(1)
+}
+
+// Kraken Orderer
+type MockKrakenOrderer struct {
+	mock.Mock
+}
+
+func (m MockKrakenOrderer) MakeOrder(ctx context.Context, order *configuration.DCAOrder) (*orders.OrderFufilled, error) {
+	args := m.Called(order)
+	return args.Get(0).(*orders.OrderFufilled), args.Error(1)
+}
+
+func (m MockKrakenOrderer) ProcessTransaction(ctx context.Context, transactionsIds ...string) (*[]orders.OrderComplete, error) {
+	args := m.Called(transactionsIds)
+	return args.Get(0).(*[]orders.OrderComplete), args.Error(1)
+}
+
+func (m MockKrakenOrderer) CancelOrder(ctx context.Context, transactionID string) error {
+	args := m.Called(transactionID)
+	return args.Error(0)
+}
+
+// Notifier which records every event
+type RecordingNotifier struct {
+	events []notify.Event
+}
+
+func (r *RecordingNotifier) Notify(ctx context.Context, event notify.Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+// Ensures when no records are found, then
+// an error is returned
+func TestProcessTransactionsNoRecords(t *testing.T) {
+	services := &DCAServices{notifier: &RecordingNotifier{}, metrics: metrics.NewRegistry()}
+	config := &AppConfig{}
+	sqsEvent := awsEvents.SQSEvent{Records: []awsEvents.SQSMessage{}}
+
+	err := ProcessTransactions(context.Background(), services, config, sqsEvent)
+	assert.Equal(t, "no sqs messages found, returning", err.Error())
+}
+
+// Ensures when there is an error getting orderers
+// it is returned
+func TestProcessTransactionsErrorOrderers(t *testing.T) {
+	var expectedOrderer *map[string]orders.Orderer
+	var expectedErr error = errors.New("error getting orderer")
+
+	mockSsm := pkg.MockSSMClient{}
+	mockOrderer := MockOrdererFactory{}
+	mockOrderer.On("GetOrderers", mock.Anything, mockSsm).Return(expectedOrderer, expectedErr)
+
+	services := &DCAServices{
+		notifier:       &RecordingNotifier{},
+		metrics:        metrics.NewRegistry(),
+		ssmAccess:      mockSsm,
+		ordererFactory: mockOrderer,
+	}
+	config := &AppConfig{}
+	sqsEvent := awsEvents.SQSEvent{Records: []awsEvents.SQSMessage{{MessageId: "ID"}}}
+
+	err := ProcessTransactions(context.Background(), services, config, sqsEvent)
+	assert.Equal(t, expectedErr, err)
+}
+
+// Ensures when the transaction is not real
+// it is deleted from the queue
+// and no glue job/s3 upload is run
+func TestProcessTransactionsNotReal(t *testing.T) {
+	queueURL := "EventSourceARN"
+	recieptHandle := "recieptHandle"
+	exchange := "kraken"
+	isReal := "false"
+
+	mockKrakenOrderer := MockKrakenOrderer{}
+	mockKrakenOrderer.On("ProcessTransaction", "TXID").Return()
+	expectedOrderer := &map[string]orders.Orderer{"kraken": mockKrakenOrderer}
+	var expectedErr error
+
+	mockSsm := pkg.MockSSMClient{}
+	mockOrderer := MockOrdererFactory{}
+	mockOrderer.On("GetOrderers", mock.Anything, mockSsm).Return(expectedOrderer, expectedErr)
+
+	mockS3 := pkg.MockS3Access{}
+	mockGlue := pkg.MockGlueAccess{}
+
+	mockSqs := pkg.MockSQSAccess{}
+	mockSqs.On("DeleteMessage", mock.Anything, mock.MatchedBy(func(s *sqs.DeleteMessageInput) bool {
+		return (*s.QueueUrl == queueURL) && (*s.ReceiptHandle == recieptHandle)
+	}), mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil)
+
+	services := &DCAServices{
+		notifier:       &RecordingNotifier{},
+		metrics:        metrics.NewRegistry(),
+		ssmAccess:      mockSsm,
+		ordererFactory: mockOrderer,
+		sqsAccess:      mockSqs,
+		s3Access:       mockS3,
+		glueAccess:     mockGlue,
+	}
+	config := &AppConfig{}
+
+	sqsEvent := awsEvents.SQSEvent{
+		Records: []awsEvents.SQSMessage{
+			{
+				MessageId:      "ID",
+				ReceiptHandle:  recieptHandle,
+				EventSourceARN: queueURL,
+				MessageAttributes: map[string]awsEvents.SQSMessageAttribute{
+					"Exchange": {StringValue: &exchange},
+					"Real":     {StringValue: &isReal},
+				},
+				Body: `{ "transaction_id": "TXID", "s3_bucket": "bucket", "s3_key": "key" }`,
+			},
+		},
+	}
+
+	err := ProcessTransactions(context.Background(), services, config, sqsEvent)
+	assert.Nil(t, err)
+
+	mockSqs.AssertExpectations(t)
+	mockS3.AssertNotCalled(t, "PutObject", mock.Anything, mock.Anything, mock.Anything)
+	mockGlue.AssertNotCalled(t, "StartJobRun", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Ensures when the incoming transaction's exchange
+// has not been confgiured then an error is raised
+func TestProcessTransactionsExchangeNotFound(t *testing.T) {
+	type testCase struct {
+		exchange    string
+		expectedErr string
+	}
+
+	cases := []testCase{
+		{exchange: "binance", expectedErr: "exchange binance was not configured"},
+		{exchange: "", expectedErr: "received sqs message with no exchange"},
+	}
+
+	for _, currentCase := range cases {
+
+		queueURL := "EventSourceARN"
+		recieptHandle := "recieptHandle"
+		exchange := currentCase.exchange
+		isReal := "true"
+
+		sqsEvent := awsEvents.SQSEvent{
+			Records: []awsEvents.SQSMessage{
+				{
+					MessageId:      "ID",
+					ReceiptHandle:  recieptHandle,
+					EventSourceARN: queueURL,
+					MessageAttributes: map[string]awsEvents.SQSMessageAttribute{
+						"Exchange": {StringValue: &exchange},
+						"Real":     {StringValue: &isReal},
+					},
+					Body: `{ "transaction_id": "TXID", "s3_bucket": "bucket", "s3_key": "key" }`,
+				},
+			},
+		}
+
+		mockKrakenOrderer := MockKrakenOrderer{}
+		mockKrakenOrderer.On("ProcessTransaction", "TXID").Return()
+		expectedOrderer := &map[string]orders.Orderer{"kraken": mockKrakenOrderer}
+		var expectedErr error
+
+		mockSsm := pkg.MockSSMClient{}
+		mockOrderer := MockOrdererFactory{}
+		mockOrderer.On("GetOrderers", mock.Anything, mockSsm).Return(expectedOrderer, expectedErr)
+
+		services := &DCAServices{
+			notifier:       &RecordingNotifier{},
+			metrics:        metrics.NewRegistry(),
+			ssmAccess:      mockSsm,
+			ordererFactory: mockOrderer,
+		}
+		config := &AppConfig{}
+
+		err := ProcessTransactions(context.Background(), services, config, sqsEvent)
+		assert.Contains(t, err.Error(), currentCase.expectedErr)
+	}
+}
+
+// Ensures when the transaction can
+// be processed it is uploaded to s3 and glue is submitted
+func TestProcessTransactions(t *testing.T) {
+
+	bucket := "bucket"
+	prefixPath := "path"
+	glueJobName := "glue_job"
+	queueURL := "EventSourceARN"
+	recieptHandle := "recieptHandle"
+	exchange := "kraken"
+	isReal := "true"
+
+	sqsEvent := awsEvents.SQSEvent{
+		Records: []awsEvents.SQSMessage{
+			{
+				MessageId:      "ID",
+				ReceiptHandle:  recieptHandle,
+				EventSourceARN: queueURL,
+				MessageAttributes: map[string]awsEvents.SQSMessageAttribute{
+					"Exchange": {StringValue: &exchange},
+					"Real":     {StringValue: &isReal},
+				},
+				Body: `{ "transaction_id": "TXID", "s3_bucket": "bucket", "s3_key": "key" }`,
+			},
+		},
+	}
+
+	mockKrakenOrderer := MockKrakenOrderer{}
+	mockKrakenOrderer.On("ProcessTransaction", []string{"TXID"}).Return(&[]orders.OrderComplete{
+		{
+			TransactionID:  "TXID",
+			ExchangeStatus: "closed",
+			Pair:           "XBTGBP",
+			Type:           "buy",
+			Price:          decimal.NewFromInt(30000),
+			Volume:         decimal.RequireFromString("0.01"),
+		},
+	}, nil)
+	expectedOrderer := &map[string]orders.Orderer{"kraken": mockKrakenOrderer}
+	var expectedErr error
+
+	mockSsm := pkg.MockSSMClient{}
+	mockOrderer := MockOrdererFactory{}
+	mockOrderer.On("GetOrderers", mock.Anything, mockSsm).Return(expectedOrderer, expectedErr)
+
+	mockS3 := pkg.MockS3Access{}
+	mockS3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	mockGlue := pkg.MockGlueAccess{}
+	jobID := "jobId"
+	mockGlue.On("StartJobRun", mock.Anything, mock.Anything, mock.Anything).Return(&glue.StartJobRunOutput{JobRunId: &jobID}, nil)
+
+	mockSqs := pkg.MockSQSAccess{}
+	mockSqs.On("DeleteMessage", mock.Anything, mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil)
+
+	services := &DCAServices{
+		notifier:       &RecordingNotifier{},
+		metrics:        metrics.NewRegistry(),
+		ssmAccess:      mockSsm,
+		ordererFactory: mockOrderer,
+		s3Access:       mockS3,
+		glueAccess:     mockGlue,
+		sqsAccess:      mockSqs,
+	}
+	config := AppConfig{s3bucket: bucket, dcaConfigPath: prefixPath}
+	config.glue.processTransactionJob = glueJobName
+	config.glue.processTransactionOperation = "upsert"
+
+	err := ProcessTransactions(context.Background(), services, &config, sqsEvent)
+	assert.Nil(t, err)
+
+	events := services.notifier.(*RecordingNotifier).events
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "DCA fills: 1 orders filled, 0 errors", events[0].Title)
+	assert.Equal(t, "300", events[0].Orders[0].Amount.String())
+	assert.Equal(t, "TXID", events[0].Orders[0].TransactionID)
+
+	assert.Equal(t, float64(300), services.metrics.Value(metrics.SpendTotal, metrics.Labels{"quote": "GBP"}))
+	assert.Equal(t, float64(0), services.metrics.Value(metrics.FeesTotal, metrics.Labels{"quote": "GBP"}))
+	assert.Equal(t, float64(1), services.metrics.Value(metrics.GlueJobsTotal, metrics.Labels{"job": glueJobName}))
+	assert.Equal(t, float64(1), services.metrics.Value(metrics.SQSMessagesTotal, metrics.Labels{"exchange": "kraken", "outcome": "processed"}))
+}
+
+// Ensures processed transactions are still uploaded
+// to s3 when no glue job is configured
+func TestProcessTransactionsWithoutGlue(t *testing.T) {
+	mockKrakenOrderer := MockKrakenOrderer{}
+	mockKrakenOrderer.On("ProcessTransaction", []string{"TXID"}).Return(&[]orders.OrderComplete{
+		{TransactionID: "TXID", ExchangeStatus: "closed", Pair: "XBTGBP", Type: "buy"},
+	}, nil)
+	expectedOrderer := &map[string]orders.Orderer{"kraken": mockKrakenOrderer}
+
+	mockSsm := pkg.MockSSMClient{}
+	mockOrderer := MockOrdererFactory{}
+	mockOrderer.On("GetOrderers", mock.Anything, mockSsm).Return(expectedOrderer, nil)
+
+	mockS3 := pkg.MockS3Access{}
+	mockS3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	mockSqs := pkg.MockSQSAccess{}
+	mockSqs.On("DeleteMessage", mock.Anything, mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil)
+
+	mockGlue := pkg.MockGlueAccess{}
+	services := &DCAServices{
+		notifier:       &RecordingNotifier{},
+		metrics:        metrics.NewRegistry(),
+		ssmAccess:      mockSsm,
+		ordererFactory: mockOrderer,
+		s3Access:       mockS3,
+		glueAccess:     mockGlue,
+		sqsAccess:      mockSqs,
+	}
+	config := AppConfig{s3bucket: "bucket"}
+
+	err := ProcessTransactions(context.Background(), services, &config, sqsEventWithBody(`{ "transaction_id": "TXID", "s3_bucket": "bucket", "s3_key": "key" }`))
+	assert.Nil(t, err)
+
+	mockS3.AssertExpectations(t)
+	mockSqs.AssertExpectations(t)
+	mockGlue.AssertNotCalled(t, "StartJobRun", mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, float64(1), services.metrics.Value(metrics.SQSMessagesTotal, metrics.Labels{"exchange": "kraken", "outcome": "processed"}))
+}
+
+// Pending Order Submitter
+type MockPendingOrderSubmitter struct {
+	mock.Mock
+}
+
+func (m MockPendingOrderSubmitter) SubmitPendingOrder(ctx context.Context, sc pkg.SQSAccess, po *orders.PendingOrders, exchange string, real bool, sqsQueue string) error {
+	args := m.Called(ctx, sc, po, exchange, real, sqsQueue)
+	return args.Error(0)
+}
+
+func limitOrderEvent(expireAt int64) awsEvents.SQSEvent {
+	exchange := "kraken"
+	isReal := "true"
+
+	return awsEvents.SQSEvent{
+		Records: []awsEvents.SQSMessage{
+			{
+				MessageId:      "ID",
+				ReceiptHandle:  "recieptHandle",
+				EventSourceARN: "EventSourceARN",
+				MessageAttributes: map[string]awsEvents.SQSMessageAttribute{
+					"Exchange": {StringValue: &exchange},
+					"Real":     {StringValue: &isReal},
+				},
+				Body: fmt.Sprintf(`{
+					"transaction_id": "TXID",
+					"s3_bucket": "bucket",
+					"s3_key": "key",
+					"expire_at": %d,
+					"order": { "exchange": "kraken", "pair": "XBTGBP", "direction": "buy", "ordertype": "limit", "volume": "0.01", "expire_after": "1h", "replace_with_market": true }
+				}`, expireAt),
+			},
+		},
+	}
+}
+
+// Ensures open limit orders which have not expired
+// are requeued and not uploaded
+func TestProcessTransactionsLimitOrderRequeued(t *testing.T) {
+	mockKrakenOrderer := MockKrakenOrderer{}
+	mockKrakenOrderer.On("ProcessTransaction", []string{"TXID"}).Return(&[]orders.OrderComplete{
+		{TransactionID: "TXID", ExchangeStatus: "open"},
+	}, nil)
+	expectedOrderer := &map[string]orders.Orderer{"kraken": mockKrakenOrderer}
+
+	mockSsm := pkg.MockSSMClient{}
+	mockOrderer := MockOrdererFactory{}
+	mockOrderer.On("GetOrderers", mock.Anything, mockSsm).Return(expectedOrderer, nil)
+
+	mockS3 := pkg.MockS3Access{}
+	mockSqs := pkg.MockSQSAccess{}
+	mockSqs.On("DeleteMessage", mock.Anything, mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil)
+
+	expireAt := time.Now().Add(time.Hour).Unix()
+	mockSubmitter := MockPendingOrderSubmitter{}
+	mockSubmitter.On("SubmitPendingOrder", mock.Anything, mockSqs, mock.MatchedBy(func(po *orders.PendingOrders) bool {
+		return po.TransactionID == "TXID" && po.ExpireAt == expireAt
+	}), "kraken", true, "queue_url").Return(nil)
+
+	services := &DCAServices{
+		notifier:              &RecordingNotifier{},
+		metrics:               metrics.NewRegistry(),
+		ssmAccess:             mockSsm,
+		ordererFactory:        mockOrderer,
+		s3Access:              mockS3,
+		sqsAccess:             mockSqs,
+		pendingOrderSubmitter: mockSubmitter,
+	}
+	config := AppConfig{s3bucket: "bucket"}
+	config.queue.sqsURL = "queue_url"
+
+	err := ProcessTransactions(context.Background(), services, &config, limitOrderEvent(expireAt))
+	assert.Nil(t, err)
+
+	mockSubmitter.AssertExpectations(t)
+	mockSqs.AssertExpectations(t)
+	mockKrakenOrderer.AssertNotCalled(t, "CancelOrder", mock.Anything)
+	mockS3.AssertNotCalled(t, "PutObject", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Ensures expired limit orders are cancelled and the
+// remaining volume is replaced with a market order
+func TestProcessTransactionsLimitOrderExpired(t *testing.T) {
+	mockKrakenOrderer := MockKrakenOrderer{}
+	mockKrakenOrderer.On("ProcessTransaction", []string{"TXID"}).Return(&[]orders.OrderComplete{
+		{TransactionID: "TXID", ExchangeStatus: "open"},
+	}, nil).Once()
+	mockKrakenOrderer.On("CancelOrder", "TXID").Return(nil)
+	mockKrakenOrderer.On("ProcessTransaction", []string{"TXID"}).Return(&[]orders.OrderComplete{
+		{TransactionID: "TXID", ExchangeStatus: "canceled", Volume: decimal.RequireFromString("0.004")},
+	}, nil).Once()
+	mockKrakenOrderer.On("MakeOrder", mock.MatchedBy(func(order *configuration.DCAOrder) bool {
+		return order.OrderType == "market" && order.Volume == "0.006" && order.LimitPrice == nil && order.ExpireAfter == ""
+	})).Return(&orders.OrderFufilled{TransactionID: "MARKET"}, nil)
+	expectedOrderer := &map[string]orders.Orderer{"kraken": mockKrakenOrderer}
+
+	mockSsm := pkg.MockSSMClient{}
+	mockOrderer := MockOrdererFactory{}
+	mockOrderer.On("GetOrderers", mock.Anything, mockSsm).Return(expectedOrderer, nil)
+
+	mockS3 := pkg.MockS3Access{}
+	mockS3.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		return *input.Key == "pending/exchange=kraken/MARKET.json"
+	}), mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+	mockS3.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		return *input.Key == "processed/exchange=kraken/TXID.json"
+	}), mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	mockGlue := pkg.MockGlueAccess{}
+	jobID := "jobId"
+	mockGlue.On("StartJobRun", mock.Anything, mock.Anything, mock.Anything).Return(&glue.StartJobRunOutput{JobRunId: &jobID}, nil)
+
+	mockSqs := pkg.MockSQSAccess{}
+	mockSqs.On("DeleteMessage", mock.Anything, mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil)
+
+	mockSubmitter := MockPendingOrderSubmitter{}
+	mockSubmitter.On("SubmitPendingOrder", mock.Anything, mockSqs, mock.MatchedBy(func(po *orders.PendingOrders) bool {
+		return po.TransactionID == "MARKET" && po.ExpireAt == 0
+	}), "kraken", true, "queue_url").Return(nil)
+
+	services := &DCAServices{
+		notifier:              &RecordingNotifier{},
+		metrics:               metrics.NewRegistry(),
+		ssmAccess:             mockSsm,
+		ordererFactory:        mockOrderer,
+		s3Access:              mockS3,
+		glueAccess:            mockGlue,
+		sqsAccess:             mockSqs,
+		pendingOrderSubmitter: mockSubmitter,
+	}
+	config := AppConfig{s3bucket: "bucket"}
+	config.queue.sqsURL = "queue_url"
+	config.transactions.pendingS3TransactionPrefix = "pending"
+	config.transactions.processedS3TransactionPrefix = "processed"
+
+	err := ProcessTransactions(context.Background(), services, &config, limitOrderEvent(time.Now().Add(-time.Minute).Unix()))
+	assert.Nil(t, err)
+
+	mockKrakenOrderer.AssertExpectations(t)
+	mockS3.AssertExpectations(t)
+	mockSubmitter.AssertExpectations(t)
+}
+
+func sqsEventWithBody(body string) awsEvents.SQSEvent {
+	exchange := "kraken"
+	isReal := "true"
+
+	return awsEvents.SQSEvent{
+		Records: []awsEvents.SQSMessage{
+			{
+				MessageId:      "ID",
+				ReceiptHandle:  "recieptHandle",
+				EventSourceARN: "EventSourceARN",
+				MessageAttributes: map[string]awsEvents.SQSMessageAttribute{
+					"Exchange": {StringValue: &exchange},
+					"Real":     {StringValue: &isReal},
+				},
+				Body: body,
+			},
+		},
+	}
+}
+
+// Ensures due slices are placed under their parent
+// and run and the next slice is scheduled
+func TestProcessTransactionsScheduledSlice(t *testing.T) {
+	mockKrakenOrderer := MockKrakenOrderer{}
+	mockKrakenOrderer.On("MakeOrder", mock.MatchedBy(func(order *configuration.DCAOrder) bool {
+		return order.Volume == "0.01" && order.Execution == nil
+	})).Return(&orders.OrderFufilled{TransactionID: "CHILD"}, nil)
+	expectedOrderer := &map[string]orders.Orderer{"kraken": mockKrakenOrderer}
+
+	mockSsm := pkg.MockSSMClient{}
+	mockOrderer := MockOrdererFactory{}
+	mockOrderer.On("GetOrderers", mock.Anything, mockSsm).Return(expectedOrderer, nil)
+
+	mockS3 := pkg.MockS3Access{}
+	mockS3.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		return *input.Key == "pending/exchange=kraken/CHILD.json"
+	}), mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	mockSqs := pkg.MockSQSAccess{}
+	mockSqs.On("DeleteMessage", mock.Anything, mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil)
+
+	mockSubmitter := MockPendingOrderSubmitter{}
+	mockSubmitter.On("SubmitPendingOrder", mock.Anything, mockSqs, mock.MatchedBy(func(po *orders.PendingOrders) bool {
+		return po.TransactionID == "CHILD" && po.ParentID == "PARENT" && po.Slices == 6 && po.RunID == "RUNID"
+	}), "kraken", true, "queue_url").Return(nil).Once()
+	mockSubmitter.On("SubmitPendingOrder", mock.Anything, mockSqs, mock.MatchedBy(func(po *orders.PendingOrders) bool {
+		return po.IsScheduledSlice() && po.ParentID == "PARENT" && po.Slice == 3 && po.RunID == "RUNID" && po.ExecuteAt > time.Now().Unix()
+	}), "kraken", true, "queue_url").Return(nil).Once()
+
+	services := &DCAServices{
+		notifier:              &RecordingNotifier{},
+		metrics:               metrics.NewRegistry(),
+		ssmAccess:             mockSsm,
+		ordererFactory:        mockOrderer,
+		s3Access:              mockS3,
+		sqsAccess:             mockSqs,
+		pendingOrderSubmitter: mockSubmitter,
+	}
+	config := AppConfig{s3bucket: "bucket"}
+	config.queue.sqsURL = "queue_url"
+	config.transactions.pendingS3TransactionPrefix = "pending"
+
+	body := fmt.Sprintf(`{
+		"run_id": "RUNID",
+		"transaction_id": "",
+		"s3_bucket": "bucket",
+		"s3_key": "",
+		"parent_id": "PARENT",
+		"slice": 2,
+		"slices": 6,
+		"execute_at": %d,
+		"order": { "exchange": "kraken", "pair": "XBTGBP", "direction": "buy", "ordertype": "market", "volume": "0.06", "execution": { "strategy": "twap", "slices": 6, "interval": "10m" } }
+	}`, time.Now().Add(-time.Second).Unix())
+
+	err := ProcessTransactions(context.Background(), services, &config, sqsEventWithBody(body))
+	assert.Nil(t, err)
+
+	mockKrakenOrderer.AssertExpectations(t)
+	mockS3.AssertExpectations(t)
+	mockSubmitter.AssertExpectations(t)
+	mockSqs.AssertExpectations(t)
+}
+
+// MemoryS3 keeps objects in memory so concurrent writers can be tested
+type MemoryS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (m *MemoryS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	content, ok := m.objects[*params.Key]
+	if !ok {
+		return nil, &s3types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(content))}, nil
+}
+
+func (m *MemoryS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	content, err := ioutil.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[*params.Key] = content
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *MemoryS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	listed := &s3.ListObjectsV2Output{}
+	for key := range m.objects {
+		if strings.HasPrefix(key, *params.Prefix) {
+			listed.Contents = append(listed.Contents, s3types.Object{Key: aws.String(key)})
+		}
+	}
+	return listed, nil
+}
+
+// parentServices creates the services to process child
+// orders of a parent which are kept in memory
+func parentServices(filled ...orders.OrderComplete) (*DCAServices, *AppConfig, *MemoryS3) {
+	mockKrakenOrderer := MockKrakenOrderer{}
+	for _, order := range filled {
+		mockKrakenOrderer.On("ProcessTransaction", []string{order.TransactionID}).Return(&[]orders.OrderComplete{order}, nil)
+	}
+
+	mockSsm := pkg.MockSSMClient{}
+	mockOrderer := MockOrdererFactory{}
+	mockOrderer.On("GetOrderers", mock.Anything, mockSsm).Return(&map[string]orders.Orderer{"kraken": mockKrakenOrderer}, nil)
+
+	mockSqs := pkg.MockSQSAccess{}
+	mockSqs.On("DeleteMessage", mock.Anything, mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil)
+
+	memory := &MemoryS3{objects: map[string][]byte{}}
+	services := &DCAServices{
+		notifier:       notify.Multi{},
+		metrics:        metrics.NewRegistry(),
+		ssmAccess:      mockSsm,
+		ordererFactory: mockOrderer,
+		s3Access:       memory,
+		glueAccess:     pkg.MockGlueAccess{},
+		sqsAccess:      mockSqs,
+	}
+	config := &AppConfig{s3bucket: "bucket"}
+	config.transactions.processedS3TransactionPrefix = "processed"
+
+	return services, config, memory
+}
+
+func child(transactionID string, volume string) orders.OrderComplete {
+	return orders.OrderComplete{TransactionID: transactionID, ExchangeStatus: "closed", Pair: "XBTGBP", Type: "buy", Price: decimal.NewFromInt(100), Volume: decimal.RequireFromString(volume)}
+}
+
+func childBody(transactionID string) string {
+	return fmt.Sprintf(`{ "transaction_id": "%s", "s3_bucket": "bucket", "s3_key": "key", "parent_id": "PARENT", "slices": 6 }`, transactionID)
+}
+
+func rolledUp(t *testing.T, memory *MemoryS3) orders.ParentOrder {
+	var parent orders.ParentOrder
+	assert.Nil(t, json.Unmarshal(memory.objects["processed/parent/exchange=kraken/PARENT.json"], &parent))
+	return parent
+}
+
+// Ensures processed child orders carry their parent and the parent
+// is rebuilt from every child recorded under it
+func TestProcessTransactionsParentRollUp(t *testing.T) {
+	services, config, memory := parentServices(child("CHILD", "0.01"))
+	memory.objects["processed/parent/exchange=kraken/PARENT/EARLIER.json"], _ = json.Marshal(child("EARLIER", "0.03"))
+
+	err := ProcessTransactions(context.Background(), services, config, sqsEventWithBody(childBody("CHILD")))
+	assert.Nil(t, err)
+
+	assert.Contains(t, string(memory.objects["processed/exchange=kraken/CHILD.json"]), `"parent_id":"PARENT"`)
+	assert.Contains(t, string(memory.objects["processed/parent/exchange=kraken/PARENT/CHILD.json"]), `"parent_id":"PARENT"`)
+
+	parent := rolledUp(t, memory)
+	assert.Equal(t, 6, parent.Slices)
+	assert.Equal(t, 2, len(parent.Children))
+	assert.Equal(t, "0.04", parent.Volume.String())
+	assert.Equal(t, "100", parent.AveragePrice.String())
+}
+
+// Ensures a redelivered child is not rolled up into its parent again
+func TestProcessTransactionsParentRollUpRedelivered(t *testing.T) {
+	services, config, memory := parentServices(child("CHILD", "0.01"))
+
+	for i := 0; i < 2; i++ {
+		err := ProcessTransactions(context.Background(), services, config, sqsEventWithBody(childBody("CHILD")))
+		assert.Nil(t, err)
+	}
+
+	parent := rolledUp(t, memory)
+	assert.Equal(t, 1, len(parent.Children))
+	assert.Equal(t, "0.01", parent.Volume.String())
+}
+
+// Ensures children of the same parent rolled up at the
+// same time are all in the roll up once each has finished
+func TestRollUpParentConcurrent(t *testing.T) {
+	services, config, memory := parentServices()
+
+	var wg sync.WaitGroup
+	for _, transactionID := range []string{"A", "B", "C", "D"} {
+		wg.Add(1)
+		go func(transactionID string) {
+			defer wg.Done()
+			po := &orders.PendingOrders{TransactionID: transactionID, ParentID: "PARENT", Slices: 4}
+			assert.Nil(t, rollUpParent(context.Background(), services, config, po, "kraken", &[]orders.OrderComplete{child(transactionID, "1")}))
+		}(transactionID)
+	}
+	wg.Wait()
+
+	parent := rolledUp(t, memory)
+	assert.Equal(t, 4, len(parent.Children))
+	assert.Equal(t, "4", parent.Volume.String())
+}
+
+// DCA Configuration
+type MockDCAConfiguration struct {
+	mock.Mock
+}
+
+func (m MockDCAConfiguration) GetDCAConfiguration(ctx context.Context, s3Client pkg.S3Access, s3Bucket *string, s3ConfigPath *string) (*configuration.DCAConfig, error) {
+	args := m.Called(ctx, s3Client, s3Bucket, s3ConfigPath)
+	return args.Get(0).(*configuration.DCAConfig), args.Error(1)
+}
+
+// Withdrawal Sweeper
+type MockWithdrawalSweeper struct {
+	mock.Mock
+}
+
+func (m MockWithdrawalSweeper) Sweep(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, conf map[string]configuration.WithdrawalConfig, orderers map[string]orders.Orderer) ([]orders.Withdrawal, error) {
+	args := m.Called(ctx, s3Client, s3Bucket, s3Prefix, conf, orderers)
+	return args.Get(0).([]orders.Withdrawal), args.Error(1)
+}
+
+// Ensures withdrawals are swept once transactions are processed
+// and refused withdrawals do not fail the processing
+func TestProcessTransactionsSweepsWithdrawals(t *testing.T) {
+	mockKrakenOrderer := MockKrakenOrderer{}
+	mockKrakenOrderer.On("ProcessTransaction", []string{"TXID"}).Return(&[]orders.OrderComplete{{TransactionID: "TXID"}}, nil)
+	expectedOrderer := &map[string]orders.Orderer{"kraken": mockKrakenOrderer}
+
+	mockSsm := pkg.MockSSMClient{}
+	mockOrderer := MockOrdererFactory{}
+	mockOrderer.On("GetOrderers", mock.Anything, mockSsm).Return(expectedOrderer, nil)
+
+	mockS3 := pkg.MockS3Access{}
+	mockS3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	mockGlue := pkg.MockGlueAccess{}
+	jobID := "jobId"
+	mockGlue.On("StartJobRun", mock.Anything, mock.Anything, mock.Anything).Return(&glue.StartJobRunOutput{JobRunId: &jobID}, nil)
+
+	mockSqs := pkg.MockSQSAccess{}
+	mockSqs.On("DeleteMessage", mock.Anything, mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil)
+
+	withdrawals := map[string]configuration.WithdrawalConfig{
+		"XXBT": {Exchange: "kraken", Key: "cold", Threshold: decimal.RequireFromString("0.1"), Enabled: true},
+	}
+	mockConfig := MockDCAConfiguration{}
+	mockConfig.On("GetDCAConfiguration", mock.Anything, mockS3, mock.Anything, mock.Anything).Return(&configuration.DCAConfig{Withdrawals: withdrawals}, nil)
+
+	mockSweeper := MockWithdrawalSweeper{}
+	mockSweeper.On("Sweep", mock.Anything, mockS3, "bucket", "withdrawals", withdrawals, *expectedOrderer).Return([]orders.Withdrawal{}, errors.New("1 withdrawals refused"))
+
+	services := &DCAServices{
+		notifier:          &RecordingNotifier{},
+		metrics:           metrics.NewRegistry(),
+		ssmAccess:         mockSsm,
+		ordererFactory:    mockOrderer,
+		s3Access:          mockS3,
+		glueAccess:        mockGlue,
+		sqsAccess:         mockSqs,
+		configSource:      mockConfig,
+		withdrawalSweeper: mockSweeper,
+	}
+	config := AppConfig{s3bucket: "bucket", dcaConfigPath: "config"}
+	config.withdrawals.s3Prefix = "withdrawals"
+
+	body := `{ "transaction_id": "TXID", "s3_bucket": "bucket", "s3_key": "key" }`
+
+	err := ProcessTransactions(context.Background(), services, &config, sqsEventWithBody(body))
+	assert.Nil(t, err)
+
+	mockConfig.AssertExpectations(t)
+	mockSweeper.AssertExpectations(t)
+}
@@ -0,0 +1,284 @@
+// Package queue holds pending orders in place of SQS
+// when the orders are executed and processed in a single process.
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	awsEvents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// EventSource is the event source of the messages received from a local queue.
+const EventSource = "dcad:queue"
+
+// Message is a message held by the local queue.
+//
+// Like SQS, a received message is hidden until it becomes visible again
+// and can only be deleted with the receipt handle it was last received with.
+type Message struct {
+	ID            string            `json:"id"`
+	Body          string            `json:"body"`
+	Attributes    map[string]string `json:"attributes"`
+	SentAt        time.Time         `json:"sent_at"`
+	VisibleAt     time.Time         `json:"visible_at"`
+	ReceiveCount  int               `json:"receive_count"`
+	ReceiptHandle string            `json:"receipt_handle,omitempty"`
+}
+
+// Local is a queue held in memory which when given a path is
+// saved to the file after every change so messages survive restarts.
+//
+// It implements the SQS operations used to submit, receive and delete pending orders.
+type Local struct {
+	mu       sync.Mutex
+	path     string
+	messages []Message
+	now      func() time.Time
+}
+
+// NewLocal creates a local queue, loading the messages saved at the path
+// when there are any. An empty path keeps the messages in memory only.
+func NewLocal(path string) (*Local, error) {
+	local := &Local{path: path, messages: []Message{}, now: time.Now}
+	if path == "" {
+		return local, nil
+	}
+
+	saved, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return local, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(saved, &local.messages); err != nil {
+		return nil, fmt.Errorf("could not load queue %s: %w", path, err)
+	}
+	return local, nil
+}
+
+// SendMessage adds a message to the queue which is visible once it has been delayed.
+func (l *Local) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := map[string]string{}
+	for name, attribute := range params.MessageAttributes {
+		attributes[name] = aws.ToString(attribute.StringValue)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now().UTC()
+	l.messages = append(l.messages, Message{
+		ID:         id,
+		Body:       aws.ToString(params.MessageBody),
+		Attributes: attributes,
+		SentAt:     now,
+		VisibleAt:  now.Add(time.Duration(params.DelaySeconds) * time.Second),
+	})
+
+	if err := l.save(); err != nil {
+		return nil, err
+	}
+	return &sqs.SendMessageOutput{MessageId: aws.String(id)}, nil
+}
+
+// DeleteMessage removes the message last received with the receipt handle.
+func (l *Local) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	receiptHandle := aws.ToString(params.ReceiptHandle)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for index, message := range l.messages {
+		if message.ReceiptHandle != "" && message.ReceiptHandle == receiptHandle {
+			l.messages = append(l.messages[:index], l.messages[index+1:]...)
+			return &sqs.DeleteMessageOutput{}, l.save()
+		}
+	}
+
+	return nil, fmt.Errorf("receipt handle %s is not valid", receiptHandle)
+}
+
+// Receive receives up to the maximum number of visible messages in the order
+// they were sent, hiding them for the visibility timeout so a message which
+// is not deleted is received again once it has timed out.
+func (l *Local) Receive(max int, visibility time.Duration) (awsEvents.SQSEvent, error) {
+	received, err := l.receive(max, visibility)
+
+	event := awsEvents.SQSEvent{Records: []awsEvents.SQSMessage{}}
+	for _, message := range received {
+		attributes := map[string]awsEvents.SQSMessageAttribute{}
+		for name, value := range message.Attributes {
+			attributes[name] = awsEvents.SQSMessageAttribute{DataType: "String", StringValue: aws.String(value)}
+		}
+
+		event.Records = append(event.Records, awsEvents.SQSMessage{
+			MessageId:         message.ID,
+			ReceiptHandle:     message.ReceiptHandle,
+			Body:              message.Body,
+			MessageAttributes: attributes,
+			EventSource:       EventSource,
+			EventSourceARN:    EventSource,
+		})
+	}
+	return event, err
+}
+
+// ReceiveMessage receives visible messages like Receive, hidden for the
+// visibility timeout of the input or else 30 seconds like SQS.
+func (l *Local) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	max := int(params.MaxNumberOfMessages)
+	if max <= 0 {
+		max = 1
+	}
+	visibility := 30 * time.Second
+	if params.VisibilityTimeout > 0 {
+		visibility = time.Duration(params.VisibilityTimeout) * time.Second
+	}
+
+	received, err := l.receive(max, visibility)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &sqs.ReceiveMessageOutput{Messages: []types.Message{}}
+	for _, message := range received {
+		attributes := map[string]types.MessageAttributeValue{}
+		for name, value := range message.Attributes {
+			attributes[name] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+		}
+
+		output.Messages = append(output.Messages, types.Message{
+			MessageId:     aws.String(message.ID),
+			ReceiptHandle: aws.String(message.ReceiptHandle),
+			Body:          aws.String(message.Body),
+			Attributes: map[string]string{
+				string(types.MessageSystemAttributeNameSentTimestamp):           strconv.FormatInt(message.SentAt.UnixNano()/int64(time.Millisecond), 10),
+				string(types.MessageSystemAttributeNameApproximateReceiveCount): strconv.Itoa(message.ReceiveCount),
+			},
+			MessageAttributes: attributes,
+		})
+	}
+	return output, nil
+}
+
+// ChangeMessageVisibility hides the message last received with the
+// receipt handle for the timeout from now, where zero makes it visible.
+func (l *Local) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	receiptHandle := aws.ToString(params.ReceiptHandle)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for index := range l.messages {
+		message := &l.messages[index]
+		if message.ReceiptHandle != "" && message.ReceiptHandle == receiptHandle {
+			message.VisibleAt = l.now().UTC().Add(time.Duration(params.VisibilityTimeout) * time.Second)
+			return &sqs.ChangeMessageVisibilityOutput{}, l.save()
+		}
+	}
+
+	return nil, fmt.Errorf("receipt handle %s is not valid", receiptHandle)
+}
+
+// receive hides up to the maximum number of visible messages for the
+// visibility timeout, returning them with their new receipt handles.
+func (l *Local) receive(max int, visibility time.Duration) ([]Message, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now().UTC()
+	received := []Message{}
+	for index := range l.messages {
+		if len(received) >= max {
+			break
+		}
+
+		message := &l.messages[index]
+		if message.VisibleAt.After(now) {
+			continue
+		}
+
+		receiptHandle, err := newID()
+		if err != nil {
+			return received, err
+		}
+
+		message.ReceiptHandle = receiptHandle
+		message.ReceiveCount++
+		message.VisibleAt = now.Add(visibility)
+		received = append(received, *message)
+	}
+
+	if len(received) == 0 {
+		return received, nil
+	}
+	return received, l.save()
+}
+
+// Messages are the messages on the queue whether or not they are visible.
+func (l *Local) Messages() []Message {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	messages := make([]Message, len(l.messages))
+	copy(messages, l.messages)
+	return messages
+}
+
+// save writes the messages to a temporary file which replaces the
+// saved messages so a crash never leaves a partially written queue.
+func (l *Local) save() error {
+	if l.path == "" {
+		return nil
+	}
+
+	serialised, err := json.Marshal(l.messages)
+	if err != nil {
+		return err
+	}
+
+	temporary, err := ioutil.TempFile(filepath.Dir(l.path), filepath.Base(l.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+
+	if _, err := temporary.Write(serialised); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temporary.Name(), l.path)
+}
+
+func newID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
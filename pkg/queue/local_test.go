@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func send(t *testing.T, queue pkg.SQSAccess, body string, delaySeconds int32) {
+	_, err := queue.SendMessage(context.Background(), &sqs.SendMessageInput{
+		MessageBody:  aws.String(body),
+		DelaySeconds: delaySeconds,
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"Exchange": {DataType: aws.String("String"), StringValue: aws.String("kraken")},
+		},
+	})
+	assert.Nil(t, err)
+}
+
+// Ensures messages are received in the order they were sent
+// with their attributes, once their delay has passed
+func TestLocalReceive(t *testing.T) {
+	now := time.Date(2022, 1, 7, 6, 0, 0, 0, time.UTC)
+	queue, err := NewLocal("")
+	assert.Nil(t, err)
+	queue.now = func() time.Time { return now }
+
+	send(t, queue, "first", 0)
+	send(t, queue, "delayed", 60)
+	send(t, queue, "second", 0)
+
+	event, err := queue.Receive(10, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(event.Records))
+	assert.Equal(t, "first", event.Records[0].Body)
+	assert.Equal(t, "second", event.Records[1].Body)
+	assert.Equal(t, "kraken", *event.Records[0].MessageAttributes["Exchange"].StringValue)
+
+	event, err = queue.Receive(10, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(event.Records))
+
+	now = now.Add(61 * time.Second)
+	event, err = queue.Receive(1, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(event.Records))
+	assert.Equal(t, "first", event.Records[0].Body)
+}
+
+// Ensures messages received through the SQS API are hidden
+// until their visibility is changed with the receipt handle
+func TestLocalReceiveMessage(t *testing.T) {
+	now := time.Date(2022, 1, 7, 6, 0, 0, 0, time.UTC)
+	queue, err := NewLocal("")
+	assert.Nil(t, err)
+	queue.now = func() time.Time { return now }
+
+	send(t, queue, "first", 0)
+	send(t, queue, "second", 0)
+
+	received, err := queue.ReceiveMessage(context.Background(), &sqs.ReceiveMessageInput{MaxNumberOfMessages: 10, VisibilityTimeout: 60})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(received.Messages))
+	assert.Equal(t, "first", *received.Messages[0].Body)
+	assert.Equal(t, "kraken", *received.Messages[0].MessageAttributes["Exchange"].StringValue)
+	assert.Equal(t, "1", received.Messages[0].Attributes["ApproximateReceiveCount"])
+
+	_, err = queue.ChangeMessageVisibility(context.Background(), &sqs.ChangeMessageVisibilityInput{ReceiptHandle: received.Messages[1].ReceiptHandle, VisibilityTimeout: 0})
+	assert.Nil(t, err)
+
+	again, err := queue.ReceiveMessage(context.Background(), &sqs.ReceiveMessageInput{MaxNumberOfMessages: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(again.Messages))
+	assert.Equal(t, "second", *again.Messages[0].Body)
+
+	_, err = queue.ChangeMessageVisibility(context.Background(), &sqs.ChangeMessageVisibilityInput{ReceiptHandle: aws.String("unknown")})
+	assert.NotNil(t, err)
+}
+
+// Ensures messages are deleted with the receipt handle they were last
+// received with and are received again when they are not deleted
+func TestLocalDeleteMessage(t *testing.T) {
+	now := time.Date(2022, 1, 7, 6, 0, 0, 0, time.UTC)
+	queue, err := NewLocal("")
+	assert.Nil(t, err)
+	queue.now = func() time.Time { return now }
+
+	send(t, queue, "order", 0)
+
+	first, err := queue.Receive(10, time.Minute)
+	assert.Nil(t, err)
+
+	now = now.Add(2 * time.Minute)
+	second, err := queue.Receive(10, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(second.Records))
+	assert.Equal(t, 2, queue.Messages()[0].ReceiveCount)
+
+	_, err = queue.DeleteMessage(context.Background(), &sqs.DeleteMessageInput{ReceiptHandle: aws.String(first.Records[0].ReceiptHandle)})
+	assert.NotNil(t, err)
+
+	_, err = queue.DeleteMessage(context.Background(), &sqs.DeleteMessageInput{ReceiptHandle: aws.String(second.Records[0].ReceiptHandle)})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(queue.Messages()))
+}
+
+// Ensures messages saved to a file are loaded again
+func TestLocalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+
+	queue, err := NewLocal(path)
+	assert.Nil(t, err)
+	send(t, queue, "order", 0)
+
+	reloaded, err := NewLocal(path)
+	assert.Nil(t, err)
+
+	event, err := reloaded.Receive(10, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(event.Records))
+	assert.Equal(t, "order", event.Records[0].Body)
+	assert.Equal(t, "kraken", *event.Records[0].MessageAttributes["Exchange"].StringValue)
+}
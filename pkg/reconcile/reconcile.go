@@ -0,0 +1,276 @@
+// Package reconcile compares the orders recorded at each stage, pending
+// and processed, with the orders closed on the exchange to find orders
+// which are stuck between stages or were made outside of the DCA manager.
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/orders"
+)
+
+// Options are where the orders are recorded, the exchange they are
+// reconciled against and the time range to reconcile.
+type Options struct {
+	S3Bucket        string
+	PendingPrefix   string
+	ProcessedPrefix string
+	Exchange        string
+	From            time.Time
+	To              time.Time
+}
+
+// Record is an order recorded in S3 by its transaction ID under the
+// partition of its exchange, as a rolled up parent when it is under parent/.
+type Record struct {
+	TransactionID string    `json:"transaction_id"`
+	Exchange      string    `json:"exchange"`
+	Parent        bool      `json:"parent,omitempty"`
+	S3Key         string    `json:"s3_key"`
+	LastModified  time.Time `json:"last_modified"`
+}
+
+// Orphan is an order which is pending but was never processed along with
+// its status on the exchange, which is empty when the exchange did not
+// close the order within the range such as orders faked by a dry run.
+type Orphan struct {
+	Record
+	ExchangeStatus string `json:"exchange_status,omitempty"`
+}
+
+// Duplicate is a transaction recorded under more than one key in a stage,
+// such as under the partitions of two exchanges or as both a rolled up
+// parent and an order.
+type Duplicate struct {
+	Stage         string   `json:"stage"`
+	TransactionID string   `json:"transaction_id"`
+	S3Keys        []string `json:"s3_keys"`
+}
+
+// Report is the outcome of reconciling an exchange over a time range.
+//
+// Orphans were pending within the range but never processed, External
+// are orders which filled on the exchange within the range but were never
+// recorded so were made outside of the DCA manager.
+type Report struct {
+	Exchange   string                 `json:"exchange"`
+	From       time.Time              `json:"from"`
+	To         time.Time              `json:"to"`
+	Pending    int                    `json:"pending"`
+	Processed  int                    `json:"processed"`
+	Closed     int                    `json:"closed"`
+	Orphans    []Orphan               `json:"orphans"`
+	Duplicates []Duplicate            `json:"duplicates"`
+	External   []orders.OrderComplete `json:"external"`
+}
+
+// Issues is the number of problems found.
+func (r Report) Issues() int {
+	return len(r.Orphans) + len(r.Duplicates) + len(r.External)
+}
+
+// Reconciler is an abstraction to reconcile orders and fix what it finds.
+type Reconciler interface {
+	Reconcile(ctx context.Context, s3Client pkg.S3Access, history orders.OrderHistory, options Options) (*Report, error)
+	Requeue(ctx context.Context, sqsClient pkg.SQSAccess, submitter orders.PendingOrderQueue, queueURL string, s3Bucket string, report *Report) ([]string, error)
+}
+
+// Ledger reconciles the orders recorded in S3 with the exchange.
+type Ledger struct{}
+
+// Reconcile lists the pending and processed orders of the exchange and
+// compares them with the orders the exchange closed within the range.
+//
+// Only orders which became pending within the range can be orphans but
+// they are compared with every processed order, whenever it was processed.
+func (l Ledger) Reconcile(ctx context.Context, s3Client pkg.S3Access, history orders.OrderHistory, options Options) (*Report, error) {
+	allPending, err := listRecords(ctx, s3Client, options.S3Bucket, options.PendingPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("could not list pending orders: %w", err)
+	}
+
+	allProcessed, err := listRecords(ctx, s3Client, options.S3Bucket, options.ProcessedPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("could not list processed orders: %w", err)
+	}
+
+	pending := ofExchange(allPending, options.Exchange)
+	processed := ofExchange(allProcessed, options.Exchange)
+
+	closed, err := history.GetClosedOrders(ctx, options.From, options.To)
+	if err != nil {
+		return nil, fmt.Errorf("could not get closed orders from %s: %w", options.Exchange, err)
+	}
+
+	report := &Report{
+		Exchange:   options.Exchange,
+		From:       options.From,
+		To:         options.To,
+		Processed:  len(processed),
+		Closed:     len(closed),
+		Orphans:    []Orphan{},
+		Duplicates: append(duplicates("pending", allPending, options.Exchange), duplicates("processed", allProcessed, options.Exchange)...),
+		External:   []orders.OrderComplete{},
+	}
+
+	closedStatus := make(map[string]string, len(closed))
+	for _, order := range closed {
+		closedStatus[order.TransactionID] = order.ExchangeStatus
+	}
+
+	for transactionID, records := range pending {
+		record := records[0]
+		if record.LastModified.Before(options.From) || !record.LastModified.Before(options.To) {
+			continue
+		}
+
+		report.Pending++
+		if _, ok := processed[transactionID]; !ok {
+			report.Orphans = append(report.Orphans, Orphan{Record: record, ExchangeStatus: closedStatus[transactionID]})
+		}
+	}
+
+	for _, order := range closed {
+		_, isPending := pending[order.TransactionID]
+		_, isProcessed := processed[order.TransactionID]
+		if isPending || isProcessed || order.Volume.IsZero() {
+			continue
+		}
+		report.External = append(report.External, order)
+	}
+
+	sort.Slice(report.Orphans, func(i, j int) bool {
+		return report.Orphans[i].LastModified.Before(report.Orphans[j].LastModified)
+	})
+	sort.SliceStable(report.External, func(i, j int) bool {
+		return report.External[i].CloseTime < report.External[j].CloseTime
+	})
+	return report, nil
+}
+
+// Requeue submits the orphans which the exchange closed to the pending
+// orders queue so they are processed, returning their transaction IDs.
+//
+// Orphans the exchange does not know about are never requeued as they
+// could not be processed, such as orders faked by a dry run.
+func (l Ledger) Requeue(ctx context.Context, sqsClient pkg.SQSAccess, submitter orders.PendingOrderQueue, queueURL string, s3Bucket string, report *Report) ([]string, error) {
+	requeued := []string{}
+	for _, orphan := range report.Orphans {
+		if orphan.ExchangeStatus == "" {
+			continue
+		}
+
+		pending := &orders.PendingOrders{
+			TransactionID: orphan.TransactionID,
+			S3Bucket:      s3Bucket,
+			S3Key:         orphan.S3Key,
+		}
+		if err := submitter.SubmitPendingOrder(ctx, sqsClient, pending, report.Exchange, true, queueURL); err != nil {
+			return requeued, fmt.Errorf("could not requeue %s: %w", orphan.TransactionID, err)
+		}
+		requeued = append(requeued, orphan.TransactionID)
+	}
+	return requeued, nil
+}
+
+// listRecords lists the orders stored as <prefix>/exchange=<exchange>/<txid>.json and
+// the parents rolled up as <prefix>/parent/exchange=<exchange>/<txid>.json of
+// every exchange, keyed by their transaction ID.
+func listRecords(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string) (map[string][]Record, error) {
+	records := map[string][]Record{}
+	listPrefix := s3Prefix + "/"
+
+	var continuationToken *string
+	for {
+		listed, err := s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            &s3Bucket,
+			Prefix:            &listPrefix,
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range listed.Contents {
+			if object.Key == nil {
+				continue
+			}
+
+			parts := strings.Split(strings.TrimPrefix(*object.Key, listPrefix), "/")
+			parent := len(parts) == 3 && parts[0] == "parent"
+			if parent {
+				parts = parts[1:]
+			}
+
+			// Anything else not laid out by exchange is skipped
+			if len(parts) != 2 || !strings.HasPrefix(parts[0], "exchange=") || !strings.HasSuffix(parts[1], ".json") {
+				continue
+			}
+
+			record := Record{
+				TransactionID: strings.TrimSuffix(parts[1], ".json"),
+				Exchange:      strings.TrimPrefix(parts[0], "exchange="),
+				Parent:        parent,
+				S3Key:         *object.Key,
+			}
+			if object.LastModified != nil {
+				record.LastModified = *object.LastModified
+			}
+			records[record.TransactionID] = append(records[record.TransactionID], record)
+		}
+
+		if !listed.IsTruncated {
+			break
+		}
+		continuationToken = listed.NextContinuationToken
+	}
+
+	return records, nil
+}
+
+// ofExchange gets the orders recorded under the exchange, matched regardless
+// of case, leaving out the rolled up parents.
+func ofExchange(records map[string][]Record, exchange string) map[string][]Record {
+	found := map[string][]Record{}
+	for transactionID, recorded := range records {
+		for _, record := range recorded {
+			if !record.Parent && strings.EqualFold(record.Exchange, exchange) {
+				found[transactionID] = append(found[transactionID], record)
+			}
+		}
+	}
+	return found
+}
+
+// duplicates finds the transactions of the exchange recorded under more than
+// one key, whether under another exchange or as a rolled up parent.
+func duplicates(stage string, records map[string][]Record, exchange string) []Duplicate {
+	found := []Duplicate{}
+	for transactionID, recorded := range records {
+		if len(recorded) < 2 {
+			continue
+		}
+
+		duplicate := Duplicate{Stage: stage, TransactionID: transactionID}
+		underExchange := false
+		for _, record := range recorded {
+			duplicate.S3Keys = append(duplicate.S3Keys, record.S3Key)
+			underExchange = underExchange || strings.EqualFold(record.Exchange, exchange)
+		}
+		if !underExchange {
+			continue
+		}
+
+		sort.Strings(duplicate.S3Keys)
+		found = append(found, duplicate)
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].TransactionID < found[j].TransactionID })
+	return found
+}
@@ -0,0 +1,169 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	from = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	to   = time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// History returns fixed closed orders
+type History struct {
+	closed []orders.OrderComplete
+	err    error
+}
+
+func (h History) GetClosedOrders(ctx context.Context, start time.Time, end time.Time) ([]orders.OrderComplete, error) {
+	return h.closed, h.err
+}
+
+// Submitter records the pending orders submitted
+type Submitter struct {
+	submitted []*orders.PendingOrders
+	exchanges []string
+}
+
+func (s *Submitter) SubmitPendingOrder(ctx context.Context, sc pkg.SQSAccess, po *orders.PendingOrders, exchange string, real bool, sqsQueue string) error {
+	s.submitted = append(s.submitted, po)
+	s.exchanges = append(s.exchanges, exchange)
+	return nil
+}
+
+func object(key string, modified time.Time) s3types.Object {
+	return s3types.Object{Key: aws.String(key), LastModified: aws.Time(modified)}
+}
+
+func setup() *pkg.MockS3Access {
+	s3Access := &pkg.MockS3Access{}
+	for prefix, output := range map[string]*s3.ListObjectsV2Output{
+		"pending/": {Contents: []s3types.Object{
+			object("pending/exchange=kraken/TX-ORPHAN.json", from.AddDate(0, 0, 1)),
+			object("pending/exchange=kraken/TX-FAKE.json", from.AddDate(0, 0, 2)),
+			object("pending/exchange=kraken/TX-DONE.json", from.AddDate(0, 0, 3)),
+			object("pending/exchange=kraken/TX-OLD.json", from.AddDate(0, 0, -1)),
+			object("pending/exchange=other/TX-OTHER.json", from.AddDate(0, 0, 1)),
+		}},
+		"processed/": {Contents: []s3types.Object{
+			object("processed/exchange=kraken/TX-DONE.json", from.AddDate(0, 0, 3)),
+			object("processed/exchange=Kraken/TX-DONE.json", from.AddDate(0, 0, 4)),
+			object("processed/exchange=kraken/TX-IMPORTED.json", from.AddDate(0, 0, 5)),
+			object("processed/parent/exchange=kraken/TX-PARENT.json", from.AddDate(0, 0, 5)),
+		}},
+	} {
+		prefix, output := prefix, output
+		s3Access.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+			return *input.Prefix == prefix
+		}), mock.Anything).Return(output, nil)
+	}
+	return s3Access
+}
+
+func options() Options {
+	return Options{S3Bucket: "bucket", PendingPrefix: "pending", ProcessedPrefix: "processed", Exchange: "kraken", From: from, To: to}
+}
+
+// Ensures orphans, duplicates and external trades are found within the range
+func TestReconcile(t *testing.T) {
+	s3Access := setup()
+	history := History{closed: []orders.OrderComplete{
+		{TransactionID: "TX-ORPHAN", ExchangeStatus: "closed", Volume: decimal.NewFromInt(1)},
+		{TransactionID: "TX-DONE", ExchangeStatus: "closed", Volume: decimal.NewFromInt(1)},
+		{TransactionID: "TX-IMPORTED", ExchangeStatus: "closed", Volume: decimal.NewFromInt(1)},
+		{TransactionID: "TX-MANUAL", ExchangeStatus: "closed", Volume: decimal.NewFromInt(2), CloseTime: 2},
+		{TransactionID: "TX-CANCELLED", ExchangeStatus: "canceled", Volume: decimal.Zero},
+	}}
+
+	report, err := Ledger{}.Reconcile(context.Background(), s3Access, history, options())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, report.Pending)
+	assert.Equal(t, 2, report.Processed)
+	assert.Equal(t, 5, report.Closed)
+
+	assert.Equal(t, 2, len(report.Orphans))
+	assert.Equal(t, "TX-ORPHAN", report.Orphans[0].TransactionID)
+	assert.Equal(t, "closed", report.Orphans[0].ExchangeStatus)
+	assert.Equal(t, "TX-FAKE", report.Orphans[1].TransactionID)
+	assert.Equal(t, "", report.Orphans[1].ExchangeStatus)
+
+	assert.Equal(t, []Duplicate{{Stage: "processed", TransactionID: "TX-DONE", S3Keys: []string{"processed/exchange=Kraken/TX-DONE.json", "processed/exchange=kraken/TX-DONE.json"}}}, report.Duplicates)
+
+	assert.Equal(t, 1, len(report.External))
+	assert.Equal(t, "TX-MANUAL", report.External[0].TransactionID)
+	assert.Equal(t, 4, report.Issues())
+}
+
+// Ensures transactions recorded under another exchange or as both a
+// rolled up parent and an order are duplicates
+func TestReconcileDuplicates(t *testing.T) {
+	s3Access := &pkg.MockS3Access{}
+	s3Access.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == "pending/"
+	}), mock.Anything).Return(&s3.ListObjectsV2Output{Contents: []s3types.Object{
+		object("pending/exchange=kraken/TX-MOVED.json", from.AddDate(0, 0, 1)),
+		object("pending/exchange=binance/TX-MOVED.json", from.AddDate(0, 0, 1)),
+		object("pending/exchange=binance/TX-ELSEWHERE.json", from.AddDate(0, 0, 1)),
+		object("pending/exchange=coinbase/TX-ELSEWHERE.json", from.AddDate(0, 0, 1)),
+	}}, nil)
+	s3Access.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == "processed/"
+	}), mock.Anything).Return(&s3.ListObjectsV2Output{Contents: []s3types.Object{
+		object("processed/exchange=kraken/TX-MOVED.json", from.AddDate(0, 0, 2)),
+		object("processed/exchange=kraken/TX-ROLLED.json", from.AddDate(0, 0, 2)),
+		object("processed/parent/exchange=kraken/TX-ROLLED.json", from.AddDate(0, 0, 3)),
+		object("processed/parent/exchange=kraken/TX-PARENT.json", from.AddDate(0, 0, 3)),
+	}}, nil)
+
+	report, err := Ledger{}.Reconcile(context.Background(), s3Access, History{}, options())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Pending)
+	assert.Equal(t, 2, report.Processed)
+	assert.Equal(t, 0, len(report.Orphans))
+	assert.Equal(t, []Duplicate{
+		{Stage: "pending", TransactionID: "TX-MOVED", S3Keys: []string{"pending/exchange=binance/TX-MOVED.json", "pending/exchange=kraken/TX-MOVED.json"}},
+		{Stage: "processed", TransactionID: "TX-ROLLED", S3Keys: []string{"processed/exchange=kraken/TX-ROLLED.json", "processed/parent/exchange=kraken/TX-ROLLED.json"}},
+	}, report.Duplicates)
+	assert.Equal(t, 2, report.Issues())
+}
+
+// Ensures only orphans closed on the exchange are requeued
+func TestRequeue(t *testing.T) {
+	report := &Report{Exchange: "kraken", Orphans: []Orphan{
+		{Record: Record{TransactionID: "TX-ORPHAN", S3Key: "pending/exchange=kraken/TX-ORPHAN.json"}, ExchangeStatus: "closed"},
+		{Record: Record{TransactionID: "TX-FAKE", S3Key: "pending/exchange=kraken/TX-FAKE.json"}},
+	}}
+	submitter := &Submitter{}
+
+	requeued, err := Ledger{}.Requeue(context.Background(), &pkg.MockSQSAccess{}, submitter, "queue", "bucket", report)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"TX-ORPHAN"}, requeued)
+	assert.Equal(t, 1, len(submitter.submitted))
+	assert.Equal(t, "bucket", submitter.submitted[0].S3Bucket)
+	assert.Equal(t, "pending/exchange=kraken/TX-ORPHAN.json", submitter.submitted[0].S3Key)
+	assert.Equal(t, "kraken", submitter.exchanges[0])
+}
+
+// Ensures an error from the exchange is returned
+func TestReconcileHistoryError(t *testing.T) {
+	s3Access := setup()
+
+	_, err := Ledger{}.Reconcile(context.Background(), s3Access, History{err: errors.New("rate limited")}, options())
+
+	assert.EqualError(t, err, "could not get closed orders from kraken: rate limited")
+}
@@ -0,0 +1,179 @@
+package runs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+)
+
+// ErrRunNotFound is returned when no summary was recorded for a run.
+var ErrRunNotFound = errors.New("run not found")
+
+// Loader is an abstraction to load persisted run summaries.
+type Loader interface {
+	ListRuns(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, date string, limit int) ([]RunSummary, error)
+	ListRunsBetween(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, from string, to string) ([]RunSummary, error)
+	GetRun(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, runID string) (*RunSummary, error)
+}
+
+// S3Loader loads run summaries persisted to S3.
+type S3Loader struct{}
+
+// ListRuns loads the most recently recorded summaries up to the limit, newest first.
+// When a date is given only the runs triggered on that date are listed.
+func (s S3Loader) ListRuns(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, date string, limit int) ([]RunSummary, error) {
+	listPrefix := s3Prefix + "/"
+	if date != "" {
+		if _, err := time.Parse(DateLayout, date); err != nil {
+			return nil, fmt.Errorf("invalid date %s, expected %s", date, DateLayout)
+		}
+		listPrefix = fmt.Sprintf("%s/date=%s/", s3Prefix, date)
+	}
+
+	objects, err := listSummaries(ctx, s3Client, s3Bucket, listPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// Summaries are written once the run finishes so the newest is the most recent run
+	sort.SliceStable(objects, func(i, j int) bool {
+		return objects[i].LastModified.After(*objects[j].LastModified)
+	})
+	if limit > 0 && len(objects) > limit {
+		objects = objects[:limit]
+	}
+
+	summaries := make([]RunSummary, 0, len(objects))
+	for _, object := range objects {
+		summary, err := getSummary(ctx, s3Client, s3Bucket, *object.Key)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, *summary)
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].TriggerTime.After(summaries[j].TriggerTime)
+	})
+	return summaries, nil
+}
+
+// ListRunsBetween loads the summaries of the runs triggered from the date through the
+// date, newest first. Either date can be empty to leave the range open.
+//
+// Only the summaries under the date partitions within the range are loaded.
+func (s S3Loader) ListRunsBetween(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, from string, to string) ([]RunSummary, error) {
+	for _, date := range []string{from, to} {
+		if _, err := time.Parse(DateLayout, date); date != "" && err != nil {
+			return nil, fmt.Errorf("invalid date %s, expected %s", date, DateLayout)
+		}
+	}
+
+	objects, err := listSummaries(ctx, s3Client, s3Bucket, s3Prefix+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := []RunSummary{}
+	for _, object := range objects {
+		// Keys are <prefix>/date=<date>/<run>.json
+		partition := strings.TrimPrefix(*object.Key, s3Prefix+"/date=")
+		if !inRange(strings.SplitN(partition, "/", 2)[0], from, to) {
+			continue
+		}
+
+		summary, err := getSummary(ctx, s3Client, s3Bucket, *object.Key)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, *summary)
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].TriggerTime.After(summaries[j].TriggerTime)
+	})
+	return summaries, nil
+}
+
+// GetRun loads the summary of the run from whichever date it was recorded under.
+func (s S3Loader) GetRun(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, runID string) (*RunSummary, error) {
+	objects, err := listSummaries(ctx, s3Client, s3Bucket, s3Prefix+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, object := range objects {
+		if strings.HasSuffix(*object.Key, "/"+runID+".json") {
+			return getSummary(ctx, s3Client, s3Bucket, *object.Key)
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrRunNotFound, runID)
+}
+
+// listSummaries lists every summary json file under the prefix.
+func listSummaries(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string) ([]types.Object, error) {
+	objects := []types.Object{}
+
+	var continuationToken *string
+	for {
+		listed, err := s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            &s3Bucket,
+			Prefix:            &s3Prefix,
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range listed.Contents {
+			if object.Key == nil || !strings.HasSuffix(*object.Key, ".json") {
+				continue
+			}
+
+			if object.LastModified == nil {
+				object.LastModified = &time.Time{}
+			}
+			objects = append(objects, object)
+		}
+
+		if !listed.IsTruncated {
+			break
+		}
+		continuationToken = listed.NextContinuationToken
+	}
+
+	return objects, nil
+}
+
+// getSummary loads a single summary from S3.
+func getSummary(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Key string) (*RunSummary, error) {
+	object, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s3Bucket,
+		Key:    &s3Key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Body.Close()
+
+	content, err := ioutil.ReadAll(object.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &RunSummary{}
+	if err := json.Unmarshal(content, summary); err != nil {
+		return nil, fmt.Errorf("could not read run summary %s: %w", s3Key, err)
+	}
+	return summary, nil
+}
@@ -0,0 +1,117 @@
+package runs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// recordedRuns mocks S3 holding the summary of each run.
+func recordedRuns(summaries ...RunSummary) *pkg.MockS3Access {
+	s3Access := &pkg.MockS3Access{}
+
+	objects := []types.Object{}
+	for _, summary := range summaries {
+		summary := summary
+		key := Key("runs", &summary)
+		lastModified := summary.TriggerTime.Add(time.Minute)
+		objects = append(objects, types.Object{Key: aws.String(key), LastModified: &lastModified})
+
+		s3Access.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+			return *input.Key == key
+		}), mock.Anything).Return(func() *s3.GetObjectOutput {
+			content, _ := json.Marshal(summary)
+			return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(content))}
+		}(), nil)
+	}
+
+	s3Access.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == "runs/"
+	}), mock.Anything).Return(&s3.ListObjectsV2Output{Contents: objects}, nil)
+
+	s3Access.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == "runs/date=2022-01-07/"
+	}), mock.Anything).Return(&s3.ListObjectsV2Output{Contents: objects[:1]}, nil)
+
+	return s3Access
+}
+
+// Ensures the most recent runs are listed newest first
+// up to the limit and can be filtered to a date
+func TestS3LoaderListRuns(t *testing.T) {
+	s3Access := recordedRuns(
+		RunSummary{RunID: "first", TriggerTime: triggerTime},
+		RunSummary{RunID: "second", TriggerTime: triggerTime.AddDate(0, 0, 7)},
+		RunSummary{RunID: "third", TriggerTime: triggerTime.AddDate(0, 0, 14)},
+	)
+
+	summaries, err := S3Loader{}.ListRuns(context.Background(), s3Access, "bucket", "runs", "", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(summaries))
+	assert.Equal(t, "third", summaries[0].RunID)
+	assert.Equal(t, "second", summaries[1].RunID)
+
+	summaries, err = S3Loader{}.ListRuns(context.Background(), s3Access, "bucket", "runs", "2022-01-07", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(summaries))
+	assert.Equal(t, "first", summaries[0].RunID)
+
+	_, err = S3Loader{}.ListRuns(context.Background(), s3Access, "bucket", "runs", "07/01/2022", 0)
+	assert.NotNil(t, err)
+}
+
+// Ensures only the summaries within the dates are loaded
+func TestS3LoaderListRunsBetween(t *testing.T) {
+	recorded := []RunSummary{
+		{RunID: "first", TriggerTime: triggerTime},
+		{RunID: "second", TriggerTime: triggerTime.AddDate(0, 0, 7)},
+		{RunID: "third", TriggerTime: triggerTime.AddDate(0, 0, 14)},
+	}
+	s3Access := recordedRuns(recorded...)
+
+	summaries, err := S3Loader{}.ListRunsBetween(context.Background(), s3Access, "bucket", "runs", "2022-01-08", "2022-01-14")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(summaries))
+	assert.Equal(t, "second", summaries[0].RunID)
+
+	// Each summary can only be read once so the third was not loaded before
+	summaries, err = S3Loader{}.ListRunsBetween(context.Background(), s3Access, "bucket", "runs", "2022-01-15", "")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(summaries))
+	assert.Equal(t, "third", summaries[0].RunID)
+
+	s3Access = recordedRuns(recorded...)
+	summaries, err = S3Loader{}.ListRunsBetween(context.Background(), s3Access, "bucket", "runs", "", "2022-01-14")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(summaries))
+	assert.Equal(t, "second", summaries[0].RunID)
+
+	_, err = S3Loader{}.ListRunsBetween(context.Background(), s3Access, "bucket", "runs", "", "14/01/2022")
+	assert.NotNil(t, err)
+}
+
+// Ensures a run is found whichever date it was recorded under
+func TestS3LoaderGetRun(t *testing.T) {
+	s3Access := recordedRuns(
+		RunSummary{RunID: "first", TriggerTime: triggerTime},
+		RunSummary{RunID: "second", TriggerTime: triggerTime.AddDate(0, 0, 7)},
+	)
+
+	summary, err := S3Loader{}.GetRun(context.Background(), s3Access, "bucket", "runs", "second")
+	assert.Nil(t, err)
+	assert.Equal(t, "second", summary.RunID)
+
+	_, err = S3Loader{}.GetRun(context.Background(), s3Access, "bucket", "runs", "missing")
+	assert.True(t, errors.Is(err, ErrRunNotFound))
+}
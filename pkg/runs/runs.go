@@ -0,0 +1,253 @@
+// Package runs records what happened during each scheduled run.
+package runs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/logging"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// Outcomes of an order within a run.
+const (
+	OutcomePlaced    string = "placed"
+	OutcomeSkipped   string = "skipped"
+	OutcomeValidated string = "validated"
+	OutcomeFailed    string = "failed"
+)
+
+// DateLayout is the layout of the date partition runs are written under.
+const DateLayout = "2006-01-02"
+
+// RunSummary is the outcome of every order in a single run.
+type RunSummary struct {
+	RunID           string          `json:"run_id"`
+	TriggerTime     time.Time       `json:"trigger_time"`
+	StartTime       time.Time       `json:"start_time"`
+	EndTime         time.Time       `json:"end_time"`
+	DurationSeconds float64         `json:"duration_seconds"`
+	ConfigVersion   string          `json:"config_version,omitempty"`
+	Real            bool            `json:"real"`
+	Orders          []OrderOutcome  `json:"orders"`
+	EstimatedCost   decimal.Decimal `json:"estimated_cost"`
+	Error           string          `json:"error,omitempty"`
+}
+
+// OrderOutcome is what happened to a single order of the configuration.
+//
+// The price is the price the order was sized, limited or routed at when
+// known and the estimated cost is the volume at that price.
+type OrderOutcome struct {
+	Index         int              `json:"index"`
+	ID            string           `json:"id,omitempty"`
+	Exchange      string           `json:"exchange"`
+	Pair          string           `json:"pair"`
+	Direction     string           `json:"direction"`
+	OrderType     string           `json:"order_type"`
+	Volume        string           `json:"volume"`
+	Outcome       string           `json:"outcome"`
+	Reason        string           `json:"reason,omitempty"`
+	Error         string           `json:"error,omitempty"`
+	Price         *decimal.Decimal `json:"price,omitempty"`
+	EstimatedCost *decimal.Decimal `json:"estimated_cost,omitempty"`
+	TransactionID string           `json:"transaction_id,omitempty"`
+}
+
+// NewRunSummary starts a summary for a run with a random run ID. Runs which
+// were not triggered by a schedule are treated as triggered now.
+func NewRunSummary(triggerTime time.Time) (*RunSummary, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if triggerTime.IsZero() {
+		triggerTime = now
+	}
+
+	return &RunSummary{
+		RunID:       hex.EncodeToString(id),
+		TriggerTime: triggerTime.UTC(),
+		StartTime:   now,
+		Orders:      []OrderOutcome{},
+	}, nil
+}
+
+// NewOrderOutcome describes the order before anything has happened to it.
+func NewOrderOutcome(index int, order *configuration.DCAOrder) OrderOutcome {
+	return OrderOutcome{
+		Index:     index,
+		ID:        order.ID,
+		Exchange:  order.Exchange,
+		Pair:      order.Pair,
+		Direction: order.Direction,
+		OrderType: order.OrderType,
+		Volume:    order.Volume,
+	}
+}
+
+// Update refreshes the order details after the order has been
+// routed or sized and estimates the cost at the price.
+func (o *OrderOutcome) Update(order *configuration.DCAOrder, price *decimal.Decimal) {
+	o.Exchange = order.Exchange
+	o.Direction = order.Direction
+	o.Volume = order.Volume
+
+	if price == nil {
+		return
+	}
+
+	o.Price = price
+	if volume, err := decimal.NewFromString(order.Volume); err == nil {
+		cost := volume.Mul(*price)
+		o.EstimatedCost = &cost
+	}
+}
+
+// Add records the outcome of an order.
+func (r *RunSummary) Add(outcome OrderOutcome) {
+	r.Orders = append(r.Orders, outcome)
+}
+
+// Count is how many orders in the run had the outcome.
+func (r *RunSummary) Count(outcome string) int {
+	count := 0
+	for _, order := range r.Orders {
+		if order.Outcome == outcome {
+			count++
+		}
+	}
+	return count
+}
+
+// Finish records the error which ended the run, the total
+// estimated cost of the placed orders and how long the run took.
+func (r *RunSummary) Finish(err error) {
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	r.EstimatedCost = decimal.Zero
+	for _, order := range r.Orders {
+		if order.Outcome == OutcomePlaced && order.EstimatedCost != nil {
+			r.EstimatedCost = r.EstimatedCost.Add(*order.EstimatedCost)
+		}
+	}
+
+	r.EndTime = time.Now().UTC()
+	r.DurationSeconds = r.EndTime.Sub(r.StartTime).Seconds()
+}
+
+// CountExecutions counts the real runs each order was placed in, keyed by
+// the ID of the order. Orders without an ID and simulated runs are not counted.
+func CountExecutions(summaries []RunSummary) map[string]int {
+	executions := map[string]int{}
+	for _, summary := range summaries {
+		if !summary.Real {
+			continue
+		}
+
+		for _, order := range summary.Orders {
+			if order.ID != "" && order.Outcome == OutcomePlaced {
+				executions[order.ID]++
+			}
+		}
+	}
+	return executions
+}
+
+// Between gets the summaries of the runs triggered from the date through the date,
+// either of which can be empty to leave the range open.
+func Between(summaries []RunSummary, from string, to string) []RunSummary {
+	between := []RunSummary{}
+	for _, summary := range summaries {
+		if inRange(summary.TriggerTime.UTC().Format(DateLayout), from, to) {
+			between = append(between, summary)
+		}
+	}
+	return between
+}
+
+// inRange checks the date is from the date through the date.
+func inRange(date string, from string, to string) bool {
+	return (from == "" || date >= from) && (to == "" || date <= to)
+}
+
+// Key is where the summary is written under the prefix,
+// partitioned by the date the run was triggered.
+func Key(s3Prefix string, summary *RunSummary) string {
+	return fmt.Sprintf(
+		"%s/date=%s/%s.json",
+		s3Prefix,
+		summary.TriggerTime.UTC().Format(DateLayout),
+		summary.RunID,
+	)
+}
+
+// MultiError gathers the errors of every order which failed in a run.
+type MultiError struct {
+	Errors []error
+}
+
+// Add gathers the error.
+func (m *MultiError) Add(err error) {
+	m.Errors = append(m.Errors, err)
+}
+
+// ErrorOrNil is the gathered errors when there are any.
+func (m *MultiError) ErrorOrNil() error {
+	if len(m.Errors) == 0 {
+		return nil
+	}
+	return m
+}
+
+func (m *MultiError) Error() string {
+	messages := make([]string, 0, len(m.Errors))
+	for _, err := range m.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d orders failed: %s", len(m.Errors), strings.Join(messages, "; "))
+}
+
+// Recorder is an abstraction to persist run summaries.
+type Recorder interface {
+	Record(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, summary *RunSummary) error
+}
+
+// S3Recorder persists run summaries to S3.
+type S3Recorder struct{}
+
+// Record writes the summary to S3 as JSON.
+func (s S3Recorder) Record(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, summary *RunSummary) error {
+	content, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	key := Key(s3Prefix, summary)
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"runId":    summary.RunID,
+		"s3bucket": s3Bucket,
+		"s3path":   key,
+	}).Info("Recording Run Summary")
+
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &s3Bucket,
+		Key:    &key,
+		Body:   bytes.NewReader(content),
+	})
+	return err
+}
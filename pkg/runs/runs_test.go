@@ -0,0 +1,121 @@
+package runs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var triggerTime = time.Date(2022, 1, 7, 6, 0, 0, 0, time.UTC)
+
+// Ensures new runs have a random id and
+// default the trigger time to now
+func TestNewRunSummary(t *testing.T) {
+	first, err := NewRunSummary(triggerTime)
+	assert.Nil(t, err)
+	assert.Equal(t, 32, len(first.RunID))
+	assert.Equal(t, triggerTime, first.TriggerTime)
+
+	second, err := NewRunSummary(time.Time{})
+	assert.Nil(t, err)
+	assert.NotEqual(t, first.RunID, second.RunID)
+	assert.Equal(t, second.StartTime, second.TriggerTime)
+}
+
+// Ensures the outcome follows the order as it is
+// sized and estimates the cost at the price
+func TestOrderOutcomeUpdate(t *testing.T) {
+	order := configuration.DCAOrder{Exchange: "auto", Pair: "XBTGBP", Direction: "buy", Volume: "0.01"}
+	outcome := NewOrderOutcome(2, &order)
+	assert.Nil(t, outcome.EstimatedCost)
+
+	order.Exchange = "kraken"
+	order.Volume = "0.02"
+	price := decimal.NewFromInt(30000)
+	outcome.Update(&order, &price)
+
+	assert.Equal(t, 2, outcome.Index)
+	assert.Equal(t, "kraken", outcome.Exchange)
+	assert.Equal(t, "0.02", outcome.Volume)
+	assert.Equal(t, "600", outcome.EstimatedCost.String())
+}
+
+// Ensures finishing totals the placed orders and records the error
+func TestRunSummaryFinish(t *testing.T) {
+	summary, _ := NewRunSummary(triggerTime)
+	placedCost := decimal.NewFromInt(600)
+	skippedCost := decimal.NewFromInt(100)
+	summary.Add(OrderOutcome{Outcome: OutcomePlaced, EstimatedCost: &placedCost})
+	summary.Add(OrderOutcome{Outcome: OutcomePlaced})
+	summary.Add(OrderOutcome{Outcome: OutcomeSkipped, EstimatedCost: &skippedCost})
+
+	summary.Finish(errors.New("error placing order"))
+
+	assert.Equal(t, 2, summary.Count(OutcomePlaced))
+	assert.Equal(t, "600", summary.EstimatedCost.String())
+	assert.Equal(t, "error placing order", summary.Error)
+	assert.False(t, summary.EndTime.Before(summary.StartTime))
+}
+
+// Ensures summaries are written under the date they were triggered
+func TestS3RecorderRecord(t *testing.T) {
+	summary := &RunSummary{RunID: "abc", TriggerTime: triggerTime}
+	assert.Equal(t, "runs/date=2022-01-07/abc.json", Key("runs", summary))
+
+	s3Access := pkg.MockS3Access{}
+	s3Access.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		content, _ := ioutil.ReadAll(input.Body)
+		recorded := RunSummary{}
+		return *input.Bucket == "bucket" &&
+			*input.Key == "runs/date=2022-01-07/abc.json" &&
+			json.Unmarshal(content, &recorded) == nil &&
+			recorded.RunID == "abc"
+	}), mock.Anything).Return(&s3.PutObjectOutput{}, nil).Once()
+
+	err := S3Recorder{}.Record(context.Background(), s3Access, "bucket", "runs", summary)
+
+	assert.Nil(t, err)
+	s3Access.AssertExpectations(t)
+}
+
+// Ensures the multi error describes every failure
+func TestMultiError(t *testing.T) {
+	failures := &MultiError{}
+	assert.Nil(t, failures.ErrorOrNil())
+
+	failures.Add(errors.New("order 0 ADAGBP: unknown pair"))
+	failures.Add(errors.New("order 2 ETHGBP: insufficient funds"))
+
+	assert.Equal(t, "2 orders failed: order 0 ADAGBP: unknown pair; order 2 ETHGBP: insufficient funds", failures.ErrorOrNil().Error())
+}
+
+// Ensures only placed orders with an ID
+// in real runs count as executions
+func TestCountExecutions(t *testing.T) {
+	summaries := []RunSummary{
+		{Real: true, Orders: []OrderOutcome{
+			{ID: "btc-weekly", Outcome: OutcomePlaced},
+			{ID: "eth-weekly", Outcome: OutcomeSkipped},
+			{Outcome: OutcomePlaced},
+		}},
+		{Real: true, Orders: []OrderOutcome{
+			{ID: "btc-weekly", Outcome: OutcomePlaced},
+			{ID: "eth-weekly", Outcome: OutcomeFailed},
+		}},
+		{Real: false, Orders: []OrderOutcome{
+			{ID: "btc-weekly", Outcome: OutcomePlaced},
+		}},
+	}
+
+	assert.Equal(t, map[string]int{"btc-weekly": 2}, CountExecutions(summaries))
+}
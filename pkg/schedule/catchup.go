@@ -0,0 +1,89 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+)
+
+// Catch up policies decide which of the times missed while nothing
+// was running, such as when the daemon was down, are still run.
+const (
+	CatchUpNone   string = "none"
+	CatchUpLatest string = "latest"
+	CatchUpAll    string = "all"
+)
+
+// Grace is how late a time can be run before it is considered missed.
+const Grace = time.Minute
+
+// Earliest is a schedule of whichever of the schedules is next.
+type Earliest []Schedule
+
+// Next is the earliest next time of any of the schedules.
+func (e Earliest) Next(after time.Time) time.Time {
+	var next time.Time
+	for _, schedule := range e {
+		candidate := schedule.Next(after)
+		if candidate.IsZero() {
+			continue
+		}
+
+		if next.IsZero() || candidate.Before(next) {
+			next = candidate
+		}
+	}
+	return next
+}
+
+// ParseAll parses every expression into a single schedule.
+func ParseAll(expressions []string) (Schedule, error) {
+	schedules := make(Earliest, 0, len(expressions))
+	for _, expression := range expressions {
+		schedule, err := Parse(expression)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+// Due is the times of the schedule after the last run up to and including now
+// which should be run.
+//
+// Times more than the grace before now were missed and are only run when
+// the policy allows, never when they were missed longer ago than the window.
+// The latest policy runs only the most recent time, which may not have been missed.
+// A window of zero catches up however long ago the time was missed.
+func Due(schedule Schedule, last time.Time, now time.Time, policy string, window time.Duration) ([]time.Time, error) {
+	switch policy {
+	case "", CatchUpNone, CatchUpLatest, CatchUpAll:
+	default:
+		return nil, fmt.Errorf("unsupported catch up policy %s", policy)
+	}
+
+	due := []time.Time{}
+	missed := []time.Time{}
+	for _, t := range Between(schedule, last.Add(time.Nanosecond), now.Add(time.Nanosecond)) {
+		if now.Sub(t) <= Grace {
+			due = append(due, t)
+			continue
+		}
+
+		if window > 0 && now.Sub(t) > window {
+			continue
+		}
+		missed = append(missed, t)
+	}
+
+	switch {
+	case len(missed) == 0 || policy == "" || policy == CatchUpNone:
+		return due, nil
+	case policy == CatchUpLatest && len(due) > 0:
+		return due, nil
+	case policy == CatchUpLatest:
+		return missed[len(missed)-1:], nil
+	default:
+		return append(missed, due...), nil
+	}
+}
@@ -0,0 +1,254 @@
+// Package schedule evaluates the AWS schedule expressions orders are executed on.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchYears is how far ahead the next time of a cron expression is searched for.
+const searchYears = 5
+
+// Schedule is an abstraction over when orders are executed.
+type Schedule interface {
+	// Next is the first time strictly after the given time.
+	Next(after time.Time) time.Time
+}
+
+// Parse parses an AWS schedule expression
+// e.g cron(0 6 ? * FRI *) or rate(1 day).
+//
+// Times are always in UTC as they are in EventBridge.
+func Parse(expression string) (Schedule, error) {
+	expression = strings.TrimSpace(expression)
+
+	switch {
+	case strings.HasPrefix(expression, "cron(") && strings.HasSuffix(expression, ")"):
+		return parseCron(strings.TrimSuffix(strings.TrimPrefix(expression, "cron("), ")"))
+	case strings.HasPrefix(expression, "rate(") && strings.HasSuffix(expression, ")"):
+		return parseRate(strings.TrimSuffix(strings.TrimPrefix(expression, "rate("), ")"))
+	default:
+		return nil, fmt.Errorf("unsupported schedule expression %s", expression)
+	}
+}
+
+// Between is every time of the schedule from the start (inclusive) to the end (exclusive).
+func Between(schedule Schedule, start time.Time, end time.Time) []time.Time {
+	times := []time.Time{}
+	for next := schedule.Next(start.Add(-time.Nanosecond)); !next.IsZero() && next.Before(end); next = schedule.Next(next) {
+		times = append(times, next)
+	}
+	return times
+}
+
+// Rate is a schedule which repeats at a fixed interval.
+//
+// EventBridge starts a rate when the rule is created, here it is aligned
+// to the unix epoch so daily rates run at midnight UTC.
+type Rate struct {
+	Interval time.Duration
+}
+
+// Next is the next multiple of the interval after the given time.
+func (r Rate) Next(after time.Time) time.Time {
+	after = after.UTC()
+	next := after.Truncate(r.Interval)
+	for !next.After(after) {
+		next = next.Add(r.Interval)
+	}
+	return next
+}
+
+func parseRate(expression string) (Schedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid rate expression %s", expression)
+	}
+
+	value, err := strconv.Atoi(fields[0])
+	if err != nil || value <= 0 {
+		return nil, fmt.Errorf("invalid rate value %s", fields[0])
+	}
+
+	var unit time.Duration
+	switch strings.TrimSuffix(fields[1], "s") {
+	case "minute":
+		unit = time.Minute
+	case "hour":
+		unit = time.Hour
+	case "day":
+		unit = 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("invalid rate unit %s", fields[1])
+	}
+
+	return Rate{Interval: time.Duration(value) * unit}, nil
+}
+
+// Cron is a schedule of the six field AWS cron expression
+// minutes, hours, day of month, month, day of week and year.
+type Cron struct {
+	minutes       map[int]bool
+	hours         map[int]bool
+	daysOfMonth   map[int]bool
+	months        map[int]bool
+	daysOfWeek    map[int]bool
+	years         map[int]bool
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+var monthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+// AWS numbers the days of the week from Sunday as 1.
+var dayNames = map[string]int{
+	"SUN": 1, "MON": 2, "TUE": 3, "WED": 4, "THU": 5, "FRI": 6, "SAT": 7,
+}
+
+func parseCron(expression string) (Schedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("cron expression %s must have 6 fields", expression)
+	}
+
+	if fields[2] != "?" && fields[4] != "?" {
+		return nil, fmt.Errorf("cron expression %s must use ? for either the day of month or day of week", expression)
+	}
+
+	var err error
+	cron := Cron{
+		anyDayOfMonth: fields[2] == "?" || fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "?" || fields[4] == "*",
+	}
+
+	if cron.minutes, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if cron.hours, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if cron.daysOfMonth, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if cron.months, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, err
+	}
+	if cron.daysOfWeek, err = parseField(fields[4], 1, 7, dayNames); err != nil {
+		return nil, err
+	}
+	if cron.years, err = parseField(fields[5], 1970, 2199, nil); err != nil {
+		return nil, err
+	}
+
+	return cron, nil
+}
+
+// parseField parses lists, ranges and increments of a single cron field.
+func parseField(field string, min int, max int, names map[string]int) (map[int]bool, error) {
+	values := map[int]bool{}
+	if field == "*" || field == "?" {
+		for value := min; value <= max; value++ {
+			values[value] = true
+		}
+		return values, nil
+	}
+
+	parse := func(value string) (int, error) {
+		if named, ok := names[strings.ToUpper(value)]; ok {
+			return named, nil
+		}
+
+		number, err := strconv.Atoi(value)
+		if err != nil || number < min || number > max {
+			return 0, fmt.Errorf("invalid cron value %s, must be between %d and %d", value, min, max)
+		}
+		return number, nil
+	}
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[index+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid cron increment %s", part)
+			}
+			part = part[:index]
+		}
+
+		start, end := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = parse(bounds[0]); err != nil {
+				return nil, err
+			}
+			if end, err = parse(bounds[1]); err != nil {
+				return nil, err
+			}
+		default:
+			var err error
+			if start, err = parse(part); err != nil {
+				return nil, err
+			}
+			if step == 1 {
+				end = start
+			}
+		}
+
+		if start > end {
+			return nil, fmt.Errorf("invalid cron range %s", part)
+		}
+
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
+
+// Next finds the next minute matching every field, or the zero time
+// when there is none within the next few years.
+func (c Cron) Next(after time.Time) time.Time {
+	next := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(searchYears, 0, 0)
+
+	for next.Before(limit) {
+		if !c.matchesDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !c.hours[next.Hour()] {
+			next = next.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+
+		if !c.minutes[next.Minute()] {
+			next = next.Add(time.Minute)
+			continue
+		}
+
+		return next
+	}
+
+	return time.Time{}
+}
+
+func (c Cron) matchesDay(t time.Time) bool {
+	if !c.years[t.Year()] || !c.months[int(t.Month())] {
+		return false
+	}
+
+	dayOfMonth := c.anyDayOfMonth || c.daysOfMonth[t.Day()]
+	dayOfWeek := c.anyDayOfWeek || c.daysOfWeek[int(t.Weekday())+1]
+	return dayOfMonth && dayOfWeek
+}
@@ -0,0 +1,123 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func at(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Ensures cron expressions find the next matching minute
+func TestCronNext(t *testing.T) {
+	cases := []struct {
+		expression string
+		after      string
+		expected   string
+	}{
+		{"cron(0 6 ? * FRI *)", "2022-01-05T12:00:00Z", "2022-01-07T06:00:00Z"},
+		{"cron(0 6 ? * FRI *)", "2022-01-07T06:00:00Z", "2022-01-14T06:00:00Z"},
+		{"cron(0 6 ? * MON-FRI *)", "2022-01-07T07:00:00Z", "2022-01-10T06:00:00Z"},
+		{"cron(30 9,21 * * ? *)", "2022-01-01T10:00:00Z", "2022-01-01T21:30:00Z"},
+		{"cron(0/15 * * * ? *)", "2022-01-01T10:16:00Z", "2022-01-01T10:30:00Z"},
+		{"cron(0 0 1 JAN,JUL ? *)", "2022-02-01T00:00:00Z", "2022-07-01T00:00:00Z"},
+		{"cron(0 0 29 2 ? *)", "2022-01-01T00:00:00Z", "2024-02-29T00:00:00Z"},
+	}
+
+	for _, c := range cases {
+		schedule, err := Parse(c.expression)
+		assert.Nil(t, err, c.expression)
+		assert.Equal(t, at(c.expected), schedule.Next(at(c.after)), c.expression)
+	}
+}
+
+// Ensures rates repeat at a fixed interval from the epoch
+func TestRateNext(t *testing.T) {
+	schedule, err := Parse("rate(1 day)")
+	assert.Nil(t, err)
+	assert.Equal(t, at("2022-01-02T00:00:00Z"), schedule.Next(at("2022-01-01T00:00:00Z")))
+
+	schedule, err = Parse("rate(6 hours)")
+	assert.Nil(t, err)
+	assert.Equal(t, at("2022-01-01T12:00:00Z"), schedule.Next(at("2022-01-01T07:00:00Z")))
+}
+
+// Ensures every time between two times is found
+func TestBetween(t *testing.T) {
+	schedule, err := Parse("cron(0 6 ? * FRI *)")
+	assert.Nil(t, err)
+
+	times := Between(schedule, at("2022-01-07T06:00:00Z"), at("2022-01-28T06:00:00Z"))
+	assert.Equal(t, []time.Time{at("2022-01-07T06:00:00Z"), at("2022-01-14T06:00:00Z"), at("2022-01-21T06:00:00Z")}, times)
+}
+
+// Ensures invalid expressions are rejected
+func TestParseInvalid(t *testing.T) {
+	cases := map[string]string{
+		"0 6 * * *":            "unsupported schedule expression",
+		"cron(0 6 * *)":        "must have 6 fields",
+		"cron(0 6 1 * MON *)":  "must use ? for either the day of month or day of week",
+		"cron(61 6 ? * FRI *)": "invalid cron value 61",
+		"cron(0 6 ? * FUN *)":  "invalid cron value FUN",
+		"rate(0 days)":         "invalid rate value 0",
+		"rate(1 week)":         "invalid rate unit week",
+	}
+
+	for expression, expected := range cases {
+		_, err := Parse(expression)
+		assert.Contains(t, err.Error(), expected, expression)
+	}
+}
+
+// Ensures the earliest of several schedules is next
+func TestParseAll(t *testing.T) {
+	schedule, err := ParseAll([]string{"cron(0 6 ? * FRI *)", "cron(0 6 ? * WED *)"})
+	assert.Nil(t, err)
+	assert.Equal(t, at("2022-01-05T06:00:00Z"), schedule.Next(at("2022-01-03T00:00:00Z")))
+	assert.Equal(t, at("2022-01-07T06:00:00Z"), schedule.Next(at("2022-01-05T06:00:00Z")))
+
+	_, err = ParseAll([]string{"cron(0 6 ? * FRI *)", "every friday"})
+	assert.NotNil(t, err)
+}
+
+// Ensures times missed longer ago than the grace
+// are only run when the catch up policy allows
+func TestDue(t *testing.T) {
+	schedule, err := Parse("cron(0 6 * * ? *)")
+	assert.Nil(t, err)
+
+	last := at("2022-01-01T06:00:00Z")
+	cases := []struct {
+		name     string
+		now      string
+		policy   string
+		window   time.Duration
+		expected []time.Time
+	}{
+		{"nothing due", "2022-01-02T05:00:00Z", CatchUpAll, 0, []time.Time{}},
+		{"on time", "2022-01-02T06:00:30Z", CatchUpNone, 0, []time.Time{at("2022-01-02T06:00:00Z")}},
+		{"default skips missed", "2022-01-04T12:00:00Z", "", 0, []time.Time{}},
+		{"none skips missed", "2022-01-04T12:00:00Z", CatchUpNone, 0, []time.Time{}},
+		{"latest runs most recent", "2022-01-04T12:00:00Z", CatchUpLatest, 0, []time.Time{at("2022-01-04T06:00:00Z")}},
+		{"latest prefers on time", "2022-01-04T06:00:00Z", CatchUpLatest, 0, []time.Time{at("2022-01-04T06:00:00Z")}},
+		{"all runs every missed", "2022-01-04T12:00:00Z", CatchUpAll, 0, []time.Time{at("2022-01-02T06:00:00Z"), at("2022-01-03T06:00:00Z"), at("2022-01-04T06:00:00Z")}},
+		{"all within window", "2022-01-04T12:00:00Z", CatchUpAll, 36 * time.Hour, []time.Time{at("2022-01-03T06:00:00Z"), at("2022-01-04T06:00:00Z")}},
+		{"latest outside window", "2022-01-04T12:00:00Z", CatchUpLatest, time.Hour, []time.Time{}},
+	}
+
+	for _, c := range cases {
+		due, err := Due(schedule, last, at(c.now), c.policy, c.window)
+		assert.Nil(t, err, c.name)
+		assert.Equal(t, c.expected, due, c.name)
+	}
+
+	_, err = Due(schedule, last, at("2022-01-04T12:00:00Z"), "sometimes", 0)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,119 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+)
+
+// Multiplier rules which can be recorded against an order.
+const (
+	RuleNone         string = "none"
+	RuleDrawdown     string = "drawdown"
+	RuleAboveAverage string = "above_average"
+)
+
+// DipMultiplier is an abstraction to scale an order based on recent price action.
+type DipMultiplier interface {
+	Scale(order *configuration.DCAOrder, candles []orders.Candle, price decimal.Decimal, now time.Time) (*orders.MultiplierDecision, error)
+}
+
+// DipBuyer scales orders up when the price has dipped
+// and optionally down when it is above the moving average.
+type DipBuyer struct{}
+
+// CandleDays is the number of daily candles needed to evaluate the dip configuration.
+func CandleDays(conf *configuration.DipConfig) int {
+	if conf.MovingAverageDays > conf.LookbackDays {
+		return conf.MovingAverageDays
+	}
+	return conf.LookbackDays
+}
+
+// Scale decides the multiplier for the order from the daily candles
+// and the current price then returns the decision with the scaled volume.
+func (d DipBuyer) Scale(order *configuration.DCAOrder, candles []orders.Candle, price decimal.Decimal, now time.Time) (*orders.MultiplierDecision, error) {
+	conf := order.Dip
+	if conf == nil {
+		return nil, errors.New("order has no dip configuration")
+	}
+
+	if conf.LookbackDays <= 0 {
+		return nil, fmt.Errorf("dip lookback days must be positive, got %d", conf.LookbackDays)
+	}
+
+	baseVolume, err := decimal.NewFromString(order.Volume)
+	if err != nil {
+		return nil, fmt.Errorf("invalid volume %s: %w", order.Volume, err)
+	}
+
+	decision := &orders.MultiplierDecision{
+		Rule:       RuleNone,
+		Price:      price,
+		High:       price,
+		Multiplier: decimal.NewFromInt(1),
+		BaseVolume: order.Volume,
+	}
+
+	// Highest high within the lookback including the current price
+	lookbackStart := now.AddDate(0, 0, -conf.LookbackDays)
+	for _, candle := range candles {
+		if candle.Time.Before(lookbackStart) {
+			continue
+		}
+		decision.High = decimal.Max(decision.High, candle.High)
+	}
+
+	if decision.High.IsPositive() {
+		decision.DrawdownPct = decision.High.Sub(price).Div(decision.High).Mul(oneHundred).Round(4)
+	}
+
+	// The deepest tier reached applies
+	appliedDrawdown := decimal.Zero
+	for _, tier := range conf.Tiers {
+		if decision.DrawdownPct.LessThan(tier.DrawdownPct) {
+			continue
+		}
+
+		if decision.Rule == RuleNone || tier.DrawdownPct.GreaterThan(appliedDrawdown) {
+			decision.Rule = RuleDrawdown
+			decision.Multiplier = tier.Multiplier
+			appliedDrawdown = tier.DrawdownPct
+		}
+	}
+
+	if conf.MovingAverageDays > 0 {
+		averageStart := now.AddDate(0, 0, -conf.MovingAverageDays)
+		total := decimal.Zero
+		count := int64(0)
+		for _, candle := range candles {
+			if candle.Time.Before(averageStart) {
+				continue
+			}
+			total = total.Add(candle.Close)
+			count++
+		}
+
+		if count > 0 {
+			average := total.Div(decimal.NewFromInt(count))
+			decision.MovingAverage = &average
+
+			if decision.Rule == RuleNone && conf.AboveAverageMultiplier != nil && price.GreaterThan(average) {
+				decision.Rule = RuleAboveAverage
+				decision.Multiplier = *conf.AboveAverageMultiplier
+			}
+		}
+	}
+
+	// A zero volume would be rejected by the exchange
+	if !decision.Multiplier.IsPositive() {
+		return nil, fmt.Errorf("dip multiplier %s must be positive", decision.Multiplier)
+	}
+
+	decision.Volume = baseVolume.Mul(decision.Multiplier).Truncate(volumePrecision).String()
+	return decision, nil
+}
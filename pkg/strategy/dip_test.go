@@ -0,0 +1,118 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+var dipNow = time.Date(2022, 2, 1, 6, 0, 0, 0, time.UTC)
+
+func dipOrder() *configuration.DCAOrder {
+	below := decimal.RequireFromString("0.5")
+	return &configuration.DCAOrder{
+		Exchange: "kraken",
+		Pair:     "XBTGBP",
+		Volume:   "0.01",
+		Enabled:  true,
+		Dip: &configuration.DipConfig{
+			LookbackDays: 30,
+			Tiers: []configuration.DipTier{
+				{DrawdownPct: decimal.NewFromInt(10), Multiplier: decimal.RequireFromString("1.5")},
+				{DrawdownPct: decimal.NewFromInt(20), Multiplier: decimal.NewFromInt(2)},
+			},
+			MovingAverageDays:      3,
+			AboveAverageMultiplier: &below,
+		},
+	}
+}
+
+// candles creates a daily candle for each close ending the day before now
+func candles(closes ...string) []orders.Candle {
+	result := make([]orders.Candle, len(closes))
+	for index, close := range closes {
+		price := decimal.RequireFromString(close)
+		result[index] = orders.Candle{
+			Time:  dipNow.AddDate(0, 0, index-len(closes)),
+			High:  price,
+			Close: price,
+		}
+	}
+	return result
+}
+
+// Ensures the deepest tier reached is applied
+func TestScaleDeepestTier(t *testing.T) {
+	decision, err := DipBuyer{}.Scale(dipOrder(), candles("100", "90", "80"), decimal.NewFromInt(75), dipNow)
+
+	assert.Nil(t, err)
+	assert.Equal(t, RuleDrawdown, decision.Rule)
+	assert.Equal(t, "25", decision.DrawdownPct.String())
+	assert.Equal(t, "100", decision.High.String())
+	assert.Equal(t, "2", decision.Multiplier.String())
+	assert.Equal(t, "0.01", decision.BaseVolume)
+	assert.Equal(t, "0.02", decision.Volume)
+}
+
+// Ensures a shallow dip applies the first tier
+func TestScaleShallowTier(t *testing.T) {
+	decision, err := DipBuyer{}.Scale(dipOrder(), candles("100", "95", "90"), decimal.NewFromInt(88), dipNow)
+
+	assert.Nil(t, err)
+	assert.Equal(t, RuleDrawdown, decision.Rule)
+	assert.Equal(t, "1.5", decision.Multiplier.String())
+	assert.Equal(t, "0.015", decision.Volume)
+}
+
+// Ensures when not in a dip and above the moving
+// average the order is scaled down
+func TestScaleAboveAverage(t *testing.T) {
+	decision, err := DipBuyer{}.Scale(dipOrder(), candles("90", "95", "100"), decimal.NewFromInt(101), dipNow)
+
+	assert.Nil(t, err)
+	assert.Equal(t, RuleAboveAverage, decision.Rule)
+	assert.Equal(t, "95", decision.MovingAverage.String())
+	assert.Equal(t, "0.005", decision.Volume)
+}
+
+// Ensures candles outside of the lookback are ignored
+func TestScaleIgnoresOldHighs(t *testing.T) {
+	order := dipOrder()
+	order.Dip.LookbackDays = 2
+	order.Dip.AboveAverageMultiplier = nil
+
+	decision, err := DipBuyer{}.Scale(order, candles("200", "100", "100"), decimal.NewFromInt(99), dipNow)
+
+	assert.Nil(t, err)
+	assert.Equal(t, RuleNone, decision.Rule)
+	assert.Equal(t, "1", decision.Multiplier.String())
+	assert.Equal(t, "0.01", decision.Volume)
+}
+
+// Ensures invalid configuration is rejected
+func TestScaleInvalid(t *testing.T) {
+	order := dipOrder()
+	order.Volume = "ten"
+
+	decision, err := DipBuyer{}.Scale(order, nil, decimal.NewFromInt(1), dipNow)
+	assert.Nil(t, decision)
+	assert.Contains(t, err.Error(), "invalid volume ten")
+
+	order = dipOrder()
+	order.Dip.LookbackDays = 0
+
+	decision, err = DipBuyer{}.Scale(order, nil, decimal.NewFromInt(1), dipNow)
+	assert.Nil(t, decision)
+	assert.Contains(t, err.Error(), "lookback days must be positive")
+
+	order = dipOrder()
+	order.Dip.Tiers[0].Multiplier = decimal.Zero
+
+	decision, err = DipBuyer{}.Scale(order, candles("100", "95", "90"), decimal.NewFromInt(88), dipNow)
+	assert.Nil(t, decision)
+	assert.EqualError(t, err, "dip multiplier 0 must be positive")
+}
@@ -0,0 +1,49 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+)
+
+// LimitPrice resolves the limit price of the order.
+//
+// A fixed limit price is used as is, otherwise the offset is applied
+// below the current bid for buys and above the current ask for sells.
+func LimitPrice(order *configuration.DCAOrder, ticker *orders.Ticker) (decimal.Decimal, error) {
+	if order.LimitPrice != nil {
+		if !order.LimitPrice.IsPositive() {
+			return decimal.Zero, fmt.Errorf("limit price %s must be positive", order.LimitPrice)
+		}
+		return *order.LimitPrice, nil
+	}
+
+	if order.LimitOffsetPct == nil {
+		return decimal.Zero, errors.New("limit order requires limit_price or limit_offset_pct")
+	}
+
+	if ticker == nil {
+		return decimal.Zero, errors.New("limit offset requires a ticker")
+	}
+
+	offset := order.LimitOffsetPct.Div(oneHundred)
+	var price decimal.Decimal
+	switch strings.ToLower(order.Direction) {
+	case "buy":
+		price = ticker.Bid.Mul(decimal.NewFromInt(1).Sub(offset))
+	case "sell":
+		price = ticker.Ask.Mul(decimal.NewFromInt(1).Add(offset))
+	default:
+		return decimal.Zero, fmt.Errorf("unsupported direction %s", order.Direction)
+	}
+
+	if !price.IsPositive() {
+		return decimal.Zero, fmt.Errorf("limit price %s for pair %s must be positive", price, order.Pair)
+	}
+
+	return price, nil
+}
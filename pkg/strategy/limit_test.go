@@ -0,0 +1,48 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensures a fixed limit price is used as is
+func TestLimitPriceFixed(t *testing.T) {
+	limit := decimal.NewFromInt(25000)
+	order := &configuration.DCAOrder{Direction: "buy", OrderType: "limit", LimitPrice: &limit}
+
+	price, err := LimitPrice(order, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "25000", price.String())
+}
+
+// Ensures the offset is applied below the bid
+// for buys and above the ask for sells
+func TestLimitPriceOffset(t *testing.T) {
+	offset := decimal.NewFromInt(2)
+	quote := &orders.Ticker{Ask: decimal.NewFromInt(110), Bid: decimal.NewFromInt(100)}
+
+	buy := &configuration.DCAOrder{Direction: "buy", OrderType: "limit", LimitOffsetPct: &offset}
+	price, err := LimitPrice(buy, quote)
+	assert.Nil(t, err)
+	assert.Equal(t, "98", price.String())
+
+	sell := &configuration.DCAOrder{Direction: "sell", OrderType: "limit", LimitOffsetPct: &offset}
+	price, err = LimitPrice(sell, quote)
+	assert.Nil(t, err)
+	assert.Equal(t, "112.2", price.String())
+}
+
+// Ensures limit orders without a price are rejected
+func TestLimitPriceMissing(t *testing.T) {
+	order := &configuration.DCAOrder{Direction: "buy", OrderType: "limit"}
+
+	price, err := LimitPrice(order, nil)
+
+	assert.True(t, price.IsZero())
+	assert.Contains(t, err.Error(), "requires limit_price or limit_offset_pct")
+}
@@ -0,0 +1,176 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/logging"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// volumePrecision is the number of decimal places order volumes are truncated to.
+const volumePrecision int32 = 8
+
+var oneHundred = decimal.NewFromInt(100)
+
+// PortfolioPlanner is an abstraction to plan the orders
+// needed to move a portfolio toward its target allocation.
+type PortfolioPlanner interface {
+	Plan(ctx context.Context, conf *configuration.PortfolioConfig, market orders.MarketData) ([]configuration.DCAOrder, error)
+}
+
+// Rebalancer plans portfolio orders by splitting the contribution
+// across the targets which are furthest below their target weight.
+type Rebalancer struct{}
+
+// holding is the current position of a single portfolio target.
+type holding struct {
+	target configuration.PortfolioTarget
+	ticker *orders.Ticker
+	value  decimal.Decimal
+}
+
+// Plan fetches the current balances and prices from the exchange
+// and returns the orders which move the portfolio toward its targets.
+//
+// Overweight assets are only sold when selling is allowed and they have drifted
+// above their target weight by more than the tolerance (in percentage points),
+// any proceeds are then reallocated alongside the contribution.
+func (r Rebalancer) Plan(ctx context.Context, conf *configuration.PortfolioConfig, market orders.MarketData) ([]configuration.DCAOrder, error) {
+	if len(conf.Targets) == 0 {
+		return nil, errors.New("portfolio has no targets")
+	}
+
+	if conf.Contribution.IsNegative() {
+		return nil, fmt.Errorf("portfolio contribution %s cannot be negative", conf.Contribution)
+	}
+
+	totalWeight := decimal.Zero
+	for _, target := range conf.Targets {
+		if target.Weight.IsNegative() {
+			return nil, fmt.Errorf("portfolio target %s has a negative weight", target.Asset)
+		}
+		totalWeight = totalWeight.Add(target.Weight)
+	}
+
+	if !totalWeight.IsPositive() {
+		return nil, errors.New("portfolio target weights must add up to more than zero")
+	}
+
+	balances, err := market.GetBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	holdings := make([]holding, len(conf.Targets))
+	holdingsValue := decimal.Zero
+	for index, target := range conf.Targets {
+		ticker, err := market.GetTicker(ctx, target.Pair)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ticker.Ask.IsPositive() || !ticker.Bid.IsPositive() {
+			return nil, fmt.Errorf("no valid price for pair %s", target.Pair)
+		}
+
+		value := balances[target.Asset].Mul(ticker.Last)
+		holdings[index] = holding{target: target, ticker: ticker, value: value}
+		holdingsValue = holdingsValue.Add(value)
+	}
+
+	totalValue := holdingsValue.Add(conf.Contribution)
+	if !totalValue.IsPositive() {
+		return []configuration.DCAOrder{}, nil
+	}
+
+	planned := []configuration.DCAOrder{}
+	cash := conf.Contribution
+
+	// Sell down overweight assets first so the proceeds can be reallocated
+	if conf.AllowSell {
+		for index, h := range holdings {
+			targetValue := totalValue.Mul(h.target.Weight).Div(totalWeight)
+			drift := h.value.Sub(targetValue).Div(totalValue).Mul(oneHundred)
+
+			if drift.LessThanOrEqual(conf.Tolerance) {
+				continue
+			}
+
+			sellValue := h.value.Sub(targetValue)
+			volume := sellValue.Div(h.ticker.Bid).Truncate(volumePrecision)
+			if !volume.IsPositive() {
+				continue
+			}
+
+			logging.FromContext(ctx).WithFields(logrus.Fields{
+				"asset":  h.target.Asset,
+				"pair":   h.target.Pair,
+				"drift":  drift.StringFixed(2),
+				"value":  sellValue.StringFixed(2),
+				"volume": volume.String(),
+			}).Info("Selling overweight asset")
+
+			planned = append(planned, portfolioOrder(conf, h.target.Pair, "sell", volume))
+			cash = cash.Add(sellValue)
+			holdings[index].value = targetValue
+		}
+	}
+
+	// Split the cash across the assets which are below their target
+	deficits := make([]decimal.Decimal, len(holdings))
+	totalDeficit := decimal.Zero
+	for index, h := range holdings {
+		targetValue := totalValue.Mul(h.target.Weight).Div(totalWeight)
+		deficit := decimal.Max(targetValue.Sub(h.value), decimal.Zero)
+
+		deficits[index] = deficit
+		totalDeficit = totalDeficit.Add(deficit)
+	}
+
+	for index, h := range holdings {
+		var buyValue decimal.Decimal
+		switch {
+		case totalDeficit.IsZero():
+			buyValue = cash.Mul(h.target.Weight).Div(totalWeight)
+		case totalDeficit.GreaterThanOrEqual(cash):
+			buyValue = cash.Mul(deficits[index]).Div(totalDeficit)
+		default:
+			surplus := cash.Sub(totalDeficit).Mul(h.target.Weight).Div(totalWeight)
+			buyValue = deficits[index].Add(surplus)
+		}
+
+		volume := buyValue.Div(h.ticker.Ask).Truncate(volumePrecision)
+		if !volume.IsPositive() {
+			continue
+		}
+
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			"asset":  h.target.Asset,
+			"pair":   h.target.Pair,
+			"value":  buyValue.StringFixed(2),
+			"volume": volume.String(),
+		}).Info("Buying toward target")
+
+		planned = append(planned, portfolioOrder(conf, h.target.Pair, "buy", volume))
+	}
+
+	return planned, nil
+}
+
+// portfolioOrder creates a market order for a planned portfolio trade.
+func portfolioOrder(conf *configuration.PortfolioConfig, pair string, direction string, volume decimal.Decimal) configuration.DCAOrder {
+	return configuration.DCAOrder{
+		Exchange:  conf.Exchange,
+		Direction: direction,
+		OrderType: "market",
+		Volume:    volume.String(),
+		Pair:      pair,
+		Validate:  conf.Validate,
+		Enabled:   true,
+	}
+}
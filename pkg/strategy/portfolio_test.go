@@ -0,0 +1,171 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMarketData struct {
+	mock.Mock
+}
+
+func (m *MockMarketData) GetBalances(ctx context.Context) (map[string]decimal.Decimal, error) {
+	args := m.Called()
+	return args.Get(0).(map[string]decimal.Decimal), args.Error(1)
+}
+
+func (m *MockMarketData) GetTicker(ctx context.Context, pair string) (*orders.Ticker, error) {
+	args := m.Called(pair)
+	return args.Get(0).(*orders.Ticker), args.Error(1)
+}
+
+func (m *MockMarketData) GetOHLC(ctx context.Context, pair string, since time.Time) ([]orders.Candle, error) {
+	args := m.Called(pair, since)
+	return args.Get(0).([]orders.Candle), args.Error(1)
+}
+
+func ticker(pair string, price string) *orders.Ticker {
+	p := decimal.RequireFromString(price)
+	return &orders.Ticker{Pair: pair, Ask: p, Bid: p, Last: p}
+}
+
+func portfolio() *configuration.PortfolioConfig {
+	return &configuration.PortfolioConfig{
+		Exchange:     "kraken",
+		Contribution: decimal.NewFromInt(100),
+		Tolerance:    decimal.NewFromInt(5),
+		Enabled:      true,
+		Targets: []configuration.PortfolioTarget{
+			{Asset: "XXBT", Pair: "XBTGBP", Weight: decimal.NewFromInt(60)},
+			{Asset: "XETH", Pair: "ETHGBP", Weight: decimal.NewFromInt(30)},
+			{Asset: "ADA", Pair: "ADAGBP", Weight: decimal.NewFromInt(10)},
+		},
+	}
+}
+
+// Ensures when nothing is held the contribution
+// is split by the target weights
+func TestPlanEmptyPortfolio(t *testing.T) {
+	market := &MockMarketData{}
+	market.On("GetBalances").Return(map[string]decimal.Decimal{}, nil)
+	market.On("GetTicker", "XBTGBP").Return(ticker("XBTGBP", "30000"), nil)
+	market.On("GetTicker", "ETHGBP").Return(ticker("ETHGBP", "2000"), nil)
+	market.On("GetTicker", "ADAGBP").Return(ticker("ADAGBP", "1"), nil)
+
+	planned, err := Rebalancer{}.Plan(context.Background(), portfolio(), market)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(planned))
+	assert.Equal(t, "XBTGBP", planned[0].Pair)
+	assert.Equal(t, "buy", planned[0].Direction)
+	assert.Equal(t, "0.002", planned[0].Volume)
+	assert.Equal(t, "0.015", planned[1].Volume)
+	assert.Equal(t, "10", planned[2].Volume)
+	assert.Equal(t, "kraken", planned[2].Exchange)
+	assert.Equal(t, "market", planned[2].OrderType)
+	assert.True(t, planned[2].Enabled)
+	market.AssertExpectations(t)
+}
+
+// Ensures the contribution is directed to
+// the underweight assets first
+func TestPlanUnderweightAsset(t *testing.T) {
+	// Holdings: BTC 600, ETH 200, ADA 100 -> total after contribution 1000
+	// Targets:  BTC 600, ETH 300, ADA 100 -> only ETH is underweight
+	market := &MockMarketData{}
+	market.On("GetBalances").Return(map[string]decimal.Decimal{
+		"XXBT": decimal.RequireFromString("0.02"),
+		"XETH": decimal.RequireFromString("0.1"),
+		"ADA":  decimal.RequireFromString("100"),
+	}, nil)
+	market.On("GetTicker", "XBTGBP").Return(ticker("XBTGBP", "30000"), nil)
+	market.On("GetTicker", "ETHGBP").Return(ticker("ETHGBP", "2000"), nil)
+	market.On("GetTicker", "ADAGBP").Return(ticker("ADAGBP", "1"), nil)
+
+	planned, err := Rebalancer{}.Plan(context.Background(), portfolio(), market)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(planned))
+	assert.Equal(t, "ETHGBP", planned[0].Pair)
+	assert.Equal(t, "0.05", planned[0].Volume)
+}
+
+// Ensures overweight assets outside of the tolerance
+// are sold when selling is allowed
+func TestPlanSellOverweight(t *testing.T) {
+	// Holdings: BTC 900, ETH 0, ADA 0 -> total after contribution 1000
+	// BTC is 30 points above its 60% target
+	market := &MockMarketData{}
+	market.On("GetBalances").Return(map[string]decimal.Decimal{
+		"XXBT": decimal.RequireFromString("0.03"),
+	}, nil)
+	market.On("GetTicker", "XBTGBP").Return(ticker("XBTGBP", "30000"), nil)
+	market.On("GetTicker", "ETHGBP").Return(ticker("ETHGBP", "2000"), nil)
+	market.On("GetTicker", "ADAGBP").Return(ticker("ADAGBP", "1"), nil)
+
+	conf := portfolio()
+	conf.AllowSell = true
+	planned, err := Rebalancer{}.Plan(context.Background(), conf, market)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(planned))
+	assert.Equal(t, "sell", planned[0].Direction)
+	assert.Equal(t, "XBTGBP", planned[0].Pair)
+	assert.Equal(t, "0.01", planned[0].Volume)
+	assert.Equal(t, "buy", planned[1].Direction)
+	assert.Equal(t, "0.15", planned[1].Volume)
+	assert.Equal(t, "100", planned[2].Volume)
+}
+
+// Ensures overweight assets are not sold
+// when selling is not allowed
+func TestPlanSellNotAllowed(t *testing.T) {
+	market := &MockMarketData{}
+	market.On("GetBalances").Return(map[string]decimal.Decimal{
+		"XXBT": decimal.RequireFromString("0.03"),
+	}, nil)
+	market.On("GetTicker", "XBTGBP").Return(ticker("XBTGBP", "30000"), nil)
+	market.On("GetTicker", "ETHGBP").Return(ticker("ETHGBP", "2000"), nil)
+	market.On("GetTicker", "ADAGBP").Return(ticker("ADAGBP", "1"), nil)
+
+	planned, err := Rebalancer{}.Plan(context.Background(), portfolio(), market)
+
+	assert.Nil(t, err)
+	for _, order := range planned {
+		assert.Equal(t, "buy", order.Direction)
+		assert.NotEqual(t, "XBTGBP", order.Pair)
+	}
+}
+
+// Ensures invalid weights are rejected
+func TestPlanInvalidWeights(t *testing.T) {
+	conf := portfolio()
+	for index := range conf.Targets {
+		conf.Targets[index].Weight = decimal.Zero
+	}
+
+	planned, err := Rebalancer{}.Plan(context.Background(), conf, &MockMarketData{})
+
+	assert.Nil(t, planned)
+	assert.Contains(t, err.Error(), "weights must add up to more than zero")
+}
+
+// Ensures errors getting market data are returned
+func TestPlanErrorGettingBalances(t *testing.T) {
+	expectedErr := errors.New("error getting balances")
+	market := &MockMarketData{}
+	market.On("GetBalances").Return(map[string]decimal.Decimal{}, expectedErr)
+
+	planned, err := Rebalancer{}.Plan(context.Background(), portfolio(), market)
+
+	assert.Nil(t, planned)
+	assert.Equal(t, expectedErr, err)
+}
@@ -0,0 +1,112 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeAuto routes an order to whichever exchange is cheapest.
+const ExchangeAuto string = "auto"
+
+// Router is an abstraction to decide which exchange an order is placed on.
+type Router interface {
+	Route(ctx context.Context, order *configuration.DCAOrder, orderers map[string]orders.Orderer) (*orders.RoutingDecision, error)
+}
+
+// BestExecution routes orders to the exchange with the best effective price
+// where the effective price of buys is the ask plus the fee
+// and the effective price of sells is the bid minus the fee.
+//
+// Exchanges which can not quote the pair are recorded but not considered.
+type BestExecution struct{}
+
+// Route quotes the order on every exchange and decides which exchange to use.
+func (b BestExecution) Route(ctx context.Context, order *configuration.DCAOrder, orderers map[string]orders.Orderer) (*orders.RoutingDecision, error) {
+	direction := strings.ToLower(order.Direction)
+	if direction != "buy" && direction != "sell" {
+		return nil, fmt.Errorf("unsupported direction %s", order.Direction)
+	}
+
+	// Quote in a stable order so ties are decided the same way every run
+	exchanges := make([]string, 0, len(orderers))
+	for exchange := range orderers {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+
+	decision := &orders.RoutingDecision{Alternatives: make([]orders.RouteQuote, 0, len(exchanges))}
+	var best *orders.RouteQuote
+	for _, exchange := range exchanges {
+		quote := quoteExchange(ctx, exchange, orderers[exchange], order.Pair, direction)
+		decision.Alternatives = append(decision.Alternatives, quote)
+
+		if quote.Error != "" {
+			continue
+		}
+
+		if best == nil ||
+			(direction == "buy" && quote.EffectivePrice.LessThan(best.EffectivePrice)) ||
+			(direction == "sell" && quote.EffectivePrice.GreaterThan(best.EffectivePrice)) {
+			best = &decision.Alternatives[len(decision.Alternatives)-1]
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no exchange could quote pair %s", order.Pair)
+	}
+
+	decision.Exchange = best.Exchange
+	return decision, nil
+}
+
+// quoteExchange gets the effective price of the pair on the exchange
+// recording why when the exchange could not quote.
+func quoteExchange(ctx context.Context, exchange string, orderer orders.Orderer, pair string, direction string) orders.RouteQuote {
+	quote := orders.RouteQuote{Exchange: exchange}
+
+	market, ok := orderer.(orders.MarketData)
+	if !ok {
+		quote.Error = "exchange does not provide market data"
+		return quote
+	}
+
+	fees, ok := orderer.(orders.FeeSchedule)
+	if !ok {
+		quote.Error = "exchange does not provide a fee schedule"
+		return quote
+	}
+
+	ticker, err := market.GetTicker(ctx, pair)
+	if err != nil {
+		quote.Error = err.Error()
+		return quote
+	}
+
+	feePct, err := fees.GetFeePct(ctx, pair)
+	if err != nil {
+		quote.Error = err.Error()
+		return quote
+	}
+
+	fee := feePct.Div(oneHundred)
+	quote.FeePct = feePct
+	if direction == "buy" {
+		quote.Price = ticker.Ask
+		quote.EffectivePrice = ticker.Ask.Mul(decimal.NewFromInt(1).Add(fee))
+	} else {
+		quote.Price = ticker.Bid
+		quote.EffectivePrice = ticker.Bid.Mul(decimal.NewFromInt(1).Sub(fee))
+	}
+
+	if !quote.Price.IsPositive() {
+		quote.Error = fmt.Sprintf("no valid price for pair %s", pair)
+	}
+
+	return quote
+}
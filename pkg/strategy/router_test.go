@@ -0,0 +1,110 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockRoutableOrderer is an exchange which provides market data and fees
+type MockRoutableOrderer struct {
+	MockMarketData
+}
+
+func (m *MockRoutableOrderer) MakeOrder(ctx context.Context, order *configuration.DCAOrder) (*orders.OrderFufilled, error) {
+	args := m.Called(order)
+	return args.Get(0).(*orders.OrderFufilled), args.Error(1)
+}
+
+func (m *MockRoutableOrderer) ProcessTransaction(ctx context.Context, transactionsIds ...string) (*[]orders.OrderComplete, error) {
+	args := m.Called(transactionsIds)
+	return args.Get(0).(*[]orders.OrderComplete), args.Error(1)
+}
+
+func (m *MockRoutableOrderer) CancelOrder(ctx context.Context, transactionID string) error {
+	return m.Called(transactionID).Error(0)
+}
+
+func (m *MockRoutableOrderer) GetFeePct(ctx context.Context, pair string) (decimal.Decimal, error) {
+	args := m.Called(pair)
+	return args.Get(0).(decimal.Decimal), args.Error(1)
+}
+
+func routable(ask string, bid string, fee string) *MockRoutableOrderer {
+	orderer := &MockRoutableOrderer{}
+	orderer.On("GetTicker", "XBTGBP").Return(&orders.Ticker{
+		Pair: "XBTGBP",
+		Ask:  decimal.RequireFromString(ask),
+		Bid:  decimal.RequireFromString(bid),
+	}, nil)
+	orderer.On("GetFeePct", "XBTGBP").Return(decimal.RequireFromString(fee), nil)
+	return orderer
+}
+
+// Ensures buys are routed to the lowest price including fees
+func TestRouteBuy(t *testing.T) {
+	order := &configuration.DCAOrder{Exchange: ExchangeAuto, Pair: "XBTGBP", Direction: "buy"}
+	orderers := map[string]orders.Orderer{
+		"kraken":   routable("100", "99", "0.26"),
+		"coinbase": routable("99.9", "99", "0.6"),
+	}
+
+	decision, err := BestExecution{}.Route(context.Background(), order, orderers)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "kraken", decision.Exchange)
+	assert.Equal(t, 2, len(decision.Alternatives))
+	assert.Equal(t, "coinbase", decision.Alternatives[0].Exchange)
+	assert.Equal(t, "100.4994", decision.Alternatives[0].EffectivePrice.String())
+	assert.Equal(t, "100.26", decision.Alternatives[1].EffectivePrice.String())
+}
+
+// Ensures sells are routed to the highest price after fees
+func TestRouteSell(t *testing.T) {
+	order := &configuration.DCAOrder{Exchange: ExchangeAuto, Pair: "XBTGBP", Direction: "sell"}
+	orderers := map[string]orders.Orderer{
+		"kraken":   routable("101", "100", "0.5"),
+		"coinbase": routable("101", "100.3", "1"),
+	}
+
+	decision, err := BestExecution{}.Route(context.Background(), order, orderers)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "kraken", decision.Exchange)
+}
+
+// Ensures exchanges which can not quote are
+// recorded but not routed to
+func TestRouteSkipsFailedQuotes(t *testing.T) {
+	failing := &MockRoutableOrderer{}
+	failing.On("GetTicker", "XBTGBP").Return(&orders.Ticker{}, errors.New("unknown pair"))
+
+	order := &configuration.DCAOrder{Exchange: ExchangeAuto, Pair: "XBTGBP", Direction: "buy"}
+	orderers := map[string]orders.Orderer{
+		"binance": failing,
+		"kraken":  routable("100", "99", "0.26"),
+	}
+
+	decision, err := BestExecution{}.Route(context.Background(), order, orderers)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "kraken", decision.Exchange)
+	assert.Equal(t, "unknown pair", decision.Alternatives[0].Error)
+}
+
+// Ensures when no exchange can quote an error is returned
+func TestRouteNoQuotes(t *testing.T) {
+	failing := &MockRoutableOrderer{}
+	failing.On("GetTicker", "XBTGBP").Return(&orders.Ticker{}, errors.New("unknown pair"))
+
+	order := &configuration.DCAOrder{Exchange: ExchangeAuto, Pair: "XBTGBP", Direction: "buy"}
+	decision, err := BestExecution{}.Route(context.Background(), order, map[string]orders.Orderer{"kraken": failing})
+
+	assert.Nil(t, decision)
+	assert.Contains(t, err.Error(), "no exchange could quote pair XBTGBP")
+}
@@ -0,0 +1,84 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/shopspring/decimal"
+)
+
+// ExecutionTWAP splits an order into slices placed evenly over time.
+const ExecutionTWAP string = "twap"
+
+// MaxSlices is the most slices an order can be split into.
+const MaxSlices int = 100
+
+// TWAPPlan is how the volume of an order is split into slices.
+type TWAPPlan struct {
+	Volumes  []decimal.Decimal
+	Interval time.Duration
+}
+
+// IsTWAP determines if the order should be sliced over time.
+func IsTWAP(order *configuration.DCAOrder) bool {
+	return order.Execution != nil && order.Execution.Strategy == ExecutionTWAP
+}
+
+// PlanTWAP splits the volume of the order into equal slices
+// where the final slice takes any remainder lost to rounding.
+func PlanTWAP(order *configuration.DCAOrder) (*TWAPPlan, error) {
+	conf := order.Execution
+	if conf == nil {
+		return nil, errors.New("order has no execution configuration")
+	}
+
+	if conf.Strategy != ExecutionTWAP {
+		return nil, fmt.Errorf("unsupported execution strategy %s", conf.Strategy)
+	}
+
+	if conf.Slices < 1 || conf.Slices > MaxSlices {
+		return nil, fmt.Errorf("twap slices must be between 1 and %d, got %d", MaxSlices, conf.Slices)
+	}
+
+	interval, err := time.ParseDuration(conf.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid twap interval %s: %w", conf.Interval, err)
+	}
+
+	if interval <= 0 {
+		return nil, fmt.Errorf("twap interval %s must be positive", conf.Interval)
+	}
+
+	volume, err := decimal.NewFromString(order.Volume)
+	if err != nil {
+		return nil, fmt.Errorf("invalid volume %s: %w", order.Volume, err)
+	}
+
+	slices := decimal.NewFromInt(int64(conf.Slices))
+	slice := volume.Div(slices).Truncate(volumePrecision)
+	if !slice.IsPositive() {
+		return nil, fmt.Errorf("volume %s is too small to split into %d slices", order.Volume, conf.Slices)
+	}
+
+	plan := &TWAPPlan{Volumes: make([]decimal.Decimal, conf.Slices), Interval: interval}
+	for index := range plan.Volumes {
+		plan.Volumes[index] = slice
+	}
+	plan.Volumes[conf.Slices-1] = volume.Sub(slice.Mul(decimal.NewFromInt(int64(conf.Slices - 1))))
+
+	return plan, nil
+}
+
+// SliceOrder is the order placed for a single slice of the plan.
+func (p *TWAPPlan) SliceOrder(order *configuration.DCAOrder, slice int) (*configuration.DCAOrder, error) {
+	if slice < 0 || slice >= len(p.Volumes) {
+		return nil, fmt.Errorf("slice %d is outside of the %d planned slices", slice, len(p.Volumes))
+	}
+
+	child := *order
+	child.Volume = p.Volumes[slice].String()
+	child.Execution = nil
+	return &child, nil
+}
@@ -0,0 +1,68 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/stretchr/testify/assert"
+)
+
+func twapOrder(volume string, slices int, interval string) *configuration.DCAOrder {
+	return &configuration.DCAOrder{
+		Exchange:  "kraken",
+		Pair:      "XBTGBP",
+		Direction: "buy",
+		OrderType: "market",
+		Volume:    volume,
+		Enabled:   true,
+		Execution: &configuration.ExecutionConfig{Strategy: ExecutionTWAP, Slices: slices, Interval: interval},
+	}
+}
+
+// Ensures the volume is split evenly with the
+// remainder placed on the final slice
+func TestPlanTWAP(t *testing.T) {
+	plan, err := PlanTWAP(twapOrder("0.1", 3, "10m"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 10*time.Minute, plan.Interval)
+	assert.Equal(t, 3, len(plan.Volumes))
+	assert.Equal(t, "0.03333333", plan.Volumes[0].String())
+	assert.Equal(t, "0.03333333", plan.Volumes[1].String())
+	assert.Equal(t, "0.03333334", plan.Volumes[2].String())
+}
+
+// Ensures slice orders carry the slice volume
+// and are not sliced again
+func TestTWAPSliceOrder(t *testing.T) {
+	order := twapOrder("0.06", 6, "10m")
+	plan, err := PlanTWAP(order)
+	assert.Nil(t, err)
+
+	child, err := plan.SliceOrder(order, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, "0.01", child.Volume)
+	assert.Nil(t, child.Execution)
+	assert.NotNil(t, order.Execution)
+
+	_, err = plan.SliceOrder(order, 6)
+	assert.Contains(t, err.Error(), "outside of the 6 planned slices")
+}
+
+// Ensures invalid configuration is rejected
+func TestPlanTWAPInvalid(t *testing.T) {
+	_, err := PlanTWAP(twapOrder("0.1", 0, "10m"))
+	assert.Contains(t, err.Error(), "twap slices must be between 1 and 100")
+
+	_, err = PlanTWAP(twapOrder("0.1", 6, "soon"))
+	assert.Contains(t, err.Error(), "invalid twap interval soon")
+
+	_, err = PlanTWAP(twapOrder("0.00000001", 6, "10m"))
+	assert.Contains(t, err.Error(), "too small to split into 6 slices")
+
+	order := twapOrder("0.1", 6, "10m")
+	order.Execution.Strategy = "vwap"
+	_, err = PlanTWAP(order)
+	assert.Contains(t, err.Error(), "unsupported execution strategy vwap")
+}
@@ -0,0 +1,131 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+)
+
+// DateLayout is the layout dates are expected in within configuration.
+const DateLayout = "2006-01-02"
+
+// ValueAverager is an abstraction to size an order using value averaging.
+type ValueAverager interface {
+	Size(order *configuration.DCAOrder, history []orders.OrderComplete, ticker *orders.Ticker, now time.Time) (*ValueAveragingTrade, error)
+}
+
+// ValueAveraging sizes orders so the position grows by a fixed value each period.
+type ValueAveraging struct{}
+
+// ValueAveragingTrade is the trade computed for a value averaging order.
+// A zero Volume means the position is already on target.
+type ValueAveragingTrade struct {
+	Pair         string          `json:"pair"`
+	Periods      int64           `json:"periods"`
+	TargetValue  decimal.Decimal `json:"target_value"`
+	Position     decimal.Decimal `json:"position"`
+	CurrentValue decimal.Decimal `json:"current_value"`
+	TradeValue   decimal.Decimal `json:"trade_value"`
+	Direction    string          `json:"direction"`
+	Price        decimal.Decimal `json:"price"`
+	Volume       decimal.Decimal `json:"volume"`
+}
+
+// Size computes the trade for the order from the processed history of the pair
+// where the target value is the increment multiplied by the periods elapsed
+// since the start date (including the current period).
+func (v ValueAveraging) Size(order *configuration.DCAOrder, history []orders.OrderComplete, ticker *orders.Ticker, now time.Time) (*ValueAveragingTrade, error) {
+	conf := order.ValueAveraging
+	if conf == nil {
+		return nil, errors.New("order has no value averaging configuration")
+	}
+
+	if !ticker.Ask.IsPositive() || !ticker.Bid.IsPositive() {
+		return nil, fmt.Errorf("no valid price for pair %s", order.Pair)
+	}
+
+	periods, err := periodsElapsed(conf, now)
+	if err != nil {
+		return nil, err
+	}
+
+	position := decimal.Zero
+	for _, processed := range history {
+		if processed.Pair != order.Pair {
+			continue
+		}
+
+		switch strings.ToLower(processed.Type) {
+		case "buy":
+			position = position.Add(processed.Volume)
+		case "sell":
+			position = position.Sub(processed.Volume)
+		}
+	}
+
+	trade := &ValueAveragingTrade{
+		Pair:         order.Pair,
+		Periods:      periods,
+		TargetValue:  conf.Increment.Mul(decimal.NewFromInt(periods)),
+		Position:     position,
+		CurrentValue: position.Mul(ticker.Last),
+	}
+
+	tradeValue := trade.TargetValue.Sub(trade.CurrentValue)
+	if conf.Max != nil {
+		tradeValue = decimal.Min(tradeValue, *conf.Max)
+	}
+	if conf.Min != nil {
+		tradeValue = decimal.Max(tradeValue, *conf.Min)
+	}
+	if !conf.AllowSell {
+		tradeValue = decimal.Max(tradeValue, decimal.Zero)
+	}
+	trade.TradeValue = tradeValue
+
+	if tradeValue.IsNegative() {
+		trade.Direction = "sell"
+		trade.Price = ticker.Bid
+	} else {
+		trade.Direction = "buy"
+		trade.Price = ticker.Ask
+	}
+	trade.Volume = tradeValue.Abs().Div(trade.Price).Truncate(volumePrecision)
+
+	return trade, nil
+}
+
+// periodsElapsed counts the periods since the start date including
+// the current period, before the start date no periods have elapsed.
+func periodsElapsed(conf *configuration.ValueAveragingConfig, now time.Time) (int64, error) {
+	start, err := time.Parse(DateLayout, conf.StartDate)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value averaging start date %s: %w", conf.StartDate, err)
+	}
+
+	now = now.UTC()
+	if now.Before(start) {
+		return 0, nil
+	}
+
+	day := 24 * time.Hour
+	switch strings.ToLower(conf.Period) {
+	case "daily":
+		return int64(now.Sub(start)/day) + 1, nil
+	case "weekly":
+		return int64(now.Sub(start)/(7*day)) + 1, nil
+	case "monthly":
+		months := int64(now.Year()-start.Year())*12 + int64(now.Month()-start.Month())
+		if now.Day() < start.Day() {
+			months--
+		}
+		return months + 1, nil
+	default:
+		return 0, fmt.Errorf("unsupported value averaging period %s", conf.Period)
+	}
+}
@@ -0,0 +1,131 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func valueAveragingOrder(conf configuration.ValueAveragingConfig) *configuration.DCAOrder {
+	return &configuration.DCAOrder{
+		Exchange:       "kraken",
+		Pair:           "ADAGBP",
+		Enabled:        true,
+		ValueAveraging: &conf,
+	}
+}
+
+func processedOrder(pair string, direction string, volume string) orders.OrderComplete {
+	return orders.OrderComplete{Pair: pair, Type: direction, Volume: decimal.RequireFromString(volume)}
+}
+
+// Ensures the trade is the difference between
+// the target value and the current value
+func TestSizeBuysDifference(t *testing.T) {
+	order := valueAveragingOrder(configuration.ValueAveragingConfig{
+		StartDate: "2022-01-01",
+		Period:    "weekly",
+		Increment: decimal.NewFromInt(100),
+	})
+	history := []orders.OrderComplete{
+		processedOrder("ADAGBP", "buy", "150"),
+		processedOrder("ADAGBP", "sell", "50"),
+		processedOrder("XBTGBP", "buy", "1"),
+	}
+	now := time.Date(2022, 1, 15, 6, 0, 0, 0, time.UTC)
+
+	trade, err := ValueAveraging{}.Size(order, history, ticker("ADAGBP", "1"), now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), trade.Periods)
+	assert.Equal(t, "300", trade.TargetValue.String())
+	assert.Equal(t, "100", trade.Position.String())
+	assert.Equal(t, "200", trade.TradeValue.String())
+	assert.Equal(t, "buy", trade.Direction)
+	assert.Equal(t, "200", trade.Volume.String())
+}
+
+// Ensures the trade is clamped by the max
+func TestSizeClampedByMax(t *testing.T) {
+	max := decimal.NewFromInt(50)
+	order := valueAveragingOrder(configuration.ValueAveragingConfig{
+		StartDate: "2022-01-01",
+		Period:    "monthly",
+		Increment: decimal.NewFromInt(100),
+		Max:       &max,
+	})
+	now := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	trade, err := ValueAveraging{}.Size(order, nil, ticker("ADAGBP", "2"), now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), trade.Periods)
+	assert.Equal(t, "50", trade.TradeValue.String())
+	assert.Equal(t, "25", trade.Volume.String())
+}
+
+// Ensures when above target and selling is allowed
+// the excess is sold down to the min clamp
+func TestSizeSellsClampedByMin(t *testing.T) {
+	min := decimal.NewFromInt(-20)
+	order := valueAveragingOrder(configuration.ValueAveragingConfig{
+		StartDate: "2022-01-01",
+		Period:    "daily",
+		Increment: decimal.NewFromInt(10),
+		Min:       &min,
+		AllowSell: true,
+	})
+	history := []orders.OrderComplete{processedOrder("ADAGBP", "buy", "100")}
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	trade, err := ValueAveraging{}.Size(order, history, ticker("ADAGBP", "1"), now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), trade.Periods)
+	assert.Equal(t, "-20", trade.TradeValue.String())
+	assert.Equal(t, "sell", trade.Direction)
+	assert.Equal(t, "20", trade.Volume.String())
+}
+
+// Ensures when above target and selling is not allowed
+// nothing is traded
+func TestSizeAboveTargetSellNotAllowed(t *testing.T) {
+	order := valueAveragingOrder(configuration.ValueAveragingConfig{
+		StartDate: "2022-01-01",
+		Period:    "daily",
+		Increment: decimal.NewFromInt(10),
+	})
+	history := []orders.OrderComplete{processedOrder("ADAGBP", "buy", "100")}
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	trade, err := ValueAveraging{}.Size(order, history, ticker("ADAGBP", "1"), now)
+
+	assert.Nil(t, err)
+	assert.True(t, trade.Volume.IsZero())
+	assert.Equal(t, "buy", trade.Direction)
+}
+
+// Ensures invalid configuration is rejected
+func TestSizeInvalidConfiguration(t *testing.T) {
+	type testCase struct {
+		conf        configuration.ValueAveragingConfig
+		expectedErr string
+	}
+
+	cases := []testCase{
+		{conf: configuration.ValueAveragingConfig{StartDate: "01/01/2022", Period: "daily"}, expectedErr: "invalid value averaging start date"},
+		{conf: configuration.ValueAveragingConfig{StartDate: "2022-01-01", Period: "yearly"}, expectedErr: "unsupported value averaging period yearly"},
+	}
+
+	for _, currentCase := range cases {
+		order := valueAveragingOrder(currentCase.conf)
+		trade, err := ValueAveraging{}.Size(order, nil, ticker("ADAGBP", "1"), time.Now())
+
+		assert.Nil(t, trade)
+		assert.Contains(t, err.Error(), currentCase.expectedErr)
+	}
+}
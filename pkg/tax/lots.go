@@ -0,0 +1,114 @@
+package tax
+
+import (
+	"fmt"
+
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+)
+
+// FIFO calculates gains where disposals are matched
+// with the earliest acquisitions still held.
+type FIFO struct{}
+
+// AverageCost calculates gains where disposals are matched
+// with the average cost of everything held.
+type AverageCost struct{}
+
+// lot is an acquisition which has not been completely disposed of.
+type lot struct {
+	quantity decimal.Decimal
+	cost     decimal.Decimal
+}
+
+// Calculate matches every sell with the earliest lots of the asset.
+func (f FIFO) Calculate(processed []orders.OrderComplete) (*Report, error) {
+	trades, skipped, err := toTrades(processed, DefaultQuoteCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	lots := map[string][]lot{}
+	disposals := []Disposal{}
+	for _, t := range trades {
+		if t.Buy {
+			lots[t.Asset] = append(lots[t.Asset], lot{quantity: t.Quantity, cost: t.Amount.Add(t.Fee)})
+			continue
+		}
+
+		disposal := sellDisposal(t)
+		remaining := t.Quantity
+		held := lots[t.Asset]
+		for remaining.IsPositive() && len(held) > 0 {
+			matched := decimal.Min(remaining, held[0].quantity)
+			cost := proportion(held[0].cost, matched, held[0].quantity)
+
+			disposal.AllowableCost = disposal.AllowableCost.Add(cost)
+			held[0].quantity = held[0].quantity.Sub(matched)
+			held[0].cost = held[0].cost.Sub(cost)
+			remaining = remaining.Sub(matched)
+
+			if held[0].quantity.IsZero() {
+				held = held[1:]
+			}
+		}
+		lots[t.Asset] = held
+
+		if remaining.IsPositive() {
+			return nil, fmt.Errorf("disposal of %s %s on %s is more than was held", t.Quantity, t.Asset, disposal.Date)
+		}
+
+		disposal.PoolQuantity = t.Quantity
+		disposal.finish()
+		disposals = append(disposals, *disposal)
+	}
+
+	return newReport(MethodFIFO, disposals, skipped), nil
+}
+
+// Calculate matches every sell with the average cost of the asset.
+func (a AverageCost) Calculate(processed []orders.OrderComplete) (*Report, error) {
+	trades, skipped, err := toTrades(processed, DefaultQuoteCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	pools := map[string]lot{}
+	disposals := []Disposal{}
+	for _, t := range trades {
+		pool := pools[t.Asset]
+		if t.Buy {
+			pool.quantity = pool.quantity.Add(t.Quantity)
+			pool.cost = pool.cost.Add(t.Amount).Add(t.Fee)
+			pools[t.Asset] = pool
+			continue
+		}
+
+		disposal := sellDisposal(t)
+		if t.Quantity.GreaterThan(pool.quantity) {
+			return nil, fmt.Errorf("disposal of %s %s on %s is more than the %s held", t.Quantity, t.Asset, disposal.Date, pool.quantity)
+		}
+
+		cost := proportion(pool.cost, t.Quantity, pool.quantity)
+		pool.quantity = pool.quantity.Sub(t.Quantity)
+		pool.cost = pool.cost.Sub(cost)
+		pools[t.Asset] = pool
+
+		disposal.AllowableCost = cost
+		disposal.PoolQuantity = t.Quantity
+		disposal.finish()
+		disposals = append(disposals, *disposal)
+	}
+
+	return newReport(MethodAverageCost, disposals, skipped), nil
+}
+
+// sellDisposal creates the disposal of a single sell.
+func sellDisposal(t trade) *Disposal {
+	disposal := newDisposal(t.Asset, t.Time)
+	disposal.Quantity = t.Quantity
+	disposal.Proceeds = t.Amount
+	disposal.Fees = t.Fee
+	disposal.TransactionIDs = []string{t.TransactionID}
+	return disposal
+}
@@ -0,0 +1,133 @@
+package tax
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Report is every disposal grouped by the tax year it falls in.
+//
+// Skipped pairs are not quoted in the quote currency so none of
+// their orders are included in the report.
+type Report struct {
+	Method       string    `json:"method"`
+	TaxYears     []TaxYear `json:"tax_years"`
+	SkippedPairs []string  `json:"skipped_pairs"`
+}
+
+// TaxYear is the disposals and totals of a single tax year.
+type TaxYear struct {
+	TaxYear       string          `json:"tax_year"`
+	Method        string          `json:"method"`
+	Disposals     []Disposal      `json:"disposals"`
+	SkippedPairs  []string        `json:"skipped_pairs"`
+	Proceeds      decimal.Decimal `json:"proceeds"`
+	AllowableCost decimal.Decimal `json:"allowable_cost"`
+	Gains         decimal.Decimal `json:"gains"`
+	Losses        decimal.Decimal `json:"losses"`
+	NetGain       decimal.Decimal `json:"net_gain"`
+}
+
+// csvHeader is the header row of the disposals CSV.
+var csvHeader = []string{
+	"date",
+	"tax_year",
+	"asset",
+	"quantity",
+	"proceeds",
+	"allowable_cost",
+	"fees",
+	"gain",
+	"same_day_quantity",
+	"bed_and_breakfast_quantity",
+	"pool_quantity",
+	"transaction_ids",
+}
+
+// newReport groups the disposals into tax years in date order.
+func newReport(method string, disposals []Disposal, skippedPairs []string) *Report {
+	sort.SliceStable(disposals, func(i, j int) bool {
+		if disposals[i].Date == disposals[j].Date {
+			return disposals[i].Asset < disposals[j].Asset
+		}
+		return disposals[i].Date < disposals[j].Date
+	})
+
+	report := &Report{Method: method, TaxYears: []TaxYear{}, SkippedPairs: skippedPairs}
+	for _, disposal := range disposals {
+		if len(report.TaxYears) == 0 || report.TaxYears[len(report.TaxYears)-1].TaxYear != disposal.TaxYear {
+			report.TaxYears = append(report.TaxYears, TaxYear{TaxYear: disposal.TaxYear, Method: method, SkippedPairs: skippedPairs})
+		}
+
+		year := &report.TaxYears[len(report.TaxYears)-1]
+		year.Disposals = append(year.Disposals, disposal)
+		year.Proceeds = year.Proceeds.Add(disposal.Proceeds)
+		year.AllowableCost = year.AllowableCost.Add(disposal.AllowableCost)
+
+		if disposal.Gain.IsPositive() {
+			year.Gains = year.Gains.Add(disposal.Gain)
+		} else {
+			year.Losses = year.Losses.Add(disposal.Gain.Abs())
+		}
+		year.NetGain = year.Gains.Sub(year.Losses)
+	}
+
+	return report
+}
+
+// Year gets the given tax year from the report e.g 2021/22.
+// A year with no disposals is returned empty.
+func (r *Report) Year(taxYear string) TaxYear {
+	for _, year := range r.TaxYears {
+		if year.TaxYear == taxYear {
+			return year
+		}
+	}
+
+	return TaxYear{TaxYear: taxYear, Method: r.Method, Disposals: []Disposal{}, SkippedPairs: r.SkippedPairs}
+}
+
+// WriteJSON writes the tax year as JSON.
+func (y TaxYear) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(y)
+}
+
+// WriteCSV writes the disposals of the tax year as CSV
+// with monetary amounts rounded to pence.
+func (y TaxYear) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, d := range y.Disposals {
+		row := []string{
+			d.Date,
+			d.TaxYear,
+			d.Asset,
+			d.Quantity.String(),
+			d.Proceeds.StringFixed(2),
+			d.AllowableCost.StringFixed(2),
+			d.Fees.StringFixed(2),
+			d.Gain.StringFixed(2),
+			d.SameDayQuantity.String(),
+			d.BedAndBreakfastQuantity.String(),
+			d.PoolQuantity.String(),
+			strings.Join(d.TransactionIDs, " "),
+		}
+
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,157 @@
+package tax
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+)
+
+// BedAndBreakfastDays is how many days after a disposal
+// acquisitions are matched to it.
+const BedAndBreakfastDays = 30
+
+// Section104 calculates gains with the HMRC share matching rules.
+//
+// Disposals are first matched with acquisitions on the same day,
+// then with acquisitions within the following 30 days and
+// finally with the Section 104 pool at its average cost.
+type Section104 struct{}
+
+// tradingDay is every buy and sell of an asset on a single day.
+type tradingDay struct {
+	time          time.Time
+	buyQuantity   decimal.Decimal
+	buyCost       decimal.Decimal
+	buyRemaining  decimal.Decimal
+	sellRemaining decimal.Decimal
+	disposal      *Disposal
+}
+
+// Calculate matches the disposals of every asset within the orders.
+func (s Section104) Calculate(processed []orders.OrderComplete) (*Report, error) {
+	trades, skipped, err := toTrades(processed, DefaultQuoteCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	days := map[string][]*tradingDay{}
+	assets := []string{}
+	for _, t := range trades {
+		assetDays, ok := days[t.Asset]
+		if !ok {
+			assets = append(assets, t.Asset)
+		}
+
+		// Trades are sorted so the day is either the last one or a new one
+		day := dayOf(t.Time)
+		if len(assetDays) == 0 || dayOf(assetDays[len(assetDays)-1].time) != day {
+			assetDays = append(assetDays, &tradingDay{time: t.Time})
+		}
+		current := assetDays[len(assetDays)-1]
+
+		if t.Buy {
+			current.buyQuantity = current.buyQuantity.Add(t.Quantity)
+			current.buyCost = current.buyCost.Add(t.Amount).Add(t.Fee)
+		} else {
+			if current.disposal == nil {
+				current.disposal = newDisposal(t.Asset, t.Time)
+			}
+			current.sellRemaining = current.sellRemaining.Add(t.Quantity)
+			current.disposal.Quantity = current.disposal.Quantity.Add(t.Quantity)
+			current.disposal.Proceeds = current.disposal.Proceeds.Add(t.Amount)
+			current.disposal.Fees = current.disposal.Fees.Add(t.Fee)
+			current.disposal.TransactionIDs = append(current.disposal.TransactionIDs, t.TransactionID)
+		}
+
+		days[t.Asset] = assetDays
+	}
+
+	disposals := []Disposal{}
+	for _, asset := range assets {
+		assetDisposals, err := matchAsset(asset, days[asset])
+		if err != nil {
+			return nil, err
+		}
+		disposals = append(disposals, assetDisposals...)
+	}
+
+	return newReport(MethodSection104, disposals, skipped), nil
+}
+
+// matchAsset applies the matching rules in order across every day of the asset.
+func matchAsset(asset string, days []*tradingDay) ([]Disposal, error) {
+	for _, day := range days {
+		day.buyRemaining = day.buyQuantity
+	}
+
+	// Same day rule
+	for _, day := range days {
+		matched := decimal.Min(day.buyRemaining, day.sellRemaining)
+		if !matched.IsPositive() {
+			continue
+		}
+
+		day.disposal.SameDayQuantity = matched
+		day.disposal.AllowableCost = day.disposal.AllowableCost.Add(proportion(day.buyCost, matched, day.buyQuantity))
+		day.buyRemaining = day.buyRemaining.Sub(matched)
+		day.sellRemaining = day.sellRemaining.Sub(matched)
+	}
+
+	// Bed and breakfast rule, earliest acquisitions first
+	for index, day := range days {
+		if !day.sellRemaining.IsPositive() {
+			continue
+		}
+
+		window := day.time.In(london).AddDate(0, 0, BedAndBreakfastDays).Format(DateLayout)
+		for _, later := range days[index+1:] {
+			if dayOf(later.time) > window || !day.sellRemaining.IsPositive() {
+				break
+			}
+
+			matched := decimal.Min(later.buyRemaining, day.sellRemaining)
+			if !matched.IsPositive() {
+				continue
+			}
+
+			day.disposal.BedAndBreakfastQuantity = day.disposal.BedAndBreakfastQuantity.Add(matched)
+			day.disposal.AllowableCost = day.disposal.AllowableCost.Add(proportion(later.buyCost, matched, later.buyQuantity))
+			later.buyRemaining = later.buyRemaining.Sub(matched)
+			day.sellRemaining = day.sellRemaining.Sub(matched)
+		}
+	}
+
+	// Section 104 pool
+	poolQuantity := decimal.Zero
+	poolCost := decimal.Zero
+	disposals := []Disposal{}
+	for _, day := range days {
+		if day.buyRemaining.IsPositive() {
+			poolQuantity = poolQuantity.Add(day.buyRemaining)
+			poolCost = poolCost.Add(proportion(day.buyCost, day.buyRemaining, day.buyQuantity))
+		}
+
+		if day.disposal == nil {
+			continue
+		}
+
+		if day.sellRemaining.IsPositive() {
+			if day.sellRemaining.GreaterThan(poolQuantity) {
+				return nil, fmt.Errorf("disposal of %s %s on %s is more than the %s held", day.sellRemaining, asset, day.disposal.Date, poolQuantity)
+			}
+
+			cost := proportion(poolCost, day.sellRemaining, poolQuantity)
+			day.disposal.PoolQuantity = day.sellRemaining
+			day.disposal.AllowableCost = day.disposal.AllowableCost.Add(cost)
+			poolQuantity = poolQuantity.Sub(day.sellRemaining)
+			poolCost = poolCost.Sub(cost)
+		}
+
+		day.disposal.finish()
+		disposals = append(disposals, *day.disposal)
+	}
+
+	return disposals, nil
+}
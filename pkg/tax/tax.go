@@ -0,0 +1,195 @@
+// Package tax calculates capital gains on disposals of processed orders.
+package tax
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	// Lambda runtimes do not ship a timezone database
+	_ "time/tzdata"
+
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+)
+
+// Cost basis methods which can be calculated.
+const (
+	MethodSection104  string = "section104"
+	MethodFIFO        string = "fifo"
+	MethodAverageCost string = "average_cost"
+)
+
+// DefaultQuoteCurrency is the currency gains are calculated in.
+const DefaultQuoteCurrency string = "GBP"
+
+// DateLayout is the layout dates are written in reports.
+const DateLayout = "2006-01-02"
+
+// UK tax years and days are based on the time in London.
+var london = mustLoadLocation("Europe/London")
+
+// Calculator is an abstraction to calculate the gains of disposals.
+type Calculator interface {
+	Calculate(processed []orders.OrderComplete) (*Report, error)
+}
+
+// NewCalculator gets the calculator for the given method.
+func NewCalculator(method string) (Calculator, error) {
+	switch method {
+	case MethodSection104:
+		return Section104{}, nil
+	case MethodFIFO:
+		return FIFO{}, nil
+	case MethodAverageCost:
+		return AverageCost{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported cost basis method %s", method)
+	}
+}
+
+// Disposal is the gain or loss from disposing of an asset.
+//
+// Proceeds are before fees and the allowable cost includes the fees
+// of both the acquisitions matched and the disposal itself. The matched
+// quantities show which rule each part of the disposal was matched under.
+type Disposal struct {
+	Date                    string          `json:"date"`
+	TaxYear                 string          `json:"tax_year"`
+	Asset                   string          `json:"asset"`
+	Quantity                decimal.Decimal `json:"quantity"`
+	Proceeds                decimal.Decimal `json:"proceeds"`
+	AllowableCost           decimal.Decimal `json:"allowable_cost"`
+	Fees                    decimal.Decimal `json:"fees"`
+	Gain                    decimal.Decimal `json:"gain"`
+	SameDayQuantity         decimal.Decimal `json:"same_day_quantity"`
+	BedAndBreakfastQuantity decimal.Decimal `json:"bed_and_breakfast_quantity"`
+	PoolQuantity            decimal.Decimal `json:"pool_quantity"`
+	TransactionIDs          []string        `json:"transaction_ids"`
+}
+
+// trade is a single fill of an asset in the quote currency.
+// The amount is the quantity multiplied by the price before fees.
+type trade struct {
+	TransactionID string
+	Asset         string
+	Time          time.Time
+	Buy           bool
+	Quantity      decimal.Decimal
+	Amount        decimal.Decimal
+	Fee           decimal.Decimal
+}
+
+// TaxYearOf is the UK tax year the time falls in
+// where tax years run from the 6th of April e.g 2021/22.
+func TaxYearOf(t time.Time) string {
+	local := t.In(london)
+	start := local.Year()
+	if local.Before(time.Date(start, time.April, 6, 0, 0, 0, 0, london)) {
+		start--
+	}
+
+	return fmt.Sprintf("%d/%02d", start, (start+1)%100)
+}
+
+// dayOf is the date in London of the time.
+func dayOf(t time.Time) string {
+	return t.In(london).Format(DateLayout)
+}
+
+// newDisposal creates an empty disposal of the asset on the given time.
+func newDisposal(asset string, t time.Time) *Disposal {
+	return &Disposal{
+		Date:    dayOf(t),
+		TaxYear: TaxYearOf(t),
+		Asset:   asset,
+	}
+}
+
+// finish calculates the gain once the disposal has been matched.
+func (d *Disposal) finish() {
+	d.AllowableCost = d.AllowableCost.Add(d.Fees)
+	d.Gain = d.Proceeds.Sub(d.AllowableCost)
+}
+
+// toTrades converts filled orders quoted in the currency into trades sorted by time.
+//
+// Orders of pairs which are not quoted in the currency cannot be valued
+// so they are left out and their pairs returned to be reported as skipped.
+func toTrades(processed []orders.OrderComplete, quote string) ([]trade, []string, error) {
+	trades := make([]trade, 0, len(processed))
+	skipped := map[string]bool{}
+	for _, order := range processed {
+		if order.IsOpen() || !order.Volume.IsPositive() {
+			continue
+		}
+
+		asset, err := baseAsset(order.Pair, quote)
+		if err != nil {
+			skipped[strings.ToUpper(order.Pair)] = true
+			continue
+		}
+
+		direction := strings.ToLower(order.Type)
+		if direction != "buy" && direction != "sell" {
+			return nil, nil, fmt.Errorf("unsupported direction %s for transaction %s", order.Type, order.TransactionID)
+		}
+
+		closed := order.CloseTime
+		if closed == 0 {
+			closed = order.OpenTime
+		}
+
+		trades = append(trades, trade{
+			TransactionID: order.TransactionID,
+			Asset:         asset,
+			Time:          time.Unix(0, int64(closed*float64(time.Second))),
+			Buy:           direction == "buy",
+			Quantity:      order.Volume,
+			Amount:        order.Volume.Mul(order.Price),
+			Fee:           order.Fee,
+		})
+	}
+
+	sort.SliceStable(trades, func(i, j int) bool {
+		if trades[i].Time.Equal(trades[j].Time) {
+			return trades[i].TransactionID < trades[j].TransactionID
+		}
+		return trades[i].Time.Before(trades[j].Time)
+	})
+
+	skippedPairs := make([]string, 0, len(skipped))
+	for pair := range skipped {
+		skippedPairs = append(skippedPairs, pair)
+	}
+	sort.Strings(skippedPairs)
+
+	return trades, skippedPairs, nil
+}
+
+// baseAsset is the asset traded in the pair, normalised so every spelling
+// of a pair is pooled together e.g BTC for XBTGBP, XXBTZGBP or BTC-GBP.
+func baseAsset(pair string, quote string) (string, error) {
+	base, pairQuote, ok := orders.SplitPair(pair)
+	if !ok || pairQuote != orders.Asset(quote) {
+		return "", fmt.Errorf("pair %s is not quoted in %s", pair, quote)
+	}
+	return base, nil
+}
+
+// proportion is the share of the total for the part of the quantity.
+func proportion(total decimal.Decimal, part decimal.Decimal, quantity decimal.Decimal) decimal.Decimal {
+	if quantity.IsZero() {
+		return decimal.Zero
+	}
+	return total.Mul(part).Div(quantity)
+}
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
@@ -0,0 +1,217 @@
+package tax
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func fill(txid string, date string, direction string, volume string, price string, fee string) orders.OrderComplete {
+	closed, err := time.Parse(DateLayout, date)
+	if err != nil {
+		panic(err)
+	}
+
+	return orders.OrderComplete{
+		TransactionID:  txid,
+		ExchangeStatus: "closed",
+		Pair:           "XXBTZGBP",
+		Type:           direction,
+		OrderType:      "market",
+		Price:          decimal.RequireFromString(price),
+		Fee:            decimal.RequireFromString(fee),
+		Volume:         decimal.RequireFromString(volume),
+		CloseTime:      float64(closed.Add(12 * time.Hour).Unix()),
+	}
+}
+
+// Ensures tax years start on the 6th of April in London
+func TestTaxYearOf(t *testing.T) {
+	cases := map[string]string{
+		"2022-04-05T22:59:59Z": "2021/22",
+		"2022-04-05T23:00:00Z": "2022/23",
+		"2000-01-01T00:00:00Z": "1999/00",
+	}
+
+	for input, expected := range cases {
+		at, err := time.Parse(time.RFC3339, input)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, TaxYearOf(at), input)
+	}
+}
+
+// Ensures disposals are matched with same day acquisitions,
+// then acquisitions within 30 days and then the pool
+func TestSection104(t *testing.T) {
+	processed := []orders.OrderComplete{
+		fill("A", "2021-05-01", "buy", "1", "1000", "10"),
+		fill("B", "2021-06-01", "buy", "1", "2000", "0"),
+		fill("C", "2021-07-01", "sell", "1", "3000", "5"),
+		fill("D", "2021-07-01", "buy", "0.5", "2800", "0"),
+		fill("E", "2021-07-20", "buy", "0.25", "2000", "0"),
+		fill("F", "2021-08-15", "buy", "1", "100", "0"),
+		fill("G", "2022-04-10", "sell", "0.5", "4000", "0"),
+	}
+
+	report, err := Section104{}.Calculate(processed)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(report.TaxYears))
+
+	first := report.Year("2021/22").Disposals[0]
+	assert.Equal(t, "2021-07-01", first.Date)
+	assert.Equal(t, "BTC", first.Asset)
+	assert.Equal(t, "0.5", first.SameDayQuantity.String())
+	assert.Equal(t, "0.25", first.BedAndBreakfastQuantity.String())
+	assert.Equal(t, "0.25", first.PoolQuantity.String())
+	assert.Equal(t, "3000", first.Proceeds.String())
+	assert.Equal(t, "2281.25", first.AllowableCost.String())
+	assert.Equal(t, "718.75", first.Gain.String())
+
+	second := report.Year("2022/23")
+	assert.Equal(t, "0.5", second.Disposals[0].PoolQuantity.String())
+	assert.Equal(t, "497.05", second.Disposals[0].AllowableCost.StringFixed(2))
+	assert.Equal(t, "1502.95", second.NetGain.StringFixed(2))
+}
+
+// Ensures disposals consume the earliest lots first
+// with fees included in the allowable cost
+func TestFIFO(t *testing.T) {
+	processed := []orders.OrderComplete{
+		fill("A", "2021-05-01", "buy", "1", "100", "1"),
+		fill("B", "2021-05-02", "buy", "1", "200", "1"),
+		fill("C", "2021-05-03", "sell", "1.5", "300", "3"),
+	}
+
+	report, err := FIFO{}.Calculate(processed)
+	assert.Nil(t, err)
+
+	disposal := report.Year("2021/22").Disposals[0]
+	assert.Equal(t, "450", disposal.Proceeds.String())
+	assert.Equal(t, "204.5", disposal.AllowableCost.String())
+	assert.Equal(t, "245.5", disposal.Gain.String())
+}
+
+// Ensures disposals use the average cost of the holding
+func TestAverageCost(t *testing.T) {
+	processed := []orders.OrderComplete{
+		fill("A", "2021-05-01", "buy", "1", "100", "1"),
+		fill("B", "2021-05-02", "buy", "1", "200", "1"),
+		fill("C", "2021-05-03", "sell", "1.5", "300", "3"),
+		fill("D", "2021-05-04", "sell", "0.5", "50", "0"),
+	}
+
+	calculator, err := NewCalculator(MethodAverageCost)
+	assert.Nil(t, err)
+
+	report, err := calculator.Calculate(processed)
+	assert.Nil(t, err)
+
+	year := report.Year("2021/22")
+	assert.Equal(t, "229.5", year.Disposals[0].AllowableCost.String())
+	assert.Equal(t, "220.5", year.Disposals[0].Gain.String())
+	assert.Equal(t, "-50.5", year.Disposals[1].Gain.String())
+	assert.Equal(t, "220.5", year.Gains.String())
+	assert.Equal(t, "50.5", year.Losses.String())
+	assert.Equal(t, "170", year.NetGain.String())
+}
+
+// Ensures open orders are ignored and invalid orders are rejected
+func TestCalculateInvalid(t *testing.T) {
+	open := fill("A", "2021-05-01", "sell", "1", "100", "0")
+	open.ExchangeStatus = "open"
+	report, err := Section104{}.Calculate([]orders.OrderComplete{open})
+	assert.Nil(t, err)
+	assert.Empty(t, report.TaxYears)
+
+	for _, calculator := range []Calculator{Section104{}, FIFO{}, AverageCost{}} {
+		_, err = calculator.Calculate([]orders.OrderComplete{fill("A", "2021-05-01", "sell", "1", "100", "0")})
+		assert.Contains(t, err.Error(), "disposal of 1 BTC on 2021-05-01 is more than")
+	}
+
+	_, err = NewCalculator("lifo")
+	assert.Contains(t, err.Error(), "unsupported cost basis method lifo")
+}
+
+// Ensures orders of pairs not quoted in GBP are skipped and reported rather than failing the report
+func TestCalculateSkippedPairs(t *testing.T) {
+	usd := fill("C", "2021-05-02", "buy", "1", "100", "0")
+	usd.Pair = "xethzusd"
+	processed := []orders.OrderComplete{
+		fill("A", "2021-05-01", "buy", "1", "100", "0"),
+		usd,
+		usd,
+		fill("B", "2021-05-03", "sell", "1", "150", "0"),
+	}
+
+	for _, calculator := range []Calculator{Section104{}, FIFO{}, AverageCost{}} {
+		report, err := calculator.Calculate(processed)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"XETHZUSD"}, report.SkippedPairs)
+		assert.Equal(t, "50", report.Year("2021/22").NetGain.String())
+		assert.Equal(t, []string{"XETHZUSD"}, report.Year("2021/22").SkippedPairs)
+		assert.Equal(t, []string{"XETHZUSD"}, report.Year("2019/20").SkippedPairs)
+	}
+}
+
+// Ensures every spelling of a pair is pooled as the same asset
+// and assets ending in Z are not mistaken for Kraken quotes
+func TestCalculatePairSpellings(t *testing.T) {
+	altname := fill("A", "2021-05-01", "buy", "1", "1000", "0")
+	altname.Pair = "XBTGBP"
+	tezosBuy := fill("C", "2021-05-01", "buy", "10", "5", "0")
+	tezosBuy.Pair = "XTZGBP"
+	tezosSell := fill("D", "2021-06-01", "sell", "10", "6", "0")
+	tezosSell.Pair = "XTZGBP"
+	processed := []orders.OrderComplete{
+		altname,
+		fill("B", "2021-05-01", "sell", "1", "1500", "0"),
+		tezosBuy,
+		tezosSell,
+	}
+
+	report, err := Section104{}.Calculate(processed)
+	assert.Nil(t, err)
+	assert.Empty(t, report.SkippedPairs)
+
+	disposals := report.Year("2021/22").Disposals
+	assert.Equal(t, 2, len(disposals))
+	assert.Equal(t, "BTC", disposals[0].Asset)
+	assert.Equal(t, "1", disposals[0].SameDayQuantity.String())
+	assert.Equal(t, "500", disposals[0].Gain.String())
+	assert.Equal(t, "XTZ", disposals[1].Asset)
+	assert.Equal(t, "10", disposals[1].Gain.String())
+}
+
+// Ensures a tax year can be written as CSV and JSON
+func TestTaxYearWrite(t *testing.T) {
+	processed := []orders.OrderComplete{
+		fill("A", "2021-05-01", "buy", "3", "100", "1"),
+		fill("B", "2021-05-03", "sell", "1", "150", "1"),
+	}
+
+	report, err := Section104{}.Calculate(processed)
+	assert.Nil(t, err)
+	year := report.Year("2021/22")
+
+	var csvOutput bytes.Buffer
+	assert.Nil(t, year.WriteCSV(&csvOutput))
+	lines := strings.Split(strings.TrimSpace(csvOutput.String()), "\n")
+	assert.Equal(t, strings.Join(csvHeader, ","), lines[0])
+	assert.Equal(t, "2021-05-03,2021/22,BTC,1,150.00,101.33,1.00,48.67,0,0,1,B", lines[1])
+
+	var jsonOutput bytes.Buffer
+	assert.Nil(t, year.WriteJSON(&jsonOutput))
+	decoded := TaxYear{}
+	assert.Nil(t, json.Unmarshal(jsonOutput.Bytes(), &decoded))
+	assert.Equal(t, MethodSection104, decoded.Method)
+	assert.Equal(t, "B", decoded.Disposals[0].TransactionIDs[0])
+
+	empty := report.Year("2019/20")
+	assert.Empty(t, empty.Disposals)
+}
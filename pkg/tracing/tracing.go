@@ -0,0 +1,130 @@
+// Package tracing follows an order from the scheduled run through
+// SQS to the Glue job with OpenTelemetry spans.
+package tracing
+
+import (
+	"context"
+	"os"
+
+	awsEvents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the instrumentation creating every span.
+const TracerName = "github.com/kiran94/dca-manager"
+
+// Environment Variables which enable exporting through OTLP.
+const (
+	EnvOTLPEndpoint       string = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	EnvOTLPTracesEndpoint string = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+)
+
+// Flush exports any spans which have not been exported yet.
+type Flush func(ctx context.Context) error
+
+// Setup propagates trace context in the W3C format and when an OTLP endpoint
+// is configured exports spans to it for the service. Without an endpoint
+// spans are not recorded and flushing does nothing.
+func Setup(ctx context.Context, service string) (Flush, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	if os.Getenv(EnvOTLPEndpoint) == "" && os.Getenv(EnvOTLPTracesEndpoint) == "" {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(service))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.ForceFlush, nil
+}
+
+// Start starts a span as a child of any span in the context.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// StartLinked starts a span as a child of any span in the context which is
+// linked to the span in the linked context, such as the span which sent a message.
+func StartLinked(ctx context.Context, linked context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	options := []trace.SpanStartOption{trace.WithAttributes(attributes...)}
+	if link := trace.LinkFromContext(linked); link.SpanContext.IsValid() {
+		options = append(options, trace.WithLinks(link))
+	}
+
+	return otel.Tracer(TracerName).Start(ctx, name, options...)
+}
+
+// End records the error on the span when there is one then ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// InjectSQS adds the trace context to the attributes of a message being sent.
+func InjectSQS(ctx context.Context, attributes map[string]sqstypes.MessageAttributeValue) {
+	otel.GetTextMapPropagator().Inject(ctx, sendCarrier(attributes))
+}
+
+// ExtractSQS is the trace context of the span which sent the message.
+func ExtractSQS(ctx context.Context, attributes map[string]awsEvents.SQSMessageAttribute) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, receiveCarrier(attributes))
+}
+
+// sendCarrier carries trace context in the attributes of a message being sent.
+type sendCarrier map[string]sqstypes.MessageAttributeValue
+
+func (c sendCarrier) Get(key string) string {
+	return aws.ToString(c[key].StringValue)
+}
+
+func (c sendCarrier) Set(key string, value string) {
+	c[key] = sqstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
+func (c sendCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// receiveCarrier carries trace context in the attributes of a received message.
+type receiveCarrier map[string]awsEvents.SQSMessageAttribute
+
+func (c receiveCarrier) Get(key string) string {
+	return aws.ToString(c[key].StringValue)
+}
+
+func (c receiveCarrier) Set(key string, value string) {
+	c[key] = awsEvents.SQSMessageAttribute{DataType: "String", StringValue: aws.String(value)}
+}
+
+func (c receiveCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	awsEvents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// record sets a provider which records every span for the test
+func record(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// Ensures nothing is exported without an OTLP endpoint
+func TestSetupWithoutEndpoint(t *testing.T) {
+	t.Setenv(EnvOTLPEndpoint, "")
+	t.Setenv(EnvOTLPTracesEndpoint, "")
+
+	flush, err := Setup(context.Background(), "execute_orders")
+
+	assert.Nil(t, err)
+	assert.Nil(t, flush(context.Background()))
+}
+
+// Ensures errors are recorded on the span
+func TestEnd(t *testing.T) {
+	recorder := record(t)
+
+	_, span := Start(context.Background(), "MakeOrder")
+	End(span, errors.New("insufficient funds"))
+
+	spans := recorder.Ended()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "MakeOrder", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "insufficient funds", spans[0].Status().Description)
+}
+
+// Ensures the trace context sent with a message links the span processing it
+func TestSQSPropagation(t *testing.T) {
+	recorder := record(t)
+	_, err := Setup(context.Background(), "execute_orders")
+	assert.Nil(t, err)
+
+	ctx, sender := Start(context.Background(), "SubmitPendingOrder")
+	sent := map[string]sqstypes.MessageAttributeValue{
+		"Exchange": {DataType: aws.String("String"), StringValue: aws.String("kraken")},
+	}
+	InjectSQS(ctx, sent)
+	sender.End()
+
+	assert.Contains(t, sent, "traceparent")
+	assert.Equal(t, "String", *sent["traceparent"].DataType)
+
+	// Lambda hands the attributes to process_orders as an event
+	received := map[string]awsEvents.SQSMessageAttribute{}
+	for key, value := range sent {
+		received[key] = awsEvents.SQSMessageAttribute{DataType: *value.DataType, StringValue: value.StringValue}
+	}
+
+	invocation, parent := Start(context.Background(), "ProcessTransactions")
+	_, processing := StartLinked(invocation, ExtractSQS(invocation, received), "ProcessMessage")
+	processing.End()
+	parent.End()
+
+	spans := recorder.Ended()
+	assert.Equal(t, 3, len(spans))
+	assert.Equal(t, "ProcessMessage", spans[1].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[1].Parent().SpanID())
+	assert.Equal(t, 1, len(spans[1].Links()))
+	assert.Equal(t, sender.SpanContext().TraceID(), spans[1].Links()[0].SpanContext.TraceID())
+	assert.Equal(t, sender.SpanContext().SpanID(), spans[1].Links()[0].SpanContext.SpanID())
+}
+
+// Ensures messages sent without trace context are not linked
+func TestStartLinkedWithoutContext(t *testing.T) {
+	recorder := record(t)
+
+	_, span := StartLinked(context.Background(), ExtractSQS(context.Background(), map[string]awsEvents.SQSMessageAttribute{}), "ProcessMessage")
+	span.End()
+
+	assert.Equal(t, 0, len(recorder.Ended()[0].Links()))
+}
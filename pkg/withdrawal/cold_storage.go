@@ -0,0 +1,226 @@
+// Package withdrawal moves funds off exchanges to cold storage.
+package withdrawal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/logging"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/sirupsen/logrus"
+)
+
+// StatusInitiated is the status of a withdrawal which has just been requested.
+const StatusInitiated string = "Initiated"
+
+// Sweeper is an abstraction to withdraw balances above a threshold.
+type Sweeper interface {
+	Sweep(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, conf map[string]configuration.WithdrawalConfig, orderers map[string]orders.Orderer) ([]orders.Withdrawal, error)
+}
+
+// ColdStorage withdraws balances to cold storage and tracks the withdrawals in S3.
+type ColdStorage struct{}
+
+// Sweep updates the status of recent withdrawals of every configured asset
+// then withdraws each balance at or above its threshold.
+//
+// Withdrawals to keys which the exchange has not approved are refused and
+// returned as an error once every other asset has been swept.
+func (c ColdStorage) Sweep(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, conf map[string]configuration.WithdrawalConfig, orderers map[string]orders.Orderer) ([]orders.Withdrawal, error) {
+	assets := make([]string, 0, len(conf))
+	for asset := range conf {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+
+	withdrawn := []orders.Withdrawal{}
+	refused := []string{}
+	for _, asset := range assets {
+		assetConf := conf[asset]
+		if !assetConf.Enabled {
+			continue
+		}
+
+		withdrawal, err := sweepAsset(ctx, s3Client, s3Bucket, s3Prefix, asset, assetConf, orderers)
+		if err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("asset", asset).Error("Refusing Withdrawal")
+			refused = append(refused, err.Error())
+			continue
+		}
+
+		if withdrawal != nil {
+			withdrawn = append(withdrawn, *withdrawal)
+		}
+	}
+
+	if len(refused) > 0 {
+		return withdrawn, fmt.Errorf("%d withdrawals refused: %s", len(refused), strings.Join(refused, "; "))
+	}
+
+	return withdrawn, nil
+}
+
+// sweepAsset tracks and withdraws a single asset.
+func sweepAsset(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, asset string, conf configuration.WithdrawalConfig, orderers map[string]orders.Orderer) (*orders.Withdrawal, error) {
+	orderer, ok := orderers[conf.Exchange]
+	if !ok {
+		return nil, fmt.Errorf("no orderer found for exchange %s", conf.Exchange)
+	}
+
+	withdrawer, ok := orderer.(orders.Withdrawer)
+	if !ok {
+		return nil, fmt.Errorf("exchange %s does not support withdrawals", conf.Exchange)
+	}
+
+	market, ok := orderer.(orders.MarketData)
+	if !ok {
+		return nil, fmt.Errorf("exchange %s does not provide market data", conf.Exchange)
+	}
+
+	if conf.Key == "" {
+		return nil, fmt.Errorf("no withdrawal key configured for %s", asset)
+	}
+
+	// Track the withdrawals which have already been made
+	recent, err := withdrawer.WithdrawalStatus(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range recent {
+		if err := putWithdrawal(ctx, s3Client, s3Bucket, s3Prefix, status); err != nil {
+			return nil, err
+		}
+	}
+
+	balances, err := market.GetBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := balances[asset]
+	amount := balance.Sub(conf.Keep)
+
+	sweepLog := logging.FromContext(ctx).WithFields(logrus.Fields{
+		"asset":     asset,
+		"exchange":  conf.Exchange,
+		"key":       conf.Key,
+		"balance":   balance,
+		"threshold": conf.Threshold,
+		"keep":      conf.Keep,
+		"amount":    amount,
+	})
+
+	if balance.LessThan(conf.Threshold) || !amount.IsPositive() {
+		sweepLog.Info("Balance below withdrawal threshold")
+		return nil, nil
+	}
+
+	// The exchange only quotes withdrawals to keys which have been approved
+	quote, err := withdrawer.WithdrawInfo(ctx, asset, conf.Key, amount)
+	if err != nil {
+		return nil, fmt.Errorf("withdrawal key %s for %s is not approved on %s: %w", conf.Key, asset, conf.Exchange, err)
+	}
+
+	if quote.Limit.IsPositive() && amount.GreaterThan(quote.Limit) {
+		sweepLog.WithField("limit", quote.Limit).Warn("Withdrawal above limit, withdrawing the limit")
+		amount = quote.Limit
+	}
+
+	refID, err := withdrawer.Withdraw(ctx, asset, conf.Key, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawal := orders.Withdrawal{
+		RefID:    refID,
+		Exchange: conf.Exchange,
+		Asset:    asset,
+		Key:      conf.Key,
+		Amount:   amount,
+		Fee:      quote.Fee,
+		Status:   StatusInitiated,
+		Time:     time.Now().Unix(),
+	}
+
+	sweepLog.WithFields(logrus.Fields{"refId": refID, "fee": quote.Fee}).Info("Withdrawal Initiated")
+
+	if err := putWithdrawal(ctx, s3Client, s3Bucket, s3Prefix, withdrawal); err != nil {
+		return nil, err
+	}
+
+	return &withdrawal, nil
+}
+
+// putWithdrawal writes the withdrawal to S3 keeping the
+// withdrawal key from when the withdrawal was initiated.
+func putWithdrawal(ctx context.Context, s3Client pkg.S3Access, s3Bucket string, s3Prefix string, withdrawal orders.Withdrawal) error {
+	if withdrawal.RefID == "" {
+		return nil
+	}
+
+	s3Path := fmt.Sprintf(
+		"%s/exchange=%s/%s.json",
+		s3Prefix,
+		strings.ToLower(withdrawal.Exchange),
+		withdrawal.RefID,
+	)
+
+	if withdrawal.Key == "" {
+		existing, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: &s3Bucket,
+			Key:    &s3Path,
+		})
+
+		var noSuchKey *s3types.NoSuchKey
+		if err != nil && !errors.As(err, &noSuchKey) {
+			return err
+		}
+
+		if err == nil {
+			defer existing.Body.Close()
+			existingBytes, err := ioutil.ReadAll(existing.Body)
+			if err != nil {
+				return err
+			}
+
+			var previous orders.Withdrawal
+			if err := json.Unmarshal(existingBytes, &previous); err != nil {
+				return err
+			}
+			withdrawal.Key = previous.Key
+		}
+	}
+
+	withdrawalBytes, err := json.Marshal(withdrawal)
+	if err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"refId":    withdrawal.RefID,
+		"status":   withdrawal.Status,
+		"fee":      withdrawal.Fee,
+		"s3bucket": s3Bucket,
+		"s3path":   s3Path,
+	}).Info("Uploading Withdrawal to S3")
+
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &s3Bucket,
+		Key:    &s3Path,
+		Body:   bytes.NewReader(withdrawalBytes),
+	})
+
+	return err
+}
@@ -0,0 +1,167 @@
+package withdrawal
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kiran94/dca-manager/pkg"
+	"github.com/kiran94/dca-manager/pkg/configuration"
+	"github.com/kiran94/dca-manager/pkg/orders"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockWithdrawer is an exchange which supports withdrawals
+type MockWithdrawer struct {
+	mock.Mock
+}
+
+func (m *MockWithdrawer) MakeOrder(ctx context.Context, order *configuration.DCAOrder) (*orders.OrderFufilled, error) {
+	args := m.Called(order)
+	return args.Get(0).(*orders.OrderFufilled), args.Error(1)
+}
+
+func (m *MockWithdrawer) ProcessTransaction(ctx context.Context, transactionsIds ...string) (*[]orders.OrderComplete, error) {
+	args := m.Called(transactionsIds)
+	return args.Get(0).(*[]orders.OrderComplete), args.Error(1)
+}
+
+func (m *MockWithdrawer) CancelOrder(ctx context.Context, transactionID string) error {
+	return m.Called(transactionID).Error(0)
+}
+
+func (m *MockWithdrawer) GetBalances(ctx context.Context) (map[string]decimal.Decimal, error) {
+	args := m.Called()
+	return args.Get(0).(map[string]decimal.Decimal), args.Error(1)
+}
+
+func (m *MockWithdrawer) GetTicker(ctx context.Context, pair string) (*orders.Ticker, error) {
+	args := m.Called(pair)
+	return args.Get(0).(*orders.Ticker), args.Error(1)
+}
+
+func (m *MockWithdrawer) GetOHLC(ctx context.Context, pair string, since time.Time) ([]orders.Candle, error) {
+	args := m.Called(pair, since)
+	return args.Get(0).([]orders.Candle), args.Error(1)
+}
+
+func (m *MockWithdrawer) WithdrawInfo(ctx context.Context, asset string, key string, amount decimal.Decimal) (*orders.WithdrawalQuote, error) {
+	args := m.Called(asset, key, amount.String())
+	return args.Get(0).(*orders.WithdrawalQuote), args.Error(1)
+}
+
+func (m *MockWithdrawer) Withdraw(ctx context.Context, asset string, key string, amount decimal.Decimal) (string, error) {
+	args := m.Called(asset, key, amount.String())
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockWithdrawer) WithdrawalStatus(ctx context.Context, asset string) ([]orders.Withdrawal, error) {
+	args := m.Called(asset)
+	return args.Get(0).([]orders.Withdrawal), args.Error(1)
+}
+
+func coldStorageConfig() map[string]configuration.WithdrawalConfig {
+	return map[string]configuration.WithdrawalConfig{
+		"XXBT": {
+			Exchange:  "kraken",
+			Key:       "cold",
+			Threshold: decimal.RequireFromString("0.1"),
+			Keep:      decimal.RequireFromString("0.01"),
+			Enabled:   true,
+		},
+	}
+}
+
+// Ensures balances above the threshold are withdrawn
+// leaving the keep amount on the exchange
+func TestSweep(t *testing.T) {
+	withdrawer := &MockWithdrawer{}
+	withdrawer.On("WithdrawalStatus", "XXBT").Return([]orders.Withdrawal{}, nil)
+	withdrawer.On("GetBalances").Return(map[string]decimal.Decimal{"XXBT": decimal.RequireFromString("0.15")}, nil)
+	withdrawer.On("WithdrawInfo", "XXBT", "cold", "0.14").Return(&orders.WithdrawalQuote{Limit: decimal.NewFromInt(1), Fee: decimal.RequireFromString("0.0005")}, nil)
+	withdrawer.On("Withdraw", "XXBT", "cold", "0.14").Return("REFID", nil)
+
+	mockS3 := &pkg.MockS3Access{}
+	mockS3.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		body, _ := ioutil.ReadAll(input.Body)
+		return *input.Key == "withdrawals/exchange=kraken/REFID.json" && strings.Contains(string(body), `"status":"Initiated"`)
+	}), mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	withdrawn, err := ColdStorage{}.Sweep(context.Background(), mockS3, "bucket", "withdrawals", coldStorageConfig(), map[string]orders.Orderer{"kraken": withdrawer})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(withdrawn))
+	assert.Equal(t, "REFID", withdrawn[0].RefID)
+	assert.Equal(t, "cold", withdrawn[0].Key)
+	assert.Equal(t, "0.0005", withdrawn[0].Fee.String())
+	withdrawer.AssertExpectations(t)
+	mockS3.AssertExpectations(t)
+}
+
+// Ensures balances below the threshold are left on the exchange
+func TestSweepBelowThreshold(t *testing.T) {
+	withdrawer := &MockWithdrawer{}
+	withdrawer.On("WithdrawalStatus", "XXBT").Return([]orders.Withdrawal{}, nil)
+	withdrawer.On("GetBalances").Return(map[string]decimal.Decimal{"XXBT": decimal.RequireFromString("0.09")}, nil)
+
+	withdrawn, err := ColdStorage{}.Sweep(context.Background(), &pkg.MockS3Access{}, "bucket", "withdrawals", coldStorageConfig(), map[string]orders.Orderer{"kraken": withdrawer})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(withdrawn))
+	withdrawer.AssertNotCalled(t, "Withdraw", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Ensures withdrawals to keys the exchange has
+// not approved are refused
+func TestSweepUnapprovedKey(t *testing.T) {
+	withdrawer := &MockWithdrawer{}
+	withdrawer.On("WithdrawalStatus", "XXBT").Return([]orders.Withdrawal{}, nil)
+	withdrawer.On("GetBalances").Return(map[string]decimal.Decimal{"XXBT": decimal.RequireFromString("0.15")}, nil)
+	withdrawer.On("WithdrawInfo", "XXBT", "cold", "0.14").Return(&orders.WithdrawalQuote{}, errors.New("EFunding:Unknown withdraw key"))
+
+	withdrawn, err := ColdStorage{}.Sweep(context.Background(), &pkg.MockS3Access{}, "bucket", "withdrawals", coldStorageConfig(), map[string]orders.Orderer{"kraken": withdrawer})
+
+	assert.Equal(t, 0, len(withdrawn))
+	assert.Contains(t, err.Error(), "withdrawal key cold for XXBT is not approved on kraken")
+	withdrawer.AssertNotCalled(t, "Withdraw", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Ensures the status of previous withdrawals is
+// tracked keeping the key they were sent to
+func TestSweepTracksStatus(t *testing.T) {
+	withdrawer := &MockWithdrawer{}
+	withdrawer.On("WithdrawalStatus", "XXBT").Return([]orders.Withdrawal{
+		{RefID: "OLD", Exchange: "kraken", Asset: "XXBT", Status: "Success", Fee: decimal.RequireFromString("0.0005")},
+		{RefID: "UNKNOWN", Exchange: "kraken", Asset: "XXBT", Status: "Pending"},
+	}, nil)
+	withdrawer.On("GetBalances").Return(map[string]decimal.Decimal{}, nil)
+
+	mockS3 := &pkg.MockS3Access{}
+	mockS3.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+		return *input.Key == "withdrawals/exchange=kraken/OLD.json"
+	}), mock.Anything).Return(&s3.GetObjectOutput{Body: ioutil.NopCloser(strings.NewReader(`{"refid":"OLD","key":"cold","status":"Initiated"}`))}, nil)
+	mockS3.On("GetObject", mock.Anything, mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+		return *input.Key == "withdrawals/exchange=kraken/UNKNOWN.json"
+	}), mock.Anything).Return(&s3.GetObjectOutput{}, &s3types.NoSuchKey{})
+
+	uploaded := map[string]string{}
+	mockS3.On("PutObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.PutObjectOutput{}, nil).Run(func(args mock.Arguments) {
+		input := args.Get(1).(*s3.PutObjectInput)
+		body, _ := ioutil.ReadAll(input.Body)
+		uploaded[*input.Key] = string(body)
+	})
+
+	_, err := ColdStorage{}.Sweep(context.Background(), mockS3, "bucket", "withdrawals", coldStorageConfig(), map[string]orders.Orderer{"kraken": withdrawer})
+
+	assert.Nil(t, err)
+	assert.Contains(t, uploaded["withdrawals/exchange=kraken/OLD.json"], `"key":"cold"`)
+	assert.Contains(t, uploaded["withdrawals/exchange=kraken/OLD.json"], `"status":"Success"`)
+	assert.Contains(t, uploaded["withdrawals/exchange=kraken/UNKNOWN.json"], `"status":"Pending"`)
+}
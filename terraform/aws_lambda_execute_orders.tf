@@ -5,6 +5,9 @@ locals {
   lambda_process_order_object            = "dca-process-orders.zip"
   lambda_s3_pending_transaction_prefix   = "transactions/status=pending"
   lambda_s3_processed_transaction_prefix = "transactions/status=complete"
+  lambda_s3_withdrawal_prefix            = "withdrawals"
+  lambda_s3_runs_prefix                  = "runs"
+  lambda_s3_overrides_path               = "overrides.json"
 }
 
 # Lambda
@@ -22,11 +25,18 @@ resource "aws_lambda_function" "execute_orders" {
 
   environment {
     variables = {
-      "DCA_BUCKET"                   = aws_s3_bucket.main.bucket
-      "DCA_CONFIG"                   = aws_s3_bucket_object.config.id,
-      "DCA_ALLOW_REAL"               = "1"
-      "DCA_PENDING_ORDERS_QUEUE_URL" = aws_sqs_queue.pending_orders_queue.url,
-      "DCA_PENDING_ORDER_S3_PREFIX"  = local.lambda_s3_pending_transaction_prefix,
+      "DCA_BUCKET"                     = aws_s3_bucket.main.bucket
+      "DCA_CONFIG"                     = aws_s3_bucket_object.config.id,
+      "DCA_ALLOW_REAL"                 = "1"
+      "DCA_PENDING_ORDERS_QUEUE_URL"   = aws_sqs_queue.pending_orders_queue.url,
+      "DCA_PENDING_ORDER_S3_PREFIX"    = local.lambda_s3_pending_transaction_prefix,
+      "DCA_PROCESSED_ORDER_S3_PREFIX"  = local.lambda_s3_processed_transaction_prefix,
+      "DCA_RUNS_S3_PREFIX"             = local.lambda_s3_runs_prefix
+      "DCA_OVERRIDES_S3_PATH"          = local.lambda_s3_overrides_path
+      "DCA_NOTIFY_SNS_TOPIC_ARN"       = aws_sns_topic.notifications.arn
+      "DCA_NOTIFY_SLACK_WEBHOOK_URL"   = var.notify_slack_webhook_url
+      "DCA_NOTIFY_DISCORD_WEBHOOK_URL" = var.notify_discord_webhook_url
+      "DCA_NOTIFY_WEBHOOK_URL"         = var.notify_webhook_url
     }
   }
 
@@ -85,6 +95,7 @@ resource "aws_iam_role" "execute_orders_iam_role" {
           Action = [
             "s3:GetObject",
             "s3:PutObject",
+            "s3:ListBucket",
             "ssm:GetParameter",
             "sns:Publish",
             "sqs:SendMessage",
@@ -101,7 +112,8 @@ resource "aws_iam_role" "execute_orders_iam_role" {
             "${aws_ssm_parameter.kraken_api_secret.arn}",
             "${aws_sqs_queue.pending_orders_queue.arn}",
             "${aws_sns_topic.lambda_failure_dlq.arn}",
-            "${aws_sns_topic.lambda_success.arn}"
+            "${aws_sns_topic.lambda_success.arn}",
+            "${aws_sns_topic.notifications.arn}"
           ]
         }
       ]
@@ -13,10 +13,17 @@ resource "aws_lambda_function" "process_orders" {
   environment {
     variables = {
       "DCA_BUCKET"                             = aws_s3_bucket.main.bucket
+      "DCA_CONFIG"                             = aws_s3_bucket_object.config.id
       "DCA_PENDING_ORDER_S3_PREFIX"            = local.lambda_s3_pending_transaction_prefix
       "DCA_PROCESSED_ORDER_S3_PREFIX"          = local.lambda_s3_processed_transaction_prefix,
+      "DCA_PENDING_ORDERS_QUEUE_URL"           = aws_sqs_queue.pending_orders_queue.url
+      "DCA_WITHDRAWAL_S3_PREFIX"               = local.lambda_s3_withdrawal_prefix
       "DCA_GLUE_PROCESS_TRANSACTION_JOB"       = aws_glue_job.load_transactions.id
       "DCA_GLUE_PROCESS_TRANSACTION_OPERATION" = "upsert"
+      "DCA_NOTIFY_SNS_TOPIC_ARN"               = aws_sns_topic.notifications.arn
+      "DCA_NOTIFY_SLACK_WEBHOOK_URL"           = var.notify_slack_webhook_url
+      "DCA_NOTIFY_DISCORD_WEBHOOK_URL"         = var.notify_discord_webhook_url
+      "DCA_NOTIFY_WEBHOOK_URL"                 = var.notify_webhook_url
     }
   }
 
@@ -63,6 +70,7 @@ resource "aws_iam_role" "process_order_iam_role" {
             "ssm:GetParameter",
             "sns:Publish",
             "sqs:ReceiveMessage",
+            "sqs:SendMessage",
             "sqs:DeleteMessage",
             "sqs:ChangeMessageVisibility",
             "sqs:GetQueueAttributes",
@@ -75,6 +83,7 @@ resource "aws_iam_role" "process_order_iam_role" {
             "${aws_ssm_parameter.kraken_api_key.arn}",
             "${aws_ssm_parameter.kraken_api_secret.arn}",
             "${aws_sns_topic.lambda_failure_dlq.arn}",
+            "${aws_sns_topic.notifications.arn}",
             "${aws_sqs_queue.pending_orders_queue.arn}",
             "${aws_glue_job.load_transactions.arn}"
           ]
@@ -23,3 +23,16 @@ resource "aws_sns_topic_subscription" "lambda_success" {
   protocol  = "email"
   endpoint  = var.lambda_success_email[count.index]
 }
+
+// RUN NOTIFICATIONS
+resource "aws_sns_topic" "notifications" {
+  name = "dca-notifications"
+}
+
+resource "aws_sns_topic_subscription" "notifications" {
+  count = length(var.notify_email)
+
+  topic_arn = aws_sns_topic.notifications.arn
+  protocol  = "email"
+  endpoint  = var.notify_email[count.index]
+}
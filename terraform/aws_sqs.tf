@@ -2,8 +2,23 @@ resource "aws_sqs_queue" "pending_orders_queue" {
   name                       = "dca-pending-orders-queue"
   visibility_timeout_seconds = 30
   message_retention_seconds  = 1209600
+
+  redrive_policy = jsonencode({
+    deadLetterTargetArn = aws_sqs_queue.pending_orders_dlq.arn
+    maxReceiveCount     = 5
+  })
+}
+
+# Pending orders which failed processing too many times, see cmd/dlq
+resource "aws_sqs_queue" "pending_orders_dlq" {
+  name                      = "dca-pending-orders-dlq"
+  message_retention_seconds = 1209600
 }
 
 output "pending_orders_queue_url" {
   value = aws_sqs_queue.pending_orders_queue.url
 }
+
+output "pending_orders_dlq_url" {
+  value = aws_sqs_queue.pending_orders_dlq.url
+}
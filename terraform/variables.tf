@@ -36,6 +36,28 @@ variable "lambda_success_email" {
   description = "The Email to notify when a successful lambda execution completes"
 }
 
+variable "notify_email" {
+  type        = list(string)
+  description = "The Emails to announce runs and fills to, kept apart from the lambda success emails"
+  default     = []
+}
+
+// Override with TF_VAR_ to announce runs to chat, empty disables the sink
+variable "notify_slack_webhook_url" {
+  description = "The Slack incoming webhook to announce runs and fills to"
+  default     = ""
+}
+
+variable "notify_discord_webhook_url" {
+  description = "The Discord webhook to announce runs and fills to"
+  default     = ""
+}
+
+variable "notify_webhook_url" {
+  description = "The endpoint to post run and fill events to as JSON"
+  default     = ""
+}
+
 // SECRETS
 // Override with TF_VAR_
 variable "KRAKEN_API_KEY" {